@@ -6,6 +6,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/aws/aws-sdk-go/service/lambda"
@@ -24,12 +26,14 @@ type S3API s3iface.S3API
 type LambdaAPI lambdaiface.LambdaAPI
 type SFNAPI sfniface.SFNAPI
 type DynamoDBAPI dynamodbiface.DynamoDBAPI
+type CloudWatchAPI cloudwatchiface.CloudWatchAPI
 
 type AwsClients interface {
 	S3Client(region *string, account_id *string, role *string) S3API
 	LambdaClient(region *string, account_id *string, role *string) LambdaAPI
 	SFNClient(region *string, account_id *string, role *string) SFNAPI
 	DynamoDBClient(region *string, account_id *string, role *string) DynamoDBAPI
+	CloudWatchClient(region *string, account_id *string, role *string) CloudWatchAPI
 }
 
 ////////////
@@ -114,3 +118,7 @@ func (c *Clients) SFNClient(region *string, account_id *string, role *string) SF
 func (c *Clients) DynamoDBClient(region *string, account_id *string, role *string) DynamoDBAPI {
 	return dynamodb.New(c.Session(), c.Config(region, account_id, role))
 }
+
+func (c *Clients) CloudWatchClient(region *string, account_id *string, role *string) CloudWatchAPI {
+	return cloudwatch.New(c.Session(), c.Config(region, account_id, role))
+}
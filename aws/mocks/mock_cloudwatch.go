@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+type MockCloudWatchClient struct {
+	cloudwatchiface.CloudWatchAPI
+	DescribeAlarmsResp  *cloudwatch.DescribeAlarmsOutput
+	DescribeAlarmsError error
+}
+
+func (m *MockCloudWatchClient) init() {
+	if m.DescribeAlarmsResp == nil {
+		m.DescribeAlarmsResp = &cloudwatch.DescribeAlarmsOutput{}
+	}
+}
+
+func (m *MockCloudWatchClient) DescribeAlarms(in *cloudwatch.DescribeAlarmsInput) (*cloudwatch.DescribeAlarmsOutput, error) {
+	m.init()
+	return m.DescribeAlarmsResp, m.DescribeAlarmsError
+}
@@ -1,29 +1,230 @@
 package mocks
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/coinbase/step/utils/to"
 )
 
 type MockLambdaClient struct {
 	lambdaiface.LambdaAPI
-	UpdateFunctionCodeResp  *lambda.FunctionConfiguration
-	UpdateFunctionCodeError error
-	ListTagsResp            *lambda.ListTagsOutput
+	UpdateFunctionCodeResp   *lambda.FunctionConfiguration
+	UpdateFunctionCodeError  error
+	UpdateFunctionCodeInputs []*lambda.UpdateFunctionCodeInput
+	ListTagsResp             *lambda.ListTagsOutput
+	ListTagsError            error
+	GetFunctionResp          *lambda.GetFunctionOutput
+
+	GetAliasResp      *lambda.AliasConfiguration
+	GetAliasError     error
+	UpdateAliasError  error
+	UpdateAliasInputs []*lambda.UpdateAliasInput
+
+	CreateFunctionInputs []*lambda.CreateFunctionInput
+	CreateAliasInputs    []*lambda.CreateAliasInput
+
+	// GetFunctionConfigurationResps are returned in order, the last one is repeated
+	GetFunctionConfigurationResps     []*lambda.FunctionConfiguration
+	GetFunctionConfigurationError     error
+	UpdateFunctionConfigurationInputs []*lambda.UpdateFunctionConfigurationInput
+	TagResourceInputs                 []*lambda.TagResourceInput
+
+	PublishVersionInputs []*lambda.PublishVersionInput
+	PublishVersionError  error
+	PublishVersionResp   *lambda.FunctionConfiguration // the version Lambda returns if $LATEST is unchanged
+
+	UntagResourceInputs  []*lambda.UntagResourceInput
+	DeleteFunctionInputs []*lambda.DeleteFunctionInput
+
+	// CodeSha256 of the code deployed by UpdateFunctionCode and CreateFunction, by function name
+	CodeSha256 map[string]*string
+
+	// Configurations deployed by UpdateFunctionConfiguration, by function name
+	Configurations map[string]*lambda.UpdateFunctionConfigurationInput
 }
 
 func (m *MockLambdaClient) init() {
 	if m.UpdateFunctionCodeResp == nil {
 		m.UpdateFunctionCodeResp = &lambda.FunctionConfiguration{}
 	}
+
+	if m.GetAliasResp == nil {
+		m.GetAliasResp = &lambda.AliasConfiguration{}
+	}
+
+	if m.GetFunctionResp == nil {
+		m.GetFunctionResp = &lambda.GetFunctionOutput{Configuration: &lambda.FunctionConfiguration{}}
+	}
+
+	if m.CodeSha256 == nil {
+		m.CodeSha256 = map[string]*string{}
+	}
+
+	if m.Configurations == nil {
+		m.Configurations = map[string]*lambda.UpdateFunctionConfigurationInput{}
+	}
+}
+
+// deployCode remembers the CodeSha256 of zip like Lambda returns it, base64 of the SHA256
+func (m *MockLambdaClient) deployCode(function_name *string, zip []byte) {
+	sha := sha256.Sum256(zip)
+	m.CodeSha256[arnName(function_name)] = to.Strp(base64.StdEncoding.EncodeToString(sha[:]))
 }
 
 func (m *MockLambdaClient) UpdateFunctionCode(in *lambda.UpdateFunctionCodeInput) (*lambda.FunctionConfiguration, error) {
 	m.init()
+	m.UpdateFunctionCodeInputs = append(m.UpdateFunctionCodeInputs, in)
+	if m.UpdateFunctionCodeError == nil {
+		m.deployCode(in.FunctionName, in.ZipFile)
+	}
 	return m.UpdateFunctionCodeResp, m.UpdateFunctionCodeError
 }
 
 func (m *MockLambdaClient) ListTags(in *lambda.ListTagsInput) (*lambda.ListTagsOutput, error) {
 	m.init()
+	if m.ListTagsError != nil {
+		return nil, m.ListTagsError
+	}
 	return m.ListTagsResp, nil
 }
+
+func (m *MockLambdaClient) GetFunction(in *lambda.GetFunctionInput) (*lambda.GetFunctionOutput, error) {
+	m.init()
+	return m.GetFunctionResp, nil
+}
+
+// GetFunctionConfiguration returns the next GetFunctionConfigurationResps with the CodeSha256 of the deployed code,
+// and for $LATEST the deployed configuration. GetFunctionConfigurationError is returned until code is deployed
+func (m *MockLambdaClient) GetFunctionConfiguration(in *lambda.GetFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	m.init()
+	code_sha, deployed := m.CodeSha256[arnName(in.FunctionName)]
+	if m.GetFunctionConfigurationError != nil && !deployed {
+		return nil, m.GetFunctionConfigurationError
+	}
+
+	resp := lambda.FunctionConfiguration{}
+	if len(m.GetFunctionConfigurationResps) > 0 {
+		resp = *m.GetFunctionConfigurationResps[0]
+	}
+
+	if len(m.GetFunctionConfigurationResps) > 1 {
+		m.GetFunctionConfigurationResps = m.GetFunctionConfigurationResps[1:]
+	}
+
+	if resp.CodeSha256 == nil {
+		resp.CodeSha256 = code_sha
+	}
+
+	// nil fields of UpdateFunctionConfiguration are not changed
+	if config, ok := m.Configurations[arnName(in.FunctionName)]; ok && in.Qualifier == nil {
+		if config.MemorySize != nil {
+			resp.MemorySize = config.MemorySize
+		}
+
+		if config.Timeout != nil {
+			resp.Timeout = config.Timeout
+		}
+
+		if config.Runtime != nil {
+			resp.Runtime = config.Runtime
+		}
+
+		if config.Handler != nil {
+			resp.Handler = config.Handler
+		}
+
+		if config.Environment != nil {
+			resp.Environment = &lambda.EnvironmentResponse{Variables: config.Environment.Variables}
+		}
+
+		if config.Layers != nil {
+			resp.Layers = []*lambda.Layer{}
+			for _, arn := range config.Layers {
+				resp.Layers = append(resp.Layers, &lambda.Layer{Arn: arn})
+			}
+		}
+	}
+
+	return &resp, nil
+}
+
+func (m *MockLambdaClient) UpdateFunctionConfiguration(in *lambda.UpdateFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	m.init()
+	m.UpdateFunctionConfigurationInputs = append(m.UpdateFunctionConfigurationInputs, in)
+	m.Configurations[arnName(in.FunctionName)] = in
+	return &lambda.FunctionConfiguration{FunctionName: in.FunctionName, LastUpdateStatus: to.Strp(lambda.LastUpdateStatusInProgress)}, nil
+}
+
+// PublishVersion records the input and returns PublishVersionResp,
+// or version 1 with the description and deployed CodeSha256
+func (m *MockLambdaClient) PublishVersion(in *lambda.PublishVersionInput) (*lambda.FunctionConfiguration, error) {
+	m.init()
+	m.PublishVersionInputs = append(m.PublishVersionInputs, in)
+	if m.PublishVersionError != nil {
+		return nil, m.PublishVersionError
+	}
+
+	if m.PublishVersionResp != nil {
+		return m.PublishVersionResp, nil
+	}
+
+	return &lambda.FunctionConfiguration{FunctionName: in.FunctionName, Version: to.Strp("1"), Description: in.Description, CodeSha256: m.CodeSha256[arnName(in.FunctionName)]}, nil
+}
+
+func (m *MockLambdaClient) TagResource(in *lambda.TagResourceInput) (*lambda.TagResourceOutput, error) {
+	m.init()
+	m.TagResourceInputs = append(m.TagResourceInputs, in)
+	return &lambda.TagResourceOutput{}, nil
+}
+
+func (m *MockLambdaClient) UntagResource(in *lambda.UntagResourceInput) (*lambda.UntagResourceOutput, error) {
+	m.init()
+	m.UntagResourceInputs = append(m.UntagResourceInputs, in)
+	return &lambda.UntagResourceOutput{}, nil
+}
+
+func (m *MockLambdaClient) DeleteFunction(in *lambda.DeleteFunctionInput) (*lambda.DeleteFunctionOutput, error) {
+	m.init()
+	m.DeleteFunctionInputs = append(m.DeleteFunctionInputs, in)
+	return &lambda.DeleteFunctionOutput{}, nil
+}
+
+func (m *MockLambdaClient) GetAlias(in *lambda.GetAliasInput) (*lambda.AliasConfiguration, error) {
+	m.init()
+	if m.GetAliasError != nil {
+		return nil, m.GetAliasError
+	}
+	return m.GetAliasResp, nil
+}
+
+// CreateFunction records the input and returns version 1
+func (m *MockLambdaClient) CreateFunction(in *lambda.CreateFunctionInput) (*lambda.FunctionConfiguration, error) {
+	m.init()
+	m.CreateFunctionInputs = append(m.CreateFunctionInputs, in)
+	m.deployCode(in.FunctionName, in.Code.ZipFile)
+	return &lambda.FunctionConfiguration{FunctionName: in.FunctionName, Version: to.Strp("1")}, nil
+}
+
+// CreateAlias records the input and updates the alias GetAlias returns
+func (m *MockLambdaClient) CreateAlias(in *lambda.CreateAliasInput) (*lambda.AliasConfiguration, error) {
+	m.init()
+	m.CreateAliasInputs = append(m.CreateAliasInputs, in)
+	m.GetAliasResp = &lambda.AliasConfiguration{Name: in.Name, FunctionVersion: in.FunctionVersion}
+	m.GetAliasError = nil
+	return m.GetAliasResp, nil
+}
+
+// UpdateAlias records the input and updates the alias GetAlias returns
+func (m *MockLambdaClient) UpdateAlias(in *lambda.UpdateAliasInput) (*lambda.AliasConfiguration, error) {
+	m.init()
+	m.UpdateAliasInputs = append(m.UpdateAliasInputs, in)
+	if m.UpdateAliasError != nil {
+		return nil, m.UpdateAliasError
+	}
+
+	m.GetAliasResp = &lambda.AliasConfiguration{Name: in.Name, FunctionVersion: in.FunctionVersion, RoutingConfig: in.RoutingConfig}
+	return m.GetAliasResp, nil
+}
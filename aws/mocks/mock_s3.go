@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sort"
 	"strings"
 	"time"
 
@@ -132,8 +133,39 @@ func (m *MockS3Client) GetObject(in *s3.GetObjectInput) (*s3.GetObjectOutput, er
 	return resp.Resp, resp.Error
 }
 
+// ListObjects lists the added objects under Prefix, with a Delimiter they are grouped into CommonPrefixes
 func (m *MockS3Client) ListObjects(in *s3.ListObjectsInput) (*s3.ListObjectsOutput, error) {
-	return nil, nil
+	m.init()
+	prefix := to.Strs(in.Prefix)
+	delimiter := to.Strs(in.Delimiter)
+
+	keys := []string{}
+	for key := range m.GetObjectResp {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsOutput{IsTruncated: to.Boolp(false)}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			common := prefix + rest[:i+len(delimiter)]
+			if !seen[common] {
+				seen[common] = true
+				out.CommonPrefixes = append(out.CommonPrefixes, &s3.CommonPrefix{Prefix: to.Strp(common)})
+			}
+			continue
+		}
+
+		out.Contents = append(out.Contents, &s3.Object{Key: to.Strp(key)})
+	}
+
+	return out, nil
 }
 
 func (m *MockS3Client) PutObject(in *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
@@ -8,13 +8,21 @@ import (
 
 type MockSFNClient struct {
 	sfniface.SFNAPI
-	UpdateStateMachineResp   *sfn.UpdateStateMachineOutput
-	UpdateStateMachineError  error
-	StartExecutionResp       *sfn.StartExecutionOutput
-	DescribeExecutionResp    *sfn.DescribeExecutionOutput
-	GetExecutionHistoryResp  *sfn.GetExecutionHistoryOutput
-	DescribeStateMachineResp *sfn.DescribeStateMachineOutput
-	ListExecutionsResp       *sfn.ListExecutionsOutput
+	UpdateStateMachineResp    *sfn.UpdateStateMachineOutput
+	UpdateStateMachineError   error
+	UpdateStateMachineInputs  []*sfn.UpdateStateMachineInput
+	StartExecutionResp        *sfn.StartExecutionOutput
+	DescribeExecutionResp     *sfn.DescribeExecutionOutput
+	DescribeExecutionResps    map[string]*sfn.DescribeExecutionOutput // by ExecutionArn, else DescribeExecutionResp
+	DescribeExecutionErrors   map[string]error                        // by ExecutionArn
+	GetExecutionHistoryResp   *sfn.GetExecutionHistoryOutput
+	DescribeStateMachineResp  *sfn.DescribeStateMachineOutput
+	DescribeStateMachineError error
+	CreateStateMachineInputs  []*sfn.CreateStateMachineInput
+	ListExecutionsResp        *sfn.ListExecutionsOutput
+
+	// Definitions deployed by UpdateStateMachine and CreateStateMachine, by state machine name
+	Definitions map[string]*string
 }
 
 func (m *MockSFNClient) init() {
@@ -38,10 +46,18 @@ func (m *MockSFNClient) init() {
 	if m.ListExecutionsResp == nil {
 		m.ListExecutionsResp = &sfn.ListExecutionsOutput{Executions: []*sfn.ExecutionListItem{}}
 	}
+
+	if m.Definitions == nil {
+		m.Definitions = map[string]*string{}
+	}
 }
 
 func (m *MockSFNClient) UpdateStateMachine(in *sfn.UpdateStateMachineInput) (*sfn.UpdateStateMachineOutput, error) {
 	m.init()
+	m.UpdateStateMachineInputs = append(m.UpdateStateMachineInputs, in)
+	if m.UpdateStateMachineError == nil {
+		m.Definitions[arnName(in.StateMachineArn)] = in.Definition
+	}
 	return m.UpdateStateMachineResp, m.UpdateStateMachineError
 }
 
@@ -52,6 +68,12 @@ func (m *MockSFNClient) StartExecution(in *sfn.StartExecutionInput) (*sfn.StartE
 
 func (m *MockSFNClient) DescribeExecution(in *sfn.DescribeExecutionInput) (*sfn.DescribeExecutionOutput, error) {
 	m.init()
+	if err, ok := m.DescribeExecutionErrors[to.Strs(in.ExecutionArn)]; ok {
+		return nil, err
+	}
+	if resp, ok := m.DescribeExecutionResps[to.Strs(in.ExecutionArn)]; ok {
+		return resp, nil
+	}
 	return m.DescribeExecutionResp, nil
 }
 
@@ -60,9 +82,30 @@ func (m *MockSFNClient) GetExecutionHistory(in *sfn.GetExecutionHistoryInput) (*
 	return m.GetExecutionHistoryResp, nil
 }
 
+// DescribeStateMachine returns DescribeStateMachineResp with the deployed definition,
+// DescribeStateMachineError is returned until a definition is deployed
 func (m *MockSFNClient) DescribeStateMachine(in *sfn.DescribeStateMachineInput) (*sfn.DescribeStateMachineOutput, error) {
 	m.init()
-	return m.DescribeStateMachineResp, nil
+	definition, deployed := m.Definitions[arnName(in.StateMachineArn)]
+	if m.DescribeStateMachineError != nil && !deployed {
+		return nil, m.DescribeStateMachineError
+	}
+
+	if !deployed || m.DescribeStateMachineResp == nil {
+		return m.DescribeStateMachineResp, nil
+	}
+
+	resp := *m.DescribeStateMachineResp
+	resp.Definition = definition
+	return &resp, nil
+}
+
+// CreateStateMachine records the input
+func (m *MockSFNClient) CreateStateMachine(in *sfn.CreateStateMachineInput) (*sfn.CreateStateMachineOutput, error) {
+	m.init()
+	m.CreateStateMachineInputs = append(m.CreateStateMachineInputs, in)
+	m.Definitions[arnName(in.Name)] = in.Definition
+	return &sfn.CreateStateMachineOutput{StateMachineArn: in.Name}, nil
 }
 
 func (m *MockSFNClient) ListExecutions(in *sfn.ListExecutionsInput) (*sfn.ListExecutionsOutput, error) {
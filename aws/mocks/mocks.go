@@ -1,12 +1,18 @@
 package mocks
 
-import "github.com/coinbase/step/aws"
+import (
+	"strings"
+
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/utils/to"
+)
 
 type MockClients struct {
-	S3       *MockS3Client
-	Lambda   *MockLambdaClient
-	SFN      *MockSFNClient
-	DynamoDB *MockDynamoDBClient
+	S3         *MockS3Client
+	Lambda     *MockLambdaClient
+	SFN        *MockSFNClient
+	DynamoDB   *MockDynamoDBClient
+	CloudWatch *MockCloudWatchClient
 }
 
 func (awsc *MockClients) S3Client(*string, *string, *string) aws.S3API {
@@ -25,11 +31,25 @@ func (awsc *MockClients) DynamoDBClient(*string, *string, *string) aws.DynamoDBA
 	return awsc.DynamoDB
 }
 
+func (awsc *MockClients) CloudWatchClient(*string, *string, *string) aws.CloudWatchAPI {
+	return awsc.CloudWatch
+}
+
 func MockAwsClients() *MockClients {
 	return &MockClients{
 		&MockS3Client{},
 		&MockLambdaClient{},
 		&MockSFNClient{},
 		&MockDynamoDBClient{},
+		&MockCloudWatchClient{},
+	}
+}
+
+// arnName is the resource name at the end of a Lambda or State Machine ARN, or the name itself
+func arnName(arn *string) string {
+	parts := strings.Split(to.Strs(arn), ":")
+	if len(parts) >= 7 {
+		return parts[6]
 	}
+	return to.Strs(arn)
 }
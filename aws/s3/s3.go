@@ -218,6 +218,34 @@ func Delete(s3c aws.S3API, bucket *string, path *string) error {
 	return nil
 }
 
+// ListDirs returns the names of the directories directly under dir
+func ListDirs(s3c aws.S3API, bucket *string, dir *string) ([]string, error) {
+	prefix := fmt.Sprintf("%v/", *dir)
+	dirs := []string{}
+
+	input := &s3.ListObjectsInput{
+		Bucket:    bucket,
+		Prefix:    &prefix,
+		Delimiter: to.Strp("/"),
+	}
+
+	for {
+		output, err := s3c.ListObjects(input)
+		if err != nil {
+			return nil, s3Error(bucket, dir, err)
+		}
+
+		for _, cp := range output.CommonPrefixes {
+			dirs = append(dirs, strings.TrimSuffix(strings.TrimPrefix(*cp.Prefix, prefix), "/"))
+		}
+
+		if output.IsTruncated == nil || !*output.IsTruncated || output.NextMarker == nil {
+			return dirs, nil
+		}
+		input.Marker = output.NextMarker
+	}
+}
+
 /////////
 // Struct Helpers
 /////////
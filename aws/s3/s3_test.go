@@ -127,3 +127,16 @@ func Test_PutStruct_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "asd", str.Name)
 }
+
+func Test_ListDirs_Success(t *testing.T) {
+	s3c := &mocks.MockS3Client{}
+	s3c.AddGetObject("account/project/config/lock", "", nil)
+	s3c.AddGetObject("account/project/config/release-1/release", "{}", nil)
+	s3c.AddGetObject("account/project/config/release-1/lambda.zip", "", nil)
+	s3c.AddGetObject("account/project/config/release-2/release", "{}", nil)
+	s3c.AddGetObject("account/project/other/release-3/release", "{}", nil)
+
+	dirs, err := ListDirs(s3c, to.Strp("bucket"), to.Strp("account/project/config"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"release-1", "release-2"}, dirs)
+}
@@ -0,0 +1,122 @@
+package bifrost
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/execution"
+	"github.com/coinbase/step/utils/to"
+)
+
+// ReleaseStatus is a release joined to its deployer execution
+type ReleaseStatus struct {
+	ReleaseID   *string           `json:"release_id,omitempty"`
+	ProjectName *string           `json:"project_name,omitempty"`
+	ConfigName  *string           `json:"config_name,omitempty"`
+	CreatedAt   *time.Time        `json:"created_at,omitempty"`
+	RollbackOf  *string           `json:"rollback_of,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"` // e.g. who deployed it
+
+	ExecutionArn *string    `json:"execution_arn,omitempty"`
+	Status       *string    `json:"status,omitempty"` // of the execution, nil if it was not found
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	StoppedAt    *time.Time `json:"stopped_at,omitempty"`
+	Duration     *float64   `json:"duration_seconds,omitempty"`
+
+	Success *bool         `json:"success,omitempty"`
+	Error   *ReleaseError `json:"error,omitempty"`
+
+	Release interface{} `json:"release,omitempty"` // the whole release, if requested
+}
+
+// ExecutionArn is the deployer execution named by the ReleaseID
+func (r *Release) ExecutionArn(deployer_arn *string) *string {
+	arn := strings.Replace(*deployer_arn, ":stateMachine:", ":execution:", 1)
+	return to.Strp(fmt.Sprintf("%v:%v", arn, *r.ReleaseID))
+}
+
+// FindExecution returns the deployer execution of the release, nil if not found.
+// Executions are named by the ReleaseID, or by ExecutionName while running
+func (r *Release) FindExecution(sfnc aws.SFNAPI, deployer_arn *string) (*execution.Execution, *execution.StateDetails, error) {
+	exec, sd, err := execution.GetDetails(sfnc, r.ExecutionArn(deployer_arn))
+	if err == nil {
+		return exec, sd, nil
+	}
+
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != sfn.ErrCodeExecutionDoesNotExist {
+		return nil, nil, err
+	}
+
+	running, err := execution.FindExecution(sfnc, deployer_arn, r.ExecutionPrefix())
+	if err != nil || running == nil {
+		return nil, nil, err
+	}
+
+	exec, sd, err = execution.GetDetails(sfnc, running.ExecutionArn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The execution of another release of the project and config
+	var input Release
+	if exec.Input == nil || json.Unmarshal([]byte(*exec.Input), &input) != nil || to.Strs(input.ReleaseID) != *r.ReleaseID {
+		return nil, nil, nil
+	}
+
+	return exec, sd, nil
+}
+
+// Status joins the release to its deployer execution, reporting its success, error and timing
+func (r *Release) Status(sfnc aws.SFNAPI, deployer_arn *string) (*ReleaseStatus, error) {
+	status := &ReleaseStatus{
+		ReleaseID:   r.ReleaseID,
+		ProjectName: r.ProjectName,
+		ConfigName:  r.ConfigName,
+		CreatedAt:   r.CreatedAt,
+		RollbackOf:  r.RollbackOf,
+		Metadata:    r.Metadata,
+	}
+
+	exec, sd, err := r.FindExecution(sfnc, deployer_arn)
+	if err != nil || exec == nil {
+		return status, err
+	}
+
+	status.ExecutionArn = exec.ExecutionArn
+	status.Status = exec.Status
+	status.StartedAt = exec.StartDate
+	status.StoppedAt = exec.StopDate
+
+	if exec.StartDate != nil && exec.StopDate != nil {
+		status.Duration = to.Float64p(exec.StopDate.Sub(*exec.StartDate).Seconds())
+	}
+
+	// A failed execution has no output so the last state output has the error
+	output := exec.Output
+	if output == nil && sd != nil {
+		output = sd.LastOutput
+	}
+
+	if output != nil {
+		var result struct {
+			Success *bool         `json:"success,omitempty"`
+			Error   *ReleaseError `json:"error,omitempty"`
+		}
+
+		if err := json.Unmarshal([]byte(*output), &result); err == nil {
+			status.Success = result.Success
+			status.Error = result.Error
+		}
+	}
+
+	if status.Success == nil && to.Strs(exec.Status) != sfn.ExecutionStatusRunning {
+		status.Success = to.Boolp(to.Strs(exec.Status) == sfn.ExecutionStatusSucceeded)
+	}
+
+	return status, nil
+}
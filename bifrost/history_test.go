@@ -0,0 +1,106 @@
+package bifrost
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/execution"
+	"github.com/coinbase/step/server"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForExecution(t *testing.T, sfnc *server.SFN, arn *string, status string) {
+	for i := 0; i < 100; i++ {
+		out, err := sfnc.DescribeExecution(&sfn.DescribeExecutionInput{ExecutionArn: arn})
+		assert.NoError(t, err)
+		if *out.Status == status {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("execution %v not %v", *arn, status)
+}
+
+func Test_Bifrost_Release_Status(t *testing.T) {
+	sfnc := server.NewSFN(to.Strp("us-east-1"), to.Strp("000000000000"))
+
+	block := make(chan struct{})
+	defer close(block)
+
+	sfnc.Handlers["deploy"] = func(_ context.Context, release *Release) (*Release, error) {
+		switch *release.ReleaseID {
+		case "release-failed":
+			return nil, fmt.Errorf("deploy failed")
+		case "release-running":
+			<-block
+		}
+		release.Success = to.Boolp(true)
+		return release, nil
+	}
+
+	out, err := sfnc.CreateStateMachine(&sfn.CreateStateMachineInput{
+		Name: to.Strp("coinbase-step-deployer"),
+		Definition: to.Strp(`{
+			"StartAt": "Deploy",
+			"States": {
+				"Deploy": {
+					"Type": "Task",
+					"Resource": "deploy",
+					"Catch": [{"ErrorEquals": ["States.ALL"], "ResultPath": "$.error", "Next": "Failure"}],
+					"End": true
+				},
+				"Failure": {"Type": "Fail", "Error": "DeployFailed"}
+			}
+		}`),
+		RoleArn: to.Strp("arn:aws:iam::000000000000:role/step"),
+	})
+	assert.NoError(t, err)
+	deployer_arn := out.StateMachineArn
+
+	deploy := func(id string, name *string) *Release {
+		release := MockRelease()
+		release.ReleaseID = to.Strp(id)
+		release.Metadata = map[string]string{"User": "user@example.com"}
+		_, err := execution.StartExecution(sfnc, deployer_arn, name, release)
+		assert.NoError(t, err)
+		return release
+	}
+
+	succeeded := deploy("release-succeeded", to.Strp("release-succeeded"))
+	waitForExecution(t, sfnc, succeeded.ExecutionArn(deployer_arn), sfn.ExecutionStatusSucceeded)
+
+	status, err := succeeded.Status(sfnc, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, sfn.ExecutionStatusSucceeded, *status.Status)
+	assert.Equal(t, true, *status.Success)
+	assert.Nil(t, status.Error)
+	assert.Equal(t, "user@example.com", status.Metadata["User"])
+	assert.NotNil(t, status.StoppedAt)
+	assert.True(t, *status.Duration >= 0)
+
+	failed := deploy("release-failed", to.Strp("release-failed"))
+	waitForExecution(t, sfnc, failed.ExecutionArn(deployer_arn), sfn.ExecutionStatusFailed)
+
+	status, err = failed.Status(sfnc, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, sfn.ExecutionStatusFailed, *status.Status)
+	assert.Equal(t, false, *status.Success)
+	assert.Equal(t, "deploy failed", *status.Error.Cause)
+
+	// Executions named by ExecutionName are found while running
+	running := deploy("release-running", MockRelease().ExecutionName())
+	status, err = running.Status(sfnc, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, sfn.ExecutionStatusRunning, *status.Status)
+	assert.Nil(t, status.Success)
+
+	// Another release of the project and config is not joined to the running execution
+	status, err = MockRelease().Status(sfnc, deployer_arn)
+	assert.NoError(t, err)
+	assert.Nil(t, status.Status)
+	assert.Nil(t, status.ExecutionArn)
+}
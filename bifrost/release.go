@@ -43,6 +43,9 @@ type Release struct {
 
 	Timeout *int `json:"timeout,omitempty"` // How long should we try and deploy in seconds
 
+	// RollbackOf is the ReleaseID whose artifacts are redeployed, recorded for the audit trail
+	RollbackOf *string `json:"rollback_of,omitempty"`
+
 	// Additional Metadata attached but should not be functional
 	Metadata map[string]string `json:"metadata,omitempty"`
 
@@ -58,6 +61,7 @@ type Release struct {
 // 1. the attributes are assigned
 // 2. the time frame of created at is valid
 // 3. the uploaded release sha is correct (requires S3 and &Release{})
+// 4. a rollback is of an earlier release of the same project and config
 func (r *Release) Validate(s3c aws.S3API, cRelease interface{}) error {
 
 	if reflect.ValueOf(cRelease).Kind() != reflect.Ptr {
@@ -65,6 +69,23 @@ func (r *Release) Validate(s3c aws.S3API, cRelease interface{}) error {
 		return fmt.Errorf("Release passed to Validate must be pointer e.g. &Release{}")
 	}
 
+	if err := r.ValidateAttributes(); err != nil {
+		return err
+	}
+
+	if err := r.validateReleaseSHA(s3c, cRelease); err != nil {
+		return err
+	}
+
+	if err := r.validateRollback(s3c); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateAttributes are the checks of Validate that do not need the release uploaded to S3
+func (r *Release) ValidateAttributes() error {
 	if is.EmptyStr(r.AwsAccountID) {
 		return fmt.Errorf("AwsAccountID must be defined")
 	}
@@ -111,8 +132,26 @@ func (r *Release) Validate(s3c aws.S3API, cRelease interface{}) error {
 		return fmt.Errorf("Created at older than 10 days (or in the future)")
 	}
 
-	if err := r.validateReleaseSHA(s3c, cRelease); err != nil {
-		return err
+	return nil
+}
+
+// validateRollback checks the release being rolled back to is in S3.
+// A rollback is signed with a new CreatedAt so releases older than 10 days can be redeployed
+func (r *Release) validateRollback(s3c aws.S3API) error {
+	if r.RollbackOf == nil {
+		return nil
+	}
+
+	if is.EmptyStr(r.RollbackOf) || strings.Contains(*r.RollbackOf, "/") {
+		return fmt.Errorf("RollbackOf must be a ReleaseID")
+	}
+
+	if *r.RollbackOf == *r.ReleaseID {
+		return fmt.Errorf("RollbackOf must not be the ReleaseID")
+	}
+
+	if _, err := s3.Get(s3c, r.Bucket, r.RollbackOfPath()); err != nil {
+		return fmt.Errorf("RollbackOf release %v not found: %v", *r.RollbackOf, err.Error())
 	}
 
 	return nil
@@ -199,6 +238,28 @@ func (r *Release) ReleasePath() *string {
 	return &s
 }
 
+// RollbackOfPath is the path of the release being rolled back to
+func (r *Release) RollbackOfPath() *string {
+	s := fmt.Sprintf("%v/%v/release", *r.RootDir(), *r.RollbackOf)
+	return &s
+}
+
+// SourceReleaseDir is the directory of the release artifacts,
+// for a rollback this is the directory of the release being rolled back to
+func (r *Release) SourceReleaseDir() *string {
+	if is.EmptyStr(r.RollbackOf) {
+		return r.ReleaseDir()
+	}
+
+	s := fmt.Sprintf("%v/%v", *r.RootDir(), *r.RollbackOf)
+	return &s
+}
+
+// ListReleaseIDs returns the ReleaseIDs in S3 of the project and config
+func (r *Release) ListReleaseIDs(s3c aws.S3API) ([]string, error) {
+	return s3.ListDirs(s3c, r.Bucket, r.RootDir())
+}
+
 func (release *Release) LogPath() *string {
 	s := fmt.Sprintf("%v/log", *release.ReleaseDir())
 	return &s
@@ -391,12 +452,17 @@ func (r *Release) Halt(s3c aws.S3API, message *string) error {
 
 // RemoveHalt deletes the halt flat from S3
 func (r *Release) RemoveHalt(s3c aws.S3API) {
-	if err := s3.Delete(s3c, r.Bucket, r.HaltPath()); err != nil {
+	if err := r.DeleteHalt(s3c); err != nil {
 		// ignore errors
 		fmt.Printf("Warning(RemoveHalt) error ignored: %v\n", err.Error())
 	}
 }
 
+// DeleteHalt deletes the halt flag from S3 and returns the error
+func (r *Release) DeleteHalt(s3c aws.S3API) error {
+	return s3.Delete(s3c, r.Bucket, r.HaltPath())
+}
+
 // Returns the haltFlag
 func (r *Release) haltFlag(s3c aws.S3API) *string {
 	output, body, err := s3.GetObject(s3c, r.Bucket, r.HaltPath())
@@ -56,3 +56,30 @@ func Test_Bifrost_Release_Is_Valid(t *testing.T) {
 
 	assert.NoError(t, release.Validate(awsc.S3Client(release.AwsRegion, nil, nil), &Release{}))
 }
+
+func Test_Bifrost_Release_Rollback(t *testing.T) {
+	release := MockRelease()
+	release.ReleaseID = to.Strp("release-2")
+	assert.Equal(t, "account/project/config/release-2", *release.SourceReleaseDir())
+
+	release.RollbackOf = to.Strp("release-1")
+	awsc := MockAwsClients(release)
+	s3c := awsc.S3Client(release.AwsRegion, nil, nil)
+
+	assert.Equal(t, "account/project/config/release-1", *release.SourceReleaseDir())
+	assert.Equal(t, "account/project/config/release-1/release", *release.RollbackOfPath())
+	assert.Regexp(t, "RollbackOf release release-1 not found", release.Validate(s3c, &Release{}))
+
+	awsc.S3.AddGetObject(*release.RollbackOfPath(), "{}", nil)
+	assert.NoError(t, release.Validate(s3c, &Release{}))
+
+	ids, err := release.ListReleaseIDs(s3c)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"release-1", "release-2"}, ids)
+
+	release.RollbackOf = to.Strp("release-2")
+	assert.Regexp(t, "RollbackOf must not be the ReleaseID", release.Validate(s3c, &Release{}))
+
+	release.RollbackOf = to.Strp("../other/release-1")
+	assert.Regexp(t, "RollbackOf must be a ReleaseID", release.Validate(s3c, &Release{}))
+}
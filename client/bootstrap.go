@@ -3,6 +3,7 @@ package client
 import (
 	"fmt"
 	"io/ioutil"
+	"time"
 
 	"github.com/coinbase/step/aws"
 	"github.com/coinbase/step/deployer"
@@ -34,11 +35,42 @@ func Bootstrap(release *deployer.Release, zip_file_path *string) error {
 
 	fmt.Println("Deploying Lambda Function")
 
-	err = release.DeployLambdaCode(awsc.LambdaClient(nil, nil, nil), &bts)
+	err = whileUpdating(func() error {
+		return release.DeployLambdaCode(awsc.LambdaClient(nil, nil, nil), &bts)
+	})
 	if err != nil {
 		return err
 	}
 
+	for _, l := range release.Lambdas {
+		bts, err := ioutil.ReadFile(*l.ZipFile)
+		if err != nil {
+			return err
+		}
+
+		err = whileUpdating(func() error {
+			return release.DeployLambdaArtifactCode(awsc.LambdaClient(nil, nil, nil), l, &bts)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	fmt.Println("Success")
 	return nil
 }
+
+// whileUpdating calls deploy again while the Lambda configuration is updating,
+// the deployer retries in its State Machine but Bootstrap runs before it exists
+func whileUpdating(deploy func() error) error {
+	for attempt := 0; attempt < 60; attempt++ {
+		err := deploy()
+		if _, ok := err.(deployer.DeployNotReadyError); !ok {
+			return err
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	return fmt.Errorf("Lambda configuration still updating after 60 attempts")
+}
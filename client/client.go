@@ -1,6 +1,7 @@
 package client
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/coinbase/step/aws"
@@ -20,13 +21,26 @@ func PrepareRelease(release *deployer.Release, zip_file_path *string) error {
 	}
 	release.LambdaSHA256 = &lambda_sha
 
+	for _, l := range release.Lambdas {
+		if l.ZipFile == nil {
+			return fmt.Errorf("Lambda %v zip file must be defined", to.Strs(l.Name))
+		}
+
+		sha, err := to.SHA256File(*l.ZipFile)
+		if err != nil {
+			return err
+		}
+		l.SHA256 = &sha
+	}
+
 	// Interpolate variables for resource strings
-	release.StateMachineJSON = to.InterpolateArnVariables(
-		release.StateMachineJSON,
-		release.AwsRegion,
-		release.AwsAccountID,
-		release.LambdaName,
-	)
+	release.InterpolateArns()
+
+	for _, sm := range release.StateMachines {
+		if sm.StateMachineJSON != nil {
+			sm.SHA256 = to.Strp(to.SHA256Str(sm.StateMachineJSON))
+		}
+	}
 
 	return nil
 }
@@ -48,6 +62,12 @@ func PrepareReleaseBundle(awsc aws.AwsClients, release *deployer.Release, zip_fi
 		return err
 	}
 
+	for _, l := range release.Lambdas {
+		if err := s3.PutFile(awsc.S3Client(release.AwsRegion, nil, nil), l.ZipFile, release.Bucket, release.LambdaArtifactZipPath(l)); err != nil {
+			return err
+		}
+	}
+
 	// reset CreateAt because it can take a while to upload the lambda
 	release.CreatedAt = to.Timep(time.Now())
 
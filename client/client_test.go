@@ -1,13 +1,19 @@
 package client
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sfn"
 	"github.com/coinbase/step/aws/mocks"
+	"github.com/coinbase/step/aws/s3"
 	"github.com/coinbase/step/bifrost"
 	"github.com/coinbase/step/deployer"
 	"github.com/coinbase/step/machine"
+	"github.com/coinbase/step/server"
 	"github.com/coinbase/step/utils/to"
 	"github.com/stretchr/testify/assert"
 )
@@ -37,3 +43,202 @@ func Test_Client_PrepareReleaseBundle(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func Test_Client_sendDeployToDeployer(t *testing.T) {
+	sfnc := server.NewSFN(to.Strp("us-east-1"), to.Strp("000000000000"))
+
+	deployed := make(chan *deployer.Release, 1)
+	sfnc.Handlers["deployer"] = func(_ context.Context, release *deployer.Release) (*deployer.Release, error) {
+		deployed <- release
+		return release, nil
+	}
+
+	out, err := sfnc.CreateStateMachine(&sfn.CreateStateMachineInput{
+		Name:       to.Strp("coinbase-step-deployer"),
+		Definition: to.Strp(`{"StartAt": "Deploy", "States": {"Deploy": {"Type": "Task", "Resource": "deployer", "End": true}}}`),
+		RoleArn:    to.Strp("arn:aws:iam::000000000000:role/step"),
+	})
+	assert.NoError(t, err)
+
+	release := &deployer.Release{
+		Release: bifrost.Release{
+			ReleaseID:   to.Strp("release-1"),
+			ProjectName: to.Strp("project"),
+			ConfigName:  to.Strp("config"),
+		},
+	}
+
+	err = sendDeployToDeployer(sfnc, release.ReleaseID, release, out.StateMachineArn)
+	assert.NoError(t, err)
+	assert.Equal(t, "project", *(<-deployed).ProjectName)
+
+	executions, err := sfnc.ListExecutions(&sfn.ListExecutionsInput{StateMachineArn: out.StateMachineArn})
+	assert.NoError(t, err)
+	assert.Equal(t, "release-1", *executions.Executions[0].Name)
+	assert.Equal(t, "SUCCEEDED", *executions.Executions[0].Status)
+}
+
+func Test_Client_PrepareRollback(t *testing.T) {
+	awsc := mocks.MockAwsClients()
+	deployer_arn := to.Strp("arn:aws:states:us-east-1:000000000000:stateMachine:coinbase-step-deployer")
+
+	for i, id := range []string{"release-1", "release-2", "release-3"} {
+		release := &deployer.Release{
+			Release: bifrost.Release{
+				AwsRegion:    to.Strp("us-east-1"),
+				AwsAccountID: to.Strp("000000000000"),
+				ReleaseID:    to.Strp(id),
+				CreatedAt:    to.Timep(time.Now().Add(time.Duration(i) * time.Minute)),
+				ProjectName:  to.Strp("project"),
+				ConfigName:   to.Strp("config"),
+				Bucket:       to.Strp("bucket"),
+			},
+			LambdaName: to.Strp("lambda"),
+		}
+		assert.NoError(t, s3.PutStruct(awsc.S3, release.Bucket, release.ReleasePath(), release))
+	}
+
+	awsc.SFN.DescribeExecutionResps = map[string]*sfn.DescribeExecutionOutput{
+		"arn:aws:states:us-east-1:000000000000:execution:coinbase-step-deployer:release-2": {Status: to.Strp(sfn.ExecutionStatusFailed)},
+	}
+
+	template := &deployer.Release{
+		Release: bifrost.Release{
+			AwsAccountID: to.Strp("000000000000"),
+			ProjectName:  to.Strp("project"),
+			ConfigName:   to.Strp("config"),
+			Bucket:       to.Strp("bucket"),
+		},
+	}
+
+	// By default the last successful release before the latest
+	rollback, err := PrepareRollback(awsc, template, nil, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, "release-1", *rollback.RollbackOf)
+	assert.Equal(t, "lambda", *rollback.LambdaName)
+
+	uploaded := &deployer.Release{}
+	assert.NoError(t, s3.GetStruct(awsc.S3, rollback.Bucket, rollback.ReleasePath(), uploaded))
+	assert.Equal(t, to.SHA256Struct(rollback), to.SHA256Struct(uploaded))
+
+	rollback, err = PrepareRollback(awsc, template, to.Strp("release-2"), deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, "release-2", *rollback.RollbackOf)
+
+	_, err = PrepareRollback(awsc, template, to.Strp("release-9"), deployer_arn)
+	assert.Error(t, err)
+}
+
+func Test_Client_PreparePlan(t *testing.T) {
+	awsc := mocks.MockAwsClients()
+	awsc.SFN.DescribeStateMachineResp = &sfn.DescribeStateMachineOutput{
+		Definition: to.Strp(machine.EmptyStateMachine),
+		RoleArn:    to.Strp("arn:aws:iam::000000000000:role/step/project/config/role"),
+	}
+	awsc.Lambda.ListTagsResp = &lambda.ListTagsOutput{Tags: map[string]*string{
+		"ProjectName": to.Strp("project"),
+		"ConfigName":  to.Strp("config"),
+		"DeployWith":  to.Strp("step-deployer"),
+	}}
+
+	release := &deployer.Release{
+		Release: bifrost.Release{
+			AwsRegion:    to.Strp("us-east-1"),
+			AwsAccountID: to.Strp("000000000000"),
+			ReleaseID:    to.TimeUUID("release-"),
+			CreatedAt:    to.Timep(time.Now()),
+			ProjectName:  to.Strp("project"),
+			ConfigName:   to.Strp("config"),
+			Bucket:       to.Strp("bucket"),
+		},
+		LambdaName:       to.Strp("lambda"),
+		StepFnName:       to.Strp("step"),
+		StateMachineJSON: to.Strp(machine.EmptyStateMachine),
+	}
+
+	plan, err := PreparePlan(awsc, release, to.Strp("../resources/empty_lambda.zip"))
+	assert.NoError(t, err)
+	assert.False(t, plan.StateMachines[0].Changed)
+	assert.True(t, plan.Lambdas[0].CodeChanged)
+	assert.Empty(t, plan.ValidationErrors)
+
+	// Nothing is uploaded
+	_, err = s3.Get(awsc.S3, release.Bucket, release.ReleasePath())
+	assert.Error(t, err)
+	_, err = s3.Get(awsc.S3, release.Bucket, release.LambdaZipPath())
+	assert.Error(t, err)
+
+	awsc.Lambda.ListTagsResp.Tags["ConfigName"] = to.Strp("other")
+	plan, err = PreparePlan(awsc, release, to.Strp("../resources/empty_lambda.zip"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lambda ConfigName tag incorrect, expecting config has other"}, plan.ValidationErrors)
+}
+
+func Test_Client_PrepareReleaseBundle_Artifacts(t *testing.T) {
+	awsc := mocks.MockAwsClients()
+	release := &deployer.Release{
+		Release: bifrost.Release{
+			AwsRegion:    to.Strp("us-east-1"),
+			AwsAccountID: to.Strp("000000000000"),
+			ReleaseID:    to.TimeUUID("release-"),
+			CreatedAt:    to.Timep(time.Now()),
+			ProjectName:  to.Strp("project"),
+			ConfigName:   to.Strp("config"),
+			Bucket:       to.Strp("bucket"),
+		},
+		LambdaName:       to.Strp("lambda"),
+		StepFnName:       to.Strp("step"),
+		StateMachineJSON: to.Strp(machine.EmptyStateMachine),
+		Lambdas:          []*deployer.LambdaArtifact{{Name: to.Strp("worker"), ZipFile: to.Strp("../resources/empty_lambda.zip")}},
+		StateMachines: []*deployer.StateMachineArtifact{{
+			Name:             to.Strp("child"),
+			StateMachineJSON: to.Strp(`{"StartAt": "A", "States": {"A": {"Type": "Task", "Resource": "{{lambda_arn:worker}}", "End": true}}}`),
+		}},
+	}
+
+	assert.NoError(t, PrepareReleaseBundle(awsc, release, to.Strp("../resources/empty_lambda.zip")))
+	assert.Equal(t, *release.LambdaSHA256, *release.Lambdas[0].SHA256)
+	assert.Contains(t, *release.StateMachines[0].StateMachineJSON, "arn:aws:lambda:us-east-1:000000000000:function:worker")
+	assert.Equal(t, to.SHA256Str(release.StateMachines[0].StateMachineJSON), *release.StateMachines[0].SHA256)
+
+	_, err := s3.Get(awsc.S3, release.Bucket, to.Strp(*release.ReleaseDir()+"/lambdas/worker.zip"))
+	assert.NoError(t, err)
+}
+
+func Test_Client_HaltRelease_UnhaltRelease(t *testing.T) {
+	awsc := mocks.MockAwsClients()
+
+	halt := &deployer.Release{
+		Release: bifrost.Release{
+			AwsRegion:    to.Strp("us-east-1"),
+			AwsAccountID: to.Strp("000000000000"),
+			ProjectName:  to.Strp("project"),
+			ConfigName:   to.Strp("config"),
+			Bucket:       to.Strp("bucket"),
+		},
+	}
+
+	running := &deployer.Release{
+		Release: bifrost.Release{
+			AwsRegion:    to.Strp("us-east-1"),
+			AwsAccountID: to.Strp("000000000000"),
+			ReleaseID:    to.Strp("release-1"),
+			ProjectName:  to.Strp("project"),
+			ConfigName:   to.Strp("config"),
+			Bucket:       to.Strp("bucket"),
+			Timeout:      to.Intp(600),
+		},
+	}
+
+	assert.NoError(t, running.IsHalt(awsc.S3))
+
+	assert.NoError(t, HaltRelease(awsc, halt, to.Strp("bad deploy")))
+	assert.Regexp(t, "bad deploy", running.IsHalt(awsc.S3))
+
+	assert.NoError(t, UnhaltRelease(awsc, halt))
+	assert.NoError(t, running.IsHalt(awsc.S3))
+
+	// A failed delete is returned
+	awsc.S3.DeleteObjectResp[*halt.HaltPath()] = &mocks.DeleteObjectResponse{Error: fmt.Errorf("access denied")}
+	assert.Regexp(t, "access denied", UnhaltRelease(awsc, halt))
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/deployer"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Halt writes the halt flag of the project and config of release so a running deploy fails
+// before its next state. The flag is ignored by the deployer after 5 minutes
+func Halt(release *deployer.Release, message *string) error {
+	awsc := &aws.Clients{}
+
+	region, account_id := to.RegionAccount()
+	release.SetDefaults(region, account_id, "coinbase-step-deployer-")
+
+	if err := HaltRelease(awsc, release, message); err != nil {
+		return err
+	}
+
+	fmt.Printf("Halted s3://%v/%v\n", *release.Bucket, *release.HaltPath())
+	return nil
+}
+
+// HaltRelease writes the halt flag with message
+func HaltRelease(awsc aws.AwsClients, release *deployer.Release, message *string) error {
+	return release.Halt(awsc.S3Client(release.AwsRegion, nil, nil), message)
+}
+
+// Unhalt removes the halt flag of the project and config of release so it can be deployed again
+func Unhalt(release *deployer.Release) error {
+	awsc := &aws.Clients{}
+
+	region, account_id := to.RegionAccount()
+	release.SetDefaults(region, account_id, "coinbase-step-deployer-")
+
+	if err := UnhaltRelease(awsc, release); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed s3://%v/%v\n", *release.Bucket, *release.HaltPath())
+	return nil
+}
+
+// UnhaltRelease removes the halt flag
+func UnhaltRelease(awsc aws.AwsClients, release *deployer.Release) error {
+	return release.DeleteHalt(awsc.S3Client(release.AwsRegion, nil, nil))
+}
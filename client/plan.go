@@ -0,0 +1,50 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/deployer"
+)
+
+// Plan prints what deploying the release would change and runs its validations,
+// without taking locks or uploading the release
+func Plan(release *deployer.Release, zip_file_path *string) error {
+	plan, err := PreparePlan(&aws.Clients{}, release, zip_file_path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(plan.String())
+
+	if len(plan.ValidationErrors) > 0 {
+		return fmt.Errorf("Release is invalid")
+	}
+
+	return nil
+}
+
+// PreparePlan returns the plan of the release with the errors of Validate and ValidateResources
+func PreparePlan(awsc aws.AwsClients, release *deployer.Release, zip_file_path *string) (*deployer.Plan, error) {
+	if err := PrepareRelease(release, zip_file_path); err != nil {
+		return nil, err
+	}
+
+	lambdac := awsc.LambdaClient(nil, nil, nil)
+	sfnc := awsc.SFNClient(nil, nil, nil)
+
+	plan, err := release.Plan(lambdac, sfnc)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := release.ValidateAttributes(); err != nil {
+		plan.ValidationErrors = append(plan.ValidationErrors, err.Error())
+	}
+
+	if err := release.ValidateResources(lambdac, sfnc); err != nil {
+		plan.ValidationErrors = append(plan.ValidationErrors, err.Error())
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/deployer"
+	"github.com/coinbase/step/utils/to"
+)
+
+// ListReleases prints the JSON status of the releases of the project and config of release, newest first
+func ListReleases(release *deployer.Release, deployer_arn *string) error {
+	awsc := &aws.Clients{}
+
+	region, account_id := to.RegionAccount()
+	release.SetDefaults(region, account_id, "coinbase-step-deployer-")
+
+	history, err := release.History(awsc.S3Client(release.AwsRegion, nil, nil), awsc.SFNClient(nil, nil, nil), deployer_arn)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(to.PrettyJSONStr(history))
+	return nil
+}
+
+// ShowRelease prints the JSON status of the release with release_id, or the latest release
+func ShowRelease(release *deployer.Release, release_id *string, deployer_arn *string) error {
+	awsc := &aws.Clients{}
+
+	region, account_id := to.RegionAccount()
+	release.SetDefaults(region, account_id, "coinbase-step-deployer-")
+
+	status, err := release.ShowRelease(awsc.S3Client(release.AwsRegion, nil, nil), awsc.SFNClient(nil, nil, nil), deployer_arn, release_id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(to.PrettyJSONStr(status))
+	return nil
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/deployer"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Rollback redeploys an earlier release of the project and config of release with the deployer.
+// Without a release_id it is the last successful release before the deployed one
+func Rollback(release *deployer.Release, release_id *string, deployer_arn *string) error {
+	awsc := &aws.Clients{}
+
+	region, account_id := to.RegionAccount()
+	release.SetDefaults(region, account_id, "coinbase-step-deployer-")
+
+	fmt.Println("Preparing Rollback")
+	rollback, err := PrepareRollback(awsc, release, release_id, deployer_arn)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(to.PrettyJSONStr(rollback))
+	return sendDeployToDeployer(awsc.SFNClient(nil, nil, nil), rollback.ReleaseID, rollback, deployer_arn)
+}
+
+// PrepareRollback finds the release to roll back to, then uploads it re-signed as a new release
+func PrepareRollback(awsc aws.AwsClients, release *deployer.Release, release_id *string, deployer_arn *string) (*deployer.Release, error) {
+	s3c := awsc.S3Client(release.AwsRegion, nil, nil)
+
+	releases, err := release.ListReleases(s3c)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := findRollbackTarget(awsc.SFNClient(nil, nil, nil), releases, release_id, deployer_arn)
+	if err != nil {
+		return nil, err
+	}
+
+	rollback := target.NewRollback()
+	if err := s3.PutStruct(s3c, rollback.Bucket, rollback.ReleasePath(), rollback); err != nil {
+		return nil, err
+	}
+
+	return rollback, nil
+}
+
+func findRollbackTarget(sfnc aws.SFNAPI, releases []*deployer.Release, release_id *string, deployer_arn *string) (*deployer.Release, error) {
+	if is.EmptyStr(release_id) {
+		return deployer.LastSuccessfulRelease(sfnc, releases, deployer_arn)
+	}
+
+	for _, r := range releases {
+		if *r.ReleaseID == *release_id {
+			return r, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Release %v not found", *release_id)
+}
@@ -0,0 +1,154 @@
+package deployer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coinbase/step/machine"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// LambdaArtifact is a Lambda deployed by the release
+type LambdaArtifact struct {
+	Name    *string `json:"name,omitempty"`
+	SHA256  *string `json:"sha256,omitempty"` // of the zip file
+	ZipFile *string `json:"-"`                // local path of the zip file, used by the client
+
+	Config *LambdaConfig `json:"config,omitempty"` // applied before the code
+}
+
+// StateMachineArtifact is a State Machine deployed by the release
+type StateMachineArtifact struct {
+	Name             *string `json:"name,omitempty"`
+	StateMachineJSON *string `json:"state_machine_json,omitempty"`
+	SHA256           *string `json:"sha256,omitempty"` // of the StateMachineJSON
+}
+
+// LambdaArtifacts are the Lambdas then the Lambda of LambdaName, in the order they are deployed
+func (r *Release) LambdaArtifacts() []*LambdaArtifact {
+	all := append([]*LambdaArtifact{}, r.Lambdas...)
+	return append(all, r.mainLambdaArtifact())
+}
+
+// mainLambdaArtifact is the Lambda of LambdaName
+func (r *Release) mainLambdaArtifact() *LambdaArtifact {
+	return &LambdaArtifact{Name: r.LambdaName, SHA256: r.LambdaSHA256, Config: r.LambdaConfig}
+}
+
+// StateMachineArtifacts are the StateMachines then the State Machine of StepFnName, in the order they are deployed,
+// so child State Machines exist before the State Machine calling them
+func (r *Release) StateMachineArtifacts() []*StateMachineArtifact {
+	all := append([]*StateMachineArtifact{}, r.StateMachines...)
+	return append(all, &StateMachineArtifact{Name: r.StepFnName, StateMachineJSON: r.StateMachineJSON})
+}
+
+// isMainLambda is true for the Lambda of LambdaName, the only one with TrafficShifting
+func (r *Release) isMainLambda(l *LambdaArtifact) bool {
+	return to.Strs(l.Name) == to.Strs(r.LambdaName)
+}
+
+// lambdaLabel names the Lambda in errors, the Lambda of LambdaName is just "Lambda"
+func (r *Release) lambdaLabel(l *LambdaArtifact) string {
+	if r.isMainLambda(l) {
+		return "Lambda"
+	}
+	return fmt.Sprintf("Lambda %v", *l.Name)
+}
+
+// stateMachineLabel names the State Machine in errors, the State Machine of StepFnName is just "Step Function"
+func (r *Release) stateMachineLabel(sm *StateMachineArtifact) string {
+	if to.Strs(sm.Name) == to.Strs(r.StepFnName) {
+		return "Step Function"
+	}
+	return fmt.Sprintf("Step Function %v", *sm.Name)
+}
+
+// LambdaArtifactZipPath is LambdaZipPath for the Lambda of LambdaName, otherwise lambdas/{name}.zip in the SourceReleaseDir
+func (r *Release) LambdaArtifactZipPath(l *LambdaArtifact) *string {
+	if r.isMainLambda(l) {
+		return r.LambdaZipPath()
+	}
+
+	s := fmt.Sprintf("%v/lambdas/%v.zip", *r.SourceReleaseDir(), *l.Name)
+	return &s
+}
+
+func (r *Release) lambdaArn(name *string) *string {
+	return to.LambdaArn(r.AwsRegion, r.AwsAccountID, name)
+}
+
+func (r *Release) stepArn(name *string) *string {
+	return to.StepArn(r.AwsRegion, r.AwsAccountID, name)
+}
+
+// validateArtifacts checks the Lambdas and StateMachines are named once, and have the SHA256 of their content
+func (r *Release) validateArtifacts() error {
+	lambdas := map[string]bool{*r.LambdaName: true}
+	for _, l := range r.Lambdas {
+		if is.EmptyStr(l.Name) {
+			return fmt.Errorf("Lambdas Name must be defined")
+		}
+
+		if lambdas[*l.Name] {
+			return fmt.Errorf("Lambda %v is in the release more than once", *l.Name)
+		}
+		lambdas[*l.Name] = true
+
+		if is.EmptyStr(l.SHA256) {
+			return fmt.Errorf("Lambda %v SHA256 must be defined", *l.Name)
+		}
+	}
+
+	state_machines := map[string]bool{*r.StepFnName: true}
+	for _, sm := range r.StateMachines {
+		if is.EmptyStr(sm.Name) {
+			return fmt.Errorf("StateMachines Name must be defined")
+		}
+
+		if state_machines[*sm.Name] {
+			return fmt.Errorf("State Machine %v is in the release more than once", *sm.Name)
+		}
+		state_machines[*sm.Name] = true
+
+		if is.EmptyStr(sm.StateMachineJSON) {
+			return fmt.Errorf("State Machine %v StateMachineJSON must be defined", *sm.Name)
+		}
+
+		if err := machine.Validate(sm.StateMachineJSON); err != nil {
+			return fmt.Errorf("State Machine %v StateMachineJSON invalid with '%v'", *sm.Name, err.Error())
+		}
+
+		if sha := to.SHA256Str(sm.StateMachineJSON); to.Strs(sm.SHA256) != sha {
+			return fmt.Errorf("State Machine %v SHA mismatch, expecting %v, got %v", *sm.Name, to.Strs(sm.SHA256), sha)
+		}
+	}
+
+	return nil
+}
+
+// InterpolateArns replaces the templates in the State Machines, {{aws_account}}, {{aws_region}} and {{lambda_name}}
+// like to.InterpolateArnVariables, {{lambda_arn:<name>}} and {{step_arn:<name>}} with the ARN of each Lambda and State Machine
+func (r *Release) InterpolateArns() {
+	replacements := []string{}
+	for _, l := range r.LambdaArtifacts() {
+		replacements = append(replacements, fmt.Sprintf("{{lambda_arn:%v}}", to.Strs(l.Name)), to.Strs(r.lambdaArn(l.Name)))
+	}
+	for _, sm := range r.StateMachineArtifacts() {
+		replacements = append(replacements, fmt.Sprintf("{{step_arn:%v}}", to.Strs(sm.Name)), to.Strs(r.stepArn(sm.Name)))
+	}
+	replacer := strings.NewReplacer(replacements...)
+
+	interpolate := func(state_machine *string) *string {
+		if state_machine == nil {
+			return nil
+		}
+		state_machine = to.InterpolateArnVariables(state_machine, r.AwsRegion, r.AwsAccountID, r.LambdaName)
+		return to.Strp(replacer.Replace(*state_machine))
+	}
+
+	r.StateMachineJSON = interpolate(r.StateMachineJSON)
+	for _, sm := range r.StateMachines {
+		sm.StateMachineJSON = interpolate(sm.StateMachineJSON)
+	}
+}
@@ -0,0 +1,107 @@
+package deployer
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws/mocks"
+	"github.com/coinbase/step/machine"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+var childStateMachine = `{"StartAt": "Work", "States": {"Work": {"Type": "Task", "Resource": "{{lambda_arn:worker}}", "End": true}}}`
+
+func MockMultiRelease() (*Release, *mocks.MockClients) {
+	release := MockRelease()
+	release.Lambdas = []*LambdaArtifact{
+		{Name: to.Strp("worker"), SHA256: to.Strp(to.SHA256Str(to.Strp("worker_zip")))},
+	}
+	release.StateMachines = []*StateMachineArtifact{
+		{Name: to.Strp("child"), StateMachineJSON: to.Strp(childStateMachine), SHA256: to.Strp(to.SHA256Str(&childStateMachine))},
+	}
+
+	awsc := MockAwsClients(release)
+	awsc.S3.AddGetObject(*release.LambdaArtifactZipPath(release.Lambdas[0]), "worker_zip", nil)
+	return release, awsc
+}
+
+func Test_Release_InterpolateArns(t *testing.T) {
+	release := MockRelease()
+	release.AwsRegion = to.Strp("us-east-1")
+	release.AwsAccountID = to.Strp("000000000000")
+	release.StateMachineJSON = to.Strp(`{"a": "{{lambda_name}}", "b": "{{step_arn:child}}", "c": "{{lambda_arn:lambdaname}}"}`)
+	release.Lambdas = []*LambdaArtifact{{Name: to.Strp("worker")}}
+	release.StateMachines = []*StateMachineArtifact{{Name: to.Strp("child"), StateMachineJSON: to.Strp(childStateMachine)}}
+
+	release.InterpolateArns()
+	assert.JSONEq(t, `{
+		"a": "lambdaname",
+		"b": "arn:aws:states:us-east-1:000000000000:stateMachine:child",
+		"c": "arn:aws:lambda:us-east-1:000000000000:function:lambdaname"
+	}`, *release.StateMachineJSON)
+	assert.Contains(t, *release.StateMachines[0].StateMachineJSON, `"arn:aws:lambda:us-east-1:000000000000:function:worker"`)
+	assert.Equal(t, "000000000000/project/development/release-1/lambdas/worker.zip", *release.LambdaArtifactZipPath(release.Lambdas[0]))
+	assert.Equal(t, *release.LambdaZipPath(), *release.LambdaArtifactZipPath(release.LambdaArtifacts()[1]))
+}
+
+func Test_Release_ValidateArtifacts(t *testing.T) {
+	release, _ := MockMultiRelease()
+	assert.NoError(t, release.validateArtifacts())
+
+	release.Lambdas[0].Name = to.Strp("lambdaname")
+	assert.Regexp(t, "Lambda lambdaname is in the release more than once", release.validateArtifacts())
+
+	release, _ = MockMultiRelease()
+	release.Lambdas[0].SHA256 = nil
+	assert.Regexp(t, "Lambda worker SHA256 must be defined", release.validateArtifacts())
+
+	release, _ = MockMultiRelease()
+	release.StateMachines[0].StateMachineJSON = to.Strp(machine.EmptyStateMachine)
+	assert.Regexp(t, "State Machine child SHA mismatch", release.validateArtifacts())
+
+	release, _ = MockMultiRelease()
+	release.StateMachines[0].Name = release.StepFnName
+	assert.Regexp(t, "State Machine stepfnname is in the release more than once", release.validateArtifacts())
+}
+
+func Test_DeployHandler_Execution_MultipleArtifacts(t *testing.T) {
+	release, awsc := MockMultiRelease()
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+
+	// Child State Machines and Lambdas are deployed first
+	assert.Equal(t, 2, len(awsc.SFN.UpdateStateMachineInputs))
+	assert.Equal(t, "arn:aws:states:us-east-1:00000000:stateMachine:child", *awsc.SFN.UpdateStateMachineInputs[0].StateMachineArn)
+	assert.Equal(t, "arn:aws:states:us-east-1:00000000:stateMachine:stepfnname", *awsc.SFN.UpdateStateMachineInputs[1].StateMachineArn)
+
+	assert.Equal(t, 2, len(awsc.Lambda.UpdateFunctionCodeInputs))
+	assert.Equal(t, "arn:aws:lambda:us-east-1:00000000:function:worker", *awsc.Lambda.UpdateFunctionCodeInputs[0].FunctionName)
+	assert.Equal(t, "worker_zip", string(awsc.Lambda.UpdateFunctionCodeInputs[0].ZipFile))
+	assert.Equal(t, "arn:aws:lambda:us-east-1:00000000:function:lambdaname", *awsc.Lambda.UpdateFunctionCodeInputs[1].FunctionName)
+	assert.Equal(t, "lambda_zip", string(awsc.Lambda.UpdateFunctionCodeInputs[1].ZipFile))
+}
+
+func Test_DeployHandler_Execution_MultipleArtifacts_Errors(t *testing.T) {
+	// The worker zip is not the uploaded one
+	release, awsc := MockMultiRelease()
+	awsc.S3.AddGetObject(*release.LambdaArtifactZipPath(release.Lambdas[0]), "other_zip", nil)
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Regexp(t, "Lambda worker SHA mismatch", exec.LastOutputJSON)
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionCodeInputs))
+
+	// The child State Machine role is checked
+	release, awsc = MockMultiRelease()
+	awsc.SFN.DescribeStateMachineResp = &sfn.DescribeStateMachineOutput{RoleArn: to.Strp("arn:aws:iam::000000000000:role/other")}
+	state_machine = createTestStateMachine(t, awsc)
+
+	exec, err = state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Regexp(t, "Incorrect Step Function child Role Path", exec.LastOutputJSON)
+}
@@ -0,0 +1,200 @@
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+// LambdaConfig is the configuration of a Lambda the deployer applies before its code, nil fields are left as they are.
+// Architecture is deployed with the code
+type LambdaConfig struct {
+	MemorySize   *int64             `json:"memory_size,omitempty"` // MB
+	Timeout      *int64             `json:"timeout,omitempty"`     // seconds
+	Runtime      *string            `json:"runtime,omitempty"`
+	Handler      *string            `json:"handler,omitempty"`
+	Environment  map[string]*string `json:"environment,omitempty"`  // replaces all environment variables
+	Layers       []*string          `json:"layers,omitempty"`       // layer version ARNs, replaces all layers
+	Tags         map[string]*string `json:"tags,omitempty"`         // added to the Lambda, cannot be the ResourceTags
+	Architecture *string            `json:"architecture,omitempty"` // x86_64 or arm64
+}
+
+// Validate checks the limits of Lambda and that the tags ValidateResources checks are not changed
+func (c *LambdaConfig) Validate() error {
+	if c.MemorySize != nil && (*c.MemorySize < 128 || *c.MemorySize > 10240) {
+		return fmt.Errorf("MemorySize must be between 128 and 10240, got %v", *c.MemorySize)
+	}
+
+	if c.Timeout != nil && (*c.Timeout < 1 || *c.Timeout > 900) {
+		return fmt.Errorf("Timeout must be between 1 and 900, got %v", *c.Timeout)
+	}
+
+	for key, value := range c.Environment {
+		if key == "" || value == nil {
+			return fmt.Errorf("Environment variable %q must have a name and value", key)
+		}
+	}
+
+	if c.Architecture != nil && *c.Architecture != lambda.ArchitectureX8664 && *c.Architecture != lambda.ArchitectureArm64 {
+		return fmt.Errorf("Architecture must be %v or %v, got %v", lambda.ArchitectureX8664, lambda.ArchitectureArm64, *c.Architecture)
+	}
+
+	if len(c.Layers) > 5 {
+		return fmt.Errorf("Layers must be at most 5, got %v", len(c.Layers))
+	}
+
+	for key, value := range c.Tags {
+		if value == nil {
+			return fmt.Errorf("Tag %q must have a value", key)
+		}
+
+		if strings.HasPrefix(key, "aws:") {
+			return fmt.Errorf("Tag %q is reserved by AWS", key)
+		}
+
+		for _, resource_key := range resourceTagKeys {
+			if key == resource_key {
+				return fmt.Errorf("Tag %q is checked by the deployer and cannot be changed", key)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *LambdaConfig) updateInput(arn *string) *lambda.UpdateFunctionConfigurationInput {
+	input := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: arn,
+		MemorySize:   c.MemorySize,
+		Timeout:      c.Timeout,
+		Runtime:      c.Runtime,
+		Handler:      c.Handler,
+		Layers:       c.Layers,
+	}
+
+	if c.Environment != nil {
+		input.Environment = &lambda.Environment{Variables: c.Environment}
+	}
+
+	return input
+}
+
+// configuration is c without the Tags and Architecture, the fields UpdateFunctionConfiguration deploys
+func (c *LambdaConfig) configuration() *LambdaConfig {
+	fields := *c
+	fields.Tags = nil
+	fields.Architecture = nil
+	return &fields
+}
+
+// architectures is the Architectures of the code input, nil leaves them as they are
+func (c *LambdaConfig) architectures() []*string {
+	if c == nil || c.Architecture == nil {
+		return nil
+	}
+	return []*string{c.Architecture}
+}
+
+// current is the part of the deployed configuration c would change
+func (c *LambdaConfig) current(fn *lambda.FunctionConfiguration, tags map[string]*string) *LambdaConfig {
+	current := &LambdaConfig{}
+	if fn == nil {
+		return current
+	}
+
+	if c.MemorySize != nil {
+		current.MemorySize = fn.MemorySize
+	}
+
+	if c.Timeout != nil {
+		current.Timeout = fn.Timeout
+	}
+
+	if c.Runtime != nil {
+		current.Runtime = fn.Runtime
+	}
+
+	if c.Handler != nil {
+		current.Handler = fn.Handler
+	}
+
+	if c.Environment != nil && fn.Environment != nil {
+		current.Environment = fn.Environment.Variables
+	}
+
+	if c.Layers != nil {
+		for _, layer := range fn.Layers {
+			current.Layers = append(current.Layers, layer.Arn)
+		}
+	}
+
+	if c.Architecture != nil && len(fn.Architectures) > 0 {
+		current.Architecture = fn.Architectures[0]
+	}
+
+	for key := range c.Tags {
+		if value, ok := tags[key]; ok {
+			if current.Tags == nil {
+				current.Tags = map[string]*string{}
+			}
+			current.Tags[key] = value
+		}
+	}
+
+	return current
+}
+
+// Diff is how c changes the deployed configuration and tags, in the lines of diffJSON
+func (c *LambdaConfig) Diff(fn *lambda.FunctionConfiguration, tags map[string]*string) []string {
+	var a, b interface{}
+	json.Unmarshal([]byte(to.CompactJSONStr(c.current(fn, tags))), &a)
+	json.Unmarshal([]byte(to.CompactJSONStr(c)), &b)
+	return diffJSON("$", a, b)
+}
+
+// DeployLambdaConfig tags the Lambda and updates its configuration if it is not the Config.
+// It returns true while the Lambda is updating, including after this update, as its code cannot be updated until it finishes
+func (release *Release) DeployLambdaConfig(lambdaClient aws.LambdaAPI, l *LambdaArtifact) (bool, error) {
+	if l.Config == nil {
+		return false, nil
+	}
+
+	arn := release.lambdaArn(l.Name)
+
+	fn, err := lambdaClient.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{FunctionName: arn})
+	if err != nil {
+		return false, err
+	}
+
+	if fn == nil {
+		return false, fmt.Errorf("Unknown Lambda Configuration Error")
+	}
+
+	// Lambdas that predate LastUpdateStatus have none
+	switch to.Strs(fn.LastUpdateStatus) {
+	case lambda.LastUpdateStatusInProgress:
+		return true, nil
+	case lambda.LastUpdateStatusFailed:
+		return false, fmt.Errorf("Lambda %v update failed with %v: %v", *arn, to.Strs(fn.LastUpdateStatusReasonCode), to.Strs(fn.LastUpdateStatusReason))
+	}
+
+	if len(l.Config.Tags) > 0 {
+		if _, err := lambdaClient.TagResource(&lambda.TagResourceInput{Resource: arn, Tags: l.Config.Tags}); err != nil {
+			return false, err
+		}
+	}
+
+	if len(l.Config.configuration().Diff(fn, nil)) == 0 {
+		return false, nil
+	}
+
+	if _, err := lambdaClient.UpdateFunctionConfiguration(l.Config.updateInput(arn)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,184 @@
+package deployer
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func MockLambdaConfig() *LambdaConfig {
+	return &LambdaConfig{
+		MemorySize:  to.Int64p(256),
+		Timeout:     to.Int64p(30),
+		Environment: map[string]*string{"LOG_LEVEL": to.Strp("debug")},
+		Layers:      []*string{to.Strp("arn:aws:lambda:us-east-1:00000000:layer:lib:2")},
+		Tags:        map[string]*string{"Team": to.Strp("payments")},
+	}
+}
+
+func Test_LambdaConfig_Validate(t *testing.T) {
+	assert.NoError(t, MockLambdaConfig().Validate())
+
+	c := MockLambdaConfig()
+	c.MemorySize = to.Int64p(64)
+	assert.Regexp(t, "MemorySize must be between", c.Validate())
+
+	c = MockLambdaConfig()
+	c.Timeout = to.Int64p(901)
+	assert.Regexp(t, "Timeout must be between", c.Validate())
+
+	c = MockLambdaConfig()
+	c.Environment["EMPTY"] = nil
+	assert.Regexp(t, "Environment variable \"EMPTY\"", c.Validate())
+
+	for _, key := range []string{"ProjectName", "ConfigName", "DeployWith"} {
+		c = MockLambdaConfig()
+		c.Tags[key] = to.Strp("other")
+		assert.Regexp(t, "checked by the deployer and cannot be changed", c.Validate())
+	}
+
+	c = MockLambdaConfig()
+	c.Layers = append(c.Layers, c.Layers[0], c.Layers[0], c.Layers[0], c.Layers[0], c.Layers[0])
+	assert.Regexp(t, "Layers must be at most 5", c.Validate())
+
+	c = MockLambdaConfig()
+	c.Tags["aws:cloudformation:stack-name"] = to.Strp("stack")
+	assert.Regexp(t, "reserved by AWS", c.Validate())
+}
+
+func Test_Release_Validate_LambdaConfig(t *testing.T) {
+	release := MockRelease()
+	release.LambdaSHA256 = to.Strp("sha")
+	release.LambdaConfig = MockLambdaConfig()
+	assert.NoError(t, release.validateAttributes())
+
+	release.LambdaConfig.Tags["DeployWith"] = to.Strp("other")
+	assert.Regexp(t, "Lambda Config invalid", release.validateAttributes())
+}
+
+func Test_LambdaConfig_Diff(t *testing.T) {
+	c := MockLambdaConfig()
+
+	diff := c.Diff(&lambda.FunctionConfiguration{
+		MemorySize:  to.Int64p(128),
+		Timeout:     to.Int64p(30),
+		Runtime:     to.Strp("go1.x"),
+		Environment: &lambda.EnvironmentResponse{Variables: map[string]*string{"LOG_LEVEL": to.Strp("info"), "OLD": to.Strp("x")}},
+		Layers:      []*lambda.Layer{{Arn: to.Strp("arn:aws:lambda:us-east-1:00000000:layer:lib:2")}},
+	}, map[string]*string{"ProjectName": to.Strp("project")})
+
+	assert.Equal(t, []string{
+		`~ $.environment.LOG_LEVEL: "info" => "debug"`,
+		`- $.environment.OLD: "x"`,
+		`~ $.memory_size: 128 => 256`,
+		`+ $.tags: {"Team":"payments"}`,
+	}, diff)
+
+	assert.Equal(t, []string{}, (&LambdaConfig{Timeout: to.Int64p(30)}).Diff(&lambda.FunctionConfiguration{Timeout: to.Int64p(30)}, nil))
+}
+
+func Test_LambdaConfig_Architecture(t *testing.T) {
+	c := MockLambdaConfig()
+	c.Architecture = to.Strp("sparc")
+	assert.Regexp(t, "Architecture must be x86_64 or arm64", c.Validate())
+
+	c.Architecture = to.Strp(lambda.ArchitectureArm64)
+	assert.NoError(t, c.Validate())
+
+	diff := (&LambdaConfig{Architecture: c.Architecture}).Diff(&lambda.FunctionConfiguration{
+		Architectures: []*string{to.Strp(lambda.ArchitectureX8664)},
+	}, nil)
+	assert.Equal(t, []string{`~ $.architecture: "x86_64" => "arm64"`}, diff)
+
+	release, _ := MockCreateRelease(false)
+	release.LambdaConfig = c
+	l := release.mainLambdaArtifact()
+	zip := to.ABytep([]byte("zip"))
+
+	assert.Equal(t, []*string{c.Architecture}, release.deployLambdaInput(l, zip).Architectures)
+	assert.Equal(t, []*string{c.Architecture}, release.createLambdaInput(l, zip).Architectures)
+
+	// Without an Architecture the deployed one is kept
+	release.LambdaConfig.Architecture = nil
+	assert.Nil(t, release.deployLambdaInput(l, zip).Architectures)
+	release.LambdaConfig = nil
+	assert.Nil(t, release.deployLambdaInput(l, zip).Architectures)
+}
+
+func Test_Release_DeployLambda_ConfigNotReady(t *testing.T) {
+	release := MockRelease()
+	release.LambdaConfig = MockLambdaConfig()
+	awsc := MockAwsClients(release)
+
+	// The configuration is updated and DeployNotReadyError returned before any code is uploaded
+	err := release.DeployLambda(awsc.Lambda, awsc.S3)
+	assert.IsType(t, DeployNotReadyError{}, err)
+	assert.Equal(t, 1, len(awsc.Lambda.UpdateFunctionConfigurationInputs))
+	assert.Equal(t, int64(256), *awsc.Lambda.UpdateFunctionConfigurationInputs[0].MemorySize)
+	assert.Equal(t, "debug", *awsc.Lambda.UpdateFunctionConfigurationInputs[0].Environment.Variables["LOG_LEVEL"])
+	assert.Equal(t, "payments", *awsc.Lambda.TagResourceInputs[0].Tags["Team"])
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionCodeInputs))
+
+	// While it is updating
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{
+		{LastUpdateStatus: to.Strp(lambda.LastUpdateStatusInProgress)},
+	}
+	assert.IsType(t, DeployNotReadyError{}, release.DeployLambda(awsc.Lambda, awsc.S3))
+	assert.Equal(t, 1, len(awsc.Lambda.UpdateFunctionConfigurationInputs))
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionCodeInputs))
+
+	// Once updated the configuration is not updated again and the code is uploaded
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{
+		{LastUpdateStatus: to.Strp(lambda.LastUpdateStatusSuccessful)},
+	}
+	assert.NoError(t, release.DeployLambda(awsc.Lambda, awsc.S3))
+	assert.Equal(t, 1, len(awsc.Lambda.UpdateFunctionConfigurationInputs))
+	assert.Equal(t, 1, len(awsc.Lambda.UpdateFunctionCodeInputs))
+
+	// Failed update does not deploy code
+	awsc = MockAwsClients(release)
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{
+		{LastUpdateStatus: to.Strp(lambda.LastUpdateStatusFailed), LastUpdateStatusReason: to.Strp("bad layer")},
+	}
+
+	assert.Regexp(t, "update failed with .*bad layer", release.DeployLambda(awsc.Lambda, awsc.S3))
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionConfigurationInputs))
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionCodeInputs))
+}
+
+func Test_DeployHandler_Execution_LambdaConfig(t *testing.T) {
+	release := MockRelease()
+	release.LambdaConfig = MockLambdaConfig()
+	awsc := MockAwsClients(release)
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+	assert.Equal(t, 1, len(awsc.Lambda.UpdateFunctionConfigurationInputs))
+	assert.Equal(t, "arn:aws:lambda:us-east-1:00000000:function:lambdaname", *awsc.Lambda.UpdateFunctionConfigurationInputs[0].FunctionName)
+	assert.Equal(t, 1, len(awsc.Lambda.UpdateFunctionCodeInputs))
+
+	// Deploy is retried while the configuration updates
+	assert.Equal(t, []string{"Snapshot", "Deploy", "Deploy", "VerifyDeploy"}, exec.Path()[3:7])
+}
+
+func Test_DeployHandler_Execution_LambdaConfig_Create(t *testing.T) {
+	release, _ := MockCreateRelease(false)
+	release.LambdaConfig = MockLambdaConfig()
+	awsc := mockMissingResources(MockAwsClients(release))
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionConfigurationInputs))
+	fn := awsc.Lambda.CreateFunctionInputs[0]
+	assert.Equal(t, int64(256), *fn.MemorySize)
+	assert.Equal(t, "debug", *fn.Environment.Variables["LOG_LEVEL"])
+	assert.Equal(t, "payments", *fn.Tags["Team"])
+	assert.Equal(t, "step-deployer", *fn.Tags["DeployWith"])
+}
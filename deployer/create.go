@@ -0,0 +1,185 @@
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// CreateConfig opts a release into creating its State Machine and Lambda when they do not exist.
+// They are created with the tags and roles ValidateResources checks, so later deploys need no Create
+type CreateConfig struct {
+	StepRoleArn   *string `json:"step_role_arn,omitempty"`   // role of the State Machine
+	LambdaRoleArn *string `json:"lambda_role_arn,omitempty"` // execution role of the Lambda
+	Runtime       *string `json:"runtime,omitempty"`
+	Handler       *string `json:"handler,omitempty"`
+	MemorySize    *int64  `json:"memory_size,omitempty"` // MB
+	Timeout       *int64  `json:"timeout,omitempty"`     // seconds
+}
+
+// SetDefaults is a Go Lambda with the binary named lambda
+func (c *CreateConfig) SetDefaults() {
+	if c.Runtime == nil {
+		c.Runtime = to.Strp(lambda.RuntimeGo1X)
+	}
+
+	if c.Handler == nil {
+		c.Handler = to.Strp("lambda")
+	}
+}
+
+// Validate checks both roles are under /step/{project}/{config}/
+func (c *CreateConfig) Validate(project *string, config *string) error {
+	if is.EmptyStr(c.StepRoleArn) {
+		return fmt.Errorf("Create StepRoleArn must be defined")
+	}
+
+	if is.EmptyStr(c.LambdaRoleArn) {
+		return fmt.Errorf("Create LambdaRoleArn must be defined")
+	}
+
+	if is.EmptyStr(c.Runtime) || is.EmptyStr(c.Handler) {
+		return fmt.Errorf("Create Runtime and Handler must be defined")
+	}
+
+	expected := fmt.Sprintf("/step/%v/%v/", *project, *config)
+	for _, role := range []*string{c.StepRoleArn, c.LambdaRoleArn} {
+		if path := to.ArnPath(*role); path != expected {
+			return fmt.Errorf("Incorrect Create Role Path, expecting %v, got %v", expected, path)
+		}
+	}
+
+	return nil
+}
+
+// awsErrorCode is true if err is an AWS error with code
+func awsErrorCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
+
+// missingResource is true if the release creates resources and err is that the resource does not exist
+func (release *Release) missingResource(err error) bool {
+	if release.Create == nil {
+		return false
+	}
+
+	return awsErrorCode(err, lambda.ErrCodeResourceNotFoundException) || awsErrorCode(err, sfn.ErrCodeStateMachineDoesNotExist)
+}
+
+// resourceTagKeys are the keys of ResourceTags
+var resourceTagKeys = []string{"ProjectName", "ConfigName", "DeployWith"}
+
+// ResourceTags are the tags ValidateResources checks
+func (release *Release) ResourceTags() map[string]*string {
+	return map[string]*string{
+		"ProjectName": release.ProjectName,
+		"ConfigName":  release.ConfigName,
+		"DeployWith":  to.Strp("step-deployer"),
+	}
+}
+
+// createLambdaInput is the Lambda of Create with the Config of the Lambda
+func (release *Release) createLambdaInput(l *LambdaArtifact, zip *[]byte) *lambda.CreateFunctionInput {
+	input := &lambda.CreateFunctionInput{
+		FunctionName:  l.Name,
+		Code:          &lambda.FunctionCode{ZipFile: *zip},
+		Role:          release.Create.LambdaRoleArn,
+		Runtime:       release.Create.Runtime,
+		Handler:       release.Create.Handler,
+		MemorySize:    release.Create.MemorySize,
+		Timeout:       release.Create.Timeout,
+		Tags:          release.ResourceTags(),
+		Publish:       to.Boolp(release.publish(l)),
+		Architectures: l.Config.architectures(),
+	}
+
+	if l.Config == nil {
+		return input
+	}
+
+	if l.Config.MemorySize != nil {
+		input.MemorySize = l.Config.MemorySize
+	}
+
+	if l.Config.Timeout != nil {
+		input.Timeout = l.Config.Timeout
+	}
+
+	if l.Config.Runtime != nil {
+		input.Runtime = l.Config.Runtime
+	}
+
+	if l.Config.Handler != nil {
+		input.Handler = l.Config.Handler
+	}
+
+	if l.Config.Environment != nil {
+		input.Environment = &lambda.Environment{Variables: l.Config.Environment}
+	}
+
+	input.Layers = l.Config.Layers
+
+	tags := map[string]*string{}
+	for key, value := range l.Config.Tags {
+		tags[key] = value
+	}
+	for key, value := range release.ResourceTags() {
+		tags[key] = value
+	}
+	input.Tags = tags
+
+	return input
+}
+
+// CreateLambda creates the Lambda with the code, with TrafficShifting the code is published as LambdaVersion
+func (release *Release) CreateLambda(lambdaClient aws.LambdaAPI, l *LambdaArtifact, zip *[]byte) error {
+	out, err := lambdaClient.CreateFunction(release.createLambdaInput(l, zip))
+	if err != nil {
+		return err
+	}
+
+	return release.setLambdaVersion(l, out)
+}
+
+func (release *Release) createStepFunctionInput(sm *StateMachineArtifact) *sfn.CreateStateMachineInput {
+	tags := []*sfn.Tag{}
+	for _, key := range resourceTagKeys {
+		tags = append(tags, &sfn.Tag{Key: to.Strp(key), Value: release.ResourceTags()[key]})
+	}
+
+	return &sfn.CreateStateMachineInput{
+		Name:       sm.Name,
+		Definition: to.Strp(to.PrettyJSONStr(sm.StateMachineJSON)),
+		RoleArn:    release.Create.StepRoleArn,
+		Tags:       tags,
+	}
+}
+
+// CreateStepFunction creates the State Machine
+func (release *Release) CreateStepFunction(sfnClient aws.SFNAPI, sm *StateMachineArtifact) error {
+	_, err := sfnClient.CreateStateMachine(release.createStepFunctionInput(sm))
+	return err
+}
+
+// createAlias points a new alias at LambdaVersion, there is no traffic to shift
+func (release *Release) createAlias(lambdac aws.LambdaAPI) error {
+	_, err := lambdac.CreateAlias(&lambda.CreateAliasInput{
+		FunctionName:    release.LambdaArn(),
+		Name:            release.TrafficShifting.Alias,
+		FunctionVersion: release.LambdaVersion,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	release.PreviousVersion = release.LambdaVersion
+	release.TrafficPercent = 100
+	return nil
+}
@@ -0,0 +1,126 @@
+package deployer
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws/mocks"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func MockCreateRelease(shifting bool) (*Release, *mocks.MockClients) {
+	release := MockRelease()
+	release.Create = &CreateConfig{
+		StepRoleArn:   to.Strp("arn:aws:iam::000000000000:role/step/project/development/step-role"),
+		LambdaRoleArn: to.Strp("arn:aws:iam::000000000000:role/step/project/development/lambda-role"),
+	}
+
+	if shifting {
+		release.TrafficShifting = &TrafficShifting{Alias: to.Strp("live")}
+	}
+
+	return release, mockMissingResources(MockAwsClients(release))
+}
+
+// mockMissingResources makes none of the State Machines and Lambdas exist
+func mockMissingResources(awsc *mocks.MockClients) *mocks.MockClients {
+	awsc.Lambda.ListTagsError = awserr.New(lambda.ErrCodeResourceNotFoundException, "not found", nil)
+	awsc.Lambda.UpdateFunctionCodeError = awserr.New(lambda.ErrCodeResourceNotFoundException, "not found", nil)
+	awsc.Lambda.GetAliasError = awserr.New(lambda.ErrCodeResourceNotFoundException, "not found", nil)
+	awsc.SFN.DescribeStateMachineError = awserr.New(sfn.ErrCodeStateMachineDoesNotExist, "not found", nil)
+	awsc.SFN.UpdateStateMachineError = awserr.New(sfn.ErrCodeStateMachineDoesNotExist, "not found", nil)
+	awsc.Lambda.GetFunctionConfigurationError = awserr.New(lambda.ErrCodeResourceNotFoundException, "not found", nil)
+	awsc.Lambda.PublishVersionError = awserr.New(lambda.ErrCodeResourceNotFoundException, "not found", nil)
+	return awsc
+}
+
+func Test_CreateConfig_Validate(t *testing.T) {
+	c := &CreateConfig{
+		StepRoleArn:   to.Strp("arn:aws:iam::000000000000:role/step/project/config/step-role"),
+		LambdaRoleArn: to.Strp("arn:aws:iam::000000000000:role/step/project/config/lambda-role"),
+	}
+	c.SetDefaults()
+	assert.NoError(t, c.Validate(to.Strp("project"), to.Strp("config")))
+	assert.Equal(t, "go1.x", *c.Runtime)
+	assert.Equal(t, "lambda", *c.Handler)
+
+	assert.Regexp(t, "Incorrect Create Role Path", c.Validate(to.Strp("project"), to.Strp("other")))
+
+	c.LambdaRoleArn = to.Strp("arn:aws:iam::000000000000:role/lambda-role")
+	assert.Regexp(t, "Incorrect Create Role Path, expecting /step/project/config/, got /", c.Validate(to.Strp("project"), to.Strp("config")))
+
+	c.StepRoleArn = nil
+	assert.Error(t, c.Validate(to.Strp("project"), to.Strp("config")))
+}
+
+func Test_DeployHandler_Execution_Create(t *testing.T) {
+	release, awsc := MockCreateRelease(false)
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+
+	assert.Equal(t, 1, len(awsc.SFN.CreateStateMachineInputs))
+	created := awsc.SFN.CreateStateMachineInputs[0]
+	assert.Equal(t, "stepfnname", *created.Name)
+	assert.Equal(t, *release.Create.StepRoleArn, *created.RoleArn)
+	assert.Equal(t, []*sfn.Tag{
+		{Key: to.Strp("ProjectName"), Value: to.Strp("project")},
+		{Key: to.Strp("ConfigName"), Value: to.Strp("development")},
+		{Key: to.Strp("DeployWith"), Value: to.Strp("step-deployer")},
+	}, created.Tags)
+
+	assert.Equal(t, 1, len(awsc.Lambda.CreateFunctionInputs))
+	fn := awsc.Lambda.CreateFunctionInputs[0]
+	assert.Equal(t, "lambdaname", *fn.FunctionName)
+	assert.Equal(t, *release.Create.LambdaRoleArn, *fn.Role)
+	assert.Equal(t, "go1.x", *fn.Runtime)
+	assert.Equal(t, "lambda", *fn.Handler)
+	assert.Equal(t, "lambda_zip", string(fn.Code.ZipFile))
+	assert.Equal(t, "step-deployer", *fn.Tags["DeployWith"])
+	assert.Equal(t, false, *fn.Publish)
+}
+
+func Test_DeployHandler_Execution_Create_TrafficShifting(t *testing.T) {
+	release, awsc := MockCreateRelease(true)
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+	assert.Equal(t, "1", exec.Output["lambda_version"])
+
+	assert.Equal(t, true, *awsc.Lambda.CreateFunctionInputs[0].Publish)
+	assert.Equal(t, 1, len(awsc.Lambda.CreateAliasInputs))
+	assert.Equal(t, "live", *awsc.Lambda.CreateAliasInputs[0].Name)
+	assert.Equal(t, "1", *awsc.Lambda.CreateAliasInputs[0].FunctionVersion)
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateAliasInputs))
+}
+
+func Test_DeployHandler_Execution_Create_Errors(t *testing.T) {
+	// Without Create missing resources are invalid
+	release, awsc := MockCreateRelease(false)
+	release.Create = nil
+	awsc = MockAwsClients(release)
+	awsc.SFN.DescribeStateMachineError = awserr.New(sfn.ErrCodeStateMachineDoesNotExist, "not found", nil)
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"Validate", "Lock", "ValidateResources", "ReleaseLockFailure", "FailureClean"}, exec.Path())
+
+	// Roles outside /step/{project}/{config}/ are invalid
+	release, _ = MockCreateRelease(false)
+	release.Create.StepRoleArn = to.Strp("arn:aws:iam::000000000000:role/admin")
+	awsc = MockAwsClients(release)
+	state_machine = createTestStateMachine(t, awsc)
+
+	exec, err = state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Regexp(t, "Incorrect Create Role Path", exec.LastOutputJSON)
+	assert.Equal(t, 0, len(awsc.SFN.CreateStateMachineInputs))
+}
@@ -28,6 +28,11 @@ type DeployLambdaError struct {
 	err error
 }
 
+// DeployNotReadyError is retried by Deploy, VerifyDeploy and RestoreSnapshot while the Lambdas are updating
+type DeployNotReadyError struct {
+	err error
+}
+
 func (e DeploySFNError) Error() string {
 	return fmt.Sprintf("DeploySFNError: %v", e.err.Error())
 }
@@ -36,12 +41,26 @@ func (e DeployLambdaError) Error() string {
 	return fmt.Sprintf("DeployLambdaError: %v", e.err.Error())
 }
 
+func (e DeployNotReadyError) Error() string {
+	return fmt.Sprintf("DeployNotReadyError: %v", e.err.Error())
+}
+
 ////////////
 // HANDLERS
 ////////////
 
 var assumed_role = to.Strp("coinbase-step-deployer-assumed")
 
+// checkHalt returns errors.HaltError if the release timed out or the halt flag is set,
+// it is called before every state that changes or checks the resources
+func checkHalt(awsc aws.AwsClients, release *Release) error {
+	if err := release.IsHalt(awsc.S3Client(release.AwsRegion, nil, nil)); err != nil {
+		return errors.HaltError{Cause: err.Error()}
+	}
+
+	return nil
+}
+
 func ValidateHandler(awsc aws.AwsClients) interface{} {
 	return func(ctx context.Context, release *Release) (*Release, error) {
 		// Override any attributes set by the client
@@ -62,6 +81,10 @@ func ValidateHandler(awsc aws.AwsClients) interface{} {
 
 func LockHandler(awsc aws.AwsClients) interface{} {
 	return func(ctx context.Context, release *Release) (*Release, error) {
+		if err := checkHalt(awsc, release); err != nil {
+			return nil, err
+		}
+
 		// returns LockExistsError, LockError
 		locker := dynamodb.NewDynamoDBLocker(awsc.DynamoDBClient(nil, nil, nil))
 		return release, release.GrabLocks(awsc.S3Client(release.AwsRegion, nil, nil), locker, getLockTableNameFromContext(ctx, "-locks"))
@@ -70,6 +93,10 @@ func LockHandler(awsc aws.AwsClients) interface{} {
 
 func ValidateResourcesHandler(awsc aws.AwsClients) interface{} {
 	return func(ctx context.Context, release *Release) (*Release, error) {
+		if err := checkHalt(awsc, release); err != nil {
+			return nil, err
+		}
+
 		// Validate the Resources for the release
 		if err := release.ValidateResources(awsc.LambdaClient(release.AwsRegion, release.AwsAccountID, assumed_role), awsc.SFNClient(release.AwsRegion, release.AwsAccountID, assumed_role)); err != nil {
 			return nil, errors.BadReleaseError{err.Error()}
@@ -79,26 +106,167 @@ func ValidateResourcesHandler(awsc aws.AwsClients) interface{} {
 	}
 }
 
+// SnapshotHandler records what is deployed before Deploy changes it
+func SnapshotHandler(awsc aws.AwsClients) interface{} {
+	return func(ctx context.Context, release *Release) (*Release, error) {
+		if err := checkHalt(awsc, release); err != nil {
+			return nil, err
+		}
+
+		err := release.Snapshot(
+			awsc.S3Client(release.AwsRegion, nil, nil),
+			awsc.LambdaClient(release.AwsRegion, release.AwsAccountID, assumed_role),
+			awsc.SFNClient(release.AwsRegion, release.AwsAccountID, assumed_role),
+		)
+
+		if err != nil {
+			return nil, errors.DeployError{Cause: err.Error()}
+		}
+
+		return release, nil
+	}
+}
+
 func DeployHandler(awsc aws.AwsClients) interface{} {
 	return func(ctx context.Context, release *Release) (*Release, error) {
+		if err := checkHalt(awsc, release); err != nil {
+			return nil, err
+		}
 
 		// Update Step Function first because State Machine if it fails we can recover
 		if err := release.DeployStepFunction(awsc.SFNClient(release.AwsRegion, release.AwsAccountID, assumed_role)); err != nil {
 			return nil, DeploySFNError{err}
 		}
 
-		if err := release.DeployLambda(awsc.LambdaClient(release.AwsRegion, release.AwsAccountID, assumed_role), awsc.S3Client(release.AwsRegion, nil, nil)); err != nil {
+		lambdac := awsc.LambdaClient(release.AwsRegion, release.AwsAccountID, assumed_role)
+		if err := release.DeployLambda(lambdac, awsc.S3Client(release.AwsRegion, nil, nil)); err != nil {
+			if _, ok := err.(DeployNotReadyError); ok {
+				return nil, err
+			}
 			return nil, DeployLambdaError{err}
 		}
 
-		release.Success = to.Boolp(true)
-		locker := dynamodb.NewDynamoDBLocker(awsc.DynamoDBClient(nil, nil, nil))
-		release.UnlockRoot(awsc.S3Client(release.AwsRegion, nil, nil), locker, getLockTableNameFromContext(ctx, "-locks"))
+		return release, nil
+	}
+}
+
+// VerifyDeployHandler returns DeployNotReadyError while the Lambdas are updating and errors.DeployError
+// if the deployed code or definitions are not the release's. Once verified it starts shifting traffic
+func VerifyDeployHandler(awsc aws.AwsClients) interface{} {
+	return func(ctx context.Context, release *Release) (*Release, error) {
+		if err := checkHalt(awsc, release); err != nil {
+			return nil, err
+		}
+
+		lambdac := awsc.LambdaClient(release.AwsRegion, release.AwsAccountID, assumed_role)
+
+		updating, err := release.VerifyDeploy(lambdac, awsc.SFNClient(release.AwsRegion, release.AwsAccountID, assumed_role))
+		if updating {
+			return nil, DeployNotReadyError{err}
+		}
+
+		if err != nil {
+			return nil, errors.DeployError{Cause: err.Error()}
+		}
+
+		if release.TrafficShifting != nil {
+			if err := release.StartTrafficShift(lambdac); err != nil {
+				return nil, DeployLambdaError{err}
+			}
+		}
+
+		return finishTrafficShift(ctx, awsc, release), nil
+	}
+}
+
+// ShiftTrafficHandler shifts the next step of traffic to the new Lambda version,
+// returning errors.HealthError if an alarm is in ALARM
+func ShiftTrafficHandler(awsc aws.AwsClients) interface{} {
+	return func(ctx context.Context, release *Release) (*Release, error) {
+		if release.TrafficShifting == nil {
+			return nil, errors.BadReleaseError{Cause: "TrafficShifting not defined"}
+		}
+
+		if err := checkHalt(awsc, release); err != nil {
+			return nil, err
+		}
+
+		unhealthy, err := release.ShiftTraffic(
+			awsc.LambdaClient(release.AwsRegion, release.AwsAccountID, assumed_role),
+			awsc.CloudWatchClient(release.AwsRegion, release.AwsAccountID, assumed_role),
+		)
+
+		if unhealthy {
+			return nil, errors.HealthError{Cause: err.Error()}
+		}
+
+		if err != nil {
+			return nil, DeployLambdaError{err}
+		}
+
+		return finishTrafficShift(ctx, awsc, release), nil
+	}
+}
+
+// RestoreSnapshotHandler deploys the Snapshot back after Deploy or VerifyDeploy failed
+func RestoreSnapshotHandler(awsc aws.AwsClients) interface{} {
+	return func(ctx context.Context, release *Release) (*Release, error) {
+		err := release.RestoreSnapshot(
+			awsc.S3Client(release.AwsRegion, nil, nil),
+			awsc.LambdaClient(release.AwsRegion, release.AwsAccountID, assumed_role),
+			awsc.SFNClient(release.AwsRegion, release.AwsAccountID, assumed_role),
+		)
+
+		if _, ok := err.(DeployNotReadyError); ok {
+			return nil, err
+		}
+
+		if err != nil {
+			return nil, errors.DeployError{Cause: err.Error()}
+		}
 
 		return release, nil
 	}
 }
 
+// RollbackHandler routes all traffic back to the previous Lambda version
+func RollbackHandler(awsc aws.AwsClients) interface{} {
+	return func(ctx context.Context, release *Release) (*Release, error) {
+		if release.TrafficShifting == nil {
+			return nil, errors.BadReleaseError{Cause: "TrafficShifting not defined"}
+		}
+
+		if err := release.RollbackTraffic(awsc.LambdaClient(release.AwsRegion, release.AwsAccountID, assumed_role)); err != nil {
+			return nil, errors.DeployError{Cause: err.Error()}
+		}
+
+		return release, nil
+	}
+}
+
+// finishTrafficShift sets Success, and releases the lock and prunes the snapshot once all traffic is on the new Lambda
+func finishTrafficShift(ctx context.Context, awsc aws.AwsClients, release *Release) *Release {
+	release.Success = to.Boolp(release.TrafficShifted())
+	if !*release.Success {
+		return release
+	}
+
+	locker := dynamodb.NewDynamoDBLocker(awsc.DynamoDBClient(nil, nil, nil))
+	release.UnlockRoot(awsc.S3Client(release.AwsRegion, nil, nil), locker, getLockTableNameFromContext(ctx, "-locks"))
+
+	// A snapshot version that is not deleted does not fail the release
+	err := release.PruneSnapshot(
+		awsc.S3Client(release.AwsRegion, nil, nil),
+		awsc.LambdaClient(release.AwsRegion, release.AwsAccountID, assumed_role),
+	)
+
+	if err != nil {
+		fmt.Println("Snapshot Not Pruned", err)
+	}
+
+	return release
+}
+
 func ReleaseLockFailureHandler(awsc aws.AwsClients) interface{} {
 	return func(ctx context.Context, release *Release) (*Release, error) {
 		locker := dynamodb.NewDynamoDBLocker(awsc.DynamoDBClient(nil, nil, nil))
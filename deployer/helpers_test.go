@@ -45,7 +45,8 @@ func MockAwsClients(r *Release) *mocks.MockClients {
 	}
 
 	awsc.SFN.DescribeStateMachineResp = &sfn.DescribeStateMachineOutput{
-		RoleArn: to.Strp(fmt.Sprintf("arn:aws:iam::000000000000:role/step/%v/%v/role-name", *r.ProjectName, *r.ConfigName)),
+		RoleArn:    to.Strp(fmt.Sprintf("arn:aws:iam::000000000000:role/step/%v/%v/role-name", *r.ProjectName, *r.ConfigName)),
+		Definition: to.Strp(machine.EmptyStateMachine),
 	}
 
 	lambda_zip_file_contents := "lambda_zip"
@@ -0,0 +1,55 @@
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/bifrost"
+	"github.com/coinbase/step/utils/is"
+)
+
+// History returns the status of each release of the project and config of r, newest first
+func (r *Release) History(s3c aws.S3API, sfnc aws.SFNAPI, deployer_arn *string) ([]*bifrost.ReleaseStatus, error) {
+	releases, err := r.ListReleases(s3c)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []*bifrost.ReleaseStatus{}
+	for _, release := range releases {
+		status, err := release.Status(sfnc, deployer_arn)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, status)
+	}
+
+	return history, nil
+}
+
+// ShowRelease returns the status of the release with release_id, or the latest release, including the whole release
+func (r *Release) ShowRelease(s3c aws.S3API, sfnc aws.SFNAPI, deployer_arn *string, release_id *string) (*bifrost.ReleaseStatus, error) {
+	releases, err := r.ListReleases(s3c)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, release := range releases {
+		if !is.EmptyStr(release_id) && *release.ReleaseID != *release_id {
+			continue
+		}
+
+		status, err := release.Status(sfnc, deployer_arn)
+		if err != nil {
+			return nil, err
+		}
+
+		status.Release = release
+		return status, nil
+	}
+
+	if is.EmptyStr(release_id) {
+		return nil, fmt.Errorf("No releases found in %v", *r.RootDir())
+	}
+	return nil, fmt.Errorf("Release %v not found", *release_id)
+}
@@ -0,0 +1,52 @@
+package deployer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Release_History_ShowRelease(t *testing.T) {
+	old := MockRelease()
+	old.CreatedAt = to.Timep(time.Now().Add(-time.Hour))
+	awsc := MockAwsClients(old)
+
+	latest := MockRelease()
+	latest.ReleaseID = to.Strp("release-2")
+	assert.NoError(t, s3.PutStruct(awsc.S3, latest.Bucket, latest.ReleasePath(), latest))
+
+	deployer_arn := to.Strp("arn:aws:states:us-east-1:00000000:stateMachine:coinbase-step-deployer")
+	awsc.SFN.DescribeExecutionResps = map[string]*sfn.DescribeExecutionOutput{
+		*latest.ExecutionArn(deployer_arn): {
+			ExecutionArn: latest.ExecutionArn(deployer_arn),
+			Status:       to.Strp(sfn.ExecutionStatusRunning),
+		},
+	}
+
+	history, err := latest.History(awsc.S3, awsc.SFN, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, "release-2", *history[0].ReleaseID)
+	assert.Equal(t, sfn.ExecutionStatusRunning, *history[0].Status)
+	assert.Nil(t, history[0].Success)
+	assert.Equal(t, "release-1", *history[1].ReleaseID)
+	assert.Equal(t, true, *history[1].Success)
+	assert.Equal(t, "User@user.com", history[1].Metadata["User"])
+	assert.Nil(t, history[1].Release)
+
+	status, err := latest.ShowRelease(awsc.S3, awsc.SFN, deployer_arn, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "release-2", *status.ReleaseID)
+	assert.Equal(t, "lambdaname", *status.Release.(*Release).LambdaName)
+
+	status, err = latest.ShowRelease(awsc.S3, awsc.SFN, deployer_arn, to.Strp("release-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "release-1", *status.ReleaseID)
+
+	_, err = latest.ShowRelease(awsc.S3, awsc.SFN, deployer_arn, to.Strp("release-9"))
+	assert.Error(t, err)
+}
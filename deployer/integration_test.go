@@ -1,12 +1,14 @@
 package deployer
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/errors"
 	"github.com/coinbase/step/utils/to"
 	"github.com/stretchr/testify/assert"
 )
@@ -32,7 +34,10 @@ func Test_DeployHandler_Execution_Works(t *testing.T) {
 		"Validate",
 		"Lock",
 		"ValidateResources",
+		"Snapshot",
 		"Deploy",
+		"VerifyDeploy",
+		"TrafficShifted",
 		"Success",
 	}, exec.Path())
 
@@ -396,7 +401,9 @@ func Test_DeployHandler_Execution_Errors_DeploySFNError(t *testing.T) {
 		"Validate",
 		"Lock",
 		"ValidateResources",
+		"Snapshot",
 		"Deploy",
+		"RestoreSnapshot",
 		"ReleaseLockFailure",
 		"FailureClean",
 	}, exec.Path())
@@ -419,7 +426,66 @@ func Test_DeployHandler_Execution_Errors_DeployLambdaError(t *testing.T) {
 		"Validate",
 		"Lock",
 		"ValidateResources",
+		"Snapshot",
 		"Deploy",
-		"FailureDirty",
+		"RestoreSnapshot",
+		"ReleaseLockFailure",
+		"FailureClean",
+	}, exec.Path())
+}
+
+func Test_DeployHandler_Execution_Errors_Halt(t *testing.T) {
+	release := MockRelease()
+	awsc := MockAwsClients(release)
+	awsc.S3.AddGetObject(*release.HaltPath(), "stop the deploy", nil)
+
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+
+	assert.Error(t, err)
+	assert.Regexp(t, "HaltError", exec.LastOutputJSON)
+	assert.Regexp(t, "stop the deploy", exec.LastOutputJSON)
+
+	assert.Equal(t, []string{
+		"Validate",
+		"Lock",
+		"FailureClean",
 	}, exec.Path())
+
+	assert.Equal(t, 0, len(awsc.DynamoDB.PutItemInputs))
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionCodeInputs))
+}
+
+func Test_DeployHandler_Execution_Errors_Timeout(t *testing.T) {
+	release := MockRelease()
+	release.Timeout = to.Intp(0)
+	awsc := MockAwsClients(release)
+
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+
+	assert.Error(t, err)
+	assert.Regexp(t, "HaltError", exec.LastOutputJSON)
+	assert.Regexp(t, "Timeout", exec.LastOutputJSON)
+	assert.Equal(t, "FailureClean", exec.Path()[len(exec.Path())-1])
+}
+
+func Test_Handlers_Halt(t *testing.T) {
+	for _, name := range []string{"Lock", "ValidateResources", "Snapshot", "Deploy", "VerifyDeploy", "ShiftTraffic"} {
+		release, awsc := MockTrafficShiftingRelease(CanaryShift, nil)
+		release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+		assert.NoError(t, release.Halt(awsc.S3, to.Strp("halted")))
+
+		fn := (*CreateTaskFunctions(awsc))[name].(func(context.Context, *Release) (*Release, error))
+
+		_, err := fn(context.Background(), release)
+		assert.IsType(t, errors.HaltError{}, err, name)
+		assert.Regexp(t, "halted", err, name)
+
+		assert.Equal(t, 0, len(awsc.SFN.UpdateStateMachineInputs), name)
+		assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionCodeInputs), name)
+		assert.Equal(t, 0, len(awsc.Lambda.UpdateAliasInputs), name)
+	}
 }
@@ -33,8 +33,8 @@ func StateMachine() (*machine.StateMachine, error) {
         "Next": "ValidateResources",
         "Catch": [
           {
-            "Comment": "Something else is deploying",
-            "ErrorEquals": ["LockExistsError"],
+            "Comment": "Something else is deploying, or Halted before the Lock was grabbed",
+            "ErrorEquals": ["LockExistsError", "HaltError"],
             "ResultPath": "$.error",
             "Next": "FailureClean"
           },
@@ -50,7 +50,7 @@ func StateMachine() (*machine.StateMachine, error) {
         "Type": "TaskFn",
         "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
         "Comment": "ValidateResources",
-        "Next": "Deploy",
+        "Next": "Snapshot",
         "Catch": [
           {
             "Comment": "Try Release Lock Then Fail",
@@ -60,26 +60,133 @@ func StateMachine() (*machine.StateMachine, error) {
           }
         ]
       },
+      "Snapshot": {
+        "Type": "TaskFn",
+        "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+        "Comment": "Record the State Machine definitions and Lambda versions before changing them",
+        "Next": "Deploy",
+        "Catch": [
+          {
+            "Comment": "Nothing changed, Release Lock and Fail",
+            "ErrorEquals": ["States.ALL"],
+            "ResultPath": "$.error",
+            "Next": "ReleaseLockFailure"
+          }
+        ]
+      },
       "Deploy": {
         "Type": "TaskFn",
         "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
         "Comment": "Upload Step-Function and Lambda",
-        "Next": "Success",
+        "Next": "VerifyDeploy",
+        "Retry": [ {
+          "Comment": "Lambda configurations still updating",
+          "ErrorEquals": ["DeployNotReadyError"],
+          "MaxAttempts": 60,
+          "IntervalSeconds": 5,
+          "BackoffRate": 1.0
+        }],
         "Catch": [
           {
-            "Comment": "Unsure of State, Leave Lock and Fail",
-            "ErrorEquals": ["DeploySFNError"],
+            "Comment": "A retried Deploy may have changed resources, Restore the Snapshot, Release Lock and Fail",
+            "ErrorEquals": ["States.ALL"],
             "ResultPath": "$.error",
-            "Next": "ReleaseLockFailure"
-          },
+            "Next": "RestoreSnapshot"
+          }
+        ]
+      },
+      "VerifyDeploy": {
+        "Type": "TaskFn",
+        "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+        "Comment": "Wait for the Lambdas to Update, Verify the deployed Code and Definitions, then Shift Traffic",
+        "Next": "TrafficShifted",
+        "Retry": [ {
+          "Comment": "Lambdas still updating",
+          "ErrorEquals": ["DeployNotReadyError"],
+          "MaxAttempts": 60,
+          "IntervalSeconds": 5,
+          "BackoffRate": 1.0
+        }],
+        "Catch": [
+          {
+            "Comment": "Deployed Resources do not match the Release, Restore the Snapshot, Release Lock and Fail",
+            "ErrorEquals": ["States.ALL"],
+            "ResultPath": "$.error",
+            "Next": "RestoreSnapshot"
+          }
+        ]
+      },
+      "RestoreSnapshot": {
+        "Type": "TaskFn",
+        "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+        "Comment": "Deploy the Snapshot State Machine definitions and Lambda versions back",
+        "Next": "ReleaseLockFailure",
+        "Retry": [ {
+          "Comment": "Lambdas still updating",
+          "ErrorEquals": ["DeployNotReadyError"],
+          "MaxAttempts": 30,
+          "IntervalSeconds": 5,
+          "BackoffRate": 1.0
+        }, {
+          "Comment": "Keep trying to Restore",
+          "ErrorEquals": ["States.ALL"],
+          "MaxAttempts": 3,
+          "IntervalSeconds": 30
+        }],
+        "Catch": [{
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "FailureDirty"
+        }]
+      },
+      "TrafficShifted": {
+        "Type": "Choice",
+        "Comment": "Success is false until all traffic is on the new Lambda version",
+        "Choices": [
+          {
+            "Variable": "$.success",
+            "BooleanEquals": true,
+            "Next": "Success"
+          }
+        ],
+        "Default": "WaitForTraffic"
+      },
+      "WaitForTraffic": {
+        "Type": "Wait",
+        "SecondsPath": "$.traffic_shifting.interval_seconds",
+        "Next": "ShiftTraffic"
+      },
+      "ShiftTraffic": {
+        "Type": "TaskFn",
+        "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+        "Comment": "Check Alarms and Shift more Traffic to the new Lambda version",
+        "Next": "TrafficShifted",
+        "Catch": [
           {
-            "Comment": "Unsure of State, Leave Lock and Fail",
+            "Comment": "Unhealthy or Unsure of State, Roll Back",
             "ErrorEquals": ["States.ALL"],
             "ResultPath": "$.error",
-            "Next": "FailureDirty"
+            "Next": "Rollback"
           }
         ]
       },
+      "Rollback": {
+        "Type": "TaskFn",
+        "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+        "Comment": "Route all Traffic back to the previous Lambda version, then Restore the Snapshot",
+        "Next": "RestoreSnapshot",
+        "Retry": [ {
+          "Comment": "Keep trying to Roll Back",
+          "ErrorEquals": ["States.ALL"],
+          "MaxAttempts": 3,
+          "IntervalSeconds": 30
+        }],
+        "Catch": [{
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "FailureDirty"
+        }]
+      },
       "ReleaseLockFailure": {
         "Type": "TaskFn",
         "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
@@ -125,7 +232,12 @@ func CreateTaskFunctions(awsc aws.AwsClients) *handler.TaskHandlers {
 	tm["Validate"] = ValidateHandler(awsc)
 	tm["Lock"] = LockHandler(awsc)
 	tm["ValidateResources"] = ValidateResourcesHandler(awsc)
+	tm["Snapshot"] = SnapshotHandler(awsc)
 	tm["Deploy"] = DeployHandler(awsc)
+	tm["VerifyDeploy"] = VerifyDeployHandler(awsc)
+	tm["ShiftTraffic"] = ShiftTrafficHandler(awsc)
+	tm["RestoreSnapshot"] = RestoreSnapshotHandler(awsc)
+	tm["Rollback"] = RollbackHandler(awsc)
 	tm["ReleaseLockFailure"] = ReleaseLockFailureHandler(awsc)
 	return &tm
 }
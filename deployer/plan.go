@@ -0,0 +1,263 @@
+package deployer
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Plan is what a deploy of the release would change
+type Plan struct {
+	StateMachines    []*StateMachinePlan `json:"state_machines"`
+	Lambdas          []*LambdaPlan       `json:"lambdas"`
+	ValidationErrors []string            `json:"validation_errors,omitempty"`
+}
+
+// StateMachinePlan is the change to a State Machine
+type StateMachinePlan struct {
+	StepArn *string  `json:"step_arn,omitempty"`
+	Changed bool     `json:"changed"`
+	Created bool     `json:"created,omitempty"`
+	Diff    []string `json:"diff,omitempty"`
+}
+
+// LambdaPlan is the change to the code and configuration of a Lambda
+type LambdaPlan struct {
+	LambdaArn     *string                       `json:"lambda_arn,omitempty"`
+	Lambda        *lambda.FunctionConfiguration `json:"lambda,omitempty"` // deployed config, of the alias with TrafficShifting
+	CodeChanged   bool                          `json:"code_changed"`
+	ConfigChanged bool                          `json:"config_changed"`
+	Created       bool                          `json:"created,omitempty"`
+	CurrentSHA256 *string                       `json:"current_sha256,omitempty"`
+	SHA256        *string                       `json:"sha256,omitempty"`
+	ConfigDiff    []string                      `json:"config_diff,omitempty"`
+}
+
+// Plan compares the release to the deployed State Machines and Lambdas without changing them
+func (r *Release) Plan(lambdac aws.LambdaAPI, sfnc aws.SFNAPI) (*Plan, error) {
+	plan := &Plan{StateMachines: []*StateMachinePlan{}, Lambdas: []*LambdaPlan{}}
+
+	for _, sm := range r.StateMachineArtifacts() {
+		sm_plan, err := r.planStepFunction(sfnc, sm)
+		if err != nil {
+			return nil, err
+		}
+		plan.StateMachines = append(plan.StateMachines, sm_plan)
+	}
+
+	for _, l := range r.LambdaArtifacts() {
+		lambda_plan, err := r.planLambda(lambdac, l)
+		if err != nil {
+			return nil, err
+		}
+		plan.Lambdas = append(plan.Lambdas, lambda_plan)
+	}
+
+	return plan, nil
+}
+
+func (r *Release) planStepFunction(sfnc aws.SFNAPI, artifact *StateMachineArtifact) (*StateMachinePlan, error) {
+	plan := &StateMachinePlan{StepArn: r.stepArn(artifact.Name)}
+
+	sm, err := sfnc.DescribeStateMachine(&sfn.DescribeStateMachineInput{StateMachineArn: plan.StepArn})
+	if r.missingResource(err) {
+		plan.Changed = true
+		plan.Created = true
+		return plan, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Diff, err = diffJSONStr(sm.Definition, artifact.StateMachineJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Changed = len(plan.Diff) > 0
+	return plan, nil
+}
+
+func (r *Release) planLambda(lambdac aws.LambdaAPI, l *LambdaArtifact) (*LambdaPlan, error) {
+	plan := &LambdaPlan{LambdaArn: r.lambdaArn(l.Name), SHA256: l.SHA256}
+
+	input := &lambda.GetFunctionInput{FunctionName: plan.LambdaArn}
+	if r.TrafficShifting != nil && r.isMainLambda(l) {
+		input.Qualifier = r.TrafficShifting.Alias
+	}
+
+	fn, err := lambdac.GetFunction(input)
+	if r.missingResource(err) {
+		plan.CodeChanged = true
+		plan.Created = true
+		return plan, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if fn == nil || fn.Configuration == nil {
+		return nil, fmt.Errorf("Unknown Lambda Function Error")
+	}
+
+	plan.Lambda = fn.Configuration
+	plan.CurrentSHA256 = hexSHA256(fn.Configuration.CodeSha256)
+	plan.CodeChanged = to.Strs(plan.CurrentSHA256) != to.Strs(l.SHA256)
+
+	if l.Config != nil {
+		plan.ConfigDiff = l.Config.Diff(fn.Configuration, fn.Tags)
+		plan.ConfigChanged = len(plan.ConfigDiff) > 0
+	}
+
+	return plan, nil
+}
+
+// String is the plan for people to read
+func (p *Plan) String() string {
+	lines := []string{}
+
+	for _, sm := range p.StateMachines {
+		switch {
+		case sm.Created:
+			lines = append(lines, fmt.Sprintf("State Machine %v will be created", to.Strs(sm.StepArn)))
+		case sm.Changed:
+			lines = append(lines, fmt.Sprintf("State Machine %v changes:", to.Strs(sm.StepArn)))
+			for _, diff := range sm.Diff {
+				lines = append(lines, "  "+diff)
+			}
+		default:
+			lines = append(lines, fmt.Sprintf("State Machine %v unchanged", to.Strs(sm.StepArn)))
+		}
+	}
+
+	for _, l := range p.Lambdas {
+		switch {
+		case l.Created:
+			lines = append(lines, fmt.Sprintf("Lambda %v will be created", to.Strs(l.LambdaArn)))
+		case l.CodeChanged:
+			lines = append(lines, fmt.Sprintf("Lambda %v code changes: %v => %v", to.Strs(l.LambdaArn), to.Strs(l.CurrentSHA256), to.Strs(l.SHA256)))
+		default:
+			lines = append(lines, fmt.Sprintf("Lambda %v code unchanged", to.Strs(l.LambdaArn)))
+		}
+
+		if l.ConfigChanged {
+			lines = append(lines, fmt.Sprintf("Lambda %v config changes:", to.Strs(l.LambdaArn)))
+			for _, diff := range l.ConfigDiff {
+				lines = append(lines, "  "+diff)
+			}
+		}
+	}
+
+	if len(p.ValidationErrors) == 0 {
+		lines = append(lines, "Validation passed")
+	}
+	for _, err := range p.ValidationErrors {
+		lines = append(lines, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// hexSHA256 converts the base64 CodeSha256 of Lambda to the hex of LambdaSHA256
+func hexSHA256(code_sha *string) *string {
+	if code_sha == nil {
+		return nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(*code_sha)
+	if err != nil {
+		return code_sha
+	}
+
+	return to.Strp(hex.EncodeToString(raw))
+}
+
+func diffJSONStr(current *string, next *string) ([]string, error) {
+	var a, b interface{}
+	if current != nil {
+		if err := json.Unmarshal([]byte(*current), &a); err != nil {
+			return nil, err
+		}
+	}
+
+	if next != nil {
+		if err := json.Unmarshal([]byte(*next), &b); err != nil {
+			return nil, err
+		}
+	}
+
+	return diffJSON("$", a, b), nil
+}
+
+// diffJSON returns where b differs from a as lines of
+// "+ path: value" for added, "- path: value" for removed and "~ path: old => new" for changed values
+func diffJSON(path string, a interface{}, b interface{}) []string {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := []string{}
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		diffs := []string{}
+		for _, k := range keys {
+			child := fmt.Sprintf("%v.%v", path, k)
+			ae, aok := av[k]
+			be, bok := bv[k]
+
+			switch {
+			case !bok:
+				diffs = append(diffs, fmt.Sprintf("- %v: %v", child, jsonStr(ae)))
+			case !aok:
+				diffs = append(diffs, fmt.Sprintf("+ %v: %v", child, jsonStr(be)))
+			default:
+				diffs = append(diffs, diffJSON(child, ae, be)...)
+			}
+		}
+		return diffs
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			break
+		}
+
+		diffs := []string{}
+		for i := range av {
+			diffs = append(diffs, diffJSON(fmt.Sprintf("%v[%v]", path, i), av[i], bv[i])...)
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return []string{}
+	}
+
+	return []string{fmt.Sprintf("~ %v: %v => %v", path, jsonStr(a), jsonStr(b))}
+}
+
+func jsonStr(v interface{}) string {
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
@@ -0,0 +1,81 @@
+package deployer
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_diffJSON(t *testing.T) {
+	diffs, err := diffJSONStr(
+		to.Strp(`{"StartAt": "A", "States": {"A": {"Type": "Pass", "Next": "B"}, "B": {"Type": "Succeed"}, "C": {"Type": "Fail", "Error": "E"}}}`),
+		to.Strp(`{"StartAt":"A","States":{"A":{"Next":"D","Type":"Pass"},"B":{"Type":"Succeed"},"D":{"Type":"Succeed"}}}`),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`~ $.States.A.Next: "B" => "D"`,
+		`- $.States.C: {"Error":"E","Type":"Fail"}`,
+		`+ $.States.D: {"Type":"Succeed"}`,
+	}, diffs)
+
+	assert.Equal(t, []string{`~ $.Catch[1]: "b" => "c"`}, diffJSON("$", map[string]interface{}{"Catch": []interface{}{"a", "b"}}, map[string]interface{}{"Catch": []interface{}{"a", "c"}}))
+	assert.Equal(t, []string{`~ $.Catch: ["a"] => ["a","c"]`}, diffJSON("$", map[string]interface{}{"Catch": []interface{}{"a"}}, map[string]interface{}{"Catch": []interface{}{"a", "c"}}))
+
+	// Formatting is not a change
+	diffs, err = diffJSONStr(to.Strp(`{"a": 1}`), to.Strp("{\n  \"a\": 1\n}"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, diffs)
+}
+
+func Test_Release_Plan(t *testing.T) {
+	release := MockRelease()
+	awsc := MockAwsClients(release)
+
+	raw, _ := hex.DecodeString(*release.LambdaSHA256)
+	awsc.SFN.DescribeStateMachineResp.Definition = release.StateMachineJSON
+	awsc.Lambda.GetFunctionResp = &lambda.GetFunctionOutput{Configuration: &lambda.FunctionConfiguration{
+		CodeSha256: to.Strp(base64.StdEncoding.EncodeToString(raw)),
+	}}
+
+	plan, err := release.Plan(awsc.Lambda, awsc.SFN)
+	assert.NoError(t, err)
+	assert.False(t, plan.StateMachines[0].Changed)
+	assert.False(t, plan.Lambdas[0].CodeChanged)
+	assert.Equal(t, *release.LambdaSHA256, *plan.Lambdas[0].CurrentSHA256)
+
+	awsc.SFN.DescribeStateMachineResp.Definition = to.Strp(`{"StartAt": "Old", "States": {"Old": {"Type": "Succeed"}}}`)
+	awsc.Lambda.GetFunctionResp.Configuration.CodeSha256 = to.Strp(base64.StdEncoding.EncodeToString([]byte("old")))
+
+	plan, err = release.Plan(awsc.Lambda, awsc.SFN)
+	assert.NoError(t, err)
+	assert.True(t, plan.StateMachines[0].Changed)
+	assert.True(t, plan.Lambdas[0].CodeChanged)
+	assert.Contains(t, plan.String(), `~ $.StartAt: "Old" => "WIN"`)
+	assert.Contains(t, plan.String(), "code changes")
+}
+
+func Test_Release_Plan_LambdaConfig(t *testing.T) {
+	release := MockRelease()
+	release.LambdaConfig = &LambdaConfig{Timeout: to.Int64p(60), Tags: map[string]*string{"Team": to.Strp("payments")}}
+	awsc := MockAwsClients(release)
+	awsc.Lambda.GetFunctionResp = &lambda.GetFunctionOutput{
+		Configuration: &lambda.FunctionConfiguration{Timeout: to.Int64p(3)},
+		Tags:          map[string]*string{"Team": to.Strp("payments")},
+	}
+
+	plan, err := release.Plan(awsc.Lambda, awsc.SFN)
+	assert.NoError(t, err)
+	assert.True(t, plan.Lambdas[0].ConfigChanged)
+	assert.Equal(t, []string{"~ $.timeout: 3 => 60"}, plan.Lambdas[0].ConfigDiff)
+	assert.Contains(t, plan.String(), "config changes:\n  ~ $.timeout: 3 => 60")
+
+	awsc.Lambda.GetFunctionResp.Configuration.Timeout = to.Int64p(60)
+	plan, err = release.Plan(awsc.Lambda, awsc.SFN)
+	assert.NoError(t, err)
+	assert.False(t, plan.Lambdas[0].ConfigChanged)
+	assert.NotContains(t, plan.String(), "config changes")
+}
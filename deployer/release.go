@@ -2,6 +2,7 @@ package deployer
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/sfn"
@@ -23,6 +24,47 @@ type Release struct {
 	StepFnName   *string `json:"step_fn_name,omitempty"`  // Step Function Name
 
 	StateMachineJSON *string `json:"state_machine_json,omitempty"`
+
+	// Configuration applied to the Lambda of LambdaName before its code
+	LambdaConfig *LambdaConfig `json:"lambda_config,omitempty"`
+
+	// More Lambdas and State Machines, e.g. Lambdas called by the State Machine and child State Machines
+	Lambdas       []*LambdaArtifact       `json:"lambdas,omitempty"`
+	StateMachines []*StateMachineArtifact `json:"state_machines,omitempty"`
+
+	// Traffic Shifting, without it the Lambda code is updated in place
+	TrafficShifting *TrafficShifting `json:"traffic_shifting,omitempty"`
+	LambdaVersion   *string          `json:"lambda_version,omitempty"`   // Version published by Deploy
+	PreviousVersion *string          `json:"previous_version,omitempty"` // Version of the alias before Deploy
+	TrafficPercent  int              `json:"traffic_percent,omitempty"`  // of the alias routed to LambdaVersion
+
+	// Create the State Machine and Lambda if they do not exist
+	Create *CreateConfig `json:"create,omitempty"`
+}
+
+// WipeControlledValues also wipes the Lambda versions and traffic set by the deployer
+func (r *Release) WipeControlledValues() {
+	r.Release.WipeControlledValues()
+	r.LambdaVersion = nil
+	r.PreviousVersion = nil
+	r.TrafficPercent = 0
+}
+
+// SetDefaults also sets the defaults of TrafficShifting,
+// the default Timeout is extended by how long shifting traffic waits
+func (r *Release) SetDefaults(region *string, account *string, bucket_prefix string) {
+	default_timeout := r.Timeout == nil
+	r.Release.SetDefaults(region, account, bucket_prefix)
+	if r.TrafficShifting != nil {
+		r.TrafficShifting.SetDefaults()
+		if default_timeout {
+			r.Timeout = to.Intp(*r.Timeout + r.TrafficShifting.duration())
+		}
+	}
+
+	if r.Create != nil {
+		r.Create.SetDefaults()
+	}
 }
 
 //////////
@@ -34,6 +76,27 @@ func (r *Release) Validate(s3c aws.S3API) error {
 		return err
 	}
 
+	if err := r.validateAttributes(); err != nil {
+		return err
+	}
+
+	if err := r.ValidateLambdaSHA(s3c); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateAttributes are the checks of Validate that do not need the release and Lambda zip uploaded to S3
+func (r *Release) ValidateAttributes() error {
+	if err := r.Release.ValidateAttributes(); err != nil {
+		return err
+	}
+
+	return r.validateAttributes()
+}
+
+func (r *Release) validateAttributes() error {
 	if is.EmptyStr(r.LambdaName) {
 		return fmt.Errorf("LambdaName must be defined")
 	}
@@ -55,16 +118,61 @@ func (r *Release) Validate(s3c aws.S3API) error {
 		return fmt.Errorf("StateMachineJSON invalid with '%v'", err.Error())
 	}
 
-	if err := r.deployLambdaInput(to.ABytep([]byte{})).Validate(); err != nil {
+	if err := r.validateArtifacts(); err != nil {
 		return err
 	}
 
-	if err := r.deployStepFunctionInput().Validate(); err != nil {
-		return err
+	if r.TrafficShifting != nil {
+		if err := r.TrafficShifting.Validate(); err != nil {
+			return err
+		}
+
+		// A shorter Timeout halts every release into Rollback
+		if duration := r.TrafficShifting.duration(); r.Timeout != nil && *r.Timeout <= duration {
+			return fmt.Errorf("Timeout %v must be longer than the %v seconds TrafficShifting waits", *r.Timeout, duration)
+		}
 	}
 
-	if err := r.ValidateLambdaSHA(s3c); err != nil {
-		return err
+	if r.Create != nil {
+		if err := r.Create.Validate(r.ProjectName, r.ConfigName); err != nil {
+			return err
+		}
+
+		for _, l := range r.LambdaArtifacts() {
+			if err := r.createLambdaInput(l, to.ABytep([]byte{})).Validate(); err != nil {
+				return err
+			}
+		}
+
+		for _, sm := range r.StateMachineArtifacts() {
+			if err := r.createStepFunctionInput(sm).Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, l := range r.LambdaArtifacts() {
+		if err := r.deployLambdaInput(l, to.ABytep([]byte{})).Validate(); err != nil {
+			return err
+		}
+
+		if l.Config == nil {
+			continue
+		}
+
+		if err := l.Config.Validate(); err != nil {
+			return fmt.Errorf("%v Config invalid with '%v'", r.lambdaLabel(l), err.Error())
+		}
+
+		if err := l.Config.updateInput(r.lambdaArn(l.Name)).Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, sm := range r.StateMachineArtifacts() {
+		if err := r.deployStepFunctionInput(sm).Validate(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -84,33 +192,63 @@ func (r *Release) ValidateResources(lambdac aws.LambdaAPI, sfnc aws.SFNAPI) erro
 	return nil
 }
 
+// ValidateLambdaFunctionTags of every Lambda, a Lambda that does not exist is valid if the release creates it
 func (r *Release) ValidateLambdaFunctionTags(lambdac aws.LambdaAPI) error {
-	project, config, deployer, err := r.LambdaProjectConfigDeployerTags(lambdac)
+	for _, l := range r.LambdaArtifacts() {
+		if err := r.validateLambdaFunctionTags(lambdac, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Release) validateLambdaFunctionTags(lambdac aws.LambdaAPI, l *LambdaArtifact) error {
+	project, config, deployer, err := r.lambdaProjectConfigDeployerTags(lambdac, r.lambdaArn(l.Name))
+	if r.missingResource(err) {
+		return nil
+	}
+
 	if err != nil {
 		return err
 	}
 
 	if project == nil || config == nil || deployer == nil {
-		return fmt.Errorf("ProjectName, ConfigName and or DeployWith tag on lambda is nil")
+		return fmt.Errorf("ProjectName, ConfigName and or DeployWith tag on %v is nil", strings.ToLower(r.lambdaLabel(l)))
 	}
 
 	if *r.ProjectName != *project {
-		return fmt.Errorf("Lambda ProjectName tag incorrect, expecting %v has %v", *r.ProjectName, *project)
+		return fmt.Errorf("%v ProjectName tag incorrect, expecting %v has %v", r.lambdaLabel(l), *r.ProjectName, *project)
 	}
 
 	if *r.ConfigName != *config {
-		return fmt.Errorf("Lambda ConfigName tag incorrect, expecting %v has %v", *r.ConfigName, *config)
+		return fmt.Errorf("%v ConfigName tag incorrect, expecting %v has %v", r.lambdaLabel(l), *r.ConfigName, *config)
 	}
 
 	if "step-deployer" != *deployer {
-		return fmt.Errorf("Lambda DeployWith tag incorrect, expecting step-deployer has %v", *deployer)
+		return fmt.Errorf("%v DeployWith tag incorrect, expecting step-deployer has %v", r.lambdaLabel(l), *deployer)
 	}
 
 	return nil
 }
 
+// ValidateStepFunctionPath of every State Machine, a State Machine that does not exist is valid
+// if the release creates it as its role path is checked by Validate
 func (r *Release) ValidateStepFunctionPath(sfnc aws.SFNAPI) error {
-	out, err := sfnc.DescribeStateMachine(&sfn.DescribeStateMachineInput{StateMachineArn: r.StepArn()})
+	for _, sm := range r.StateMachineArtifacts() {
+		if err := r.validateStepFunctionPath(sfnc, sm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Release) validateStepFunctionPath(sfnc aws.SFNAPI, sm *StateMachineArtifact) error {
+	out, err := sfnc.DescribeStateMachine(&sfn.DescribeStateMachineInput{StateMachineArn: r.stepArn(sm.Name)})
+	if r.missingResource(err) {
+		return nil
+	}
 
 	if err != nil {
 		return err
@@ -124,28 +262,35 @@ func (r *Release) ValidateStepFunctionPath(sfnc aws.SFNAPI) error {
 
 	expected := fmt.Sprintf("/step/%v/%v/", *r.ProjectName, *r.ConfigName)
 	if path != expected {
-		return fmt.Errorf("Incorrect Step Function Role Path, expecting %v, got %v", expected, path)
+		return fmt.Errorf("Incorrect %v Role Path, expecting %v, got %v", r.stateMachineLabel(sm), expected, path)
 	}
 
 	return nil
 }
 
+// ValidateLambdaSHA checks the SHA256 of the zip of every Lambda
 func (r *Release) ValidateLambdaSHA(s3c aws.S3API) error {
-	sha, err := s3.GetSHA256(s3c, r.Bucket, r.LambdaZipPath())
-	if err != nil {
-		return err
-	}
-
-	if sha != *r.LambdaSHA256 {
-		return fmt.Errorf("Lambda SHA mismatch, expecting %v, got %v", *r.LambdaSHA256, sha)
+	for _, l := range r.LambdaArtifacts() {
+		sha, err := s3.GetSHA256(s3c, r.Bucket, r.LambdaArtifactZipPath(l))
+		if err != nil {
+			return err
+		}
+
+		if sha != *l.SHA256 {
+			return fmt.Errorf("%v SHA mismatch, expecting %v, got %v", r.lambdaLabel(l), *l.SHA256, sha)
+		}
 	}
 
 	return nil
 }
 
 func (r *Release) LambdaProjectConfigDeployerTags(lambdac aws.LambdaAPI) (*string, *string, *string, error) {
+	return r.lambdaProjectConfigDeployerTags(lambdac, r.LambdaArn())
+}
+
+func (r *Release) lambdaProjectConfigDeployerTags(lambdac aws.LambdaAPI, arn *string) (*string, *string, *string, error) {
 	out, err := lambdac.ListTags(&lambda.ListTagsInput{
-		Resource: r.LambdaArn(),
+		Resource: arn,
 	})
 
 	if err != nil {
@@ -163,48 +308,121 @@ func (r *Release) LambdaProjectConfigDeployerTags(lambdac aws.LambdaAPI) (*strin
 // AWS Methods
 //////////
 
-func (release *Release) deployLambdaInput(zip *[]byte) *lambda.UpdateFunctionCodeInput {
+// publish is true for the Lambda of LambdaName with TrafficShifting
+func (release *Release) publish(l *LambdaArtifact) bool {
+	return release.TrafficShifting != nil && release.isMainLambda(l)
+}
+
+func (release *Release) deployLambdaInput(l *LambdaArtifact, zip *[]byte) *lambda.UpdateFunctionCodeInput {
 	return &lambda.UpdateFunctionCodeInput{
-		FunctionName: release.LambdaArn(),
-		ZipFile:      *zip,
+		FunctionName:  release.lambdaArn(l.Name),
+		ZipFile:       *zip,
+		Publish:       to.Boolp(release.publish(l)),
+		Architectures: l.Config.architectures(),
 	}
 }
 
-// DeployLambdaCode
+// DeployLambdaCode deploys the code and LambdaConfig of the Lambda of LambdaName
 func (release *Release) DeployLambdaCode(lambdaClient aws.LambdaAPI, zip *[]byte) error {
-	_, err := lambdaClient.UpdateFunctionCode(release.deployLambdaInput(zip))
-	return err
+	return release.DeployLambdaArtifactCode(lambdaClient, release.mainLambdaArtifact(), zip)
 }
 
-// DeployLambda uploads new Code to the Lambda
-func (release *Release) DeployLambda(lambdaClient aws.LambdaAPI, s3c aws.S3API) error {
-	// Download and pass Zip file because lambda might be in another region or account
-	zip, err := s3.Get(s3c, release.Bucket, release.LambdaZipPath())
+// DeployLambdaArtifactCode, with TrafficShifting the code of the Lambda of LambdaName is published as LambdaVersion.
+// The Config of the Lambda is deployed first, DeployNotReadyError is returned while it is updating.
+// With Create the Lambda is created if it does not exist
+func (release *Release) DeployLambdaArtifactCode(lambdaClient aws.LambdaAPI, l *LambdaArtifact, zip *[]byte) error {
+	updating, err := release.DeployLambdaConfig(lambdaClient, l)
+	if release.missingResource(err) {
+		return release.CreateLambda(lambdaClient, l, zip)
+	}
+
 	if err != nil {
 		return err
 	}
 
-	err = release.DeployLambdaCode(lambdaClient, zip)
+	if updating {
+		return DeployNotReadyError{fmt.Errorf("%v configuration updating", release.lambdaLabel(l))}
+	}
+
+	out, err := lambdaClient.UpdateFunctionCode(release.deployLambdaInput(l, zip))
+	if release.missingResource(err) {
+		return release.CreateLambda(lambdaClient, l, zip)
+	}
+
 	if err != nil {
 		return err
 	}
 
+	return release.setLambdaVersion(l, out)
+}
+
+func (release *Release) setLambdaVersion(l *LambdaArtifact, out *lambda.FunctionConfiguration) error {
+	if release.publish(l) {
+		if out == nil || out.Version == nil {
+			return fmt.Errorf("Unknown Lambda Version Error")
+		}
+		release.LambdaVersion = out.Version
+	}
+
+	return nil
+}
+
+// DeployLambda deploys the Config of every Lambda then uploads new Code to every Lambda.
+// While a Config is updating it returns DeployNotReadyError before any code is uploaded, so it can be retried
+func (release *Release) DeployLambda(lambdaClient aws.LambdaAPI, s3c aws.S3API) error {
+	updating := []string{}
+	for _, l := range release.LambdaArtifacts() {
+		busy, err := release.DeployLambdaConfig(lambdaClient, l)
+		if release.missingResource(err) {
+			continue // created with its Config
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if busy {
+			updating = append(updating, release.lambdaLabel(l))
+		}
+	}
+
+	if len(updating) > 0 {
+		return DeployNotReadyError{fmt.Errorf("%v configuration updating", strings.Join(updating, ", "))}
+	}
+
+	for _, l := range release.LambdaArtifacts() {
+		// Download and pass Zip file because lambda might be in another region or account
+		zip, err := s3.Get(s3c, release.Bucket, release.LambdaArtifactZipPath(l))
+		if err != nil {
+			return err
+		}
+
+		if err := release.DeployLambdaArtifactCode(lambdaClient, l, zip); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (release *Release) deployStepFunctionInput() *sfn.UpdateStateMachineInput {
+func (release *Release) deployStepFunctionInput(sm *StateMachineArtifact) *sfn.UpdateStateMachineInput {
 	return &sfn.UpdateStateMachineInput{
-		Definition:      to.Strp(to.PrettyJSONStr(release.StateMachineJSON)),
-		StateMachineArn: release.StepArn(),
+		Definition:      to.Strp(to.PrettyJSONStr(sm.StateMachineJSON)),
+		StateMachineArn: release.stepArn(sm.Name),
 	}
 }
 
-// DeployStepFunction updates the step function State Machine
+// DeployStepFunction updates every State Machine, with Create they are created if they do not exist
 func (release *Release) DeployStepFunction(sfnClient aws.SFNAPI) error {
-	_, err := sfnClient.UpdateStateMachine(release.deployStepFunctionInput())
-
-	if err != nil {
-		return err
+	for _, sm := range release.StateMachineArtifacts() {
+		_, err := sfnClient.UpdateStateMachine(release.deployStepFunctionInput(sm))
+		if release.missingResource(err) {
+			err = release.CreateStepFunction(sfnClient, sm)
+		}
+
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -214,8 +432,9 @@ func (release *Release) DeployStepFunction(sfnClient aws.SFNAPI) error {
 // Lambda
 ///////
 
+// LambdaZipPath is in the SourceReleaseDir, so a rollback deploys the zip of the release it rolls back to
 func (release *Release) LambdaZipPath() *string {
-	s := fmt.Sprintf("%v/lambda.zip", *release.ReleaseDir())
+	s := fmt.Sprintf("%v/lambda.zip", *release.SourceReleaseDir())
 	return &s
 }
 
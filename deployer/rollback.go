@@ -0,0 +1,131 @@
+package deployer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/to"
+)
+
+// ListReleases returns the releases in S3 of the project and config of r, newest first
+func (r *Release) ListReleases(s3c aws.S3API) ([]*Release, error) {
+	ids, err := r.ListReleaseIDs(s3c)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := []*Release{}
+	for _, id := range ids {
+		release := &Release{}
+		path := to.Strp(fmt.Sprintf("%v/%v/release", *r.RootDir(), id))
+
+		if err := s3.GetStruct(s3c, r.Bucket, path, release); err != nil {
+			if _, ok := err.(*s3.NotFoundError); ok {
+				continue // not a release directory
+			}
+			return nil, err
+		}
+
+		releases = append(releases, release)
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return createdAt(releases[i]).After(createdAt(releases[j]))
+	})
+
+	return releases, nil
+}
+
+func createdAt(r *Release) time.Time {
+	if r.CreatedAt == nil {
+		return time.Time{}
+	}
+	return *r.CreatedAt
+}
+
+// DeploySucceeded is true if the deployer execution of the release succeeded.
+// An execution that was never started or is past its retention does not exist and did not succeed
+func (r *Release) DeploySucceeded(sfnc aws.SFNAPI, deployer_arn *string) (bool, error) {
+	out, err := sfnc.DescribeExecution(&sfn.DescribeExecutionInput{ExecutionArn: r.ExecutionArn(deployer_arn)})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sfn.ErrCodeExecutionDoesNotExist {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return to.Strs(out.Status) == sfn.ExecutionStatusSucceeded, nil
+}
+
+// LastSuccessfulRelease returns the newest successful release older than the deployed artifacts.
+// The deployed release is the newest successful one, if it is a rollback its artifacts are those of RollbackOf,
+// so rolling back a rollback goes further back instead of redeploying what is already deployed
+func LastSuccessfulRelease(sfnc aws.SFNAPI, releases []*Release, deployer_arn *string) (*Release, error) {
+	deployed := -1
+	for i, release := range releases {
+		succeeded, err := release.DeploySucceeded(sfnc, deployer_arn)
+		if err != nil {
+			return nil, err
+		}
+
+		if succeeded {
+			deployed = i
+			break
+		}
+	}
+
+	if deployed == -1 {
+		return nil, fmt.Errorf("No deployed release to roll back from")
+	}
+
+	// Start after the release whose artifacts are deployed
+	artifacts := releases[deployed].ReleaseID
+	if releases[deployed].RollbackOf != nil {
+		artifacts = releases[deployed].RollbackOf
+	}
+
+	for i := deployed; i < len(releases); i++ {
+		if *releases[i].ReleaseID == *artifacts {
+			deployed = i
+			break
+		}
+	}
+
+	for _, release := range releases[deployed+1:] {
+		succeeded, err := release.DeploySucceeded(sfnc, deployer_arn)
+		if err != nil {
+			return nil, err
+		}
+
+		if succeeded {
+			return release, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No successful release to roll back to")
+}
+
+// NewRollback returns a release that redeploys the artifacts of r,
+// signed with a new ReleaseID and CreatedAt
+func (r *Release) NewRollback() *Release {
+	rollback := *r
+	rollback.WipeControlledValues()
+	rollback.Error = nil
+
+	rollback.ReleaseID = to.TimeUUID("release-")
+	rollback.CreatedAt = to.Timep(time.Now())
+
+	rollback.RollbackOf = r.ReleaseID
+	if r.RollbackOf != nil {
+		// rolling back a rollback redeploys the original artifacts
+		rollback.RollbackOf = r.RollbackOf
+	}
+
+	return &rollback
+}
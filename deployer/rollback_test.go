@@ -0,0 +1,156 @@
+package deployer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Release_ListReleases_LastSuccessfulRelease(t *testing.T) {
+	old := MockRelease()
+	old.CreatedAt = to.Timep(time.Now().Add(-20 * 24 * time.Hour))
+	awsc := MockAwsClients(old)
+
+	failed := MockRelease()
+	failed.ReleaseID = to.Strp("release-2")
+	failed.CreatedAt = to.Timep(time.Now().Add(-time.Hour))
+	assert.NoError(t, s3.PutStruct(awsc.S3, failed.Bucket, failed.ReleasePath(), failed))
+
+	latest := MockRelease()
+	latest.ReleaseID = to.Strp("release-3")
+	assert.NoError(t, s3.PutStruct(awsc.S3, latest.Bucket, latest.ReleasePath(), latest))
+
+	releases, err := latest.ListReleases(awsc.S3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(releases))
+	assert.Equal(t, []string{"release-3", "release-2", "release-1"}, []string{*releases[0].ReleaseID, *releases[1].ReleaseID, *releases[2].ReleaseID})
+
+	deployer_arn := to.Strp("arn:aws:states:us-east-1:00000000:stateMachine:coinbase-step-deployer")
+	assert.Equal(t, "arn:aws:states:us-east-1:00000000:execution:coinbase-step-deployer:release-2", *failed.ExecutionArn(deployer_arn))
+
+	awsc.SFN.DescribeExecutionResps = map[string]*sfn.DescribeExecutionOutput{
+		*failed.ExecutionArn(deployer_arn): {Status: to.Strp(sfn.ExecutionStatusFailed)},
+	}
+
+	target, err := LastSuccessfulRelease(awsc.SFN, releases, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, "release-1", *target.ReleaseID)
+
+	_, err = LastSuccessfulRelease(awsc.SFN, releases[:2], deployer_arn)
+	assert.Error(t, err)
+}
+
+func Test_LastSuccessfulRelease_Rollback_After_Rollback(t *testing.T) {
+	deployer_arn := to.Strp("arn:aws:states:us-east-1:00000000:stateMachine:coinbase-step-deployer")
+
+	release := func(id string, age time.Duration) *Release {
+		r := MockRelease()
+		r.ReleaseID = to.Strp(id)
+		r.CreatedAt = to.Timep(time.Now().Add(-age))
+		return r
+	}
+
+	r1 := release("release-1", 4*time.Hour)
+	r2 := release("release-2", 3*time.Hour)
+	r3 := release("release-3", 2*time.Hour)
+	awsc := MockAwsClients(r1)
+
+	// The latest release failed, so release-2 is deployed and the rollback is to release-1
+	failed := release("release-4", time.Hour)
+	awsc.SFN.DescribeExecutionResps = map[string]*sfn.DescribeExecutionOutput{
+		*failed.ExecutionArn(deployer_arn): {Status: to.Strp(sfn.ExecutionStatusFailed)},
+	}
+
+	target, err := LastSuccessfulRelease(awsc.SFN, []*Release{failed, r2, r1}, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, "release-1", *target.ReleaseID)
+
+	// Rolling back release-3 deploys release-2
+	target, err = LastSuccessfulRelease(awsc.SFN, []*Release{r3, r2, r1}, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, "release-2", *target.ReleaseID)
+
+	// Rolling back that rollback deploys release-1, not release-3 or release-2 again
+	rollback := target.NewRollback()
+	target, err = LastSuccessfulRelease(awsc.SFN, []*Release{rollback, r3, r2, r1}, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, "release-1", *target.ReleaseID)
+
+	// There is nothing older than release-1 to roll back to
+	_, err = LastSuccessfulRelease(awsc.SFN, []*Release{target.NewRollback(), rollback, r3, r2, r1}, deployer_arn)
+	assert.Error(t, err)
+}
+
+func Test_LastSuccessfulRelease_ExecutionDoesNotExist(t *testing.T) {
+	deployer_arn := to.Strp("arn:aws:states:us-east-1:00000000:stateMachine:coinbase-step-deployer")
+
+	release := func(id string, age time.Duration) *Release {
+		r := MockRelease()
+		r.ReleaseID = to.Strp(id)
+		r.CreatedAt = to.Timep(time.Now().Add(-age))
+		return r
+	}
+
+	r1 := release("release-1", 3*time.Hour)
+	r2 := release("release-2", 2*time.Hour)
+	r3 := release("release-3", time.Hour)
+	awsc := MockAwsClients(r1)
+
+	// release-2's execution was purged, so release-1 is the rollback target
+	awsc.SFN.DescribeExecutionErrors = map[string]error{
+		*r2.ExecutionArn(deployer_arn): awserr.New(sfn.ErrCodeExecutionDoesNotExist, "purged", nil),
+	}
+
+	target, err := LastSuccessfulRelease(awsc.SFN, []*Release{r3, r2, r1}, deployer_arn)
+	assert.NoError(t, err)
+	assert.Equal(t, "release-1", *target.ReleaseID)
+
+	// Other errors are returned
+	awsc.SFN.DescribeExecutionErrors[*r1.ExecutionArn(deployer_arn)] = awserr.New(sfn.ErrCodeInvalidArn, "bad arn", nil)
+	_, err = LastSuccessfulRelease(awsc.SFN, []*Release{r3, r2, r1}, deployer_arn)
+	assert.Error(t, err)
+}
+
+func Test_DeployHandler_Execution_Rollback(t *testing.T) {
+	// The release is older than 10 days so can only be redeployed as a rollback
+	original := MockRelease()
+	original.CreatedAt = to.Timep(time.Now().Add(-20 * 24 * time.Hour))
+	original.UUID = to.Strp("uuid")
+	original.Success = to.Boolp(true)
+	awsc := MockAwsClients(original)
+
+	rollback := original.NewRollback()
+	assert.Equal(t, "release-1", *rollback.RollbackOf)
+	assert.NotEqual(t, "release-1", *rollback.ReleaseID)
+	assert.Nil(t, rollback.UUID)
+	assert.Nil(t, rollback.Success)
+	assert.Equal(t, *original.LambdaZipPath(), *rollback.LambdaZipPath())
+
+	// A rollback of a rollback redeploys the original
+	assert.Equal(t, "release-1", *rollback.NewRollback().RollbackOf)
+
+	assert.NoError(t, s3.PutStruct(awsc.S3, rollback.Bucket, rollback.ReleasePath(), rollback))
+
+	state_machine := createTestStateMachine(t, awsc)
+	exec, err := state_machine.Execute(rollback)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+	assert.Equal(t, "release-1", exec.Output["rollback_of"])
+}
+
+func Test_DeployHandler_Execution_Rollback_Errors(t *testing.T) {
+	release := MockRelease()
+	release.RollbackOf = to.Strp("release-0")
+	awsc := MockAwsClients(release)
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Regexp(t, "RollbackOf release release-0 not found", exec.LastOutputJSON)
+	assert.Equal(t, []string{"Validate", "FailureClean"}, exec.Path())
+}
@@ -0,0 +1,326 @@
+package deployer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Snapshot is what was deployed before the release, RestoreSnapshot deploys it back if the release fails.
+// Resources the release creates are not in it and are left as they are
+type Snapshot struct {
+	StateMachines []*StateMachineSnapshot `json:"state_machines"`
+	Lambdas       []*LambdaSnapshot       `json:"lambdas"`
+}
+
+// StateMachineSnapshot is the definition of a State Machine
+type StateMachineSnapshot struct {
+	Name       *string `json:"name,omitempty"`
+	Definition *string `json:"definition,omitempty"`
+}
+
+// LambdaSnapshot is a version published from $LATEST, so its code and configuration can be restored
+type LambdaSnapshot struct {
+	Name       *string            `json:"name,omitempty"`
+	Version    *string            `json:"version,omitempty"`
+	CodeSha256 *string            `json:"code_sha256,omitempty"` // base64, as Lambda returns it
+	Tags       map[string]*string `json:"tags,omitempty"`        // the values of the tags the release's config sets, null if there was none
+	Published  bool               `json:"published,omitempty"`   // Version was published for the snapshot, PruneSnapshot deletes it
+}
+
+// SnapshotPath is in the ReleaseDir so the state before each release is kept
+func (release *Release) SnapshotPath() *string {
+	s := fmt.Sprintf("%v/snapshot", *release.ReleaseDir())
+	return &s
+}
+
+// Snapshot records the State Machine definitions and publishes a version of every Lambda, then uploads them to SnapshotPath
+func (release *Release) Snapshot(s3c aws.S3API, lambdac aws.LambdaAPI, sfnc aws.SFNAPI) error {
+	snapshot := &Snapshot{StateMachines: []*StateMachineSnapshot{}, Lambdas: []*LambdaSnapshot{}}
+
+	for _, sm := range release.StateMachineArtifacts() {
+		out, err := sfnc.DescribeStateMachine(&sfn.DescribeStateMachineInput{StateMachineArn: release.stepArn(sm.Name)})
+		if release.missingResource(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if out == nil || out.Definition == nil {
+			return fmt.Errorf("Unknown Step Function Error")
+		}
+
+		snapshot.StateMachines = append(snapshot.StateMachines, &StateMachineSnapshot{Name: sm.Name, Definition: out.Definition})
+	}
+
+	description := fmt.Sprintf("step-deployer snapshot before %v", *release.ReleaseID)
+
+	for _, l := range release.LambdaArtifacts() {
+		// Lambda returns the latest version instead of publishing one if $LATEST has not changed since
+		out, err := lambdac.PublishVersion(&lambda.PublishVersionInput{
+			FunctionName: release.lambdaArn(l.Name),
+			Description:  to.Strp(description),
+		})
+
+		if release.missingResource(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if out == nil || out.Version == nil {
+			return fmt.Errorf("Unknown Lambda Version Error")
+		}
+
+		ls := &LambdaSnapshot{
+			Name:       l.Name,
+			Version:    out.Version,
+			CodeSha256: out.CodeSha256,
+			Published:  to.Strs(out.Description) == description,
+		}
+
+		if l.Config != nil && len(l.Config.Tags) > 0 {
+			tags, err := lambdac.ListTags(&lambda.ListTagsInput{Resource: release.lambdaArn(l.Name)})
+			if err != nil {
+				return err
+			}
+
+			ls.Tags = map[string]*string{}
+			for key := range l.Config.Tags {
+				ls.Tags[key] = nil
+				if tags != nil {
+					ls.Tags[key] = tags.Tags[key]
+				}
+			}
+		}
+
+		snapshot.Lambdas = append(snapshot.Lambdas, ls)
+	}
+
+	return s3.PutStruct(s3c, release.Bucket, release.SnapshotPath(), snapshot)
+}
+
+// RestoreSnapshot deploys the snapshot definitions, then the code, configuration and tags of the snapshot versions
+// to every Lambda whose code, configuration or tags the release changed
+func (release *Release) RestoreSnapshot(s3c aws.S3API, lambdac aws.LambdaAPI, sfnc aws.SFNAPI) error {
+	var snapshot Snapshot
+	if err := s3.GetStruct(s3c, release.Bucket, release.SnapshotPath(), &snapshot); err != nil {
+		return err
+	}
+
+	for _, sm := range snapshot.StateMachines {
+		if err := release.restoreStepFunction(sfnc, sm); err != nil {
+			return err
+		}
+	}
+
+	configs := map[string]*LambdaConfig{}
+	for _, l := range release.LambdaArtifacts() {
+		configs[*l.Name] = l.Config
+	}
+
+	for _, l := range snapshot.Lambdas {
+		if err := release.restoreLambda(lambdac, l, configs[*l.Name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreStepFunction updates the State Machine if its definition is not the snapshot's
+func (release *Release) restoreStepFunction(sfnc aws.SFNAPI, sm *StateMachineSnapshot) error {
+	arn := release.stepArn(sm.Name)
+
+	out, err := sfnc.DescribeStateMachine(&sfn.DescribeStateMachineInput{StateMachineArn: arn})
+	if err != nil {
+		return err
+	}
+
+	if out != nil && to.Strs(out.Definition) == to.Strs(sm.Definition) {
+		return nil
+	}
+
+	_, err = sfnc.UpdateStateMachine(&sfn.UpdateStateMachineInput{StateMachineArn: arn, Definition: sm.Definition})
+	return err
+}
+
+// restoreLambda updates the Lambda to the code of the snapshot version if its code changed,
+// and to its configuration if the release's config changed it. It returns DeployNotReadyError while the Lambda is updating
+func (release *Release) restoreLambda(lambdac aws.LambdaAPI, l *LambdaSnapshot, config *LambdaConfig) error {
+	arn := release.lambdaArn(l.Name)
+
+	current, err := lambdac.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{FunctionName: arn})
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		return fmt.Errorf("Unknown Lambda Configuration Error")
+	}
+
+	// A failed update can be restored
+	if to.Strs(current.LastUpdateStatus) == lambda.LastUpdateStatusInProgress {
+		return DeployNotReadyError{fmt.Errorf("Lambda %v updating", *l.Name)}
+	}
+
+	var architectures []*string
+	if config != nil {
+		snapshot, err := lambdac.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{FunctionName: arn, Qualifier: l.Version})
+		if err != nil {
+			return err
+		}
+
+		if snapshot == nil {
+			return fmt.Errorf("Unknown Lambda Configuration Error")
+		}
+
+		updated, err := restoreLambdaConfig(lambdac, arn, current, snapshot, config)
+		if err != nil {
+			return err
+		}
+
+		if updated {
+			return DeployNotReadyError{fmt.Errorf("Lambda %v configuration updating", *l.Name)}
+		}
+
+		if config.Architecture != nil && !reflect.DeepEqual(current.Architectures, snapshot.Architectures) {
+			architectures = snapshot.Architectures
+		}
+	}
+
+	if err := restoreLambdaTags(lambdac, arn, l.Tags); err != nil {
+		return err
+	}
+
+	if to.Strs(current.CodeSha256) == to.Strs(l.CodeSha256) && architectures == nil {
+		return nil
+	}
+
+	fn, err := lambdac.GetFunction(&lambda.GetFunctionInput{FunctionName: arn, Qualifier: l.Version})
+	if err != nil {
+		return err
+	}
+
+	if fn == nil || fn.Code == nil || fn.Code.Location == nil {
+		return fmt.Errorf("Unknown Lambda %v Code Location", *l.Name)
+	}
+
+	zip, err := downloadCode(*fn.Code.Location)
+	if err != nil {
+		return err
+	}
+
+	_, err = lambdac.UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{FunctionName: arn, ZipFile: zip, Architectures: architectures})
+	return err
+}
+
+// restoreLambdaConfig updates $LATEST to the configuration of the snapshot version if the fields of config differ,
+// it returns true if it was updated
+func restoreLambdaConfig(lambdac aws.LambdaAPI, arn *string, latest *lambda.FunctionConfiguration, snapshot *lambda.FunctionConfiguration, config *LambdaConfig) (bool, error) {
+	fields := config.configuration()
+	if reflect.DeepEqual(fields.current(latest, nil), fields.current(snapshot, nil)) {
+		return false, nil
+	}
+
+	input := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: arn,
+		MemorySize:   snapshot.MemorySize,
+		Timeout:      snapshot.Timeout,
+		Runtime:      snapshot.Runtime,
+		Handler:      snapshot.Handler,
+		Environment:  &lambda.Environment{Variables: map[string]*string{}},
+		Layers:       []*string{},
+	}
+
+	if snapshot.Environment != nil && snapshot.Environment.Variables != nil {
+		input.Environment.Variables = snapshot.Environment.Variables
+	}
+
+	for _, layer := range snapshot.Layers {
+		input.Layers = append(input.Layers, layer.Arn)
+	}
+
+	if _, err := lambdac.UpdateFunctionConfiguration(input); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// restoreLambdaTags sets the tags of the snapshot back and removes those the release added
+func restoreLambdaTags(lambdac aws.LambdaAPI, arn *string, tags map[string]*string) error {
+	restore := map[string]*string{}
+	remove := []*string{}
+	for key, value := range tags {
+		if value == nil {
+			remove = append(remove, to.Strp(key))
+		} else {
+			restore[key] = value
+		}
+	}
+
+	if len(restore) > 0 {
+		if _, err := lambdac.TagResource(&lambda.TagResourceInput{Resource: arn, Tags: restore}); err != nil {
+			return err
+		}
+	}
+
+	if len(remove) > 0 {
+		sort.Slice(remove, func(i, j int) bool { return *remove[i] < *remove[j] })
+		if _, err := lambdac.UntagResource(&lambda.UntagResourceInput{Resource: arn, TagKeys: remove}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PruneSnapshot deletes the Lambda versions published for the snapshot,
+// once the release succeeded they are not needed and would otherwise be left behind by every deploy
+func (release *Release) PruneSnapshot(s3c aws.S3API, lambdac aws.LambdaAPI) error {
+	var snapshot Snapshot
+	if err := s3.GetStruct(s3c, release.Bucket, release.SnapshotPath(), &snapshot); err != nil {
+		return err
+	}
+
+	for _, l := range snapshot.Lambdas {
+		if !l.Published {
+			continue
+		}
+
+		_, err := lambdac.DeleteFunction(&lambda.DeleteFunctionInput{FunctionName: release.lambdaArn(l.Name), Qualifier: l.Version})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// downloadCode gets the zip from the presigned URL Lambda returns in GetFunction
+func downloadCode(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Lambda Code download failed with status %v", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,180 @@
+package deployer
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/coinbase/step/aws/mocks"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockSnapshotCode deploys old_zip to the Lambda before the release and serves it as the code of the snapshot version,
+// the returned func stops the server
+func mockSnapshotCode(awsc *mocks.MockClients) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("old_zip"))
+	}))
+
+	sha := sha256.Sum256([]byte("old_zip"))
+	awsc.Lambda.CodeSha256 = map[string]*string{"lambdaname": to.Strp(base64.StdEncoding.EncodeToString(sha[:]))}
+	awsc.Lambda.GetFunctionResp = &lambda.GetFunctionOutput{
+		Configuration: &lambda.FunctionConfiguration{},
+		Code:          &lambda.FunctionCodeLocation{Location: to.Strp(server.URL)},
+	}
+
+	return server.Close
+}
+
+func Test_Release_Snapshot_Restore(t *testing.T) {
+	release := MockRelease()
+	release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+	awsc := MockAwsClients(release)
+	defer mockSnapshotCode(awsc)()
+
+	assert.NoError(t, release.Snapshot(awsc.S3, awsc.Lambda, awsc.SFN))
+	assert.Equal(t, "step-deployer snapshot before release-1", *awsc.Lambda.PublishVersionInputs[0].Description)
+
+	var snapshot Snapshot
+	assert.NoError(t, s3.GetStruct(awsc.S3, release.Bucket, release.SnapshotPath(), &snapshot))
+	assert.Equal(t, "stepfnname", *snapshot.StateMachines[0].Name)
+	assert.Equal(t, "1", *snapshot.Lambdas[0].Version)
+
+	// Nothing changed, nothing restored
+	assert.NoError(t, release.RestoreSnapshot(awsc.S3, awsc.Lambda, awsc.SFN))
+	assert.Equal(t, 0, len(awsc.SFN.UpdateStateMachineInputs))
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionCodeInputs))
+
+	release.StateMachineJSON = to.Strp(`{"StartAt": "New", "States": {"New": {"Type": "Succeed"}}}`)
+	assert.NoError(t, release.DeployStepFunction(awsc.SFN))
+	assert.NoError(t, release.DeployLambda(awsc.Lambda, awsc.S3))
+
+	assert.NoError(t, release.RestoreSnapshot(awsc.S3, awsc.Lambda, awsc.SFN))
+
+	assert.Equal(t, 2, len(awsc.SFN.UpdateStateMachineInputs))
+	assert.Equal(t, *snapshot.StateMachines[0].Definition, *awsc.SFN.UpdateStateMachineInputs[1].Definition)
+
+	assert.Equal(t, 2, len(awsc.Lambda.UpdateFunctionCodeInputs))
+	assert.Equal(t, "old_zip", string(awsc.Lambda.UpdateFunctionCodeInputs[1].ZipFile))
+}
+
+func Test_Release_restoreLambda_Config(t *testing.T) {
+	release := MockRelease()
+	awsc := MockAwsClients(release)
+	snapshot := &LambdaSnapshot{Name: release.LambdaName, Version: to.Strp("1")}
+	config := &LambdaConfig{MemorySize: to.Int64p(512), Tags: map[string]*string{"Team": to.Strp("payments")}}
+
+	// $LATEST then the snapshot version
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{
+		{MemorySize: to.Int64p(512), Timeout: to.Int64p(30)},
+		{MemorySize: to.Int64p(128), Timeout: to.Int64p(3), Layers: []*lambda.Layer{{Arn: to.Strp("layer:1")}}},
+	}
+
+	// It is retried until the configuration is updated
+	assert.IsType(t, DeployNotReadyError{}, release.restoreLambda(awsc.Lambda, snapshot, config))
+	assert.NoError(t, release.restoreLambda(awsc.Lambda, snapshot, config))
+	assert.Equal(t, 1, len(awsc.Lambda.UpdateFunctionConfigurationInputs))
+	restored := awsc.Lambda.UpdateFunctionConfigurationInputs[0]
+	assert.Equal(t, int64(128), *restored.MemorySize)
+	assert.Equal(t, int64(3), *restored.Timeout)
+	assert.Equal(t, "layer:1", *restored.Layers[0])
+	assert.Equal(t, 0, len(restored.Environment.Variables))
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionCodeInputs))
+
+	// Unchanged config is not restored
+	awsc = MockAwsClients(release)
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{{MemorySize: to.Int64p(512)}}
+	assert.NoError(t, release.restoreLambda(awsc.Lambda, snapshot, config))
+	assert.Equal(t, 0, len(awsc.Lambda.UpdateFunctionConfigurationInputs))
+}
+
+func Test_Release_Snapshot_Tags(t *testing.T) {
+	release := MockRelease()
+	release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+	release.LambdaConfig = &LambdaConfig{Tags: map[string]*string{"Team": to.Strp("payments"), "Owner": to.Strp("step")}}
+	awsc := MockAwsClients(release)
+	awsc.Lambda.ListTagsResp.Tags["Team"] = to.Strp("billing")
+
+	assert.NoError(t, release.Snapshot(awsc.S3, awsc.Lambda, awsc.SFN))
+
+	var snapshot Snapshot
+	assert.NoError(t, s3.GetStruct(awsc.S3, release.Bucket, release.SnapshotPath(), &snapshot))
+	assert.Equal(t, map[string]*string{"Team": to.Strp("billing"), "Owner": nil}, snapshot.Lambdas[0].Tags)
+
+	// Tags are set back to the snapshot's and the ones the release added are removed
+	assert.NoError(t, release.RestoreSnapshot(awsc.S3, awsc.Lambda, awsc.SFN))
+	assert.Equal(t, map[string]*string{"Team": to.Strp("billing")}, awsc.Lambda.TagResourceInputs[0].Tags)
+	assert.Equal(t, []*string{to.Strp("Owner")}, awsc.Lambda.UntagResourceInputs[0].TagKeys)
+}
+
+func Test_Release_PruneSnapshot(t *testing.T) {
+	release := MockRelease()
+	release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+	awsc := MockAwsClients(release)
+
+	// A version Lambda returns because $LATEST is unchanged is not deleted
+	awsc.Lambda.PublishVersionResp = &lambda.FunctionConfiguration{Version: to.Strp("3"), Description: to.Strp("published by a person")}
+	assert.NoError(t, release.Snapshot(awsc.S3, awsc.Lambda, awsc.SFN))
+	assert.NoError(t, release.PruneSnapshot(awsc.S3, awsc.Lambda))
+	assert.Equal(t, 0, len(awsc.Lambda.DeleteFunctionInputs))
+
+	// A version published for the snapshot is deleted
+	awsc.Lambda.PublishVersionResp = nil
+	assert.NoError(t, release.Snapshot(awsc.S3, awsc.Lambda, awsc.SFN))
+	assert.NoError(t, release.PruneSnapshot(awsc.S3, awsc.Lambda))
+	assert.Equal(t, 1, len(awsc.Lambda.DeleteFunctionInputs))
+	assert.Equal(t, "1", *awsc.Lambda.DeleteFunctionInputs[0].Qualifier)
+}
+
+func Test_DeployHandler_Execution_PruneSnapshot(t *testing.T) {
+	release := MockRelease()
+	awsc := MockAwsClients(release)
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+
+	// The snapshot version is deleted once the release succeeded
+	assert.Equal(t, 1, len(awsc.Lambda.DeleteFunctionInputs))
+	assert.Equal(t, "arn:aws:lambda:us-east-1:00000000:function:lambdaname", *awsc.Lambda.DeleteFunctionInputs[0].FunctionName)
+}
+
+func Test_Release_Snapshot_Create(t *testing.T) {
+	release, awsc := MockCreateRelease(false)
+	release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+
+	// Resources the release creates are not in the snapshot
+	assert.NoError(t, release.Snapshot(awsc.S3, awsc.Lambda, awsc.SFN))
+
+	var snapshot Snapshot
+	assert.NoError(t, s3.GetStruct(awsc.S3, release.Bucket, release.SnapshotPath(), &snapshot))
+	assert.Equal(t, 0, len(snapshot.StateMachines))
+	assert.Equal(t, 0, len(snapshot.Lambdas))
+}
+
+func Test_DeployHandler_Execution_RestoreSnapshot_Fails(t *testing.T) {
+	release := MockRelease()
+	awsc := MockAwsClients(release)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	awsc.Lambda.GetFunctionResp = &lambda.GetFunctionOutput{Code: &lambda.FunctionCodeLocation{Location: to.Strp(server.URL)}}
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{
+		{CodeSha256: to.Strp(base64.StdEncoding.EncodeToString([]byte("other")))},
+	}
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Regexp(t, "download failed with status 403", exec.LastOutputJSON)
+	assert.Equal(t, []string{"VerifyDeploy", "RestoreSnapshot", "RestoreSnapshot", "RestoreSnapshot", "RestoreSnapshot", "FailureDirty"}, exec.Path()[5:])
+}
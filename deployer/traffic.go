@@ -0,0 +1,227 @@
+package deployer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Traffic shifting types
+const (
+	CanaryShift    = "Canary"    // Percent of traffic, then all of it
+	LinearShift    = "Linear"    // Percent more traffic every step
+	AllAtOnceShift = "AllAtOnce" // all traffic in one step
+)
+
+// TrafficShifting moves an alias of the Lambda to the published version in steps,
+// IntervalSeconds apart. Before each step the Alarms are checked and if any is in ALARM
+// the alias is rolled back to its previous version
+type TrafficShifting struct {
+	Alias           *string   `json:"alias,omitempty"`
+	Type            *string   `json:"type,omitempty"`
+	Percent         *int      `json:"percent,omitempty"`
+	IntervalSeconds *int      `json:"interval_seconds,omitempty"`
+	Alarms          []*string `json:"alarms,omitempty"` // CloudWatch alarm names
+}
+
+// SetDefaults is a Canary of 10% for 5 minutes, or Linear 10% every minute
+func (ts *TrafficShifting) SetDefaults() {
+	if ts.Type == nil {
+		ts.Type = to.Strp(CanaryShift)
+	}
+
+	switch *ts.Type {
+	case CanaryShift:
+		setDefaultInt(&ts.Percent, 10)
+		setDefaultInt(&ts.IntervalSeconds, 300)
+	case LinearShift:
+		setDefaultInt(&ts.Percent, 10)
+		setDefaultInt(&ts.IntervalSeconds, 60)
+	case AllAtOnceShift:
+		ts.Percent = to.Intp(100)
+		setDefaultInt(&ts.IntervalSeconds, 0)
+	}
+}
+
+func setDefaultInt(i **int, value int) {
+	if *i == nil {
+		*i = to.Intp(value)
+	}
+}
+
+func (ts *TrafficShifting) Validate() error {
+	if is.EmptyStr(ts.Alias) {
+		return fmt.Errorf("TrafficShifting Alias must be defined")
+	}
+
+	switch to.Strs(ts.Type) {
+	case CanaryShift, LinearShift, AllAtOnceShift:
+	default:
+		return fmt.Errorf("TrafficShifting Type must be %v, %v or %v", CanaryShift, LinearShift, AllAtOnceShift)
+	}
+
+	if ts.Percent == nil || *ts.Percent < 1 || *ts.Percent > 100 {
+		return fmt.Errorf("TrafficShifting Percent must be between 1 and 100")
+	}
+
+	if ts.IntervalSeconds == nil || *ts.IntervalSeconds < 0 {
+		return fmt.Errorf("TrafficShifting IntervalSeconds must not be negative")
+	}
+
+	for _, alarm := range ts.Alarms {
+		if is.EmptyStr(alarm) {
+			return fmt.Errorf("TrafficShifting Alarms must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// nextPercent is the traffic of the step after percent
+func (ts *TrafficShifting) nextPercent(percent int) int {
+	next := percent + *ts.Percent
+	if *ts.Type == CanaryShift && percent > 0 {
+		next = 100
+	}
+
+	if next > 100 {
+		return 100
+	}
+	return next
+}
+
+// duration is the seconds waited between the steps of traffic
+func (ts *TrafficShifting) duration() int {
+	if ts.Type == nil || ts.Percent == nil || *ts.Percent < 1 || ts.IntervalSeconds == nil {
+		return 0
+	}
+
+	seconds := 0
+	for percent := ts.nextPercent(0); percent < 100; percent = ts.nextPercent(percent) {
+		seconds += *ts.IntervalSeconds
+	}
+	return seconds
+}
+
+//////////
+// AWS Methods
+//////////
+
+// TrafficShifted is true once the alias routes all traffic to LambdaVersion
+func (release *Release) TrafficShifted() bool {
+	return release.TrafficShifting == nil || release.TrafficPercent >= 100
+}
+
+// StartTrafficShift remembers the version of the alias and shifts the first step of traffic to LambdaVersion.
+// With Create a missing alias is created pointing at LambdaVersion
+func (release *Release) StartTrafficShift(lambdac aws.LambdaAPI) error {
+	if release.LambdaVersion == nil {
+		return fmt.Errorf("LambdaVersion must be published before shifting traffic")
+	}
+
+	alias, err := lambdac.GetAlias(&lambda.GetAliasInput{
+		FunctionName: release.LambdaArn(),
+		Name:         release.TrafficShifting.Alias,
+	})
+
+	if release.missingResource(err) {
+		return release.createAlias(lambdac)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if alias == nil || alias.FunctionVersion == nil {
+		return fmt.Errorf("Unknown Lambda Alias Error")
+	}
+
+	release.PreviousVersion = alias.FunctionVersion
+	return release.shiftTraffic(lambdac, release.TrafficShifting.nextPercent(0))
+}
+
+// ShiftTraffic checks the alarms then shifts the next step of traffic to LambdaVersion,
+// it returns the alarm error and true if the release is unhealthy
+func (release *Release) ShiftTraffic(lambdac aws.LambdaAPI, cwc aws.CloudWatchAPI) (bool, error) {
+	if err := release.CheckAlarms(cwc); err != nil {
+		return true, err
+	}
+
+	return false, release.shiftTraffic(lambdac, release.TrafficShifting.nextPercent(release.TrafficPercent))
+}
+
+// RollbackTraffic routes all traffic of the alias back to PreviousVersion
+func (release *Release) RollbackTraffic(lambdac aws.LambdaAPI) error {
+	if release.PreviousVersion == nil {
+		return fmt.Errorf("PreviousVersion unknown, cannot roll back")
+	}
+
+	_, err := lambdac.UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    release.LambdaArn(),
+		Name:            release.TrafficShifting.Alias,
+		FunctionVersion: release.PreviousVersion,
+		RoutingConfig:   &lambda.AliasRoutingConfiguration{AdditionalVersionWeights: map[string]*float64{}},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	release.TrafficPercent = 0
+	return nil
+}
+
+// shiftTraffic weights percent of the alias to LambdaVersion, at 100 the alias points to it
+func (release *Release) shiftTraffic(lambdac aws.LambdaAPI, percent int) error {
+	input := &lambda.UpdateAliasInput{
+		FunctionName:    release.LambdaArn(),
+		Name:            release.TrafficShifting.Alias,
+		FunctionVersion: release.PreviousVersion,
+		RoutingConfig: &lambda.AliasRoutingConfiguration{AdditionalVersionWeights: map[string]*float64{
+			*release.LambdaVersion: to.Float64p(float64(percent) / 100),
+		}},
+	}
+
+	if percent >= 100 || *release.PreviousVersion == *release.LambdaVersion {
+		percent = 100
+		input.FunctionVersion = release.LambdaVersion
+		input.RoutingConfig.AdditionalVersionWeights = map[string]*float64{}
+	}
+
+	if _, err := lambdac.UpdateAlias(input); err != nil {
+		return err
+	}
+
+	release.TrafficPercent = percent
+	return nil
+}
+
+// CheckAlarms returns an error naming the alarms in the ALARM state
+func (release *Release) CheckAlarms(cwc aws.CloudWatchAPI) error {
+	if len(release.TrafficShifting.Alarms) == 0 {
+		return nil
+	}
+
+	out, err := cwc.DescribeAlarms(&cloudwatch.DescribeAlarmsInput{AlarmNames: release.TrafficShifting.Alarms})
+	if err != nil {
+		return err
+	}
+
+	alarming := []string{}
+	for _, alarm := range out.MetricAlarms {
+		if to.Strs(alarm.StateValue) == cloudwatch.StateValueAlarm {
+			alarming = append(alarming, to.Strs(alarm.AlarmName))
+		}
+	}
+
+	if len(alarming) > 0 {
+		return fmt.Errorf("Alarms in ALARM state at %v%% traffic: %v", release.TrafficPercent, strings.Join(alarming, ", "))
+	}
+
+	return nil
+}
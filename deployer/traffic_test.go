@@ -0,0 +1,182 @@
+package deployer
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/coinbase/step/aws/mocks"
+	"github.com/coinbase/step/machine"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func MockTrafficShiftingRelease(shift_type string, percent *int) (*Release, *mocks.MockClients) {
+	release := MockRelease()
+	release.TrafficShifting = &TrafficShifting{
+		Alias:   to.Strp("live"),
+		Type:    to.Strp(shift_type),
+		Percent: percent,
+		Alarms:  []*string{to.Strp("errors")},
+	}
+
+	awsc := MockAwsClients(release)
+	awsc.Lambda.UpdateFunctionCodeResp = &lambda.FunctionConfiguration{Version: to.Strp("2")}
+	awsc.Lambda.GetAliasResp = &lambda.AliasConfiguration{Name: to.Strp("live"), FunctionVersion: to.Strp("1")}
+	return release, awsc
+}
+
+// aliasWeights returns the weight of version 2 after each UpdateAlias, 1 if the alias points to it
+func aliasWeights(awsc *mocks.MockClients) []float64 {
+	weights := []float64{}
+	for _, in := range awsc.Lambda.UpdateAliasInputs {
+		switch {
+		case *in.FunctionVersion == "2":
+			weights = append(weights, 1)
+		case in.RoutingConfig.AdditionalVersionWeights["2"] != nil:
+			weights = append(weights, *in.RoutingConfig.AdditionalVersionWeights["2"])
+		default:
+			weights = append(weights, 0)
+		}
+	}
+	return weights
+}
+
+func Test_TrafficShifting_Defaults_Validate(t *testing.T) {
+	ts := &TrafficShifting{Alias: to.Strp("live")}
+	ts.SetDefaults()
+	assert.NoError(t, ts.Validate())
+	assert.Equal(t, CanaryShift, *ts.Type)
+	assert.Equal(t, 10, *ts.Percent)
+	assert.Equal(t, 300, *ts.IntervalSeconds)
+	assert.Equal(t, []int{10, 100}, []int{ts.nextPercent(0), ts.nextPercent(10)})
+
+	ts = &TrafficShifting{Alias: to.Strp("live"), Type: to.Strp(LinearShift), Percent: to.Intp(40)}
+	ts.SetDefaults()
+	assert.NoError(t, ts.Validate())
+	assert.Equal(t, []int{40, 80, 100}, []int{ts.nextPercent(0), ts.nextPercent(40), ts.nextPercent(80)})
+
+	ts = &TrafficShifting{Alias: to.Strp("live"), Type: to.Strp("Sometimes")}
+	ts.SetDefaults()
+	assert.Error(t, ts.Validate())
+
+	ts = &TrafficShifting{Type: to.Strp(AllAtOnceShift)}
+	ts.SetDefaults()
+	assert.Error(t, ts.Validate())
+}
+
+func Test_Release_SetDefaults_TrafficShifting_Timeout(t *testing.T) {
+	release, _ := MockTrafficShiftingRelease(CanaryShift, nil)
+	release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+	assert.Equal(t, 600+300, *release.Timeout)
+
+	release, _ = MockTrafficShiftingRelease(LinearShift, nil)
+	release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+	assert.Equal(t, 600+9*60, *release.Timeout)
+
+	release, _ = MockTrafficShiftingRelease(AllAtOnceShift, nil)
+	release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+	assert.Equal(t, 600, *release.Timeout)
+
+	// An explicit Timeout is kept
+	release, _ = MockTrafficShiftingRelease(LinearShift, nil)
+	release.Timeout = to.Intp(60)
+	release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+	assert.Equal(t, 60, *release.Timeout)
+
+	// but must be longer than traffic shifting waits
+	assert.Regexp(t, "Timeout 60 must be longer than the 540 seconds TrafficShifting waits", release.validateAttributes())
+
+	release.Timeout = to.Intp(600)
+	assert.NoError(t, release.validateAttributes())
+}
+
+func Test_DeployHandler_Execution_TrafficShifting_Canary(t *testing.T) {
+	release, awsc := MockTrafficShiftingRelease(CanaryShift, nil)
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+	assert.Equal(t, "2", exec.Output["lambda_version"])
+	assert.Equal(t, "1", exec.Output["previous_version"])
+
+	assert.Equal(t, []string{
+		"Validate",
+		"Lock",
+		"ValidateResources",
+		"Snapshot",
+		"Deploy",
+		"VerifyDeploy",
+		"TrafficShifted",
+		"WaitForTraffic",
+		"ShiftTraffic",
+		"TrafficShifted",
+		"Success",
+	}, exec.Path())
+
+	assert.Equal(t, []float64{0.1, 1}, aliasWeights(awsc))
+	assertNoRootLockWithReleseLock(t, awsc, release)
+}
+
+func Test_DeployHandler_Execution_TrafficShifting_Linear(t *testing.T) {
+	release, awsc := MockTrafficShiftingRelease(LinearShift, to.Intp(50))
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+	assert.Equal(t, []float64{0.5, 1}, aliasWeights(awsc))
+}
+
+func Test_DeployHandler_Execution_TrafficShifting_AllAtOnce(t *testing.T) {
+	release, awsc := MockTrafficShiftingRelease(AllAtOnceShift, nil)
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Validate", "Lock", "ValidateResources", "Snapshot", "Deploy", "VerifyDeploy", "TrafficShifted", "Success"}, exec.Path())
+	assert.Equal(t, []float64{1}, aliasWeights(awsc))
+}
+
+func Test_DeployHandler_Execution_TrafficShifting_Alarm_Rollback(t *testing.T) {
+	release, awsc := MockTrafficShiftingRelease(CanaryShift, nil)
+	defer mockSnapshotCode(awsc)()
+	awsc.CloudWatch.DescribeAlarmsResp = &cloudwatch.DescribeAlarmsOutput{MetricAlarms: []*cloudwatch.MetricAlarm{
+		{AlarmName: to.Strp("errors"), StateValue: to.Strp(cloudwatch.StateValueAlarm)},
+	}}
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Regexp(t, "HealthError", exec.LastOutputJSON)
+	assert.Regexp(t, "errors", exec.LastOutputJSON)
+
+	assert.Equal(t, []string{
+		"Validate",
+		"Lock",
+		"ValidateResources",
+		"Snapshot",
+		"Deploy",
+		"VerifyDeploy",
+		"TrafficShifted",
+		"WaitForTraffic",
+		"ShiftTraffic",
+		"Rollback",
+		"RestoreSnapshot",
+		"ReleaseLockFailure",
+		"FailureClean",
+	}, exec.Path())
+
+	// All traffic is back on version 1
+	assert.Equal(t, []float64{0.1, 0}, aliasWeights(awsc))
+	assert.Equal(t, "1", *awsc.Lambda.GetAliasResp.FunctionVersion)
+	assertNoRootLockWithReleseLock(t, awsc, release)
+
+	// The release's definition and $LATEST code are restored
+	assert.Equal(t, 2, len(awsc.SFN.UpdateStateMachineInputs))
+	assert.Equal(t, machine.EmptyStateMachine, *awsc.SFN.Definitions["stepfnname"])
+	assert.Equal(t, 2, len(awsc.Lambda.UpdateFunctionCodeInputs))
+	assert.Equal(t, "lambda_zip", string(awsc.Lambda.UpdateFunctionCodeInputs[0].ZipFile))
+	assert.Equal(t, "old_zip", string(awsc.Lambda.UpdateFunctionCodeInputs[1].ZipFile))
+}
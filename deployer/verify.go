@@ -0,0 +1,90 @@
+package deployer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+// VerifyDeploy checks every Lambda finished updating with the code of its SHA256 and
+// every State Machine has its definition. It returns true and the reason while a Lambda is still updating
+func (release *Release) VerifyDeploy(lambdac aws.LambdaAPI, sfnc aws.SFNAPI) (bool, error) {
+	for _, l := range release.LambdaArtifacts() {
+		if updating, err := release.verifyLambda(lambdac, l); err != nil {
+			return updating, err
+		}
+	}
+
+	for _, sm := range release.StateMachineArtifacts() {
+		if err := release.verifyStepFunction(sfnc, sm); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// verifyLambda checks the published LambdaVersion of a Lambda with TrafficShifting, otherwise $LATEST
+func (release *Release) verifyLambda(lambdac aws.LambdaAPI, l *LambdaArtifact) (bool, error) {
+	input := &lambda.GetFunctionConfigurationInput{FunctionName: release.lambdaArn(l.Name)}
+	if release.publish(l) {
+		input.Qualifier = release.LambdaVersion
+	}
+
+	out, err := lambdac.GetFunctionConfiguration(input)
+	if err != nil {
+		return false, err
+	}
+
+	if out == nil {
+		return false, fmt.Errorf("Unknown Lambda Configuration Error")
+	}
+
+	label := release.lambdaLabel(l)
+
+	switch to.Strs(out.State) {
+	case lambda.StatePending:
+		return true, fmt.Errorf("%v State is %v", label, *out.State)
+	case lambda.StateFailed:
+		return false, fmt.Errorf("%v State is %v with %v: %v", label, *out.State, to.Strs(out.StateReasonCode), to.Strs(out.StateReason))
+	}
+
+	switch to.Strs(out.LastUpdateStatus) {
+	case lambda.LastUpdateStatusInProgress:
+		return true, fmt.Errorf("%v LastUpdateStatus is %v", label, *out.LastUpdateStatus)
+	case lambda.LastUpdateStatusFailed:
+		return false, fmt.Errorf("%v LastUpdateStatus is %v with %v: %v", label, *out.LastUpdateStatus, to.Strs(out.LastUpdateStatusReasonCode), to.Strs(out.LastUpdateStatusReason))
+	}
+
+	if sha := hexSHA256(out.CodeSha256); to.Strs(sha) != to.Strs(l.SHA256) {
+		return false, fmt.Errorf("%v CodeSha256 mismatch, expecting %v, deployed %v", label, to.Strs(l.SHA256), to.Strs(sha))
+	}
+
+	return false, nil
+}
+
+func (release *Release) verifyStepFunction(sfnc aws.SFNAPI, sm *StateMachineArtifact) error {
+	out, err := sfnc.DescribeStateMachine(&sfn.DescribeStateMachineInput{StateMachineArn: release.stepArn(sm.Name)})
+	if err != nil {
+		return err
+	}
+
+	if out == nil || out.Definition == nil {
+		return fmt.Errorf("Unknown Step Function Error")
+	}
+
+	diff, err := diffJSONStr(out.Definition, sm.StateMachineJSON)
+	if err != nil {
+		return err
+	}
+
+	if len(diff) > 0 {
+		return fmt.Errorf("%v definition mismatch: %v", release.stateMachineLabel(sm), strings.Join(diff, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package deployer
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DeployHandler_Execution_VerifyDeploy_WaitsForUpdate(t *testing.T) {
+	release := MockRelease()
+	awsc := MockAwsClients(release)
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{
+		{State: to.Strp(lambda.StatePending)},
+		{State: to.Strp(lambda.StateActive), LastUpdateStatus: to.Strp(lambda.LastUpdateStatusInProgress)},
+		{State: to.Strp(lambda.StateActive), LastUpdateStatus: to.Strp(lambda.LastUpdateStatusSuccessful)},
+	}
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exec.Output["success"])
+	assert.Equal(t, 1, len(awsc.Lambda.GetFunctionConfigurationResps))
+	assertNoRootLockWithReleseLock(t, awsc, release)
+}
+
+func Test_DeployHandler_Execution_VerifyDeploy_NeverReady(t *testing.T) {
+	release := MockRelease()
+	awsc := MockAwsClients(release)
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{
+		{LastUpdateStatus: to.Strp(lambda.LastUpdateStatusInProgress)},
+	}
+	state_machine := createTestStateMachine(t, awsc)
+
+	// The Lambda cannot be restored while it is updating
+	exec, err := state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Regexp(t, "DeployNotReadyError: Lambda lambdaname updating", exec.LastOutputJSON)
+	path := exec.Path()
+	assert.Equal(t, []string{"RestoreSnapshot", "FailureDirty"}, path[len(path)-2:])
+}
+
+func Test_DeployHandler_Execution_VerifyDeploy_CodeSHAMismatch(t *testing.T) {
+	release := MockRelease()
+	awsc := MockAwsClients(release)
+	defer mockSnapshotCode(awsc)()
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{
+		{CodeSha256: to.Strp(base64.StdEncoding.EncodeToString([]byte("other")))},
+	}
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Regexp(t, "DeployError", exec.LastOutputJSON)
+	assert.Regexp(t, "Lambda CodeSha256 mismatch", exec.LastOutputJSON)
+
+	assert.Equal(t, []string{
+		"Validate",
+		"Lock",
+		"ValidateResources",
+		"Snapshot",
+		"Deploy",
+		"VerifyDeploy",
+		"RestoreSnapshot",
+		"ReleaseLockFailure",
+		"FailureClean",
+	}, exec.Path())
+
+	// The snapshot code is deployed back
+	last := awsc.Lambda.UpdateFunctionCodeInputs[len(awsc.Lambda.UpdateFunctionCodeInputs)-1]
+	assert.Equal(t, "old_zip", string(last.ZipFile))
+}
+
+func Test_DeployHandler_Execution_VerifyDeploy_UpdateFailed(t *testing.T) {
+	release := MockRelease()
+	awsc := MockAwsClients(release)
+	awsc.Lambda.GetFunctionConfigurationResps = []*lambda.FunctionConfiguration{
+		{LastUpdateStatus: to.Strp(lambda.LastUpdateStatusFailed), LastUpdateStatusReason: to.Strp("no space")},
+	}
+	defer mockSnapshotCode(awsc)()
+	state_machine := createTestStateMachine(t, awsc)
+
+	exec, err := state_machine.Execute(release)
+	assert.Error(t, err)
+	assert.Regexp(t, "DeployError", exec.LastOutputJSON)
+	assert.Regexp(t, "no space", exec.LastOutputJSON)
+	assert.Equal(t, "FailureClean", exec.Path()[len(exec.Path())-1])
+}
+
+func Test_Release_VerifyDeploy(t *testing.T) {
+	release := MockRelease()
+	release.SetDefaults(to.Strp("us-east-1"), release.AwsAccountID, "")
+	awsc := MockAwsClients(release)
+
+	assert.NoError(t, release.DeployStepFunction(awsc.SFN))
+	assert.NoError(t, release.DeployLambda(awsc.Lambda, awsc.S3))
+
+	updating, err := release.VerifyDeploy(awsc.Lambda, awsc.SFN)
+	assert.False(t, updating)
+	assert.NoError(t, err)
+
+	// Formatting is not a change
+	awsc.SFN.Definitions["stepfnname"] = to.Strp(to.CompactJSONStr(release.StateMachineJSON))
+	_, err = release.VerifyDeploy(awsc.Lambda, awsc.SFN)
+	assert.NoError(t, err)
+
+	awsc.SFN.Definitions["stepfnname"] = to.Strp(`{"StartAt": "Old", "States": {"Old": {"Type": "Succeed"}}}`)
+	updating, err = release.VerifyDeploy(awsc.Lambda, awsc.SFN)
+	assert.False(t, updating)
+	assert.Regexp(t, `Step Function definition mismatch: ~ \$.StartAt: "Old" => "WIN"`, err)
+}
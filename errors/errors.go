@@ -2,9 +2,66 @@
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
+	"reflect"
 )
 
+//
+// Error Names
+//
+
+// NamedError is an error that declares its Step Functions error name and cause,
+// Retry and Catch rules match the name in ErrorEquals
+type NamedError interface {
+	error
+	ErrorName() string
+	ErrorCause() string
+}
+
+// ASLError is a NamedError with any name, e.g. a Lambda errorType
+type ASLError struct {
+	Name  string
+	Cause string
+}
+
+func (e *ASLError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Name, e.Cause)
+}
+
+func (e *ASLError) ErrorName() string {
+	return e.Name
+}
+
+func (e *ASLError) ErrorCause() string {
+	return e.Cause
+}
+
+// Name is the error name Step Functions sees for err: the name of the first NamedError it wraps,
+// otherwise the name of its type like the Lambda runtime reports it
+func Name(err error) string {
+	var named NamedError
+	if stderrors.As(err, &named) {
+		return named.ErrorName()
+	}
+
+	errorType := reflect.TypeOf(err)
+	if errorType.Kind() == reflect.Ptr {
+		return errorType.Elem().Name()
+	}
+	return errorType.Name()
+}
+
+// Cause is the error cause Step Functions sees for err: the cause of the first NamedError it wraps,
+// otherwise its message
+func Cause(err error) string {
+	var named NamedError
+	if stderrors.As(err, &named) {
+		return named.ErrorCause()
+	}
+	return err.Error()
+}
+
 //
 // General Errors that represent levels of action to be taken
 //
@@ -53,6 +110,14 @@ func (e PanicError) Error() string {
 	return fmt.Sprintf("PanicError: %v", e.Cause)
 }
 
+type SizeLimitError struct {
+	Cause string
+}
+
+func (e SizeLimitError) Error() string {
+	return fmt.Sprintf("SizeLimitError: %v", e.Cause)
+}
+
 //
 // Specific Deploy/Release errors
 //
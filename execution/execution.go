@@ -195,6 +195,23 @@ func (e *Execution) GetStateDetails(sfnc sfniface.SFNAPI) (*StateDetails, error)
 	return &sd, nil
 }
 
+// GetHistory returns all the events of the execution in ascending order, e.g. for machine.NewRecording
+func (e *Execution) GetHistory(sfnc sfniface.SFNAPI) ([]*sfn.HistoryEvent, error) {
+	events := []*sfn.HistoryEvent{}
+	err := sfnc.GetExecutionHistoryPages(&sfn.GetExecutionHistoryInput{
+		ExecutionArn: e.ExecutionArn,
+	}, func(page *sfn.GetExecutionHistoryOutput, _ bool) bool {
+		events = append(events, page.Events...)
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
 // WaitForExecution allows another application to wait for the execution to finish
 // and process output as it comes in for usability
 func (e *Execution) WaitForExecution(sfnc sfniface.SFNAPI, sleep int, fn ExecutionWaiter) {
@@ -0,0 +1,78 @@
+package execution
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws"
+	"github.com/coinbase/step/utils/is"
+)
+
+// The vendored aws-sdk-go predates the Step Functions RedriveExecution API,
+// so these types describe the request and Redrive sends it with the sfn client
+
+type RedriveExecutionInput struct {
+	_ struct{} `type:"structure"`
+
+	ExecutionArn *string `locationName:"executionArn" min:"1" type:"string" required:"true"`
+	ClientToken  *string `locationName:"clientToken" min:"1" type:"string"`
+}
+
+func (s RedriveExecutionInput) String() string {
+	return awsutil.Prettify(s)
+}
+
+func (s *RedriveExecutionInput) Validate() error {
+	if is.EmptyStr(s.ExecutionArn) {
+		return fmt.Errorf("ExecutionArn is required")
+	}
+	return nil
+}
+
+type RedriveExecutionOutput struct {
+	_ struct{} `type:"structure"`
+
+	RedriveDate *time.Time `locationName:"redriveDate" type:"timestamp" required:"true"`
+}
+
+func (s RedriveExecutionOutput) String() string {
+	return awsutil.Prettify(s)
+}
+
+// Redriver is implemented by clients that support RedriveExecution without the sfn request, e.g. server.SFN
+type Redriver interface {
+	RedriveExecution(*RedriveExecutionInput) (*RedriveExecutionOutput, error)
+}
+
+// Redrive restarts a failed execution at the state that failed, keeping the results of the earlier states
+func Redrive(sfnc aws.SFNAPI, arn *string) (*Execution, error) {
+	in := &RedriveExecutionInput{ExecutionArn: arn}
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch client := sfnc.(type) {
+	case Redriver:
+		_, err = client.RedriveExecution(in)
+	case *sfn.SFN:
+		req := client.NewRequest(&request.Operation{
+			Name:       "RedriveExecution",
+			HTTPMethod: "POST",
+			HTTPPath:   "/",
+		}, in, &RedriveExecutionOutput{})
+		err = req.Send()
+	default:
+		return nil, fmt.Errorf("SFN client %T does not support RedriveExecution", sfnc)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	exec, _, err := GetDetails(sfnc, arn)
+	return exec, err
+}
@@ -0,0 +1,94 @@
+package execution
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws/mocks"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+// redriveServer answers the sfn requests Redrive makes and records their targets and bodies
+func redriveServer(t *testing.T, redriveStatus int) (*sfn.SFN, map[string]map[string]interface{}, func()) {
+	requests := map[string]map[string]interface{}{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		in := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(body, &in))
+
+		target := r.Header.Get("X-Amz-Target")
+		requests[target] = in
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		switch target {
+		case "AWSStepFunctions.RedriveExecution":
+			if redriveStatus != http.StatusOK {
+				w.WriteHeader(redriveStatus)
+				w.Write([]byte(`{"__type": "ExecutionNotRedrivable", "message": "execution is not FAILED"}`))
+				return
+			}
+			w.Write([]byte(`{"redriveDate": 1700000000}`))
+		case "AWSStepFunctions.DescribeExecution":
+			w.Write([]byte(`{"executionArn": "arn", "status": "RUNNING", "startDate": 1700000000}`))
+		case "AWSStepFunctions.GetExecutionHistory":
+			w.Write([]byte(`{"events": []}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "UnknownOperationException"}`))
+		}
+	}))
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(ts.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+
+	return sfn.New(sess), requests, ts.Close
+}
+
+func Test_Redrive_SFN_Request(t *testing.T) {
+	sfnc, requests, close := redriveServer(t, http.StatusOK)
+	defer close()
+
+	exec, err := Redrive(sfnc, to.Strp("arn"))
+	assert.NoError(t, err)
+	assert.Equal(t, "RUNNING", *exec.Status)
+
+	assert.Equal(t, map[string]interface{}{"executionArn": "arn"}, requests["AWSStepFunctions.RedriveExecution"])
+	assert.Equal(t, map[string]interface{}{"executionArn": "arn"}, requests["AWSStepFunctions.DescribeExecution"])
+}
+
+func Test_Redrive_SFN_Request_Error(t *testing.T) {
+	sfnc, requests, close := redriveServer(t, http.StatusBadRequest)
+	defer close()
+
+	_, err := Redrive(sfnc, to.Strp("arn"))
+	assert.Equal(t, "ExecutionNotRedrivable", err.(awserr.Error).Code())
+
+	_, described := requests["AWSStepFunctions.DescribeExecution"]
+	assert.False(t, described)
+}
+
+func Test_Redrive_Errors(t *testing.T) {
+	// ExecutionArn is required
+	_, err := Redrive(&mocks.MockSFNClient{}, nil)
+	assert.Error(t, err)
+
+	// Clients without RedriveExecution are rejected
+	_, err = Redrive(&mocks.MockSFNClient{}, to.Strp("arn"))
+	assert.Error(t, err)
+}
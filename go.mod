@@ -1,14 +1,28 @@
 module github.com/coinbase/step
 
-go 1.14
+go 1.18
+
+require (
+	github.com/DataDog/datadog-lambda-go v0.6.0
+	github.com/aws/aws-lambda-go v1.20.0
+	github.com/aws/aws-sdk-go v1.44.334
+	github.com/google/gofuzz v0.0.0-20170612174753-24818f796faf
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/stretchr/testify v1.7.0
+	go.opentelemetry.io/otel v1.3.0
+	go.opentelemetry.io/otel/sdk v1.3.0
+	go.opentelemetry.io/otel/trace v1.3.0
+)
 
 require (
-	github.com/DataDog/datadog-lambda-go v0.6.0 // indirect
-	github.com/aws/aws-lambda-go v1.17.0
-	github.com/aws/aws-sdk-go v1.31.8
 	github.com/aws/aws-xray-sdk-go v1.0.1 // indirect
 	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/google/gofuzz v0.0.0-20170612174753-24818f796faf
-	github.com/stretchr/testify v1.5.1
+	github.com/go-logr/logr v1.2.1 // indirect
+	github.com/go-logr/stdr v1.2.0 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.1.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
 )
@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"runtime/debug"
 
+	"github.com/aws/aws-lambda-go/lambda/messages"
 	"github.com/coinbase/step/errors"
 )
 
@@ -18,21 +19,51 @@ import (
 // TaskHandlers maps a Task Name String to a function <pre>ahsufasiu</pre>
 type TaskHandlers map[string]interface{}
 
+// Handler is the Lambda handler of the Task functions
+type Handler func(ctx context.Context, input *RawMessage) (interface{}, error)
+
 // TaskReflection caches lots of the reflected values from the Task functions in order to speed up calls
 type TaskReflection struct {
-	Handler   reflect.Value
-	Type      reflect.Type
-	EventType reflect.Type
+	Handler    reflect.Value
+	Type       reflect.Type
+	EventType  reflect.Type // nil if the handler takes no input
+	Middleware []Middleware // wraps only this Task
+
+	TakesContext  bool // the first argument is a context.Context
+	ReturnsOutput bool // the handler returns (output, error) instead of only error
+
+	typed typedHandler // Task functions added with Register are called without reflection
 }
 
 // CreateTaskReflection creates a TaskReflection from a handler function
 func CreateTaskReflection(handlerSymbol interface{}) TaskReflection {
+	var middleware []Middleware
+	if task, ok := handlerSymbol.(*taskWithMiddleware); ok {
+		handlerSymbol, middleware = task.handler, task.middleware
+	}
+
+	if typed, ok := handlerSymbol.(typedHandler); ok {
+		return TaskReflection{typed: typed, Middleware: middleware}
+	}
+
 	handlerType := reflect.TypeOf(handlerSymbol)
 
-	return TaskReflection{
-		Handler:   reflect.ValueOf(handlerSymbol),
-		EventType: handlerType.In(1),
+	reflection := TaskReflection{
+		Handler:       reflect.ValueOf(handlerSymbol),
+		Type:          handlerType,
+		Middleware:    middleware,
+		TakesContext:  handlerType.NumIn() > 0 && handlerType.In(0).Implements(contextType),
+		ReturnsOutput: handlerType.NumOut() == 2,
 	}
+
+	switch {
+	case handlerType.NumIn() == 2:
+		reflection.EventType = handlerType.In(1)
+	case handlerType.NumIn() == 1 && !reflection.TakesContext:
+		reflection.EventType = handlerType.In(0)
+	}
+
+	return reflection
 }
 
 // Tasks returns all Task names from a TaskHandlers Map
@@ -69,10 +100,19 @@ func (t *TaskHandlers) Validate() error {
 
 // ValidateHandler checks a handler is a function with the correct arguments and return values
 func ValidateHandler(handlerSymbol interface{}) error {
+	if task, ok := handlerSymbol.(*taskWithMiddleware); ok {
+		handlerSymbol = task.handler
+	}
+
 	if handlerSymbol == nil {
 		return fmt.Errorf("Handler nil")
 	}
 
+	// The compiler checked the Task functions added with Register
+	if _, ok := handlerSymbol.(typedHandler); ok {
+		return nil
+	}
+
 	handlerType := reflect.TypeOf(handlerSymbol)
 
 	if handlerType.Kind() != reflect.Func {
@@ -87,28 +127,38 @@ func ValidateHandler(handlerSymbol interface{}) error {
 	return nil
 }
 
-func validateArguments(handler reflect.Type) error {
-	if handler.NumIn() != 2 {
-		return fmt.Errorf("handlers must take two arguments, but handler takes %d", handler.NumIn())
-	}
-
-	if handler.NumOut() != 2 {
-		return fmt.Errorf("handlers must return two arguments, but handler returns %d", handler.NumOut())
-	}
-
-	first_in := handler.In(0)
-	second_out := handler.Out(1)
+var (
+	contextType    = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorInterface = reflect.TypeOf((*error)(nil)).Elem()
+)
 
-	// First Argument implements Context
-	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
-	if !first_in.Implements(contextType) {
-		return fmt.Errorf("handlers first argument must implement context.Context")
+// validateArguments accepts the same handlers as aws-lambda-go:
+// optionally a context.Context then optionally an input, returning nothing, error or (output, error)
+func validateArguments(handler reflect.Type) error {
+	switch handler.NumIn() {
+	case 0, 1:
+	case 2:
+		// First Argument implements Context
+		if !handler.In(0).Implements(contextType) {
+			return fmt.Errorf("handlers that take two arguments must take context.Context first, but handler takes %v", handler.In(0))
+		}
+	default:
+		return fmt.Errorf("handlers may not take more than two arguments, but handler takes %d", handler.NumIn())
 	}
 
-	// Second Argument must be error
-	errorInterface := reflect.TypeOf((*error)(nil)).Elem()
-	if !second_out.Implements(errorInterface) {
-		return fmt.Errorf("handlers second return value must be error")
+	switch handler.NumOut() {
+	case 0:
+	case 1:
+		if !handler.Out(0).Implements(errorInterface) {
+			return fmt.Errorf("handlers that return one value must return error, but handler returns %v", handler.Out(0))
+		}
+	case 2:
+		// Second Argument must be error
+		if !handler.Out(1).Implements(errorInterface) {
+			return fmt.Errorf("handlers second return value must be error, but handler returns %v", handler.Out(1))
+		}
+	default:
+		return fmt.Errorf("handlers may not return more than two values, but handler returns %d", handler.NumOut())
 	}
 
 	return nil
@@ -119,11 +169,23 @@ func validateArguments(handler reflect.Type) error {
 //////
 
 // RawMessage is the struct passed to the Lambda Handler
-// It contains the name of the Task and the Inputs Raw message
+// It contains the name of the Task and the Inputs Raw message,
+// TraceContext is an optional W3C trace context (traceparent, tracestate) the Task span continues
 type RawMessage struct {
-	Task  *string
-	Input json.RawMessage
-	raw   []byte
+	Task         *string
+	Input        json.RawMessage
+	TraceContext map[string]string `json:",omitempty"`
+	raw          []byte
+}
+
+// MarshalJSON returns the JSON the message was unmarshalled from
+func (message *RawMessage) MarshalJSON() ([]byte, error) {
+	if message.raw != nil {
+		return message.raw, nil
+	}
+
+	type xRawMessage RawMessage
+	return json.Marshal((*xRawMessage)(message))
 }
 
 func (message *RawMessage) UnmarshalJSON(data []byte) error {
@@ -135,9 +197,10 @@ func (message *RawMessage) UnmarshalJSON(data []byte) error {
 	}
 
 	*message = RawMessage{
-		Task:  rawMessageX.Task,
-		Input: rawMessageX.Input,
-		raw:   data,
+		Task:         rawMessageX.Task,
+		Input:        rawMessageX.Input,
+		TraceContext: rawMessageX.TraceContext,
+		raw:          data,
 	}
 	return nil
 }
@@ -172,41 +235,65 @@ func (t *TaskError) Error() string {
 // FUNCTIONS
 ///////////
 
-// CreateHandler returns the handler passed to the lambda.Start function
-func CreateHandler(tm *TaskHandlers) (func(context context.Context, input *RawMessage) (interface{}, error), error) {
+// CreateHandler returns the handler passed to the lambda.Start function,
+// middleware wraps every Task in order, the first is the outermost.
+// Panics in Task functions are always returned as errors.PanicError
+func CreateHandler(tm *TaskHandlers, middleware ...Middleware) (Handler, error) {
 	if err := tm.Validate(); err != nil {
 		return nil, err
 	}
 
 	// This does most reflection before the run handler,
 	// that way there is less reflection in the main call
-	reflections := tm.Reflect()
+	tasks := map[string]Handler{}
+	for name, reflection := range tm.Reflect() {
+		tasks[name] = reflection.handler(name == "")
+	}
 
-	handler := func(ctx context.Context, input *RawMessage) (interface{}, error) {
+	dispatch := Chain(middleware...)(func(ctx context.Context, input *RawMessage) (interface{}, error) {
 		// Find Resource Handler
 		task_name := input.Task
 		if task_name == nil {
 			// If task_name cannot be found look for empty string (NoTask) handler
-			reflection, ok := reflections[""]
+			handler, ok := tasks[""]
 			if !ok {
 				return nil, &TaskError{"Nil Task In Message", nil, nil}
 			}
 			// call NoTask handler
-			return CallHandler(reflection, ctx, input.raw)
+			return handler(ctx, input)
 		}
 
-		reflection, ok := reflections[*task_name]
+		handler, ok := tasks[*task_name]
 
 		if !ok {
 			return nil, &TaskError{"Cannot Find Task", task_name, tm.Tasks()}
 		}
 
-		return CallHandler(reflection, ctx, input.Input)
+		return handler(ctx, input)
+	})
+
+	handler := func(ctx context.Context, input *RawMessage) (ret interface{}, err error) {
+		ctx, span := startTaskSpan(ctx, input)
+		defer func() { endTaskSpan(span, err) }()
+
+		return dispatch(ctx, input)
 	}
 
 	return handler, nil
 }
 
+// handler calls the Task function with the Input of the message, or the whole message for the NoTask handler
+func (reflection TaskReflection) handler(no_task bool) Handler {
+	call := func(ctx context.Context, input *RawMessage) (interface{}, error) {
+		if no_task {
+			return CallHandler(reflection, ctx, input.raw)
+		}
+		return CallHandler(reflection, ctx, input.Input)
+	}
+
+	return Chain(reflection.Middleware...)(Recover(call))
+}
+
 func recoveryError(r interface{}) error {
 	switch x := r.(type) {
 	case string:
@@ -222,43 +309,78 @@ func recoveryError(r interface{}) error {
 // HANDLERS
 
 // CallHandler calls a TaskReflections Handler with the correct objects using reflection
-// Mostly borrowed from the aws-lambda-go package
+// Mostly borrowed from the aws-lambda-go package. Panics are recovered by the Recover middleware of CreateHandler
 func CallHandler(reflection TaskReflection, ctx context.Context, input []byte) (ret interface{}, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("Recovering", r, fmt.Sprintf("%s\n", debug.Stack()))
-			err = recoveryError(r)
-			ret = nil
-		}
-	}()
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-	event := reflect.New(reflection.EventType)
+	defer func() { err = namedError(err) }()
 
-	if err = json.Unmarshal(input, event.Interface()); err != nil {
-		return nil, errors.UnmarshalError{err.Error()}
+	if reflection.typed != nil {
+		return reflection.typed.call(ctx, input)
 	}
 
 	// Get Type of Function Input
 	var args []reflect.Value
-	if ctx == nil {
-		ctx = context.Background()
+	if reflection.TakesContext {
+		args = append(args, reflect.ValueOf(ctx))
 	}
 
-	args = append(args, reflect.ValueOf(ctx))
-	args = append(args, event.Elem())
+	// Handlers without an input ignore it
+	if reflection.EventType != nil {
+		event := reflect.New(reflection.EventType)
+
+		if err = json.Unmarshal(input, event.Interface()); err != nil {
+			return nil, errors.UnmarshalError{err.Error()}
+		}
+
+		args = append(args, event.Elem())
+	}
 
 	response := reflection.Handler.Call(args)
 
-	if errVal, ok := response[1].Interface().(error); ok {
+	// Handlers that return nothing have a nil output and error
+	if len(response) == 0 {
+		return nil, nil
+	}
+
+	// Handlers that only return an error have a nil output
+	errIndex := 0
+	if reflection.ReturnsOutput {
+		ret = response[0].Interface()
+		errIndex = 1
+	}
+
+	if errVal, ok := response[errIndex].Interface().(error); ok {
 		err = errVal
 	}
-	ret = response[0].Interface()
 
 	return ret, err
 }
 
+// namedError keeps the errorType of Lambda error responses returned by Task functions,
+// so the state machine matches them by the same name as Step Functions
+func namedError(err error) error {
+	switch ire := err.(type) {
+	case messages.InvokeResponse_Error:
+		return &errors.ASLError{Name: ire.Type, Cause: ire.Message}
+	case *messages.InvokeResponse_Error:
+		return &errors.ASLError{Name: ire.Type, Cause: ire.Message}
+	}
+	return err
+}
+
 // CallHandlerFunction does reflection inline and should only be used for testing
-func CallHandlerFunction(handlerSymbol interface{}, ctx context.Context, input interface{}) (interface{}, error) {
+func CallHandlerFunction(handlerSymbol interface{}, ctx context.Context, input interface{}) (ret interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Recovering", r, fmt.Sprintf("%s\n", debug.Stack()))
+			err = recoveryError(r)
+			ret = nil
+		}
+	}()
+
 	if err := ValidateHandler(handlerSymbol); err != nil {
 		return nil, err
 	}
@@ -89,3 +89,16 @@ func Test_Handler_Failure(t *testing.T) {
 	_, err = handle(nil, &RawMessage{Task: to.Strp("Tester")})
 	assert.Error(t, err)
 }
+
+func Test_CallHandler_Panic(t *testing.T) {
+	tm := TaskHandlers{
+		"Panic": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			panic("at the disco")
+		},
+	}
+
+	// The Recover middleware of CreateHandler recovers it
+	assert.PanicsWithValue(t, "at the disco", func() {
+		CallHandler(tm.Reflect()["Panic"], nil, []byte(`{}`))
+	})
+}
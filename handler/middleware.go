@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/coinbase/step/errors"
+)
+
+// Middleware wraps a Handler with cross-cutting behavior,
+// it is passed to CreateHandler for all Tasks or WithMiddleware for one Task
+type Middleware func(next Handler) Handler
+
+// Chain combines middleware into one, the first is the outermost
+func Chain(middleware ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
+
+// taskWithMiddleware is a Task function with its own middleware
+type taskWithMiddleware struct {
+	handler    interface{}
+	middleware []Middleware
+}
+
+// WithMiddleware wraps a single Task function of TaskHandlers, e.g.
+//
+//	TaskHandlers{"Deploy": WithMiddleware(deploy, Idempotent(store))}
+func WithMiddleware(handlerSymbol interface{}, middleware ...Middleware) interface{} {
+	return &taskWithMiddleware{handlerSymbol, middleware}
+}
+
+// taskName is the Task of the message, or "" for the NoTask handler
+func taskName(input *RawMessage) string {
+	if input == nil || input.Task == nil {
+		return ""
+	}
+	return *input.Task
+}
+
+// Recover returns panics as errors.PanicError
+func Recover(next Handler) Handler {
+	return func(ctx context.Context, input *RawMessage) (ret interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Println("Recovering", r, fmt.Sprintf("%s\n", debug.Stack()))
+				err = recoveryError(r)
+				ret = nil
+			}
+		}()
+
+		return next(ctx, input)
+	}
+}
+
+// LambdaErrors returns errors as Lambda error responses with the errorType errors.Name and errorMessage errors.Cause,
+// so Step Functions Retry and Catch match the same names as the local state machine
+func LambdaErrors(next Handler) Handler {
+	return func(ctx context.Context, input *RawMessage) (interface{}, error) {
+		output, err := next(ctx, input)
+		if err != nil {
+			return output, messages.InvokeResponse_Error{Type: errors.Name(err), Message: errors.Cause(err)}
+		}
+		return output, nil
+	}
+}
+
+// Logging writes a JSON line for each Task call with its duration and error
+func Logging(w io.Writer) Middleware {
+	var mu sync.Mutex
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, input *RawMessage) (interface{}, error) {
+			start := time.Now()
+			output, err := next(ctx, input)
+
+			line := map[string]interface{}{
+				"level":       "info",
+				"msg":         "task succeeded",
+				"task":        taskName(input),
+				"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			}
+
+			if err != nil {
+				line["level"] = "error"
+				line["msg"] = "task failed"
+				line["error"] = errors.Name(err)
+				line["cause"] = errors.Cause(err)
+			}
+
+			raw, _ := json.Marshal(line)
+
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintln(w, string(raw))
+
+			return output, err
+		}
+	}
+}
+
+// Timing calls record with the duration of each Task call, e.g. to send it as a metric
+func Timing(record func(task string, duration time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, input *RawMessage) (interface{}, error) {
+			start := time.Now()
+			output, err := next(ctx, input)
+			record(taskName(input), time.Since(start), err)
+			return output, err
+		}
+	}
+}
+
+// SizeLimit returns errors.SizeLimitError if the Task input or JSON output is over the limits in bytes,
+// e.g. the 262144 byte Step Functions payload limit. A limit of 0 is not checked
+func SizeLimit(max_input int, max_output int) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, input *RawMessage) (interface{}, error) {
+			if max_input > 0 && input != nil && len(input.Input) > max_input {
+				return nil, errors.SizeLimitError{Cause: fmt.Sprintf("input of %v bytes is over the limit of %v bytes", len(input.Input), max_input)}
+			}
+
+			output, err := next(ctx, input)
+			if err != nil || max_output == 0 {
+				return output, err
+			}
+
+			raw, err := json.Marshal(output)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(raw) > max_output {
+				return nil, errors.SizeLimitError{Cause: fmt.Sprintf("output of %v bytes is over the limit of %v bytes", len(raw), max_output)}
+			}
+
+			return output, nil
+		}
+	}
+}
+
+// Classify replaces Task errors with the result of classify, so a state machine can Retry or Catch them by name,
+// e.g. returning errors.AlertError for errors that need a person
+func Classify(classify func(task string, err error) error) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, input *RawMessage) (interface{}, error) {
+			output, err := next(ctx, input)
+			if err == nil {
+				return output, nil
+			}
+
+			if classified := classify(taskName(input), err); classified != nil {
+				return output, classified
+			}
+
+			return output, err
+		}
+	}
+}
+
+// IdempotencyStore keeps the JSON output of Task calls by key
+type IdempotencyStore interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, output []byte) error
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	outputs map[string][]byte
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{outputs: map[string][]byte{}}
+}
+
+func (s *MemoryIdempotencyStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	output, ok := s.outputs[key]
+	return output, ok, nil
+}
+
+func (s *MemoryIdempotencyStore) Set(key string, output []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outputs[key] = output
+	return nil
+}
+
+// Idempotent returns the stored output of an earlier successful call with the same Task and input,
+// instead of calling the Task again
+func Idempotent(store IdempotencyStore) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, input *RawMessage) (interface{}, error) {
+			// The NoTask handler is called with the whole message
+			raw := []byte(input.Input)
+			if input.Task == nil {
+				var err error
+				if raw, err = json.Marshal(input); err != nil {
+					return nil, err
+				}
+			}
+
+			sum := sha256.Sum256(raw)
+			key := fmt.Sprintf("%v:%v", taskName(input), hex.EncodeToString(sum[:]))
+
+			stored, ok, err := store.Get(key)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				var output interface{}
+				if err := json.Unmarshal(stored, &output); err != nil {
+					return nil, err
+				}
+				return output, nil
+			}
+
+			output, err := next(ctx, input)
+			if err != nil {
+				return output, err
+			}
+
+			raw, err = json.Marshal(output)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := store.Set(key, raw); err != nil {
+				return nil, err
+			}
+
+			return output, nil
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/coinbase/step/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func rawMessage(t *testing.T, message string) *RawMessage {
+	var raw RawMessage
+	assert.NoError(t, json.Unmarshal([]byte(message), &raw))
+	return &raw
+}
+
+// recordCalls is Middleware that appends name to calls before calling next
+func recordCalls(calls *[]string, name string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, input *RawMessage) (interface{}, error) {
+			*calls = append(*calls, name)
+			return next(ctx, input)
+		}
+	}
+}
+
+func Test_Middleware_Order(t *testing.T) {
+	calls := []string{}
+	handle, err := CreateHandler(&TaskHandlers{
+		"Tester": WithMiddleware(func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			calls = append(calls, "Tester")
+			return "done", nil
+		}, recordCalls(&calls, "task")),
+		"Other": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			return "other", nil
+		},
+	}, recordCalls(&calls, "first"), recordCalls(&calls, "second"))
+	assert.NoError(t, err)
+
+	out, err := handle(nil, rawMessage(t, `{"Task": "Tester", "Input": {}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "done", out)
+	assert.Equal(t, []string{"first", "second", "task", "Tester"}, calls)
+
+	// Task middleware only wraps its Task
+	calls = []string{}
+	_, err = handle(nil, rawMessage(t, `{"Task": "Other", "Input": {}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func Test_Middleware_Recover(t *testing.T) {
+	handle, err := CreateHandler(&TaskHandlers{
+		"Panic": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			panic("at the disco")
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = handle(nil, rawMessage(t, `{"Task": "Panic", "Input": {}}`))
+	assert.Equal(t, errors.PanicError{Cause: "at the disco"}, err)
+}
+
+func Test_Middleware_LoggingTiming(t *testing.T) {
+	var logs bytes.Buffer
+	timed := []string{}
+
+	handle, err := CreateHandler(&TaskHandlers{
+		"Fails": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			return nil, &TaskError{"bad", nil, nil}
+		},
+	}, Logging(&logs), Timing(func(task string, duration time.Duration, err error) {
+		timed = append(timed, task)
+	}))
+	assert.NoError(t, err)
+
+	_, err = handle(nil, rawMessage(t, `{"Task": "Fails", "Input": {}}`))
+	assert.Error(t, err)
+	assert.Equal(t, []string{"Fails"}, timed)
+
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+	assert.Equal(t, "error", line["level"])
+	assert.Equal(t, "Fails", line["task"])
+	assert.Equal(t, "TaskError", line["error"])
+	assert.Contains(t, line, "duration_ms")
+}
+
+func Test_Middleware_SizeLimit(t *testing.T) {
+	handle, err := CreateHandler(&TaskHandlers{
+		"Echo": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			return ts, nil
+		},
+	}, SizeLimit(30, 20))
+	assert.NoError(t, err)
+
+	_, err = handle(nil, rawMessage(t, `{"Task": "Echo", "Input": {"Message": "this message is too long"}}`))
+	assert.IsType(t, errors.SizeLimitError{}, err)
+	assert.Regexp(t, "input of 39 bytes", err.Error())
+
+	_, err = handle(nil, rawMessage(t, `{"Task": "Echo", "Input": {"Message": "too long"}}`))
+	assert.IsType(t, errors.SizeLimitError{}, err)
+	assert.Regexp(t, "output of 22 bytes", err.Error())
+
+	out, err := handle(nil, rawMessage(t, `{"Task": "Echo", "Input": {"Message": "ok"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", *out.(*TestStruct).Message)
+}
+
+func Test_Middleware_Classify(t *testing.T) {
+	handle, err := CreateHandler(&TaskHandlers{
+		"Fails": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			return nil, fmt.Errorf("throttled")
+		},
+	}, Classify(func(task string, err error) error {
+		if err.Error() == "throttled" {
+			return errors.NotifyError{Cause: fmt.Sprintf("%v %v", task, err)}
+		}
+		return nil
+	}))
+	assert.NoError(t, err)
+
+	_, err = handle(nil, rawMessage(t, `{"Task": "Fails", "Input": {}}`))
+	assert.Equal(t, errors.NotifyError{Cause: "Fails throttled"}, err)
+}
+
+func Test_Middleware_LambdaErrors(t *testing.T) {
+	handle, err := CreateHandler(&TaskHandlers{
+		"Named": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			return nil, &errors.ASLError{Name: "Deploy.Failed", Cause: "bad release"}
+		},
+		"Typed": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			return nil, errors.HaltError{Cause: "halted"}
+		},
+		"Response": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			return nil, messages.InvokeResponse_Error{Type: "Upstream.Failed", Message: "upstream"}
+		},
+	})
+	assert.NoError(t, err)
+	lambda := LambdaErrors(handle)
+
+	_, err = lambda(nil, rawMessage(t, `{"Task": "Named", "Input": {}}`))
+	assert.Equal(t, messages.InvokeResponse_Error{Type: "Deploy.Failed", Message: "bad release"}, err)
+
+	_, err = lambda(nil, rawMessage(t, `{"Task": "Typed", "Input": {}}`))
+	assert.Equal(t, messages.InvokeResponse_Error{Type: "HaltError", Message: "HaltError: halted"}, err)
+
+	// Lambda error responses from Task functions keep their errorType
+	_, err = handle(nil, rawMessage(t, `{"Task": "Response", "Input": {}}`))
+	assert.Equal(t, &errors.ASLError{Name: "Upstream.Failed", Cause: "upstream"}, err)
+
+	output, err := lambda(nil, rawMessage(t, `{"Task": "Named", "Input": "x"}`))
+	assert.Nil(t, output)
+	assert.Equal(t, "UnmarshalError", err.(messages.InvokeResponse_Error).Type)
+}
+
+func Test_Middleware_Idempotent(t *testing.T) {
+	calls := 0
+	handle, err := CreateHandler(&TaskHandlers{
+		"Count": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			calls++
+			return map[string]interface{}{"calls": calls}, nil
+		},
+	}, Idempotent(NewMemoryIdempotencyStore()))
+	assert.NoError(t, err)
+
+	out, err := handle(nil, rawMessage(t, `{"Task": "Count", "Input": {"Message": "a"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"calls": 1}, out)
+
+	// The same input returns the stored output
+	out, err = handle(nil, rawMessage(t, `{"Task": "Count", "Input": {"Message": "a"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"calls": float64(1)}, out)
+
+	out, err = handle(nil, rawMessage(t, `{"Task": "Count", "Input": {"Message": "b"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"calls": 2}, out)
+	assert.Equal(t, 2, calls)
+}
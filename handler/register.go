@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/coinbase/step/errors"
+)
+
+// typedHandler is a Task function added with Register
+type typedHandler interface {
+	call(ctx context.Context, input []byte) (interface{}, error)
+}
+
+type typedTask[In any, Out any] func(context.Context, In) (Out, error)
+
+func (fn typedTask[In, Out]) call(ctx context.Context, input []byte) (interface{}, error) {
+	var event In
+	if err := json.Unmarshal(input, &event); err != nil {
+		return nil, errors.UnmarshalError{Cause: err.Error()}
+	}
+
+	return fn(ctx, event)
+}
+
+// Register adds the Task function fn as name to th, creating th's map if it is nil, optionally wrapped by middleware.
+// Unlike assigning to the map its signature is checked by the compiler and it is called without reflection, e.g.
+//
+//	handler.Register(&tm, "Hello", HelloHandler)
+func Register[In any, Out any](th *TaskHandlers, name string, fn func(context.Context, In) (Out, error), middleware ...Middleware) {
+	var task interface{}
+	if fn != nil {
+		task = typedTask[In, Out](fn)
+	}
+
+	if len(middleware) > 0 {
+		task = WithMiddleware(task, middleware...)
+	}
+
+	if *th == nil {
+		*th = TaskHandlers{}
+	}
+
+	(*th)[name] = task
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/step/errors"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+type greeting struct {
+	Greeting string
+}
+
+func Test_Register(t *testing.T) {
+	calls := []string{}
+	tm := TaskHandlers{
+		"Map": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			return "map", nil
+		},
+	}
+	Register(&tm, "Typed", func(_ context.Context, ts *TestStruct) (*greeting, error) {
+		return &greeting{"hello " + *ts.Message}, nil
+	})
+	Register(&tm, "Value", func(_ context.Context, ts TestStruct) (string, error) {
+		return *ts.Message, nil
+	}, recordCalls(&calls, "value"))
+
+	assert.NoError(t, tm.Validate())
+	assert.ElementsMatch(t, []string{"Map", "Typed", "Value"}, tm.Tasks())
+
+	handle, err := CreateHandler(&tm)
+	assert.NoError(t, err)
+
+	out, err := handle(nil, rawMessage(t, `{"Task": "Typed", "Input": {"Message": "step"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &greeting{"hello step"}, out)
+
+	out, err = handle(nil, rawMessage(t, `{"Task": "Value", "Input": {"Message": "value"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "value", out)
+	assert.Equal(t, []string{"value"}, calls)
+
+	out, err = handle(nil, rawMessage(t, `{"Task": "Map", "Input": {}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "map", out)
+
+	_, err = handle(nil, rawMessage(t, `{"Task": "Typed", "Input": "not a struct"}`))
+	assert.IsType(t, errors.UnmarshalError{}, err)
+
+	// Typed Task functions can be called directly
+	out, err = CallHandlerFunction(tm["Typed"], nil, map[string]interface{}{"Message": "direct"})
+	assert.NoError(t, err)
+	assert.Equal(t, &greeting{"hello direct"}, out)
+}
+
+func Test_Register_Nil(t *testing.T) {
+	tm := TaskHandlers{}
+	var fn func(context.Context, *TestStruct) (interface{}, error)
+	Register(&tm, "Nil", fn)
+
+	err := tm.Validate()
+	assert.Error(t, err)
+	assert.Equal(t, &TaskError{"Handler nil", to.Strp("Nil"), []string{"Nil"}}, err)
+}
+
+func Test_Register_Nil_TaskHandlers(t *testing.T) {
+	var tm TaskHandlers
+	Register(&tm, "Typed", func(_ context.Context, ts *TestStruct) (*greeting, error) {
+		return &greeting{"hello " + *ts.Message}, nil
+	})
+
+	assert.NoError(t, tm.Validate())
+	assert.Equal(t, []string{"Typed"}, tm.Tasks())
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Handler_Signatures(t *testing.T) {
+	calls := []string{}
+	call := func(name string) { calls = append(calls, name) }
+	message := func(ts *TestStruct) string { return *ts.Message }
+
+	tm := TaskHandlers{
+		"Empty":             func() { call("Empty") },
+		"Error":             func() error { call("Error"); return nil },
+		"Input":             func(ts *TestStruct) error { call(message(ts)); return nil },
+		"Output":            func() (string, error) { return "output", nil },
+		"InputOutput":       func(ts TestStruct) (string, error) { return *ts.Message, nil },
+		"Context":           func(context.Context) error { call("Context"); return nil },
+		"ContextInput":      func(_ context.Context, ts *TestStruct) error { call(message(ts)); return nil },
+		"ContextOutput":     func(context.Context) (int, error) { return 1, nil },
+		"ContextInputError": func(_ context.Context, ts *TestStruct) error { return fmt.Errorf("bad %v", *ts.Message) },
+	}
+
+	assert.NoError(t, tm.Validate())
+
+	handle, err := CreateHandler(&tm)
+	assert.NoError(t, err)
+
+	outputs := map[string]interface{}{}
+	for _, task := range []string{"Empty", "Error", "Input", "Output", "InputOutput", "Context", "ContextInput", "ContextOutput"} {
+		out, err := handle(nil, rawMessage(t, fmt.Sprintf(`{"Task": %q, "Input": {"Message": "%v-input"}}`, task, task)))
+		assert.NoError(t, err)
+		outputs[task] = out
+	}
+
+	assert.Equal(t, []string{"Empty", "Error", "Input-input", "Context", "ContextInput-input"}, calls)
+	assert.Equal(t, map[string]interface{}{
+		"Empty":         nil,
+		"Error":         nil,
+		"Input":         nil,
+		"Output":        "output",
+		"InputOutput":   "InputOutput-input",
+		"Context":       nil,
+		"ContextInput":  nil,
+		"ContextOutput": 1,
+	}, outputs)
+
+	_, err = handle(nil, rawMessage(t, `{"Task": "ContextInputError", "Input": {"Message": "input"}}`))
+	assert.EqualError(t, err, "bad input")
+}
+
+func Test_ValidateHandler_Errors(t *testing.T) {
+	tests := []struct {
+		handler interface{}
+		message string
+	}{
+		{"not a function", "handler kind string is not func"},
+		{func(a string, b string) error { return nil }, "must take context.Context first, but handler takes string"},
+		{func(context.Context, string, string) error { return nil }, "may not take more than two arguments, but handler takes 3"},
+		{func() string { return "" }, "must return error, but handler returns string"},
+		{func() (string, string) { return "", "" }, "second return value must be error, but handler returns string"},
+		{func() (int, int, error) { return 0, 0, nil }, "may not return more than two values, but handler returns 3"},
+	}
+
+	for _, test := range tests {
+		err := ValidateHandler(test.handler)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), test.message)
+		}
+	}
+}
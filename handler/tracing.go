@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/coinbase/step/handler"
+
+var taskNameKey = attribute.Key("step.task.name")
+
+// TraceContext returns the W3C trace context of the span in ctx, to send as a RawMessage TraceContext
+func TraceContext(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier
+}
+
+// startTaskSpan starts the span of a Task dispatch with the global TracerProvider,
+// the parent is the TraceContext of the message or the span in ctx
+func startTaskSpan(ctx context.Context, input *RawMessage) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if len(input.TraceContext) > 0 {
+		ctx = propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier(input.TraceContext))
+	}
+
+	name := "Task"
+	if input.Task != nil {
+		name = *input.Task
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(taskNameKey.String(name)),
+	)
+}
+
+func endTaskSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
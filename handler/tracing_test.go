@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_Handler_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	handle, err := CreateHandler(&TaskHandlers{
+		"Deploy": func(_ context.Context, ts *TestStruct) (interface{}, error) {
+			return nil, fmt.Errorf("bad deploy")
+		},
+	})
+	assert.NoError(t, err)
+
+	// The caller sends its trace context in the message
+	ctx, parent := tp.Tracer("caller").Start(context.Background(), "Caller")
+	message, err := json.Marshal(map[string]interface{}{
+		"Task":         "Deploy",
+		"Input":        map[string]interface{}{},
+		"TraceContext": TraceContext(ctx),
+	})
+	assert.NoError(t, err)
+	parent.End()
+
+	var raw RawMessage
+	assert.NoError(t, json.Unmarshal(message, &raw))
+
+	_, err = handle(context.Background(), &raw)
+	assert.Error(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+
+	task := spans[1]
+	assert.Equal(t, "Deploy", task.Name)
+	assert.Equal(t, codes.Error, task.Status.Code)
+	assert.Equal(t, parent.SpanContext().TraceID(), task.SpanContext.TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), task.Parent.SpanID())
+}
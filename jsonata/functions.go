@@ -0,0 +1,1286 @@
+package jsonata
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// builtin is a standard library function
+type builtin func(args []interface{}) (interface{}, error)
+
+func (b builtin) call(args []interface{}) (interface{}, error) {
+	return b(args)
+}
+
+var builtins map[string]function
+
+func init() {
+	builtins = map[string]function{
+		// String
+		"string":             builtin(fnString),
+		"length":             builtin(fnLength),
+		"substring":          builtin(fnSubstring),
+		"substringBefore":    builtin(fnSubstringBefore),
+		"substringAfter":     builtin(fnSubstringAfter),
+		"uppercase":          builtin(stringFn(strings.ToUpper)),
+		"lowercase":          builtin(stringFn(strings.ToLower)),
+		"trim":               builtin(stringFn(func(s string) string { return strings.Join(strings.Fields(s), " ") })),
+		"pad":                builtin(fnPad),
+		"contains":           builtin(fnContains),
+		"split":              builtin(fnSplit),
+		"join":               builtin(fnJoin),
+		"replace":            builtin(fnReplace),
+		"encodeUrl":          builtin(uriFn(";,/?:@&=+$#-_.!~*'()")),
+		"encodeUrlComponent": builtin(uriFn("-_.!~*'()")),
+		"decodeUrl":          builtin(fnDecodeURL),
+		"decodeUrlComponent": builtin(fnDecodeURL),
+		// Numeric
+		"number":       builtin(fnNumber),
+		"abs":          builtin(numberFn(math.Abs)),
+		"floor":        builtin(numberFn(math.Floor)),
+		"ceil":         builtin(numberFn(math.Ceil)),
+		"round":        builtin(fnRound),
+		"power":        builtin(fnPower),
+		"sqrt":         builtin(fnSqrt),
+		"formatNumber": builtin(fnFormatNumber),
+		"formatBase":   builtin(fnFormatBase),
+		"sum":          builtin(aggregateFn(func(nums []float64) interface{} { return sum(nums) })),
+		"max":          builtin(aggregateFn(func(nums []float64) interface{} { return extreme(nums, 1) })),
+		"min":          builtin(aggregateFn(func(nums []float64) interface{} { return extreme(nums, -1) })),
+		"average":      builtin(aggregateFn(average)),
+		"random":       builtin(fnRandom),
+		// Boolean
+		"boolean": builtin(fnBoolean),
+		"not":     builtin(fnNot),
+		"exists":  builtin(fnExists),
+		// Array
+		"count":    builtin(fnCount),
+		"append":   builtin(fnAppend),
+		"reverse":  builtin(fnReverse),
+		"distinct": builtin(fnDistinct),
+		"sort":     builtin(fnSort),
+		"shuffle":  builtin(fnShuffle),
+		"zip":      builtin(fnZip),
+		"map":      builtin(fnMap),
+		"filter":   builtin(fnFilter),
+		"single":   builtin(fnSingle),
+		"reduce":   builtin(fnReduce),
+		// Object
+		"keys":   builtin(fnKeys),
+		"lookup": builtin(fnLookup),
+		"spread": builtin(fnSpread),
+		"merge":  builtin(fnMerge),
+		"each":   builtin(fnEach),
+		"sift":   builtin(fnSift),
+		"type":   builtin(fnType),
+		"error":  builtin(fnError),
+		"assert": builtin(fnAssert),
+		// Date
+		"now":        builtin(fnNow),
+		"millis":     builtin(fnMillis),
+		"fromMillis": builtin(fnFromMillis),
+		"toMillis":   builtin(fnToMillis),
+		// Step Functions
+		"partition": builtin(fnPartition),
+		"range":     builtin(fnRange),
+		"hash":      builtin(fnHash),
+		"uuid":      builtin(fnUUID),
+		"parse":     builtin(fnParse),
+		// Encoding
+		"base64encode": builtin(stringFn(func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) })),
+		"base64decode": builtin(fnBase64Decode),
+	}
+}
+
+//////
+// Helpers
+//////
+
+func arg(args []interface{}, i int) interface{} {
+	if i >= len(args) {
+		return undefined
+	}
+	return args[i]
+}
+
+func argStr(args []interface{}, i int) (string, bool, error) {
+	value := arg(args, i)
+	if value == undefined {
+		return "", false, nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", false, fmt.Errorf("argument %v must be a string", i+1)
+	}
+	return str, true, nil
+}
+
+func argNum(args []interface{}, i int) (float64, bool, error) {
+	value := arg(args, i)
+	if value == undefined {
+		return 0, false, nil
+	}
+	num, ok := value.(float64)
+	if !ok {
+		return 0, false, fmt.Errorf("argument %v must be a number", i+1)
+	}
+	return num, true, nil
+}
+
+func argFn(args []interface{}, i int) (function, error) {
+	fn, ok := arg(args, i).(function)
+	if !ok {
+		return nil, fmt.Errorf("argument %v must be a function", i+1)
+	}
+	return fn, nil
+}
+
+// formatNumber formats like JSONata, 15 significant digits written as JavaScript does:
+// without an exponent from 1e-7 up to 1e21
+func formatNumber(v float64) string {
+	v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+
+	abs := math.Abs(v)
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(v, 'e', -1, 64), "e")
+		sign, digits := exponent[:1], strings.TrimLeft(exponent[1:], "0")
+		return fmt.Sprintf("%ve%v%v", mantissa, sign, digits)
+	}
+
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// toString implements $string casting
+func toString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case undefinedType:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return "", fmt.Errorf("cannot convert %v to string", v)
+		}
+		return formatNumber(v), nil
+	case function:
+		return "", nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+func stringFn(fn func(string) string) builtin {
+	return func(args []interface{}) (interface{}, error) {
+		str, ok, err := argStr(args, 0)
+		if err != nil || !ok {
+			return undefined, err
+		}
+		return fn(str), nil
+	}
+}
+
+func numberFn(fn func(float64) float64) builtin {
+	return func(args []interface{}) (interface{}, error) {
+		num, ok, err := argNum(args, 0)
+		if err != nil || !ok {
+			return undefined, err
+		}
+		return fn(num), nil
+	}
+}
+
+func aggregateFn(fn func([]float64) interface{}) builtin {
+	return func(args []interface{}) (interface{}, error) {
+		value := arg(args, 0)
+		if value == undefined {
+			return undefined, nil
+		}
+
+		nums := []float64{}
+		for _, item := range items(value) {
+			num, ok := item.(float64)
+			if !ok {
+				return nil, fmt.Errorf("argument must be an array of numbers")
+			}
+			nums = append(nums, num)
+		}
+		return fn(nums), nil
+	}
+}
+
+func sum(nums []float64) float64 {
+	total := 0.0
+	for _, n := range nums {
+		total += n
+	}
+	return total
+}
+
+func extreme(nums []float64, sign float64) interface{} {
+	if len(nums) == 0 {
+		return undefined
+	}
+	result := nums[0]
+	for _, n := range nums[1:] {
+		if (n-result)*sign > 0 {
+			result = n
+		}
+	}
+	return result
+}
+
+func average(nums []float64) interface{} {
+	if len(nums) == 0 {
+		return undefined
+	}
+	return sum(nums) / float64(len(nums))
+}
+
+//////
+// String
+//////
+
+func fnString(args []interface{}) (interface{}, error) {
+	value := arg(args, 0)
+	if value == undefined {
+		return undefined, nil
+	}
+	return toString(value)
+}
+
+func fnLength(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+	return float64(utf8.RuneCountInString(str)), nil
+}
+
+func fnSubstring(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	runes := []rune(str)
+	start, _, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	s := int(start)
+	if s < 0 {
+		s = len(runes) + s
+	}
+	if s < 0 {
+		s = 0
+	}
+	if s > len(runes) {
+		return "", nil
+	}
+
+	end := len(runes)
+	if length, ok, err := argNum(args, 2); err != nil {
+		return nil, err
+	} else if ok {
+		if length <= 0 {
+			return "", nil
+		}
+		if s+int(length) < end {
+			end = s + int(length)
+		}
+	}
+
+	return string(runes[s:end]), nil
+}
+
+func fnContains(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	pattern, _, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Contains(str, pattern), nil
+}
+
+func fnSplit(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	separator, _, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(str, separator)
+	if limit, ok, err := argNum(args, 2); err != nil {
+		return nil, err
+	} else if ok && int(limit) < len(parts) {
+		parts = parts[:int(limit)]
+	}
+
+	result := []interface{}{}
+	for _, p := range parts {
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+func fnJoin(args []interface{}) (interface{}, error) {
+	value := arg(args, 0)
+	if value == undefined {
+		return undefined, nil
+	}
+
+	separator, _, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	strs := []string{}
+	for _, item := range items(value) {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("argument 1 must be an array of strings")
+		}
+		strs = append(strs, str)
+	}
+	return strings.Join(strs, separator), nil
+}
+
+func fnReplace(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	pattern, _, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, _, err := argStr(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := -1
+	if l, ok, err := argNum(args, 3); err != nil {
+		return nil, err
+	} else if ok {
+		limit = int(l)
+	}
+	return strings.Replace(str, pattern, replacement, limit), nil
+}
+
+func fnSubstringBefore(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	chars, _, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	before, _, _ := strings.Cut(str, chars)
+	return before, nil
+}
+
+func fnSubstringAfter(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	chars, _, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, after, found := strings.Cut(str, chars); found {
+		return after, nil
+	}
+	return str, nil
+}
+
+// fnPad pads to the right for a positive width and to the left for a negative width
+func fnPad(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	width, _, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	char, ok, err := argStr(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || char == "" {
+		char = " "
+	}
+
+	padLength := int(math.Abs(width)) - utf8.RuneCountInString(str)
+	if padLength <= 0 {
+		return str, nil
+	}
+
+	padding := string([]rune(strings.Repeat(char, padLength))[:padLength])
+	if width > 0 {
+		return str + padding, nil
+	}
+	return padding + str, nil
+}
+
+// uriFn percent encodes like JavaScript encodeURI and encodeURIComponent,
+// keeping letters, digits and the reserved characters
+func uriFn(reserved string) builtin {
+	return stringFn(func(s string) string {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c < utf8.RuneSelf && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(reserved, c) >= 0) {
+				b.WriteByte(c)
+				continue
+			}
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+		return b.String()
+	})
+}
+
+func fnDecodeURL(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+	return url.PathUnescape(str)
+}
+
+//////
+// Numeric
+//////
+
+func fnNumber(args []interface{}) (interface{}, error) {
+	switch v := arg(args, 0).(type) {
+	case undefinedType:
+		return undefined, nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case string:
+		num, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to cast %q to a number", v)
+		}
+		return num, nil
+	}
+	return nil, fmt.Errorf("unable to cast to a number")
+}
+
+func fnRound(args []interface{}) (interface{}, error) {
+	num, ok, err := argNum(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	precision, _, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// round half to even like JSONata
+	shift := math.Pow(10, precision)
+	return math.RoundToEven(num*shift) / shift, nil
+}
+
+func fnRandom(args []interface{}) (interface{}, error) {
+	return rand.Float64(), nil
+}
+
+func fnPower(args []interface{}) (interface{}, error) {
+	base, ok, err := argNum(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	exponent, _, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := math.Pow(base, exponent)
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return nil, fmt.Errorf("$power(%v, %v) is not a finite number", base, exponent)
+	}
+	return result, nil
+}
+
+func fnSqrt(args []interface{}) (interface{}, error) {
+	num, ok, err := argNum(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	if num < 0 {
+		return nil, fmt.Errorf("$sqrt(%v) of a negative number", num)
+	}
+	return math.Sqrt(num), nil
+}
+
+func fnFormatBase(args []interface{}) (interface{}, error) {
+	num, ok, err := argNum(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	radix, ok, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		radix = 10
+	}
+	if radix < 2 || radix > 36 {
+		return nil, fmt.Errorf("radix must be between 2 and 36")
+	}
+
+	return strconv.FormatInt(int64(math.Round(num)), int(radix)), nil
+}
+
+func fnFormatNumber(args []interface{}) (interface{}, error) {
+	num, ok, err := argNum(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	picture, _, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if arg(args, 2) != undefined {
+		return nil, fmt.Errorf("$formatNumber options are unsupported")
+	}
+	return formatPicture(num, picture)
+}
+
+// formatPicture formats a number with an XPath decimal format picture, supporting
+// a prefix and suffix around mandatory digits 0-9, optional digits #, the grouping
+// separator , the decimal separator . and a % or ‰ suffix.
+// Exponents and sub-pictures for negative numbers are unsupported
+func formatPicture(num float64, picture string) (string, error) {
+	isDigit := func(r rune) bool { return r >= '0' && r <= '9' }
+	isActive := func(r rune) bool { return isDigit(r) || r == '#' || r == ',' || r == '.' }
+
+	runes := []rune(picture)
+	first, last := -1, -1
+	for i, r := range runes {
+		if isActive(r) {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return "", fmt.Errorf("picture %q has no digits", picture)
+	}
+
+	prefix, mantissa, suffix := string(runes[:first]), string(runes[first:last+1]), string(runes[last+1:])
+	for _, r := range mantissa {
+		if !isActive(r) {
+			return "", fmt.Errorf("unsupported picture %q", picture)
+		}
+	}
+
+	integer, fraction, _ := strings.Cut(mantissa, ".")
+	if strings.ContainsAny(fraction, ".,") {
+		return "", fmt.Errorf("unsupported picture %q", picture)
+	}
+
+	switch {
+	case strings.ContainsRune(prefix+suffix, '%'):
+		num *= 100
+	case strings.ContainsRune(prefix+suffix, '‰'):
+		num *= 1000
+	}
+
+	// grouping positions counted in digits from the right of the integer part
+	minInt, digits, groups := 0, 0, []int{}
+	for i := len(integer) - 1; i >= 0; i-- {
+		switch c := rune(integer[i]); {
+		case c == ',':
+			groups = append(groups, digits)
+		case isDigit(c):
+			minInt++
+			digits++
+		default:
+			digits++
+		}
+	}
+	minFrac := len(strings.TrimRight(fraction, "#"))
+	maxFrac := len(fraction)
+	if minInt == 0 && maxFrac == 0 {
+		minInt = 1
+	}
+
+	formatted := strconv.FormatFloat(math.Abs(num), 'f', maxFrac, 64)
+	intDigits, fracDigits, _ := strings.Cut(formatted, ".")
+	for len(fracDigits) > minFrac && strings.HasSuffix(fracDigits, "0") {
+		fracDigits = fracDigits[:len(fracDigits)-1]
+	}
+	if intDigits == "0" && minInt == 0 {
+		intDigits = ""
+	}
+	for len(intDigits) < minInt {
+		intDigits = "0" + intDigits
+	}
+
+	regular := len(groups) > 0 && groups[0] > 0
+	for i, g := range groups {
+		regular = regular && g == (i+1)*groups[0]
+	}
+
+	var b strings.Builder
+	for i, r := range intDigits {
+		right := len(intDigits) - i
+		if i > 0 && ((regular && right%groups[0] == 0) || (!regular && containsInt(groups, right))) {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if fracDigits != "" {
+		b.WriteString("." + fracDigits)
+	}
+
+	sign := ""
+	if num < 0 && strings.Trim(b.String(), "0.,") != "" {
+		sign = "-"
+	}
+	return sign + prefix + b.String() + suffix, nil
+}
+
+func containsInt(list []int, value int) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+//////
+// Boolean
+//////
+
+func fnBoolean(args []interface{}) (interface{}, error) {
+	value := arg(args, 0)
+	if value == undefined {
+		return undefined, nil
+	}
+	return truthy(value), nil
+}
+
+func fnNot(args []interface{}) (interface{}, error) {
+	value := arg(args, 0)
+	if value == undefined {
+		return undefined, nil
+	}
+	return !truthy(value), nil
+}
+
+func fnExists(args []interface{}) (interface{}, error) {
+	return arg(args, 0) != undefined, nil
+}
+
+//////
+// Array
+//////
+
+func fnCount(args []interface{}) (interface{}, error) {
+	return float64(len(items(arg(args, 0)))), nil
+}
+
+func fnAppend(args []interface{}) (interface{}, error) {
+	result := []interface{}{}
+	result = append(result, items(arg(args, 0))...)
+	result = append(result, items(arg(args, 1))...)
+	return result, nil
+}
+
+func fnReverse(args []interface{}) (interface{}, error) {
+	value := arg(args, 0)
+	if value == undefined {
+		return undefined, nil
+	}
+
+	all := items(value)
+	result := make([]interface{}, len(all))
+	for i, item := range all {
+		result[len(all)-1-i] = item
+	}
+	return result, nil
+}
+
+func fnDistinct(args []interface{}) (interface{}, error) {
+	value := arg(args, 0)
+	if value == undefined {
+		return undefined, nil
+	}
+
+	result := []interface{}{}
+	for _, item := range items(value) {
+		found := false
+		for _, r := range result {
+			if deepEqual(r, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func fnSort(args []interface{}) (interface{}, error) {
+	value := arg(args, 0)
+	if value == undefined {
+		return undefined, nil
+	}
+
+	result := append([]interface{}{}, items(value)...)
+
+	var sortErr error
+	less := func(i, j int) bool {
+		cmp, err := compare("<", result[i], result[j])
+		if err != nil {
+			sortErr = err
+			return false
+		}
+		return cmp.(bool)
+	}
+
+	if len(args) > 1 {
+		fn, err := argFn(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		// the function returns true if the first argument should be after the second
+		less = func(i, j int) bool {
+			swap, err := fn.call([]interface{}{result[j], result[i]})
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			return truthy(swap)
+		}
+	}
+
+	sort.SliceStable(result, less)
+	return result, sortErr
+}
+
+func fnMap(args []interface{}) (interface{}, error) {
+	fn, err := argFn(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	all := items(arg(args, 0))
+	result := sequence{}
+	for i, item := range all {
+		value, err := fn.call([]interface{}{item, float64(i), all})
+		if err != nil {
+			return nil, err
+		}
+		if value != undefined {
+			result = append(result, value)
+		}
+	}
+	return result, nil
+}
+
+func fnFilter(args []interface{}) (interface{}, error) {
+	fn, err := argFn(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	all := items(arg(args, 0))
+	result := sequence{}
+	for i, item := range all {
+		keep, err := fn.call([]interface{}{item, float64(i), all})
+		if err != nil {
+			return nil, err
+		}
+		if truthy(keep) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func fnReduce(args []interface{}) (interface{}, error) {
+	fn, err := argFn(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	all := items(arg(args, 0))
+	var accumulator interface{} = arg(args, 2)
+
+	if accumulator == undefined {
+		if len(all) == 0 {
+			return undefined, nil
+		}
+		accumulator, all = all[0], all[1:]
+	}
+
+	for _, item := range all {
+		accumulator, err = fn.call([]interface{}{accumulator, item})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return accumulator, nil
+}
+
+func fnZip(args []interface{}) (interface{}, error) {
+	result := []interface{}{}
+	if len(args) == 0 {
+		return result, nil
+	}
+
+	length := -1
+	for _, a := range args {
+		if n := len(items(a)); length < 0 || n < length {
+			length = n
+		}
+	}
+
+	for i := 0; i < length; i++ {
+		tuple := []interface{}{}
+		for _, a := range args {
+			tuple = append(tuple, items(a)[i])
+		}
+		result = append(result, tuple)
+	}
+	return result, nil
+}
+
+func fnShuffle(args []interface{}) (interface{}, error) {
+	value := arg(args, 0)
+	if value == undefined {
+		return undefined, nil
+	}
+
+	all := items(value)
+	result := make([]interface{}, len(all))
+	for i, j := range rand.Perm(len(all)) {
+		result[i] = all[j]
+	}
+	return result, nil
+}
+
+// fnSingle returns the one item matching the optional function, erroring on none or many
+func fnSingle(args []interface{}) (interface{}, error) {
+	var fn function
+	if len(args) > 1 {
+		var err error
+		if fn, err = argFn(args, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	all := items(arg(args, 0))
+	var result interface{} = undefined
+	matches := 0
+	for i, item := range all {
+		if fn != nil {
+			keep, err := fn.call([]interface{}{item, float64(i), all})
+			if err != nil {
+				return nil, err
+			}
+			if !truthy(keep) {
+				continue
+			}
+		}
+		if matches++; matches > 1 {
+			return nil, fmt.Errorf("$single matched more than one value")
+		}
+		result = item
+	}
+
+	if matches == 0 {
+		return nil, fmt.Errorf("$single matched no value")
+	}
+	return result, nil
+}
+
+//////
+// Object
+//////
+
+func fnKeys(args []interface{}) (interface{}, error) {
+	result := sequence{}
+	for _, item := range items(arg(args, 0)) {
+		if m, ok := item.(map[string]interface{}); ok {
+			for _, key := range sortedKeys(m) {
+				result = append(result, key)
+			}
+		}
+	}
+	return result, nil
+}
+
+func fnLookup(args []interface{}) (interface{}, error) {
+	key, _, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return lookupName(arg(args, 0), key), nil
+}
+
+func fnMerge(args []interface{}) (interface{}, error) {
+	result := map[string]interface{}{}
+	for _, item := range items(arg(args, 0)) {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("argument 1 must be an array of objects")
+		}
+		for k, v := range m {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+func fnType(args []interface{}) (interface{}, error) {
+	switch arg(args, 0).(type) {
+	case undefinedType:
+		return undefined, nil
+	case nil:
+		return "null", nil
+	case float64:
+		return "number", nil
+	case string:
+		return "string", nil
+	case bool:
+		return "boolean", nil
+	case []interface{}:
+		return "array", nil
+	case function:
+		return "function", nil
+	}
+	return "object", nil
+}
+
+func fnSpread(args []interface{}) (interface{}, error) {
+	value := arg(args, 0)
+	if value == undefined {
+		return undefined, nil
+	}
+
+	result := sequence{}
+	for _, item := range items(value) {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			result = append(result, item)
+			continue
+		}
+		for _, key := range sortedKeys(m) {
+			result = append(result, map[string]interface{}{key: m[key]})
+		}
+	}
+	return result, nil
+}
+
+// fnEach calls the function with (value, key, object) for each key of the object
+func fnEach(args []interface{}) (interface{}, error) {
+	m, ok := arg(args, 0).(map[string]interface{})
+	if !ok {
+		return undefined, nil
+	}
+
+	fn, err := argFn(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := sequence{}
+	for _, key := range sortedKeys(m) {
+		value, err := fn.call([]interface{}{m[key], key, m})
+		if err != nil {
+			return nil, err
+		}
+		if value = collapse(value); value != undefined {
+			result = append(result, value)
+		}
+	}
+	return result, nil
+}
+
+// fnSift keeps the keys of the object for which the function of (value, key, object) is true
+func fnSift(args []interface{}) (interface{}, error) {
+	m, ok := arg(args, 0).(map[string]interface{})
+	if !ok {
+		return undefined, nil
+	}
+
+	fn, err := argFn(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{}
+	for _, key := range sortedKeys(m) {
+		keep, err := fn.call([]interface{}{m[key], key, m})
+		if err != nil {
+			return nil, err
+		}
+		if truthy(keep) {
+			result[key] = m[key]
+		}
+	}
+
+	if len(result) == 0 {
+		return undefined, nil
+	}
+	return result, nil
+}
+
+func fnError(args []interface{}) (interface{}, error) {
+	message, ok, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		message = "$error() function evaluated"
+	}
+	return nil, errors.New(message)
+}
+
+func fnAssert(args []interface{}) (interface{}, error) {
+	condition, ok := arg(args, 0).(bool)
+	if !ok {
+		return nil, fmt.Errorf("argument 1 must be a boolean")
+	}
+
+	message, ok, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		message = "$assert() statement failed"
+	}
+
+	if !condition {
+		return nil, errors.New(message)
+	}
+	return undefined, nil
+}
+
+//////
+// Date
+//////
+
+func fnNow(args []interface{}) (interface{}, error) {
+	return time.Now().UTC().Format(isoMillis), nil
+}
+
+func fnMillis(args []interface{}) (interface{}, error) {
+	return float64(time.Now().UnixNano() / int64(time.Millisecond)), nil
+}
+
+const isoMillis = "2006-01-02T15:04:05.000Z"
+
+func fnFromMillis(args []interface{}) (interface{}, error) {
+	millis, ok, err := argNum(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	if len(args) > 1 {
+		return nil, fmt.Errorf("$fromMillis pictures and timezones are unsupported")
+	}
+	return time.UnixMilli(int64(millis)).UTC().Format(isoMillis), nil
+}
+
+func fnToMillis(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	if len(args) > 1 {
+		return nil, fmt.Errorf("$toMillis pictures are unsupported")
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, str); err == nil {
+			return float64(t.UnixMilli()), nil
+		}
+	}
+	return nil, fmt.Errorf("unable to parse %q as an ISO 8601 timestamp", str)
+}
+
+//////
+// Step Functions
+//////
+
+func fnPartition(args []interface{}) (interface{}, error) {
+	size, _, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= 0 || size != math.Trunc(size) {
+		return nil, fmt.Errorf("partition size must be a positive integer")
+	}
+
+	all := items(arg(args, 0))
+	result := []interface{}{}
+	for i := 0; i < len(all); i += int(size) {
+		end := i + int(size)
+		if end > len(all) {
+			end = len(all)
+		}
+		result = append(result, append([]interface{}{}, all[i:end]...))
+	}
+	return result, nil
+}
+
+func fnRange(args []interface{}) (interface{}, error) {
+	start, _, err := argNum(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	end, _, err := argNum(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok, err := argNum(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		step = 1
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("range step must not be 0")
+	}
+
+	result := []interface{}{}
+	for i := start; (step > 0 && i <= end) || (step < 0 && i >= end); i += step {
+		if len(result) >= 1000 {
+			return nil, fmt.Errorf("range larger than 1000 items")
+		}
+		result = append(result, i)
+	}
+	return result, nil
+}
+
+func fnHash(args []interface{}) (interface{}, error) {
+	data, _, err := argStr(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, _, err := argStr(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var h hash.Hash
+	switch algorithm {
+	case "MD5":
+		h = md5.New()
+	case "SHA-1":
+		h = sha1.New()
+	case "SHA-256":
+		h = sha256.New()
+	case "SHA-384":
+		h = sha512.New384()
+	case "SHA-512":
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("unknown hash algorithm %q", algorithm)
+	}
+
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fnUUID(args []interface{}) (interface{}, error) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func fnParse(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(str), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func fnBase64Decode(args []interface{}) (interface{}, error) {
+	str, ok, err := argStr(args, 0)
+	if err != nil || !ok {
+		return undefined, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
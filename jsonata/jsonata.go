@@ -0,0 +1,746 @@
+// Simple Implementation of JSONata for state machine
+package jsonata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+Like jsonpath the `input` must be from JSON Unmarshal:
+
+bool, float64, string, []interface{}, map[string]interface{} and nil
+
+This implements the subset of JSONata used in state machines, all of which is listed here:
+
+Paths: names, `quoted names`, $ and $name variables, the wildcards * and **,
+predicates [expr] filtering by a boolean or selecting by an index or array of
+indexes, e.g. list[0], list[-1], list[0..1] and list[[0, 2]].
+
+Operators: . + - * / % (modulo) unary - & = != < <= > >= and or in,
+the range .., the conditional ? :, the binding := and blocks ( ; ).
+
+Constructors: arrays [...], objects {...}, and lambdas function($x) { ... }.
+
+Functions:
+
+	String:         $string $length $substring $substringBefore $substringAfter
+	                $uppercase $lowercase $trim $pad $contains $split $join $replace
+	                $encodeUrl $encodeUrlComponent $decodeUrl $decodeUrlComponent
+	Numeric:        $number $abs $floor $ceil $round $power $sqrt $random
+	                $formatNumber $formatBase $sum $max $min $average
+	Boolean:        $boolean $not $exists
+	Array:          $count $append $reverse $distinct $sort $shuffle $zip
+	Higher order:   $map $filter $single $reduce $each $sift
+	Object:         $keys $lookup $spread $merge $type $error $assert
+	Date:           $now $millis $fromMillis $toMillis (ISO 8601 only, no pictures)
+	Step Functions: $partition $range $hash $uuid $parse $base64encode $base64decode
+
+$contains, $split and $replace take strings, not regular expressions. $formatNumber
+takes no options and its picture no exponent or negative sub-picture.
+
+Everything else fails: the chain ~>, transforms |...|, the bindings # and @,
+order-by ^(...), the parent %, regular expressions /.../ fail to parse, and any
+other function fails when called.
+*/
+
+var UNDEFINED_ERROR = errors.New("Undefined")
+
+// Expression is a compiled JSONata expression
+type Expression struct {
+	src string
+	ast node
+}
+
+// Compile parses a JSONata expression
+func Compile(expr string) (*Expression, error) {
+	ast, err := parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("JSONata Error %q: %v", expr, err)
+	}
+	return &Expression{src: expr, ast: ast}, nil
+}
+
+func (e *Expression) String() string {
+	return e.src
+}
+
+// Evaluate runs the expression against the input with variables bound as $name,
+// returns UNDEFINED_ERROR if the expression has no value
+func (e *Expression) Evaluate(input interface{}, vars map[string]interface{}) (interface{}, error) {
+	env := newEnvironment(nil)
+	for name, value := range vars {
+		env.vars[name] = value
+	}
+	env.vars["$"] = input
+
+	value, err := eval(e.ast, input, env)
+	if err != nil {
+		return nil, fmt.Errorf("JSONata Error %q: %v", e.src, err)
+	}
+
+	value = collapse(value)
+	if value == undefined {
+		return nil, UNDEFINED_ERROR
+	}
+
+	return value, nil
+}
+
+// Evaluate compiles and evaluates an expression
+func Evaluate(expr string, input interface{}, vars map[string]interface{}) (interface{}, error) {
+	e, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return e.Evaluate(input, vars)
+}
+
+//////
+// Values
+//////
+
+type undefinedType struct{}
+
+var undefined = undefinedType{}
+
+// sequence is the result of a path, collapsed when returned
+type sequence []interface{}
+
+func collapse(value interface{}) interface{} {
+	seq, ok := value.(sequence)
+	if !ok {
+		return value
+	}
+
+	switch len(seq) {
+	case 0:
+		return undefined
+	case 1:
+		return seq[0]
+	}
+	return []interface{}(seq)
+}
+
+// items returns the members of a value to iterate over
+func items(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case undefinedType:
+		return nil
+	case sequence:
+		return v
+	case []interface{}:
+		return v
+	}
+	return []interface{}{value}
+}
+
+func truthy(value interface{}) bool {
+	switch v := collapse(value).(type) {
+	case undefinedType, nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		for _, item := range v {
+			if truthy(item) {
+				return true
+			}
+		}
+		return false
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+//////
+// Environment
+//////
+
+type environment struct {
+	vars   map[string]interface{}
+	parent *environment
+}
+
+func newEnvironment(parent *environment) *environment {
+	return &environment{vars: map[string]interface{}{}, parent: parent}
+}
+
+func (env *environment) lookup(name string) (interface{}, bool) {
+	for e := env; e != nil; e = e.parent {
+		if value, ok := e.vars[name]; ok {
+			return value, true
+		}
+	}
+
+	if fn, ok := builtins[name]; ok {
+		return fn, true
+	}
+
+	return nil, false
+}
+
+//////
+// Evaluation
+//////
+
+func eval(n node, input interface{}, env *environment) (interface{}, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+	case *nameNode:
+		return lookupName(input, n.name), nil
+	case *wildcardNode:
+		return wildcard(input), nil
+	case *descendantNode:
+		return descendants(input, sequence{}), nil
+	case *variableNode:
+		switch n.name {
+		case "":
+			return input, nil
+		case "$":
+			value, _ := env.lookup("$")
+			return value, nil
+		}
+		value, ok := env.lookup(n.name)
+		if !ok {
+			return undefined, nil
+		}
+		return value, nil
+	case *pathNode:
+		return evalPath(n, input, env)
+	case *predicateNode:
+		return evalPredicate(n, input, env)
+	case *negateNode:
+		value, err := eval(n.expr, input, env)
+		if err != nil {
+			return nil, err
+		}
+		value = collapse(value)
+		if value == undefined {
+			return undefined, nil
+		}
+		num, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate %v", value)
+		}
+		return -num, nil
+	case *binaryNode:
+		return evalBinary(n, input, env)
+	case *arrayNode:
+		return evalArray(n, input, env)
+	case *objectNode:
+		return evalObject(n, input, env)
+	case *conditionNode:
+		cond, err := eval(n.cond, input, env)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return eval(n.then, input, env)
+		}
+		if n.otherwise == nil {
+			return undefined, nil
+		}
+		return eval(n.otherwise, input, env)
+	case *bindNode:
+		value, err := eval(n.expr, input, env)
+		if err != nil {
+			return nil, err
+		}
+		env.vars[n.name] = collapse(value)
+		return value, nil
+	case *blockNode:
+		blockEnv := newEnvironment(env)
+		var value interface{} = undefined
+		for _, expr := range n.exprs {
+			var err error
+			value, err = eval(expr, input, blockEnv)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	case *callNode:
+		return evalCall(n, input, env)
+	case *lambdaNode:
+		return &lambda{node: n, input: input, env: env}, nil
+	}
+
+	return nil, fmt.Errorf("unknown expression %T", n)
+}
+
+func lookupName(input interface{}, name string) interface{} {
+	switch v := input.(type) {
+	case map[string]interface{}:
+		value, ok := v[name]
+		if !ok {
+			return undefined
+		}
+		return value
+	case []interface{}, sequence:
+		seq := sequence{}
+		for _, item := range items(v) {
+			seq = appendFlat(seq, lookupName(item, name))
+		}
+		return seq
+	}
+	return undefined
+}
+
+func wildcard(input interface{}) interface{} {
+	seq := sequence{}
+	switch v := input.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			seq = appendFlat(seq, v[key])
+		}
+	case []interface{}, sequence:
+		for _, item := range items(v) {
+			seq = appendFlat(seq, wildcard(item))
+		}
+	}
+	return seq
+}
+
+// descendants is the input and every value under it, arrays are flattened
+func descendants(input interface{}, seq sequence) sequence {
+	switch v := input.(type) {
+	case undefinedType:
+		return seq
+	case []interface{}, sequence:
+		for _, item := range items(v) {
+			seq = descendants(item, seq)
+		}
+		return seq
+	case map[string]interface{}:
+		seq = append(seq, v)
+		for _, key := range sortedKeys(v) {
+			seq = descendants(v[key], seq)
+		}
+		return seq
+	}
+	return append(seq, input)
+}
+
+func appendFlat(seq sequence, value interface{}) sequence {
+	switch v := value.(type) {
+	case undefinedType:
+		return seq
+	case sequence:
+		return append(seq, v...)
+	case []interface{}:
+		return append(seq, v...)
+	}
+	return append(seq, value)
+}
+
+func evalPath(n *pathNode, input interface{}, env *environment) (interface{}, error) {
+	current, err := eval(n.steps[0], input, env)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, step := range n.steps[1:] {
+		all := items(current)
+		seq := sequence{}
+		for _, item := range all {
+			value, err := eval(step, item, env)
+			if err != nil {
+				return nil, err
+			}
+
+			if array, ok := value.([]interface{}); ok && len(all) == 1 {
+				// a single array value is kept, e.g. $states.input.list
+				seq = append(seq, array)
+				continue
+			}
+
+			if _, ok := step.(*arrayNode); ok {
+				// array constructors in a path are kept as arrays
+				seq = append(seq, value)
+				continue
+			}
+			seq = appendFlat(seq, value)
+		}
+		current = seq
+	}
+
+	return current, nil
+}
+
+func evalPredicate(n *predicateNode, input interface{}, env *environment) (interface{}, error) {
+	value, err := eval(n.expr, input, env)
+	if err != nil {
+		return nil, err
+	}
+
+	all := items(value)
+	seq := sequence{}
+
+	for i, item := range all {
+		pred, err := eval(n.pred, item, env)
+		if err != nil {
+			return nil, err
+		}
+		pred = collapse(pred)
+
+		if indexes, ok := numbers(pred); ok {
+			// a number or array of numbers selects items by index, e.g. list[0..1]
+			for _, index := range indexes {
+				index = math.Floor(index)
+				if index < 0 {
+					index += float64(len(all))
+				}
+				if int(index) == i {
+					seq = append(seq, item)
+					break
+				}
+			}
+			continue
+		}
+
+		if truthy(pred) {
+			seq = append(seq, item)
+		}
+	}
+
+	return seq, nil
+}
+
+// numbers returns the indexes of a number or non empty array of numbers
+func numbers(value interface{}) ([]float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return []float64{v}, true
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, false
+		}
+		nums := []float64{}
+		for _, item := range v {
+			num, ok := item.(float64)
+			if !ok {
+				return nil, false
+			}
+			nums = append(nums, num)
+		}
+		return nums, true
+	}
+	return nil, false
+}
+
+func evalArray(n *arrayNode, input interface{}, env *environment) (interface{}, error) {
+	array := []interface{}{}
+
+	for _, item := range n.items {
+		if rng, ok := item.(*binaryNode); ok && rng.op == ".." {
+			values, err := evalRange(rng, input, env)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, values...)
+			continue
+		}
+
+		value, err := eval(item, input, env)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := item.(*arrayNode); ok {
+			array = append(array, value)
+			continue
+		}
+		array = appendFlat(array, value)
+	}
+
+	return array, nil
+}
+
+func evalRange(n *binaryNode, input interface{}, env *environment) ([]interface{}, error) {
+	lhs, rhs, err := evalOperands(n, input, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if lhs == undefined || rhs == undefined {
+		return nil, nil
+	}
+
+	start, lok := lhs.(float64)
+	end, rok := rhs.(float64)
+	if !lok || !rok || start != math.Trunc(start) || end != math.Trunc(end) {
+		return nil, fmt.Errorf("range operands must be integers")
+	}
+
+	if end-start > 10000000 {
+		return nil, fmt.Errorf("range too large")
+	}
+
+	values := []interface{}{}
+	for i := start; i <= end; i++ {
+		values = append(values, i)
+	}
+	return values, nil
+}
+
+func evalObject(n *objectNode, input interface{}, env *environment) (interface{}, error) {
+	object := map[string]interface{}{}
+
+	for i := range n.keys {
+		key, err := eval(n.keys[i], input, env)
+		if err != nil {
+			return nil, err
+		}
+
+		keyStr, ok := collapse(key).(string)
+		if !ok {
+			return nil, fmt.Errorf("object key must be a string")
+		}
+
+		value, err := eval(n.values[i], input, env)
+		if err != nil {
+			return nil, err
+		}
+
+		value = collapse(value)
+		if value == undefined {
+			continue
+		}
+		object[keyStr] = value
+	}
+
+	return object, nil
+}
+
+func evalOperands(n *binaryNode, input interface{}, env *environment) (interface{}, interface{}, error) {
+	lhs, err := eval(n.lhs, input, env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rhs, err := eval(n.rhs, input, env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return collapse(lhs), collapse(rhs), nil
+}
+
+func evalBinary(n *binaryNode, input interface{}, env *environment) (interface{}, error) {
+	switch n.op {
+	case "and":
+		lhs, err := eval(n.lhs, input, env)
+		if err != nil || !truthy(lhs) {
+			return false, err
+		}
+		rhs, err := eval(n.rhs, input, env)
+		return truthy(rhs), err
+	case "or":
+		lhs, err := eval(n.lhs, input, env)
+		if err != nil || truthy(lhs) {
+			return true, err
+		}
+		rhs, err := eval(n.rhs, input, env)
+		return truthy(rhs), err
+	case "..":
+		values, err := evalRange(n, input, env)
+		if err != nil || values == nil {
+			return undefined, err
+		}
+		return values, nil
+	}
+
+	lhs, rhs, err := evalOperands(n, input, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "&":
+		lstr, err := toString(lhs)
+		if err != nil {
+			return nil, err
+		}
+		rstr, err := toString(rhs)
+		if err != nil {
+			return nil, err
+		}
+		return lstr + rstr, nil
+	case "=":
+		if lhs == undefined || rhs == undefined {
+			return false, nil
+		}
+		return deepEqual(lhs, rhs), nil
+	case "!=":
+		if lhs == undefined || rhs == undefined {
+			return false, nil
+		}
+		return !deepEqual(lhs, rhs), nil
+	case "in":
+		if lhs == undefined || rhs == undefined {
+			return false, nil
+		}
+		for _, item := range items(rhs) {
+			if deepEqual(lhs, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "<", "<=", ">", ">=":
+		return compare(n.op, lhs, rhs)
+	}
+
+	// Numeric operators
+	if lhs == undefined || rhs == undefined {
+		return undefined, nil
+	}
+
+	lnum, lok := lhs.(float64)
+	rnum, rok := rhs.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operands of %q must be numbers", n.op)
+	}
+
+	switch n.op {
+	case "+":
+		return lnum + rnum, nil
+	case "-":
+		return lnum - rnum, nil
+	case "*":
+		return lnum * rnum, nil
+	case "/":
+		if rnum == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lnum / rnum, nil
+	case "%":
+		if rnum == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(lnum, rnum), nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %q", n.op)
+}
+
+func compare(op string, lhs interface{}, rhs interface{}) (interface{}, error) {
+	if lhs == undefined || rhs == undefined {
+		return false, nil
+	}
+
+	var cmp int
+	switch l := lhs.(type) {
+	case float64:
+		r, ok := rhs.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number with %v", rhs)
+		}
+		cmp = compareFloat(l, r)
+	case string:
+		r, ok := rhs.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %v", rhs)
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return nil, fmt.Errorf("cannot compare %v", lhs)
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func compareFloat(l float64, r float64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+func deepEqual(lhs interface{}, rhs interface{}) bool {
+	return reflect.DeepEqual(lhs, rhs)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//////
+// Functions
+//////
+
+type function interface {
+	call(args []interface{}) (interface{}, error)
+}
+
+type lambda struct {
+	node  *lambdaNode
+	input interface{}
+	env   *environment
+}
+
+func (l *lambda) call(args []interface{}) (interface{}, error) {
+	env := newEnvironment(l.env)
+	for i, param := range l.node.params {
+		if i < len(args) {
+			env.vars[param] = args[i]
+		} else {
+			env.vars[param] = undefined
+		}
+	}
+
+	value, err := eval(l.node.body, l.input, env)
+	if err != nil {
+		return nil, err
+	}
+	return collapse(value), nil
+}
+
+func evalCall(n *callNode, input interface{}, env *environment) (interface{}, error) {
+	fnValue, err := eval(n.fn, input, env)
+	if err != nil {
+		return nil, err
+	}
+
+	fn, ok := fnValue.(function)
+	if !ok {
+		return nil, fmt.Errorf("attempted to call a non function")
+	}
+
+	args := []interface{}{}
+	for _, arg := range n.args {
+		value, err := eval(arg, input, env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, collapse(value))
+	}
+
+	return fn.call(args)
+}
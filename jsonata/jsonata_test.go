@@ -0,0 +1,324 @@
+package jsonata
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testInput(t *testing.T) interface{} {
+	var input interface{}
+	err := json.Unmarshal([]byte(`{
+		"name": "step",
+		"count": 3,
+		"single": [7],
+		"orders": [
+			{"id": "a", "price": 10, "tags": ["x", "y"]},
+			{"id": "b", "price": 20, "tags": ["z"]}
+		],
+		"nested": {"deep": {"value": true}}
+	}`), &input)
+	assert.NoError(t, err)
+	return input
+}
+
+func Test_JSONata_Evaluate(t *testing.T) {
+	input := testInput(t)
+	vars := map[string]interface{}{"states": map[string]interface{}{"input": input}}
+
+	tests := map[string]interface{}{
+		`name`:                           "step",
+		`$.name`:                         "step",
+		`nested.deep.value`:              true,
+		`orders.id`:                      []interface{}{"a", "b"},
+		`orders.tags`:                    []interface{}{"x", "y", "z"},
+		`orders[0].id`:                   "a",
+		`orders[-1].id`:                  "b",
+		`orders[0..1].id`:                []interface{}{"a", "b"},
+		`orders[[1]].id`:                 "b",
+		`orders[[0, -1]].price`:          []interface{}{float64(10), float64(20)},
+		`orders.tags[0]`:                 []interface{}{"x", "z"},
+		`nested.*.value`:                 true,
+		`$.` + "`name`":                  "step",
+		`orders[price > 15].id`:          "b",
+		`single`:                         []interface{}{float64(7)},
+		`$states.input.count * 2 + 1`:    float64(7),
+		`count % 2 = 1 ? "odd" : "even"`: "odd",
+		`name & "-" & count`:             "step-3",
+		`"b" in orders.id`:               true,
+		`count > 1 and name = "step"`:    true,
+		`count < 1 or false`:             false,
+		`[1..3]`:                         []interface{}{float64(1), float64(2), float64(3)},
+		`{"n": name, "m": missing}`:      map[string]interface{}{"n": "step"},
+		`($x := 2; $y := $x * 3; $y)`:    float64(6),
+		`$sum(orders.price)`:             float64(30),
+		`$count(orders)`:                 float64(2),
+		`$string(count)`:                 "3",
+		`$uppercase(name)`:               "STEP",
+		`$substring(name, 1, 2)`:         "te",
+		`$join(orders.id, ",")`:          "a,b",
+		`$split("a-b", "-")`:             []interface{}{"a", "b"},
+		`$exists(missing)`:               false,
+		`$map(orders, function($o) { $o.price / 10 })`:       []interface{}{float64(1), float64(2)},
+		`$filter(orders, function($o) { $o.price > 10 }).id`: "b",
+		`$reduce([1, 2, 3], function($a, $b) { $a + $b })`:   float64(6),
+		`$sort([3, 1, 2])`:             []interface{}{float64(1), float64(2), float64(3)},
+		`$merge([{"a": 1}, {"b": 2}])`: map[string]interface{}{"a": float64(1), "b": float64(2)},
+		`$keys(nested)`:                "deep",
+		`$partition([1, 2, 3], 2)`:     []interface{}{[]interface{}{float64(1), float64(2)}, []interface{}{float64(3)}},
+		`$range(0, 4, 2)`:              []interface{}{float64(0), float64(2), float64(4)},
+		`$hash("abc", "MD5")`:          "900150983cd24fb0d6963f7d28e17f72",
+		`$parse('{"a": [1]}').a`:       []interface{}{float64(1)},
+		`$type(nested)`:                "object",
+		`-count`:                       float64(-3),
+		`nested.**.value`:              true,
+		`orders.**.price`:              []interface{}{float64(10), float64(20)},
+		`$count(**.id)`:                float64(2),
+		`$string(1e20)`:                "100000000000000000000",
+		`$string(1e21)`:                "1e+21",
+		`$string(0.1 + 0.2)`:           "0.3",
+		`$string(1.5e-7)`:              "1.5e-7",
+		`$string(0.000001)`:            "0.000001",
+	}
+
+	for expr, expected := range tests {
+		value, err := Evaluate(expr, input, vars)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, expected, value, expr)
+	}
+}
+
+func Test_JSONata_Operators(t *testing.T) {
+	n := func(f float64) interface{} { return f }
+	tests := map[string]interface{}{
+		`1 + 2`:                                  n(3),
+		`5 - 7`:                                  n(-2),
+		`3 * 4`:                                  n(12),
+		`7 / 2`:                                  n(3.5),
+		`7 % 4`:                                  n(3),
+		`-(2 + 3)`:                               n(-5),
+		`2 + 3 * 4`:                              n(14),
+		`"a" & 1 & true & null`:                  "a1truenull",
+		`"a" & [1, 2]`:                           "a[1,2]",
+		`1 = 1`:                                  true,
+		`"a" = "b"`:                              false,
+		`{"a": [1]} = {"a": [1]}`:                true,
+		`1 != 2`:                                 true,
+		`1 < 2`:                                  true,
+		`"b" < "a"`:                              false,
+		`2 <= 2`:                                 true,
+		`3 > 2`:                                  true,
+		`2 >= 3`:                                 false,
+		`true and false`:                         false,
+		`false or true`:                          true,
+		`2 in [1, 2]`:                            true,
+		`"z" in "a"`:                             false,
+		`1..3`:                                   []interface{}{n(1), n(2), n(3)},
+		`[0, 2..3]`:                              []interface{}{n(0), n(2), n(3)},
+		`[3..1]`:                                 []interface{}{},
+		`true ? "y" : "n"`:                       "y",
+		`false ? "y"`:                            nil,
+		`($a := 1; $b := $a + 1)`:                n(2),
+		`($f := function($x) { $x * 2 }; $f(4))`: n(8),
+		`[1, [2, 3], [[4]]]`:                     []interface{}{n(1), []interface{}{n(2), n(3)}, []interface{}{[]interface{}{n(4)}}},
+		`{"a": 1, "b": [2]}`:                     map[string]interface{}{"a": n(1), "b": []interface{}{n(2)}},
+	}
+
+	for expr, expected := range tests {
+		value, err := Evaluate(expr, nil, nil)
+		if expected == nil {
+			assert.Equal(t, UNDEFINED_ERROR, err, expr)
+			continue
+		}
+		assert.NoError(t, err, expr)
+		assert.Equal(t, expected, value, expr)
+	}
+}
+
+func Test_JSONata_Functions(t *testing.T) {
+	n := func(f float64) interface{} { return f }
+	a := func(values ...interface{}) interface{} { return values }
+	o := map[string]interface{}{"x": n(1), "y": n(2)}
+	tests := map[string]interface{}{
+		// String
+		`$string(1.50)`:                        "1.5",
+		`$string({"a": [true]})`:               `{"a":[true]}`,
+		`$length("héllo")`:                     n(5),
+		`$substring("hello", -3, 2)`:           "ll",
+		`$substringBefore("a-b-c", "-")`:       "a",
+		`$substringBefore("abc", "-")`:         "abc",
+		`$substringAfter("a-b-c", "-")`:        "b-c",
+		`$substringAfter("abc", "-")`:          "abc",
+		`$uppercase("aB")`:                     "AB",
+		`$lowercase("aB")`:                     "ab",
+		`$trim("  a \n  b ")`:                  "a b",
+		`$pad("ab", 5)`:                        "ab   ",
+		`$pad("ab", -5, "#")`:                  "###ab",
+		`$pad("ab", 5, "xy")`:                  "abxyx",
+		`$pad("abc", 2)`:                       "abc",
+		`$contains("abc", "bc")`:               true,
+		`$split("a,b,c", ",", 2)`:              a("a", "b"),
+		`$join(["a", "b"])`:                    "ab",
+		`$replace("a-b-c", "-", "+", 1)`:       "a+b-c",
+		`$encodeUrl("https://a.com/?q=a b")`:   "https://a.com/?q=a%20b",
+		`$encodeUrlComponent("?q=a b")`:        "%3Fq%3Da%20b",
+		`$decodeUrl("https://a.com/?q=a%20b")`: "https://a.com/?q=a b",
+		`$decodeUrlComponent("%3Fq%3Da%20b")`:  "?q=a b",
+		// Numeric
+		`$number("1.5e1")`:                      n(15),
+		`$number(true)`:                         n(1),
+		`$abs(-2)`:                              n(2),
+		`$floor(-1.5)`:                          n(-2),
+		`$ceil(1.2)`:                            n(2),
+		`$round(2.5)`:                           n(2),
+		`$round(-7.5)`:                          n(-8),
+		`$round(125, -1)`:                       n(120),
+		`$power(2, 10)`:                         n(1024),
+		`$power(4, 0.5)`:                        n(2),
+		`$sqrt(9)`:                              n(3),
+		`$formatNumber(12345.6, "#,###.00")`:    "12,345.60",
+		`$formatNumber(1234.5678, "##,##0.00")`: "1,234.57",
+		`$formatNumber(1234567, "#,##,###")`:    "12,34,567",
+		`$formatNumber(0.14, "01%")`:            "14%",
+		`$formatNumber(0.5, "#.00")`:            ".50",
+		`$formatNumber(-3.1, "$0.000")`:         "-$3.100",
+		`$formatNumber(7, "000")`:               "007",
+		`$formatBase(100, 2)`:                   "1100100",
+		`$formatBase(2555, 16)`:                 "9fb",
+		`$formatBase(12)`:                       "12",
+		`$sum([1, 2.5])`:                        n(3.5),
+		`$max([1, 3, 2])`:                       n(3),
+		`$min([1, 3, 2])`:                       n(1),
+		`$average([1, 2])`:                      n(1.5),
+		// Boolean
+		`$boolean([0, ""])`: false,
+		`$boolean("a")`:     true,
+		`$not(0)`:           true,
+		`$exists(1)`:        true,
+		// Array
+		`$count([1, 2])`:                                 n(2),
+		`$append([1], 2)`:                                a(n(1), n(2)),
+		`$reverse([1, 2])`:                               a(n(2), n(1)),
+		`$distinct([1, 1, "a", "a"])`:                    a(n(1), "a"),
+		`$sort(["b", "a"])`:                              a("a", "b"),
+		`$sort([1, 3, 2], function($l, $r) { $l < $r })`: a(n(3), n(2), n(1)),
+		`$count($shuffle([1, 2, 3]))`:                    n(3),
+		`$sort($shuffle([1, 2, 3]))`:                     a(n(1), n(2), n(3)),
+		`$zip([1, 2, 3], ["a", "b"])`:                    a(a(n(1), "a"), a(n(2), "b")),
+		`$zip()`:                                         []interface{}{},
+		// Higher order
+		`$map([1, 2], function($v, $i) { $v + $i })`:  a(n(1), n(3)),
+		`$filter([1, 2, 3], function($v) { $v > 1 })`: a(n(2), n(3)),
+		`$single([1, 2, 3], function($v) { $v = 2 })`: n(2),
+		`$single([5])`: n(5),
+		`$reduce([1, 2], function($a, $v) { $a + $v }, 10)`:           n(13),
+		`$each({"x": 1, "y": 2}, function($v, $k) { $k & "=" & $v })`: a("x=1", "y=2"),
+		`$sift({"x": 1, "y": 2}, function($v) { $v > 1 })`:            map[string]interface{}{"y": n(2)},
+		`$sift({"x": 1, "y": 2}, function($v, $k) { $k = "x" })`:      map[string]interface{}{"x": n(1)},
+		// Object
+		`$keys({"x": 1, "y": 2})`:      a("x", "y"),
+		`$lookup({"x": 1}, "x")`:       n(1),
+		`$spread({"x": 1, "y": 2})`:    a(map[string]interface{}{"x": n(1)}, map[string]interface{}{"y": n(2)}),
+		`$merge([{"x": 1}, {"y": 2}])`: o,
+		`$type("a")`:                   "string",
+		`$type(function() { 1 })`:      "function",
+		`($assert(true); 1)`:           n(1),
+		// Date
+		`$fromMillis(1510067557121)`:            "2017-11-07T15:12:37.121Z",
+		`$toMillis("2017-11-07T15:12:37.121Z")`: n(1510067557121),
+		`$toMillis("2017-11-07")`:               n(1510012800000),
+		`$toMillis($fromMillis(5))`:             n(5),
+		// Step Functions
+		`$partition([1, 2, 3], 2)`:  a(a(n(1), n(2)), a(n(3))),
+		`$range(5, 1, -2)`:          a(n(5), n(3), n(1)),
+		`$hash("abc", "SHA-256")`:   "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		`$parse("[1]")`:             a(n(1)),
+		`$base64encode("step")`:     "c3RlcA==",
+		`$base64decode("c3RlcA==")`: "step",
+	}
+
+	for expr, expected := range tests {
+		value, err := Evaluate(expr, nil, nil)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, expected, value, expr)
+	}
+}
+
+func Test_JSONata_Functions_Random(t *testing.T) {
+	value, err := Evaluate(`$random()`, nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, value.(float64) >= 0 && value.(float64) < 1)
+
+	value, err = Evaluate(`$uuid()`, nil, nil)
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", value)
+
+	value, err = Evaluate(`$toMillis($now()) <= $millis()`, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, true, value)
+}
+
+func Test_JSONata_Functions_Errors(t *testing.T) {
+	tests := map[string]string{
+		`$power(-1, 0.5)`:                       "not a finite number",
+		`$sqrt(-1)`:                             "negative",
+		`$formatNumber(1, "abc")`:               "no digits",
+		`$formatNumber(1, "0.00e0")`:            "unsupported picture",
+		`$formatNumber(1, "0;(0)")`:             "unsupported picture",
+		`$formatNumber(1, "0", {})`:             "options are unsupported",
+		`$formatBase(1, 40)`:                    "radix",
+		`$single([1, 2])`:                       "more than one",
+		`$single([1], function($v) { $v > 1 })`: "no value",
+		`$error("boom")`:                        "boom",
+		`$error()`:                              "function evaluated",
+		`$assert(1 > 2, "too small")`:           "too small",
+		`$assert(false)`:                        "statement failed",
+		`$assert("true")`:                       "must be a boolean",
+		`$toMillis("yesterday")`:                "ISO 8601",
+		`$toMillis("2017-11-07", "[Y]")`:        "pictures are unsupported",
+		`$fromMillis(0, "[Y]")`:                 "pictures and timezones are unsupported",
+		`$each({"a": 1}, "f")`:                  "must be a function",
+		`$hash("a", "MD4")`:                     "unknown hash",
+		`[1.5..2]`:                              "must be integers",
+		`$formatMe(1)`:                          "non function",
+	}
+
+	for expr, message := range tests {
+		_, err := Evaluate(expr, nil, nil)
+		assert.Error(t, err, expr)
+		assert.Contains(t, fmt.Sprint(err), message, expr)
+	}
+}
+
+func Test_JSONata_Undefined(t *testing.T) {
+	_, err := Evaluate(`missing.value`, testInput(t), nil)
+	assert.Equal(t, UNDEFINED_ERROR, err)
+
+	_, err = Evaluate(`$missing`, testInput(t), nil)
+	assert.Equal(t, UNDEFINED_ERROR, err)
+}
+
+func Test_JSONata_Errors(t *testing.T) {
+	for _, expr := range []string{
+		`name +`,
+		`(1`,
+		`"unterminated`,
+		`name + 1`,
+		`1 / 0`,
+		`$nothing()`,
+		`#`,
+	} {
+		_, err := Evaluate(expr, testInput(t), nil)
+		assert.Error(t, err, expr)
+		assert.NotEqual(t, UNDEFINED_ERROR, err, expr)
+	}
+}
+
+func Test_JSONata_Unsupported(t *testing.T) {
+	for _, expr := range []string{`orders ~> $count()`, `orders#$i`, `orders@$o`, `orders^(price)`, `$ ~> |orders|{}|`, `%.name`, `/st/`} {
+		_, err := Compile(expr)
+		assert.Regexp(t, "unsupported", err, expr)
+	}
+}
@@ -0,0 +1,543 @@
+package jsonata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//////
+// Lexer
+//////
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenNumber
+	tokenString
+	tokenName
+	tokenVariable
+	tokenOperator
+)
+
+type token struct {
+	typ   tokenType
+	value string
+	num   float64
+	pos   int
+}
+
+var operators = []string{
+	":=", "!=", "<=", ">=", "..", "**",
+	".", "[", "]", "(", ")", "{", "}", ",", ":", ";", "?",
+	"+", "-", "*", "/", "%", "&", "=", "<", ">",
+}
+
+func lex(src string) ([]token, error) {
+	tokens := []token{}
+	pos := 0
+
+	for pos < len(src) {
+		c := src[pos]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+			continue
+		case strings.HasPrefix(src[pos:], "/*"):
+			end := strings.Index(src[pos+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at %v", pos)
+			}
+			pos += end + 4
+			continue
+		case c == '"' || c == '\'':
+			str, n, err := lexString(src[pos:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at %v", err, pos)
+			}
+			tokens = append(tokens, token{typ: tokenString, value: str, pos: pos})
+			pos += n
+			continue
+		case c == '`':
+			end := strings.IndexByte(src[pos+1:], '`')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated name at %v", pos)
+			}
+			tokens = append(tokens, token{typ: tokenName, value: src[pos+1 : pos+1+end], pos: pos})
+			pos += end + 2
+			continue
+		case c >= '0' && c <= '9':
+			end := pos
+			for end < len(src) && (isDigit(src[end]) || (src[end] == '.' && end+1 < len(src) && isDigit(src[end+1]))) {
+				end++
+			}
+			if end < len(src) && (src[end] == 'e' || src[end] == 'E') {
+				end++
+				if end < len(src) && (src[end] == '+' || src[end] == '-') {
+					end++
+				}
+				for end < len(src) && isDigit(src[end]) {
+					end++
+				}
+			}
+			num, err := strconv.ParseFloat(src[pos:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad number %q at %v", src[pos:end], pos)
+			}
+			tokens = append(tokens, token{typ: tokenNumber, num: num, value: src[pos:end], pos: pos})
+			pos = end
+			continue
+		case c == '$':
+			end := pos + 1
+			if end < len(src) && src[end] == '$' {
+				end++
+			} else {
+				for end < len(src) && isNameChar(src[end]) {
+					end++
+				}
+			}
+			tokens = append(tokens, token{typ: tokenVariable, value: src[pos+1 : end], pos: pos})
+			pos = end
+			continue
+		case isNameStart(c):
+			end := pos
+			for end < len(src) && isNameChar(src[end]) {
+				end++
+			}
+			tokens = append(tokens, token{typ: tokenName, value: src[pos:end], pos: pos})
+			pos = end
+			continue
+		}
+
+		for _, op := range unsupportedOperators {
+			if strings.HasPrefix(src[pos:], op) {
+				return nil, fmt.Errorf("unsupported operator %q at %v", op, pos)
+			}
+		}
+
+		found := false
+		for _, op := range operators {
+			if strings.HasPrefix(src[pos:], op) {
+				tokens = append(tokens, token{typ: tokenOperator, value: op, pos: pos})
+				pos += len(op)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unexpected character %q at %v", c, pos)
+		}
+	}
+
+	return append(tokens, token{typ: tokenEOF, pos: pos}), nil
+}
+
+// unsupportedOperators are JSONata operators this implementation does not support,
+// they fail to parse instead of being read as something else
+var unsupportedOperators = []string{"~>", "|", "@", "#", "^("}
+
+func lexString(src string) (string, int, error) {
+	quote := src[0]
+	var sb strings.Builder
+
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		if c == quote {
+			return sb.String(), i + 1, nil
+		}
+
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i >= len(src) {
+			break
+		}
+
+		switch src[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			if i+4 >= len(src) {
+				return "", 0, fmt.Errorf("bad unicode escape")
+			}
+			r, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", 0, fmt.Errorf("bad unicode escape")
+			}
+			sb.WriteRune(rune(r))
+			i += 4
+		default:
+			sb.WriteByte(src[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || unicode.IsLetter(rune(c))
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+//////
+// AST
+//////
+
+type node interface{}
+
+type literalNode struct{ value interface{} }
+type nameNode struct{ name string }
+type wildcardNode struct{}
+type descendantNode struct{}
+type variableNode struct{ name string }
+type pathNode struct{ steps []node }
+type predicateNode struct{ expr, pred node }
+type binaryNode struct {
+	op       string
+	lhs, rhs node
+}
+type negateNode struct{ expr node }
+type arrayNode struct{ items []node }
+type objectNode struct{ keys, values []node }
+type conditionNode struct{ cond, then, otherwise node }
+type bindNode struct {
+	name string
+	expr node
+}
+type blockNode struct{ exprs []node }
+type callNode struct {
+	fn   node
+	args []node
+}
+type lambdaNode struct {
+	params []string
+	body   node
+}
+
+//////
+// Parser (Pratt)
+//////
+
+var bindingPowers = map[string]int{
+	".": 75, "[": 80, "(": 80,
+	"*": 60, "/": 60, "%": 60,
+	"+": 50, "-": 50, "&": 50,
+	"=": 40, "!=": 40, "<": 40, "<=": 40, ">": 40, ">=": 40, "in": 40,
+	"and": 30, "or": 25,
+	"..": 20, "?": 20,
+	":=": 10,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(src string) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	ast, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().typ != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %v", p.peek().value, p.peek().pos)
+	}
+
+	return ast, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(value string) bool {
+	t := p.peek()
+	return t.typ == tokenOperator && t.value == value
+}
+
+func (p *parser) expect(value string) error {
+	if !p.isOp(value) {
+		return fmt.Errorf("expected %q at %v", value, p.peek().pos)
+	}
+	p.next()
+	return nil
+}
+
+// infixOp returns the operator of the next token if it is infix
+func (p *parser) infixOp() (string, bool) {
+	t := p.peek()
+	switch t.typ {
+	case tokenOperator:
+		_, ok := bindingPowers[t.value]
+		return t.value, ok
+	case tokenName:
+		if t.value == "and" || t.value == "or" || t.value == "in" {
+			return t.value, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expression(rbp int) (node, error) {
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.infixOp()
+		if !ok || bindingPowers[op] <= rbp {
+			return left, nil
+		}
+
+		p.next()
+		left, err = p.infix(op, left)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) prefix() (node, error) {
+	t := p.next()
+
+	switch t.typ {
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tokenNumber:
+		return &literalNode{t.num}, nil
+	case tokenString:
+		return &literalNode{t.value}, nil
+	case tokenVariable:
+		return &variableNode{t.value}, nil
+	case tokenName:
+		switch t.value {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "null":
+			return &literalNode{nil}, nil
+		case "function":
+			if p.isOp("(") {
+				return p.lambda()
+			}
+		}
+		return &nameNode{t.value}, nil
+	}
+
+	switch t.value {
+	case "-":
+		expr, err := p.expression(70)
+		if err != nil {
+			return nil, err
+		}
+		return &negateNode{expr}, nil
+	case "*":
+		return &wildcardNode{}, nil
+	case "**":
+		return &descendantNode{}, nil
+	case "/":
+		return nil, fmt.Errorf("unsupported regular expression at %v", t.pos)
+	case "%":
+		return nil, fmt.Errorf("unsupported parent operator at %v", t.pos)
+	case "(":
+		block := &blockNode{}
+		for !p.isOp(")") {
+			expr, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			block.exprs = append(block.exprs, expr)
+			if !p.isOp(";") {
+				break
+			}
+			p.next()
+		}
+		return block, p.expect(")")
+	case "[":
+		array := &arrayNode{}
+		for !p.isOp("]") {
+			item, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			array.items = append(array.items, item)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		return array, p.expect("]")
+	case "{":
+		object := &objectNode{}
+		for !p.isOp("}") {
+			key, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			object.keys = append(object.keys, key)
+			object.values = append(object.values, value)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		return object, p.expect("}")
+	}
+
+	return nil, fmt.Errorf("unexpected %q at %v", t.value, t.pos)
+}
+
+func (p *parser) lambda() (node, error) {
+	p.next() // (
+	lambda := &lambdaNode{}
+	for !p.isOp(")") {
+		t := p.next()
+		if t.typ != tokenVariable {
+			return nil, fmt.Errorf("function parameters must be variables at %v", t.pos)
+		}
+		lambda.params = append(lambda.params, t.value)
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
+	}
+
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	body, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	lambda.body = body
+
+	return lambda, p.expect("}")
+}
+
+func (p *parser) infix(op string, left node) (node, error) {
+	switch op {
+	case ".":
+		right, err := p.expression(bindingPowers["."])
+		if err != nil {
+			return nil, err
+		}
+
+		path, ok := left.(*pathNode)
+		if !ok {
+			path = &pathNode{steps: []node{left}}
+		}
+		if rpath, ok := right.(*pathNode); ok {
+			path.steps = append(path.steps, rpath.steps...)
+		} else {
+			path.steps = append(path.steps, right)
+		}
+		return path, nil
+	case "[":
+		if p.isOp("]") {
+			// a[] keeps arrays, treated the same as a
+			p.next()
+			return left, nil
+		}
+		pred, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		return &predicateNode{expr: left, pred: pred}, p.expect("]")
+	case "(":
+		call := &callNode{fn: left}
+		for !p.isOp(")") {
+			arg, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		return call, p.expect(")")
+	case "?":
+		then, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		cond := &conditionNode{cond: left, then: then}
+		if p.isOp(":") {
+			p.next()
+			otherwise, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			cond.otherwise = otherwise
+		}
+		return cond, nil
+	case ":=":
+		variable, ok := left.(*variableNode)
+		if !ok {
+			return nil, fmt.Errorf("left side of := must be a variable")
+		}
+		// right associative
+		right, err := p.expression(bindingPowers[":="] - 1)
+		if err != nil {
+			return nil, err
+		}
+		return &bindNode{name: variable.name, expr: right}, nil
+	}
+
+	right, err := p.expression(bindingPowers[op])
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: op, lhs: left, rhs: right}, nil
+}
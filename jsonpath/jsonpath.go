@@ -25,14 +25,34 @@ nil for JSON null
 var NOT_FOUND_ERROR = errors.New("Not Found")
 
 type Path struct {
-	path []string
+	path     []string
+	variable *string // set for workflow variable paths like $name.a.b
+	null     bool    // set for an explicit JSON null path
+}
+
+// NullPath returns the path of an explicit "null",
+// Get on it returns an empty object and Set discards the value
+func NullPath() *Path {
+	return &Path{null: true}
+}
+
+// IsNull returns true if the path is an explicit "null"
+func (path *Path) IsNull() bool {
+	return path != nil && path.null
+}
+
+// IsRoot returns true if the path is absent or "$"
+func (path *Path) IsRoot() bool {
+	return path == nil || (!path.null && path.variable == nil && len(path.path) == 0)
 }
 
 // NewPath takes string returns JSONPath Object
 func NewPath(path_string string) (*Path, error) {
 	path := Path{}
+	variable, path_string := splitVariable(path_string)
 	path_array, err := ParsePathString(path_string)
 	path.path = path_array
+	path.variable = variable
 	return &path, err
 }
 
@@ -45,6 +65,7 @@ func (path *Path) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	variable, path_string := splitVariable(path_string)
 	path_array, err := ParsePathString(path_string)
 
 	if err != nil {
@@ -52,11 +73,20 @@ func (path *Path) UnmarshalJSON(b []byte) error {
 	}
 
 	path.path = path_array
+	path.variable = variable
 	return nil
 }
 
 // MarshalJSON converts path to json string
 func (path *Path) MarshalJSON() ([]byte, error) {
+	if path.null {
+		return []byte("null"), nil
+	}
+
+	if path.variable != nil {
+		return json.Marshal(path.String())
+	}
+
 	if len(path.path) == 0 {
 		return json.Marshal("$")
 	}
@@ -64,9 +94,49 @@ func (path *Path) MarshalJSON() ([]byte, error) {
 }
 
 func (path *Path) String() string {
+	if path.null {
+		return "null"
+	}
+
+	if path.variable != nil {
+		if len(path.path) == 0 {
+			return fmt.Sprintf("$%v", *path.variable)
+		}
+		return fmt.Sprintf("$%v.%v", *path.variable, strings.Join(path.path[:], "."))
+	}
+
 	return fmt.Sprintf("$.%v", strings.Join(path.path[:], "."))
 }
 
+// Variable returns the name of the workflow variable the path reads from,
+// the path is then relative to the value of the variable
+func (path *Path) Variable() *string {
+	if path == nil {
+		return nil
+	}
+	return path.variable
+}
+
+// splitVariable splits "$name.a.b" into the variable "name" and the path "$.a.b"
+func splitVariable(path_string string) (*string, string) {
+	if len(path_string) < 2 || path_string[0] != '$' || !isNameStart(path_string[1]) {
+		return nil, path_string
+	}
+
+	name := path_string[1:]
+	rest := "$"
+	if i := strings.Index(name, "."); i >= 0 {
+		rest = "$" + name[i:]
+		name = name[:i]
+	}
+
+	return &name, rest
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
 // ParsePathString parses a path string
 func ParsePathString(path_string string) ([]string, error) {
 	// must start with $.<value> otherwise empty path
@@ -203,6 +273,11 @@ func (path *Path) Get(input interface{}) (value interface{}, err error) {
 	if path == nil {
 		return input, nil // Default is $
 	}
+
+	if path.null {
+		return map[string]interface{}{}, nil
+	}
+
 	return recursiveGet(input, path.path)
 }
 
@@ -227,6 +302,16 @@ func (path *Path) GetSlice(input interface{}) (output []interface{}, err error )
 
 // Set sets a Value in a map with Path
 func (path *Path) Set(input interface{}, value interface{}) (output map[string]interface{}, err error) {
+	if path.IsNull() {
+		// The value is discarded and the input is kept
+		switch input.(type) {
+		case map[string]interface{}:
+			return input.(map[string]interface{}), nil
+		default:
+			return nil, fmt.Errorf("Cannot discard value with null path, input type %q is not an object", reflect.TypeOf(input))
+		}
+	}
+
 	var set_path []string
 	if path == nil {
 		set_path = []string{} // default "$"
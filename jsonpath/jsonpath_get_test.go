@@ -135,3 +135,14 @@ func Test_JSONPath_GetSplice(t *testing.T) {
 	assert.Equal(t, out, test)
 
 }
+
+func Test_JSONPath_Get_Null(t *testing.T) {
+	test := map[string]interface{}{"a": "b"}
+
+	out, err := NullPath().Get(test)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{}, out)
+
+	assert.True(t, NullPath().IsNull())
+	assert.False(t, (*Path)(nil).IsNull())
+}
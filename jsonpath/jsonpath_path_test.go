@@ -55,3 +55,25 @@ func Test_JSONPath_Parsing(t *testing.T) {
 	assert.Equal(t, pathstr.path[1], "b")
 	assert.Equal(t, pathstr.path[2], "c")
 }
+
+func Test_JSONPath_Variable(t *testing.T) {
+	path, err := NewPath("$myVar.a.b")
+	assert.NoError(t, err)
+	assert.Equal(t, "myVar", *path.Variable())
+	assert.Equal(t, []string{"a", "b"}, path.path)
+	assert.Equal(t, "$myVar.a.b", path.String())
+
+	path, err = NewPath("$myVar")
+	assert.NoError(t, err)
+	assert.Equal(t, "myVar", *path.Variable())
+	assert.Equal(t, 0, len(path.path))
+
+	raw, err := json.Marshal(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `"$myVar"`, string(raw))
+
+	path, err = NewPath("$.a")
+	assert.NoError(t, err)
+	assert.Nil(t, path.Variable())
+	assert.Nil(t, (*Path)(nil).Variable())
+}
@@ -75,3 +75,14 @@ func Test_JSONPath_Set_Overwrite(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "s", out)
 }
+
+func Test_JSONPath_Set_Null(t *testing.T) {
+	test := map[string]interface{}{"a": "b"}
+
+	setted, err := NullPath().Set(test, "s")
+	assert.NoError(t, err)
+	assert.Equal(t, test, setted)
+
+	_, err = NullPath().Set([]interface{}{}, "s")
+	assert.Error(t, err)
+}
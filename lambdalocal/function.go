@@ -0,0 +1,422 @@
+// lambdalocal emulates the Lambda Runtime API so a Lambda binary, e.g. the built step binary,
+// can be invoked locally exactly as it is shipped
+package lambdalocal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coinbase/step/errors"
+	"github.com/coinbase/step/utils/to"
+)
+
+const (
+	runtimePrefix = "/2018-06-01/runtime/"
+	invokePrefix  = "/2015-03-31/functions/"
+)
+
+// Function serves the Lambda Runtime API (/2018-06-01/runtime/...) to a Lambda binary
+// and the Lambda Invoke API (POST /2015-03-31/functions/<name>/invocations) to callers,
+// e.g. a server TaskConfig URL. Like Lambda, the binary is started by the first invocation
+// and restarted after it exits or an invocation times out
+type Function struct {
+	Path       string // Lambda binary
+	Args       []string
+	Env        []string // added to the environment of the binary
+	RuntimeAPI string   // host:port the Function is served on, passed to the binary as AWS_LAMBDA_RUNTIME_API
+
+	Name    string        // function name, the base name of Path by default
+	Timeout time.Duration // of each invocation, 15 minutes by default
+
+	Stdout io.Writer // os.Stdout by default
+	Stderr io.Writer // os.Stderr by default
+
+	next chan *invocation // invocations waiting for the runtime
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd              // nil if the binary is not running
+	ready   bool                   // the running binary asked for an invocation
+	running map[string]*invocation // invocations sent to the runtime by request id
+	waiting int                    // invocations without a result
+	closed  bool
+}
+
+// invocation is a payload waiting for the result of the runtime
+type invocation struct {
+	id       string
+	payload  []byte
+	deadline time.Time
+	result   chan *result
+}
+
+type result struct {
+	payload []byte
+	err     error
+}
+
+// lambdaError is the error body of the Runtime and Invoke APIs
+type lambdaError struct {
+	ErrorMessage string   `json:"errorMessage"`
+	ErrorType    string   `json:"errorType"`
+	StackTrace   []string `json:"stackTrace,omitempty"`
+}
+
+// NewFunction returns a Function for the binary at path that will be served on runtime_api
+func NewFunction(path string, runtime_api string) *Function {
+	return &Function{
+		Path:       path,
+		RuntimeAPI: runtime_api,
+		next:       make(chan *invocation),
+		running:    map[string]*invocation{},
+	}
+}
+
+func (f *Function) name() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return filepath.Base(f.Path)
+}
+
+func (f *Function) arn() string {
+	return fmt.Sprintf("arn:aws:lambda:us-east-1:000000000000:function:%v", f.name())
+}
+
+func (f *Function) timeout() time.Duration {
+	if f.Timeout > 0 {
+		return f.Timeout
+	}
+	return 15 * time.Minute
+}
+
+// Invoke sends payload to the binary and returns its response,
+// function errors are returned as *errors.ASLError with the errorType as Name
+func (f *Function) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	inv := &invocation{
+		id:       *to.TimeUUID(""),
+		payload:  payload,
+		deadline: time.Now().Add(f.timeout()),
+		result:   make(chan *result, 1),
+	}
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("Function %q is closed", f.name())
+	}
+	f.waiting++
+	err := f.start()
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.waiting--
+		delete(f.running, inv.id)
+		f.mu.Unlock()
+	}()
+
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(time.Until(inv.deadline))
+	defer timer.Stop()
+
+	select {
+	case f.next <- inv:
+	case res := <-inv.result:
+		return res.payload, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, f.timedOut(inv)
+	}
+
+	select {
+	case res := <-inv.result:
+		return res.payload, res.err
+	case <-ctx.Done():
+		f.kill(inv)
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, f.timedOut(inv)
+	}
+}
+
+// timedOut stops the binary running inv, like Lambda does
+func (f *Function) timedOut(inv *invocation) error {
+	f.kill(inv)
+	return &errors.ASLError{Name: "Lambda.Unknown", Cause: fmt.Sprintf("Task timed out after %.2f seconds", f.timeout().Seconds())}
+}
+
+// kill stops the binary if it is running inv
+func (f *Function) kill(inv *invocation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.running[inv.id]; !ok {
+		return
+	}
+	delete(f.running, inv.id)
+
+	if f.cmd != nil && f.cmd.Process != nil {
+		f.cmd.Process.Kill()
+	}
+}
+
+// start runs the binary if it is not running, f.mu must be held
+func (f *Function) start() error {
+	if f.cmd != nil {
+		return nil
+	}
+
+	cmd := exec.Command(f.Path, f.Args...)
+	cmd.Env = append(os.Environ(), f.Env...)
+	cmd.Env = append(cmd.Env,
+		fmt.Sprintf("AWS_LAMBDA_RUNTIME_API=%v", f.RuntimeAPI),
+		fmt.Sprintf("AWS_LAMBDA_FUNCTION_NAME=%v", f.name()),
+		fmt.Sprintf("AWS_LAMBDA_FUNCTION_TIMEOUT=%v", int(f.timeout().Seconds())),
+	)
+
+	cmd.Stdout, cmd.Stderr = f.Stdout, f.Stderr
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	if err := cmd.Start(); err != nil {
+		return &errors.ASLError{Name: "Runtime.InvalidEntrypoint", Cause: err.Error()}
+	}
+
+	f.cmd = cmd
+	f.ready = false
+	go f.wait(cmd)
+	return nil
+}
+
+// wait fails the invocations of cmd after it exits and restarts it for waiting invocations.
+// If it exited before asking for an invocation the waiting invocations fail instead
+func (f *Function) wait(cmd *exec.Cmd) {
+	err := cmd.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cause := "Runtime exited without providing a reason"
+	if err != nil {
+		cause = fmt.Sprintf("Runtime exited with error: %v", err)
+	}
+	exitError := &errors.ASLError{Name: "Runtime.ExitError", Cause: cause}
+
+	failed := len(f.running)
+	for id, inv := range f.running {
+		inv.result <- &result{err: exitError}
+		delete(f.running, id)
+	}
+
+	ready := f.ready
+	f.cmd = nil
+
+	if f.closed || f.waiting == failed {
+		return
+	}
+
+	if ready {
+		if f.start() == nil {
+			return
+		}
+	}
+
+	// Fail queued invocations rather than restart a binary that cannot start
+	for {
+		select {
+		case inv := <-f.next:
+			inv.result <- &result{err: exitError}
+		default:
+			return
+		}
+	}
+}
+
+// Close stops the binary, later invocations fail
+func (f *Function) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	if f.cmd != nil && f.cmd.Process != nil {
+		return f.cmd.Process.Kill()
+	}
+	return nil
+}
+
+// TaskHandler returns a Task handler for machine.StateMachine.SetTaskHandler that invokes the binary with the Task input
+func (f *Function) TaskHandler() func(context.Context, interface{}) (interface{}, error) {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		payload, err := json.Marshal(input)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := f.Invoke(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		var output interface{}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &output); err != nil {
+				return nil, err
+			}
+		}
+
+		return output, nil
+	}
+}
+
+func (f *Function) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, runtimePrefix):
+		f.serveRuntime(w, r, strings.TrimPrefix(r.URL.Path, runtimePrefix))
+	case r.Method == "POST" && strings.HasPrefix(r.URL.Path, invokePrefix) && strings.HasSuffix(r.URL.Path, "/invocations"):
+		f.serveInvoke(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "ResourceNotFoundException", fmt.Sprintf("Unknown path %q", r.URL.Path))
+	}
+}
+
+// serveRuntime serves the Runtime API the binary calls
+func (f *Function) serveRuntime(w http.ResponseWriter, r *http.Request, path string) {
+	parts := strings.Split(path, "/")
+
+	switch {
+	case r.Method == "GET" && path == "invocation/next":
+		f.serveNext(w, r)
+	case r.Method == "POST" && len(parts) == 3 && parts[0] == "invocation" && parts[2] == "response":
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequest", err.Error())
+			return
+		}
+		f.complete(w, parts[1], &result{payload: body})
+	case r.Method == "POST" && len(parts) == 3 && parts[0] == "invocation" && parts[2] == "error":
+		f.complete(w, parts[1], &result{err: readError(r)})
+	case r.Method == "POST" && path == "init/error":
+		// The binary exits after an init error, failing the waiting invocations
+		fmt.Fprintln(f.stderr(), "Init Error", readError(r))
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusNotFound, "InvalidRequest", fmt.Sprintf("Unknown Runtime API %v %q", r.Method, path))
+	}
+}
+
+func (f *Function) stderr() io.Writer {
+	if f.Stderr != nil {
+		return f.Stderr
+	}
+	return os.Stderr
+}
+
+// serveNext blocks until an invocation is waiting and sends it to the binary
+func (f *Function) serveNext(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.ready = true
+	f.mu.Unlock()
+
+	var inv *invocation
+	select {
+	case inv = <-f.next:
+	case <-r.Context().Done():
+		return
+	}
+
+	f.mu.Lock()
+	f.running[inv.id] = inv
+	f.mu.Unlock()
+
+	w.Header().Set("Lambda-Runtime-Aws-Request-Id", inv.id)
+	w.Header().Set("Lambda-Runtime-Deadline-Ms", strconv.FormatInt(inv.deadline.UnixNano()/int64(time.Millisecond), 10))
+	w.Header().Set("Lambda-Runtime-Invoked-Function-Arn", f.arn())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(inv.payload)
+}
+
+// complete sends the result of the binary to the invocation
+func (f *Function) complete(w http.ResponseWriter, id string, res *result) {
+	f.mu.Lock()
+	inv, ok := f.running[id]
+	delete(f.running, id)
+	f.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusBadRequest, "InvalidRequestID", fmt.Sprintf("Unknown request id %q", id))
+		return
+	}
+
+	inv.result <- res
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// readError reads the error the binary posted, Lambda.Unknown if it has no errorType
+func readError(r *http.Request) error {
+	var le lambdaError
+	if body, err := ioutil.ReadAll(r.Body); err == nil {
+		json.Unmarshal(body, &le)
+	}
+
+	name := le.ErrorType
+	if name == "" {
+		name = r.Header.Get("Lambda-Runtime-Function-Error-Type")
+	}
+	if name == "" {
+		name = "Lambda.Unknown"
+	}
+
+	return &errors.ASLError{Name: name, Cause: le.ErrorMessage}
+}
+
+// serveInvoke invokes the binary with the request body, function errors are returned like Lambda
+// with the X-Amz-Function-Error header and an errorType and errorMessage body
+func (f *Function) serveInvoke(w http.ResponseWriter, r *http.Request) {
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContentException", err.Error())
+		return
+	}
+
+	output, err := f.Invoke(r.Context(), payload)
+	w.Header().Set("Content-Type", "application/json")
+
+	if err != nil {
+		body, _ := json.Marshal(&lambdaError{ErrorType: errors.Name(err), ErrorMessage: errors.Cause(err)})
+		w.Header().Set("X-Amz-Function-Error", "Unhandled")
+		w.Write(body)
+		return
+	}
+
+	w.Write(output)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	body, _ := json.Marshal(&lambdaError{ErrorType: code, ErrorMessage: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
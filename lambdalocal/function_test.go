@@ -0,0 +1,139 @@
+package lambdalocal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coinbase/step/errors"
+	"github.com/coinbase/step/handler"
+	"github.com/coinbase/step/machine"
+	"github.com/coinbase/step/utils/run"
+	"github.com/stretchr/testify/assert"
+)
+
+// The test binary is the Lambda binary when started by a Function
+func TestMain(m *testing.M) {
+	if os.Getenv("LAMBDALOCAL_TEST_HANDLER") == "1" {
+		run.LambdaTasks(&handler.TaskHandlers{
+			"Hello": func(_ context.Context, input map[string]string) (map[string]string, error) {
+				if input["name"] == "" {
+					return nil, &errors.ASLError{Name: "Hello.Failed", Cause: "name missing"}
+				}
+				return map[string]string{"greeting": fmt.Sprintf("Hello %v", input["name"])}, nil
+			},
+			"Exit": func() error {
+				os.Exit(2)
+				return nil
+			},
+			"Sleep": func() error {
+				time.Sleep(time.Minute)
+				return nil
+			},
+		})
+	}
+
+	os.Exit(m.Run())
+}
+
+func testFunction(t *testing.T) (*Function, *httptest.Server) {
+	fn := NewFunction(os.Args[0], "")
+	fn.Env = []string{"LAMBDALOCAL_TEST_HANDLER=1"}
+	fn.Stdout = ioutil.Discard
+
+	srv := httptest.NewServer(fn)
+	fn.RuntimeAPI = strings.TrimPrefix(srv.URL, "http://")
+
+	t.Cleanup(func() {
+		fn.Close()
+		srv.Close()
+	})
+
+	return fn, srv
+}
+
+func Test_Function_Invoke(t *testing.T) {
+	fn, _ := testFunction(t)
+
+	output, err := fn.Invoke(context.Background(), []byte(`{"Task": "Hello", "Input": {"name": "step"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"greeting":"Hello step"}`, string(output))
+
+	// The error name of the binary is kept
+	_, err = fn.Invoke(context.Background(), []byte(`{"Task": "Hello", "Input": {}}`))
+	assert.Equal(t, &errors.ASLError{Name: "Hello.Failed", Cause: "name missing"}, err)
+
+	// The binary is restarted after it exits
+	_, err = fn.Invoke(context.Background(), []byte(`{"Task": "Exit"}`))
+	assert.Equal(t, "Runtime.ExitError", errors.Name(err))
+
+	output, err = fn.Invoke(context.Background(), []byte(`{"Task": "Hello", "Input": {"name": "again"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"greeting":"Hello again"}`, string(output))
+}
+
+func Test_Function_Timeout(t *testing.T) {
+	fn, _ := testFunction(t)
+	fn.Timeout = 500 * time.Millisecond
+
+	_, err := fn.Invoke(context.Background(), []byte(`{"Task": "Sleep"}`))
+	assert.Equal(t, &errors.ASLError{Name: "Lambda.Unknown", Cause: "Task timed out after 0.50 seconds"}, err)
+
+	output, err := fn.Invoke(context.Background(), []byte(`{"Task": "Hello", "Input": {"name": "step"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"greeting":"Hello step"}`, string(output))
+}
+
+func Test_Function_InvokeAPI(t *testing.T) {
+	_, srv := testFunction(t)
+	url := srv.URL + "/2015-03-31/functions/function/invocations"
+
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(`{"Task": "Hello", "Input": {}}`))
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, "Unhandled", resp.Header.Get("X-Amz-Function-Error"))
+	assert.JSONEq(t, `{"errorType": "Hello.Failed", "errorMessage": "name missing"}`, string(body))
+
+	// An invocation without a binary fails instead of waiting
+	fn := NewFunction("/does/not/exist", srv.Listener.Addr().String())
+	_, err = fn.Invoke(context.Background(), []byte(`{}`))
+	assert.Equal(t, "Runtime.InvalidEntrypoint", errors.Name(err))
+}
+
+func Test_Function_StateMachine(t *testing.T) {
+	fn, _ := testFunction(t)
+
+	sm, err := machine.FromJSON([]byte(`{
+		"StartAt": "Hello",
+		"States": {
+			"Hello": {
+				"Type": "Task",
+				"Resource": "arn:aws:lambda:us-east-1:000000000000:function:hello",
+				"Parameters": {"Task": "Hello", "Input.$": "$"},
+				"Catch": [{"ErrorEquals": ["Hello.Failed"], "Next": "Missing"}],
+				"End": true
+			},
+			"Missing": {"Type": "Pass", "End": true}
+		}
+	}`))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.SetTaskHandler("Hello", fn.TaskHandler()))
+
+	exec, err := sm.Execute(map[string]interface{}{"name": "step"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello step", exec.Output["greeting"])
+
+	exec, err = sm.Execute(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Hello", "Missing"}, exec.Path())
+	assert.Equal(t, "Hello.Failed", exec.Output["Error"])
+}
@@ -3,6 +3,7 @@ package machine
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -22,11 +23,19 @@ type ChoiceState struct {
 	Default *string `json:",omitempty"` // Default State if no choices match
 
 	Choices []*Choice `json:",omitempty"`
+
+	Output interface{} `json:",omitempty"` // JSONata only
+
+	Assign interface{} `json:",omitempty"` // Assigned if the Default is chosen
 }
 
 type Choice struct {
 	ChoiceRule
 
+	Condition *string `json:",omitempty"` // JSONata replacement for the ChoiceRule
+
+	Assign interface{} `json:",omitempty"`
+
 	Next *string `json:",omitempty"`
 }
 
@@ -119,14 +128,56 @@ func (cr *ChoiceRule) String() string {
 }
 
 func (s *ChoiceState) process(ctx context.Context, input interface{}) (interface{}, *string, error) {
-	next := chooseNextState(input, s.Default, s.Choices)
+	next, assign := s.Default, s.Assign
+	if choice := chooseChoice(input, variablesOf(ctx).all(), s.Choices); choice != nil {
+		next, assign = choice.Next, choice.Assign
+	}
+
 	if next == nil {
 		return nil, nil, fmt.Errorf("State Choice Error")
 	}
+
+	if err := assignJSONPath(ctx, assign, input); err != nil {
+		return nil, nil, err
+	}
+
 	return input, next, nil
 }
 
+// processJSONata chooses the first Choice whose Condition evaluates to true
+func (s *ChoiceState) processJSONata(ctx context.Context, input interface{}) (interface{}, *string, error) {
+	vars := statesVars(ctx, map[string]interface{}{"input": input})
+
+	for _, choice := range s.Choices {
+		value, err := evalJSONata(*choice.Condition, input, vars)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Condition Error: %v", err)
+		}
+
+		positive, ok := value.(bool)
+		if !ok {
+			return nil, nil, fmt.Errorf("Condition Error: %q must evaluate to a boolean", *choice.Condition)
+		}
+
+		if positive {
+			return input, choice.Next, assignJSONata(ctx, choice.Assign, input, map[string]interface{}{"input": input})
+		}
+	}
+
+	if s.Default == nil {
+		return nil, nil, fmt.Errorf("State Choice Error")
+	}
+
+	return input, s.Default, assignJSONata(ctx, s.Assign, input, map[string]interface{}{"input": input})
+}
+
 func (s *ChoiceState) Execute(ctx context.Context, input interface{}) (output interface{}, next *string, err error) {
+	if s.isJSONata() {
+		return processError(s,
+			jsonataIO(nil, s.Output, nil, s.processJSONata),
+		)(ctx, input)
+	}
+
 	return processError(s,
 		inputOutput(
 			s.InputPath,
@@ -136,20 +187,21 @@ func (s *ChoiceState) Execute(ctx context.Context, input interface{}) (output in
 	)(ctx, input)
 }
 
-func chooseNextState(input interface{}, default_choice *string, choices []*Choice) *string {
+// chooseChoice returns the first Choice that matches or nil
+func chooseChoice(input interface{}, vars map[string]interface{}, choices []*Choice) *Choice {
 	for _, choice := range choices {
-		if choiceRulePositive(input, &choice.ChoiceRule) {
-			return choice.Next
+		if choiceRulePositive(input, vars, &choice.ChoiceRule) {
+			return choice
 		}
 	}
-	return default_choice
+	return nil
 }
 
-func choiceRulePositive(input interface{}, cr *ChoiceRule) bool {
+func choiceRulePositive(input interface{}, vars map[string]interface{}, cr *ChoiceRule) bool {
 	if cr.And != nil {
 		for _, a := range cr.And {
 			// if any choices have false then return false
-			if !choiceRulePositive(input, a) {
+			if !choiceRulePositive(input, vars, a) {
 				return false
 			}
 		}
@@ -159,7 +211,7 @@ func choiceRulePositive(input interface{}, cr *ChoiceRule) bool {
 	if cr.Or != nil {
 		for _, a := range cr.Or {
 			// if any choices have true then return true
-			if choiceRulePositive(input, a) {
+			if choiceRulePositive(input, vars, a) {
 				return true
 			}
 		}
@@ -167,7 +219,13 @@ func choiceRulePositive(input interface{}, cr *ChoiceRule) bool {
 	}
 
 	if cr.Not != nil {
-		return !choiceRulePositive(input, cr.Not)
+		return !choiceRulePositive(input, vars, cr.Not)
+	}
+
+	// Variable can read from a workflow variable instead of the input
+	input, err := pathSource(cr.Variable, input, vars)
+	if err != nil {
+		return false
 	}
 
 	if cr.StringEquals != nil {
@@ -315,8 +373,29 @@ func (s *ChoiceState) Validate() error {
 		return fmt.Errorf("%v Must have Choices", errorPrefix(s))
 	}
 
+	if err := s.fieldsValid(map[string]bool{
+		"InputPath":  s.InputPath != nil,
+		"OutputPath": s.OutputPath != nil,
+	}, map[string]bool{
+		"Output": s.Output != nil,
+	}); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
+	if err := assignValid(s.Assign); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
 	for _, c := range s.Choices {
+		if err := assignValid(c.Assign); err != nil {
+			return fmt.Errorf("%v %v", errorPrefix(s), err)
+		}
+
 		err := validateChoice(c)
+		if s.isJSONata() {
+			err = validateJSONataChoice(c)
+		}
+
 		if err != nil {
 			return fmt.Errorf("%v %v", errorPrefix(s), err)
 		}
@@ -325,12 +404,33 @@ func (s *ChoiceState) Validate() error {
 	return nil
 }
 
+// validateJSONataChoice requires a Condition and no JSONPath ChoiceRule
+func validateJSONataChoice(c *Choice) error {
+	if c.Next == nil {
+		return fmt.Errorf("Choice must have Next")
+	}
+
+	if c.Condition == nil {
+		return fmt.Errorf("Choice must have Condition")
+	}
+
+	if !reflect.DeepEqual(c.ChoiceRule, ChoiceRule{}) {
+		return fmt.Errorf("Choice Rules not allowed with QueryLanguage JSONata")
+	}
+
+	return nil
+}
+
 func validateChoice(c *Choice) error {
 
 	if c.Next == nil {
 		return fmt.Errorf("Choice must have Next")
 	}
 
+	if c.Condition != nil {
+		return fmt.Errorf("Condition not allowed with QueryLanguage JSONPath")
+	}
+
 	all_choice_rules := recursiveAllChoiceRule(&c.ChoiceRule)
 
 	for _, cr := range all_choice_rules {
@@ -0,0 +1,92 @@
+package machine
+
+import (
+	"context"
+)
+
+type contextObjectKey struct{}
+
+// withContextObject adds the context object ($$ in JSONPath, $states.context in JSONata) to ctx
+func withContextObject(ctx context.Context, contextObj map[string]interface{}) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, contextObjectKey{}, contextObj)
+}
+
+// contextObject returns the context object stored in ctx, or an empty object
+func contextObject(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return map[string]interface{}{}
+	}
+
+	contextObj, ok := ctx.Value(contextObjectKey{}).(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+
+	return contextObj
+}
+
+// TaskToken returns the $$.Task.Token of a waitForTaskToken Task from the ctx passed to its handler
+func TaskToken(ctx context.Context) *string {
+	task, ok := contextObject(ctx)["Task"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	token, ok := task["Token"].(string)
+	if !ok {
+		return nil
+	}
+
+	return &token
+}
+
+type variablesKey struct{}
+
+// withVariables adds the workflow variables scope to ctx
+func withVariables(ctx context.Context, vars *variables) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, variablesKey{}, vars)
+}
+
+// variablesOf returns the workflow variables scope stored in ctx, or a new empty scope
+func variablesOf(ctx context.Context) *variables {
+	if ctx == nil {
+		return newVariables(nil)
+	}
+
+	vars, ok := ctx.Value(variablesKey{}).(*variables)
+	if !ok {
+		return newVariables(nil)
+	}
+
+	return vars
+}
+
+type retriesKey struct{}
+
+// withRetries adds the attempts of each Retrier in the execution to ctx
+func withRetries(ctx context.Context, retries map[*Retrier]int) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, retriesKey{}, retries)
+}
+
+// retriesOf returns the Retrier attempts stored in ctx, or a new empty map
+func retriesOf(ctx context.Context) map[*Retrier]int {
+	if ctx == nil {
+		return map[*Retrier]int{}
+	}
+
+	retries, ok := ctx.Value(retriesKey{}).(map[*Retrier]int)
+	if !ok {
+		return map[*Retrier]int{}
+	}
+
+	return retries
+}
@@ -11,9 +11,18 @@ import (
 
 type HistoryEvent struct {
 	sfn.HistoryEvent
+
+	// JSON encoded workflow variables assigned by the state on StateExited events
+	AssignedVariables map[string]*string `json:",omitempty"`
 }
 
+// An EventListener is sent the history events of an execution as they are added,
+// e.g. to show the progress of a running execution
+type EventListener func(HistoryEvent)
+
 type Execution struct {
+	ID string // empty for Map iterations
+
 	Output     map[string]interface{}
 	OutputJSON string
 	Error      error
@@ -23,6 +32,41 @@ type Execution struct {
 	LastError      error // interim error
 
 	ExecutionHistory []HistoryEvent
+
+	input     interface{}      // execution input for the context object
+	variables *variables       // workflow variables assigned with Assign
+	retries   map[*Retrier]int // attempts of each Retrier
+
+	next      *string        // the running state
+	nextInput interface{}    // the input of the running state
+	store     ExecutionStore // nil if the execution is not checkpointed
+	listener  EventListener  // nil if no listener is set
+}
+
+// Variables returns the workflow variables assigned during the execution
+func (sm *Execution) Variables() map[string]interface{} {
+	if sm.variables == nil {
+		return map[string]interface{}{}
+	}
+	return sm.variables.all()
+}
+
+// encodeVariables JSON encodes the assigned variables for a StateExited event, nil if there are none
+func encodeVariables(assigned map[string]interface{}) map[string]*string {
+	if len(assigned) == 0 {
+		return nil
+	}
+
+	encoded := map[string]*string{}
+	for name, value := range assigned {
+		json_raw, err := json.Marshal(value)
+		if err != nil {
+			json_raw = []byte{}
+		}
+		encoded[name] = to.Strp(string(json_raw))
+	}
+
+	return encoded
 }
 
 func (sm *Execution) SetOutput(output interface{}, err error) {
@@ -50,23 +94,72 @@ func (sm *Execution) SetLastOutput(output interface{}, err error) {
 }
 
 func (sm *Execution) EnteredEvent(s State, input interface{}) {
-	sm.ExecutionHistory = append(sm.ExecutionHistory, createEnteredEvent(s, input))
+	sm.addEvent(createEnteredEvent(s, input))
 }
 
 func (sm *Execution) ExitedEvent(s State, output interface{}) {
-	sm.ExecutionHistory = append(sm.ExecutionHistory, createExitedEvent(s, output))
+	sm.exitedEvent(s, output, nil)
+}
+
+// exitedEvent records the variables the state assigned on its StateExited event
+func (sm *Execution) exitedEvent(s State, output interface{}, assigned map[string]interface{}) {
+	event := createExitedEvent(s, output)
+	event.AssignedVariables = encodeVariables(assigned)
+	sm.addEvent(event)
+}
+
+// addEvent appends event to the history and sends it to the listener
+func (sm *Execution) addEvent(event HistoryEvent) {
+	sm.ExecutionHistory = append(sm.ExecutionHistory, event)
+
+	if sm.listener != nil {
+		sm.listener(event)
+	}
+}
+
+// contextObject returns the context object of the state about to be executed
+func (sm *Execution) contextObject(s State) map[string]interface{} {
+	startTime := ""
+	if len(sm.ExecutionHistory) > 0 && sm.ExecutionHistory[0].Timestamp != nil {
+		startTime = sm.ExecutionHistory[0].Timestamp.Format(time.RFC3339Nano)
+	}
+
+	contextObj := map[string]interface{}{
+		"Execution": map[string]interface{}{
+			"Input":     sm.input,
+			"StartTime": startTime,
+		},
+		"State": map[string]interface{}{
+			"Name":        *s.Name(),
+			"EnteredTime": time.Now().Format(time.RFC3339Nano),
+		},
+	}
+
+	// Tasks that wait for a callback get a token to pass to the worker
+	if task, ok := s.(*TaskState); ok && task.waitForTaskToken() {
+		contextObj["Task"] = map[string]interface{}{
+			"Token": *to.TimeUUID("token-"),
+		}
+	}
+
+	return contextObj
 }
 
 func (sm *Execution) Start() {
-	sm.ExecutionHistory = []HistoryEvent{createEvent("ExecutionStarted")}
+	sm.ExecutionHistory = []HistoryEvent{}
+	sm.addEvent(createEvent("ExecutionStarted"))
 }
 
 func (sm *Execution) Failed() {
-	sm.ExecutionHistory = append(sm.ExecutionHistory, createEvent("ExecutionFailed"))
+	sm.addEvent(createEvent("ExecutionFailed"))
+}
+
+func (sm *Execution) Redriven() {
+	sm.addEvent(createEvent("ExecutionRedriven"))
 }
 
 func (sm *Execution) Succeeded() {
-	sm.ExecutionHistory = append(sm.ExecutionHistory, createEvent("ExecutionSucceeded"))
+	sm.addEvent(createEvent("ExecutionSucceeded"))
 }
 
 // Path returns the Path of States, ignoreing TaskFn states
@@ -84,7 +177,7 @@ func (sm *Execution) Path() []string {
 func createEvent(name string) HistoryEvent {
 	t := time.Now()
 	return HistoryEvent{
-		sfn.HistoryEvent{
+		HistoryEvent: sfn.HistoryEvent{
 			Type:      to.Strp(name),
 			Timestamp: &t,
 		},
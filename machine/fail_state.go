@@ -18,8 +18,42 @@ type FailState struct {
 	Cause *string `json:",omitempty"`
 }
 
-func (s *FailState) Execute(_ context.Context, input interface{}) (output interface{}, next *string, err error) {
-	return errorOutput(s.Error, s.Cause), nil, fmt.Errorf("Fail")
+func (s *FailState) Execute(ctx context.Context, input interface{}) (output interface{}, next *string, err error) {
+	if !s.isJSONata() {
+		return errorOutput(s.Error, s.Cause), nil, fmt.Errorf("Fail")
+	}
+
+	// In JSONata Error and Cause can be expressions
+	vars := statesVars(ctx, map[string]interface{}{"input": input})
+	errorStr, err := s.evalString(s.Error, input, vars)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
+	cause, err := s.evalString(s.Cause, input, vars)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
+	return errorOutput(errorStr, cause), nil, fmt.Errorf("Fail")
+}
+
+func (s *FailState) evalString(str *string, input interface{}, vars map[string]interface{}) (*string, error) {
+	if str == nil {
+		return nil, nil
+	}
+
+	value, err := evalJSONata(*str, input, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	valueStr, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%q must evaluate to a string", *str)
+	}
+
+	return &valueStr, nil
 }
 
 func (s *FailState) Validate() error {
@@ -33,6 +67,10 @@ func (s *FailState) Validate() error {
 		return fmt.Errorf("%v %v", errorPrefix(s), "must contain Error")
 	}
 
+	if err := s.fieldsValid(map[string]bool{}, map[string]bool{}); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,359 @@
+package machine
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Intrinsic Functions that return the array a Map state's ItemsPath iterates over, e.g. States.ArrayRange(1, $.count, 1)
+// https://docs.aws.amazon.com/step-functions/latest/dg/amazon-states-language-intrinsic-functions.html
+
+type intrinsicFn func(args []interface{}) (interface{}, error)
+
+var intrinsicFunctions map[string]intrinsicFn
+
+func init() {
+	intrinsicFunctions = map[string]intrinsicFn{
+		"States.Array":          intrinsicArray,
+		"States.ArrayPartition": intrinsicArrayPartition,
+		"States.ArrayRange":     intrinsicArrayRange,
+		"States.ArrayUnique":    intrinsicArrayUnique,
+		"States.StringSplit":    intrinsicStringSplit,
+	}
+}
+
+// isIntrinsic returns true if the string looks like an intrinsic function call
+func isIntrinsic(str string) bool {
+	return strings.HasPrefix(str, "States.")
+}
+
+// evalIntrinsic parses and evaluates an intrinsic function,
+// paths starting with $ are read from input and $name from variables
+func evalIntrinsic(expr string, input interface{}, vars map[string]interface{}) (interface{}, error) {
+	p := &intrinsicParser{src: expr, input: input, vars: vars}
+
+	value, err := p.call()
+	if err != nil {
+		return nil, fmt.Errorf("Intrinsic Error %q: %v", expr, err)
+	}
+
+	p.skipSpace()
+	if !p.eof() {
+		return nil, fmt.Errorf("Intrinsic Error %q: unexpected %q", expr, p.src[p.pos:])
+	}
+
+	return value, nil
+}
+
+//////
+// Parser
+//////
+
+type intrinsicParser struct {
+	src   string
+	pos   int
+	input interface{}
+	vars  map[string]interface{}
+}
+
+func (p *intrinsicParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *intrinsicParser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *intrinsicParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\n') {
+		p.pos++
+	}
+}
+
+func (p *intrinsicParser) expect(c byte) error {
+	p.skipSpace()
+	if p.eof() || p.peek() != c {
+		return fmt.Errorf("expected %q at %v", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+// token reads until a delimiter
+func (p *intrinsicParser) token() string {
+	start := p.pos
+	for !p.eof() {
+		switch p.peek() {
+		case ',', ')', '(', ' ', '\t', '\n':
+			return p.src[start:p.pos]
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *intrinsicParser) call() (interface{}, error) {
+	p.skipSpace()
+	name := p.token()
+
+	fn, ok := intrinsicFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{}
+
+	p.skipSpace()
+	if !p.eof() && p.peek() == ')' {
+		p.pos++
+		return fn(args)
+	}
+
+	for {
+		arg, err := p.arg()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		p.skipSpace()
+		if p.eof() {
+			return nil, fmt.Errorf("unterminated function %q", name)
+		}
+
+		c := p.peek()
+		p.pos++
+		if c == ')' {
+			break
+		}
+		if c != ',' {
+			return nil, fmt.Errorf("unexpected %q at %v", c, p.pos-1)
+		}
+	}
+
+	value, err := fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+	return value, nil
+}
+
+func (p *intrinsicParser) arg() (interface{}, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, fmt.Errorf("expected argument")
+	}
+
+	rest := p.src[p.pos:]
+
+	switch {
+	case p.peek() == '\'':
+		return p.str()
+	case p.peek() == '$':
+		return getPath(p.token(), p.input, nil, p.vars)
+	case isIntrinsic(rest):
+		return p.call()
+	}
+
+	tok := p.token()
+	switch tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	num, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unknown argument %q", tok)
+	}
+	return num, nil
+}
+
+func (p *intrinsicParser) str() (interface{}, error) {
+	p.pos++ // opening quote
+
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		p.pos++
+
+		switch c {
+		case '\\':
+			if p.eof() {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			sb.WriteByte(p.peek())
+			p.pos++
+		case '\'':
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return nil, fmt.Errorf("unterminated string")
+}
+
+//////
+// Argument Helpers
+//////
+
+func argCount(args []interface{}, min int, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return fmt.Errorf("wrong number of arguments %v", len(args))
+	}
+	return nil
+}
+
+func argString(arg interface{}) (string, error) {
+	str, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %v must be string", arg)
+	}
+	return str, nil
+}
+
+func argArray(arg interface{}) ([]interface{}, error) {
+	arr, ok := arg.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument %v must be array", arg)
+	}
+	return arr, nil
+}
+
+func argInt(arg interface{}) (int, error) {
+	num, ok := arg.(float64)
+	if !ok || num != math.Trunc(num) {
+		return 0, fmt.Errorf("argument %v must be integer", arg)
+	}
+	return int(num), nil
+}
+
+//////
+// Functions
+//////
+
+func intrinsicArray(args []interface{}) (interface{}, error) {
+	return append([]interface{}{}, args...), nil
+}
+
+func intrinsicArrayPartition(args []interface{}) (interface{}, error) {
+	if err := argCount(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	arr, err := argArray(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := argInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= 0 {
+		return nil, fmt.Errorf("chunk size must be positive")
+	}
+
+	chunks := []interface{}{}
+	for i := 0; i < len(arr); i += size {
+		end := i + size
+		if end > len(arr) {
+			end = len(arr)
+		}
+		chunks = append(chunks, append([]interface{}{}, arr[i:end]...))
+	}
+	return chunks, nil
+}
+
+func intrinsicArrayRange(args []interface{}) (interface{}, error) {
+	if err := argCount(args, 3, 3); err != nil {
+		return nil, err
+	}
+
+	nums := []int{}
+	for _, arg := range args {
+		num, err := argInt(arg)
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, num)
+	}
+
+	start, end, step := nums[0], nums[1], nums[2]
+	if step == 0 {
+		return nil, fmt.Errorf("step must not be 0")
+	}
+
+	arr := []interface{}{}
+	for i := start; (step > 0 && i <= end) || (step < 0 && i >= end); i += step {
+		if len(arr) >= 1000 {
+			return nil, fmt.Errorf("range larger than 1000 items")
+		}
+		arr = append(arr, float64(i))
+	}
+	return arr, nil
+}
+
+func intrinsicArrayUnique(args []interface{}) (interface{}, error) {
+	if err := argCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	arr, err := argArray(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	unique := []interface{}{}
+	for _, v := range arr {
+		found := false
+		for _, u := range unique {
+			if reflect.DeepEqual(u, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unique = append(unique, v)
+		}
+	}
+	return unique, nil
+}
+
+func intrinsicStringSplit(args []interface{}) (interface{}, error) {
+	if err := argCount(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	str, err := argString(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	delimiters, err := argString(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.FieldsFunc(str, func(r rune) bool {
+		return strings.ContainsRune(delimiters, r)
+	})
+
+	arr := []interface{}{}
+	for _, f := range fields {
+		arr = append(arr, f)
+	}
+	return arr, nil
+}
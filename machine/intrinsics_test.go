@@ -0,0 +1,50 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Intrinsics_Eval(t *testing.T) {
+	input := map[string]interface{}{
+		"list": []interface{}{"a", "b", "a"},
+		"num":  float64(3),
+		"csv":  "a,b c",
+	}
+	vars := map[string]interface{}{"count": float64(2)}
+
+	tests := map[string]interface{}{
+		`States.Array(1, 'a', $.num, true, null)`:              []interface{}{float64(1), "a", float64(3), true, nil},
+		`States.Array($count)`:                                 []interface{}{float64(2)},
+		`States.ArrayRange(1, 9, 4)`:                           []interface{}{float64(1), float64(5), float64(9)},
+		`States.ArrayRange(3, 1, -1)`:                          []interface{}{float64(3), float64(2), float64(1)},
+		`States.ArrayPartition(States.ArrayRange(1, 3, 1), 2)`: []interface{}{[]interface{}{float64(1), float64(2)}, []interface{}{float64(3)}},
+		`States.ArrayUnique($.list)`:                           []interface{}{"a", "b"},
+		`States.StringSplit($.csv, ', ')`:                      []interface{}{"a", "b", "c"},
+		`States.StringSplit('it\'s', '\'')`:                    []interface{}{"it", "s"},
+	}
+
+	for expr, expected := range tests {
+		value, err := evalIntrinsic(expr, input, vars)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, expected, value, expr)
+	}
+}
+
+func Test_Intrinsics_Errors(t *testing.T) {
+	for _, expr := range []string{
+		`States.Unknown()`,
+		`States.Format('{}', $.list)`,
+		`States.ArrayRange(1, 'a', 1)`,
+		`States.ArrayPartition($.list, 0)`,
+		`States.ArrayUnique('a')`,
+		`States.StringSplit('a')`,
+		`States.Array(1`,
+		`States.Array(1) trailing`,
+		`States.Array($.missing)`,
+	} {
+		_, err := evalIntrinsic(expr, map[string]interface{}{"list": []interface{}{}}, nil)
+		assert.Error(t, err, expr)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/coinbase/step/handler"
 	"github.com/coinbase/step/utils/is"
 	"github.com/coinbase/step/utils/to"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func DefaultHandler(_ context.Context, input interface{}) (interface{}, error) {
@@ -32,9 +33,15 @@ type States map[string]State
 type StateMachine struct {
 	Comment *string `json:",omitempty"`
 
+	QueryLanguage *string `json:",omitempty"` // JSONPath (default) or JSONata
+
 	StartAt *string
 
 	States States
+
+	store          ExecutionStore       // checkpoints top level executions
+	listener       EventListener        // is sent the history events of top level executions
+	tracerProvider trace.TracerProvider // traces executions, otel.GetTracerProvider() if nil
 }
 
 // Global Methods
@@ -72,6 +79,22 @@ func (sm *StateMachine) Tasks() map[string]*TaskState {
 	return tasks
 }
 
+// TaskStates returns all Task states, including the Task states of Map ItemProcessors
+func (sm *StateMachine) TaskStates() []*TaskState {
+	tasks := []*TaskState{}
+	for _, s := range sm.States {
+		switch s.(type) {
+		case *TaskState:
+			tasks = append(tasks, s.(*TaskState))
+		case *MapState:
+			if processor := s.(*MapState).processor(); processor != nil {
+				tasks = append(tasks, processor.TaskStates()...)
+			}
+		}
+	}
+	return tasks
+}
+
 func (sm *StateMachine) SetResource(lambda_arn *string) {
 	for _, task := range sm.Tasks() {
 		if task.Resource == nil {
@@ -127,6 +150,10 @@ func (sm *StateMachine) Validate() error {
 		return errors.New("State Machine must have States")
 	}
 
+	if err := queryLanguageValid(sm.QueryLanguage); err != nil {
+		return err
+	}
+
 	state_errors := []string{}
 
 	for _, state := range sm.States {
@@ -145,7 +172,11 @@ func (sm *StateMachine) Validate() error {
 }
 
 func (sm *StateMachine) DefaultLambdaContext(lambda_name string) context.Context {
-	return lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+	return lambdaContext(context.Background(), lambda_name)
+}
+
+func lambdaContext(ctx context.Context, lambda_name string) context.Context {
+	return lambdacontext.NewContext(ctx, &lambdacontext.LambdaContext{
 		InvokedFunctionArn: fmt.Sprintf("arn:aws:lambda:us-east-1:000000000000:function:%v", lambda_name),
 	})
 }
@@ -172,6 +203,28 @@ func processInput(input interface{}) (interface{}, error) {
 }
 
 func (sm *StateMachine) Execute(input interface{}) (*Execution, error) {
+	return sm.ExecuteWithID(*to.TimeUUID("execution-"), input)
+}
+
+// ExecuteWithID executes the State Machine as execution id,
+// if a store is set the execution is checkpointed to it and can be resumed with Resume(id)
+func (sm *StateMachine) ExecuteWithID(id string, input interface{}) (*Execution, error) {
+	return sm.ExecuteWithContext(context.Background(), id, input)
+}
+
+// ExecuteWithContext executes the State Machine as execution id until ctx is done,
+// Task handlers get ctx and no state starts after it is cancelled
+func (sm *StateMachine) ExecuteWithContext(ctx context.Context, id string, input interface{}) (*Execution, error) {
+	return sm.start(ctx, &Execution{ID: id, variables: newVariables(nil), store: sm.store, listener: sm.listener}, input)
+}
+
+// execute runs the State Machine in a new variables scope inside parent, ctx is the Map state context
+func (sm *StateMachine) execute(ctx context.Context, input interface{}, parent *variables) (*Execution, error) {
+	return sm.start(ctx, &Execution{variables: newVariables(parent)}, input)
+}
+
+// start runs exec from StartAt
+func (sm *StateMachine) start(ctx context.Context, exec *Execution, input interface{}) (*Execution, error) {
 	if err := sm.Validate(); err != nil {
 		return nil, err
 	}
@@ -182,11 +235,98 @@ func (sm *StateMachine) Execute(input interface{}) (*Execution, error) {
 	}
 
 	// Start Execution (records the history, inputs, outputs...)
-	exec := &Execution{}
+	exec.input = input
 	exec.Start()
 
-	// Execute Start State
-	output, err := sm.stateLoop(exec, sm.StartAt, input)
+	return sm.run(ctx, exec, sm.StartAt, input)
+}
+
+// Resume continues a RUNNING execution from the Checkpoint in the store
+func (sm *StateMachine) Resume(execution_id string) (*Execution, error) {
+	if sm.store == nil {
+		return nil, fmt.Errorf("Resume requires an ExecutionStore, use SetStore")
+	}
+
+	if err := sm.Validate(); err != nil {
+		return nil, err
+	}
+
+	cp, err := sm.store.Load(execution_id)
+	if err != nil {
+		return nil, err
+	}
+
+	if cp.Status != "RUNNING" {
+		return nil, fmt.Errorf("Execution %q is %v, only RUNNING executions can be resumed", execution_id, cp.Status)
+	}
+
+	exec := executionFromCheckpoint(cp, sm.store)
+	exec.listener = sm.listener
+
+	return sm.run(context.Background(), exec, cp.Next, cp.Input)
+}
+
+// Redrive restarts a failed execution at the state that failed with the input it failed with,
+// the results and variables of the earlier states are kept.
+// Replacement handlers can be set with SetTaskHandler or SetTaskFnHandlers before redriving
+func (sm *StateMachine) Redrive(failed *Execution) (*Execution, error) {
+	return sm.RedriveWithContext(context.Background(), failed)
+}
+
+// RedriveWithContext redrives the failed execution until ctx is done
+func (sm *StateMachine) RedriveWithContext(ctx context.Context, failed *Execution) (*Execution, error) {
+	if failed == nil || failed.Error == nil || failed.next == nil {
+		return nil, fmt.Errorf("Only failed executions can be redriven")
+	}
+
+	if err := sm.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := sm.States[*failed.next]; !ok {
+		return nil, fmt.Errorf("Unknown State: %v", *failed.next)
+	}
+
+	vars := newVariables(nil)
+	for name, value := range failed.Variables() {
+		vars.values[name] = value
+	}
+
+	history := append([]HistoryEvent{}, failed.ExecutionHistory...)
+
+	exec := &Execution{
+		ID:               failed.ID,
+		ExecutionHistory: history,
+		input:            failed.input,
+		variables:        vars,
+		store:            sm.store,
+		listener:         sm.listener,
+	}
+	exec.Redriven()
+
+	return sm.run(ctx, exec, failed.next, failed.nextInput)
+}
+
+// SetStore sets where executions are checkpointed
+func (sm *StateMachine) SetStore(store ExecutionStore) {
+	sm.store = store
+}
+
+// SetEventListener sets the listener sent each history event as it is added
+func (sm *StateMachine) SetEventListener(listener EventListener) {
+	sm.listener = listener
+}
+
+// run executes from the next state and records the result
+func (sm *StateMachine) run(ctx context.Context, exec *Execution, next *string, input interface{}) (*Execution, error) {
+	// Map iterations are traced by their Map state
+	if exec.ID != "" {
+		var span trace.Span
+		ctx, span = sm.tracer(ctx).Start(ctx, "Execution", trace.WithAttributes(executionIDKey.String(exec.ID)))
+		defer func() { endSpan(span, exec.Error) }()
+	}
+
+	output, err := sm.stateLoop(ctx, exec, next, input)
 
 	// Set Final Output
 	exec.SetOutput(output, err)
@@ -197,10 +337,20 @@ func (sm *StateMachine) Execute(input interface{}) (*Execution, error) {
 		exec.Succeeded()
 	}
 
+	if serr := exec.finish(output, err); serr != nil && err == nil {
+		err = serr
+	}
+
 	return exec, err
 }
 
-func (sm *StateMachine) stateLoop(exec *Execution, next *string, input interface{}) (output interface{}, err error) {
+func (sm *StateMachine) stateLoop(parent context.Context, exec *Execution, next *string, input interface{}) (output interface{}, err error) {
+	retries := 0 // attempts of the state before this one
+
+	if exec.retries == nil {
+		exec.retries = map[*Retrier]int{}
+	}
+
 	// Flat loop instead of recursion to better implement timeouts
 	for {
 		s, ok := sm.States[*next]
@@ -209,18 +359,48 @@ func (sm *StateMachine) stateLoop(exec *Execution, next *string, input interface
 			return nil, fmt.Errorf("Unknown State: %v", *next)
 		}
 
+		// A stopped execution starts no more states
+		if err := parent.Err(); err != nil {
+			return nil, err
+		}
+
 		if len(exec.ExecutionHistory) > 250 {
 			return nil, fmt.Errorf("State Overflow")
 		}
 
+		if err := exec.checkpoint(next, input); err != nil {
+			return nil, err
+		}
+
 		exec.EnteredEvent(s, input)
 
-		output, next, err = s.Execute(sm.DefaultLambdaContext(*s.Name()), input)
+		ctx, span := sm.startStateSpan(parent, s, retries)
+		ctx = withContextObject(lambdaContext(ctx, *s.Name()), exec.contextObject(s))
+		ctx = withVariables(ctx, exec.variables)
+		ctx = withRetries(ctx, exec.retries)
+		output, next, err = s.Execute(ctx, input)
+
+		// Variables are only assigned if the state completes
+		var assigned map[string]interface{}
+		if err == nil {
+			assigned, err = exec.variables.commit()
+		} else {
+			exec.variables.discard()
+		}
+
+		endSpan(span, err)
+
+		// A Retrier sends the state back to itself
+		if err == nil && next != nil && *next == *s.Name() {
+			retries++
+		} else {
+			retries = 0
+		}
 
 		if *s.GetType() != "Fail" {
 			// Failure States Dont exit.
 			exec.SetLastOutput(output, err)
-			exec.ExitedEvent(s, output)
+			exec.exitedEvent(s, output, assigned)
 		}
 
 		// If Error return error
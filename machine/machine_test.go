@@ -1,10 +1,13 @@
 package machine
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"testing"
 
+	"github.com/coinbase/step/errors"
 	"github.com/coinbase/step/utils/to"
 	"github.com/stretchr/testify/assert"
 )
@@ -95,3 +98,126 @@ func Test_Machine_MarshallAllTypes(t *testing.T) {
 
 	assert.JSONEq(t, string(raw_json), string(marshalled_json))
 }
+
+func Test_Machine_Redrive(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"StartAt": "Start",
+		"States": {
+			"Start": {"Type": "Pass", "Assign": {"count": 1}, "Result": "started", "ResultPath": "$.start", "Next": "Report"},
+			"Report": {"Type": "Task", "Resource": "report", "Parameters": {"count.$": "$count", "start.$": "$.start"}, "End": true}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.SetTaskHandler("Report", func(_ context.Context, input interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("report failed")
+	}))
+
+	failed, err := sm.Execute(map[string]interface{}{})
+	assert.Error(t, err)
+
+	// Only failed executions can be redriven
+	_, err = sm.Redrive(&Execution{})
+	assert.Error(t, err)
+
+	// Redrive with a replacement handler restarts at Report with its input and the variables
+	assert.NoError(t, sm.SetTaskHandler("Report", ReturnInputHandler))
+	exec, err := sm.Redrive(failed)
+	assert.NoError(t, err)
+
+	assert.Equal(t, failed.ID, exec.ID)
+	assert.Equal(t, map[string]interface{}{"count": float64(1), "start": "started"}, exec.Output)
+	assert.Equal(t, []string{"Start", "Report", "Report"}, exec.Path())
+
+	types := []string{}
+	for _, he := range exec.ExecutionHistory {
+		if *he.Type == "ExecutionFailed" || *he.Type == "ExecutionRedriven" || *he.Type == "ExecutionSucceeded" {
+			types = append(types, *he.Type)
+		}
+	}
+	assert.Equal(t, []string{"ExecutionFailed", "ExecutionRedriven", "ExecutionSucceeded"}, types)
+}
+
+func Test_Machine_Redrive_Retries(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"StartAt": "Report",
+		"States": {
+			"Report": {
+				"Type": "Task",
+				"Resource": "report",
+				"Retry": [{"ErrorEquals": ["States.ALL"], "MaxAttempts": 1}],
+				"End": true
+			}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	calls := 0
+	assert.NoError(t, sm.SetTaskHandler("Report", func(_ context.Context, input interface{}) (interface{}, error) {
+		calls++
+		if calls < 4 {
+			return nil, fmt.Errorf("report failed")
+		}
+		return input, nil
+	}))
+
+	failed, err := sm.Execute(map[string]interface{}{})
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls)
+
+	// The redriven state is retried again, it fails once more then succeeds on its retry
+	_, err = sm.Redrive(failed)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, calls)
+}
+
+func Test_Machine_Map_Catch_Inner_Error(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"StartAt": "M",
+		"States": {
+			"M": {
+				"Type": "Map",
+				"ItemsPath": "$.items",
+				"ResultPath": "$.map",
+				"ItemProcessor": {"StartAt": "T", "States": {"T": {"Type": "Task", "Resource": "t", "End": true}}},
+				"Catch": [{"ErrorEquals": ["MyError"], "ResultPath": "$.error", "Next": "Done"}],
+				"Next": "Done"
+			},
+			"Done": {"Type": "Succeed"}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	task := sm.States["M"].(*MapState).processor().States["T"].(*TaskState)
+	task.SetTaskHandler(func(_ context.Context, input interface{}) (interface{}, error) {
+		return nil, &errors.ASLError{Name: "MyError", Cause: "boom"}
+	})
+
+	exec, err := sm.Execute(map[string]interface{}{"items": []interface{}{1}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Error": "MyError", "Cause": "boom"}, exec.Output["error"])
+	assert.Equal(t, []string{"M", "Done"}, exec.Path())
+}
+
+func Test_Machine_EventListener(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"StartAt": "A",
+		"States": {
+			"A": {"Type": "Pass", "Assign": {"x": 1}, "Next": "B"},
+			"B": {"Type": "Succeed"}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	events := []HistoryEvent{}
+	sm.SetEventListener(func(he HistoryEvent) {
+		events = append(events, he)
+	})
+
+	exec, err := sm.Execute(map[string]interface{}{})
+	assert.NoError(t, err)
+
+	// The listener is sent every event, including the assigned variables
+	assert.Equal(t, exec.ExecutionHistory, events)
+	assert.Equal(t, "1", *events[2].AssignedVariables["x"])
+}
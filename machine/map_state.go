@@ -2,9 +2,12 @@ package machine
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+
 	"github.com/coinbase/step/jsonpath"
 	"github.com/coinbase/step/utils/to"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MapState struct {
@@ -13,9 +16,19 @@ type MapState struct {
 	Type    *string
 	Comment *string `json:",omitempty"`
 
-	Iterator   *StateMachine
-	ItemsPath  *jsonpath.Path `json:",omitempty"`
-	Parameters interface{}    `json:",omitempty"`
+	ItemProcessor *ItemProcessor `json:",omitempty"`
+	ItemSelector  interface{}    `json:",omitempty"`
+	ItemsPath     *ItemsSource   `json:",omitempty"`
+
+	// JSONata replacements for ItemsPath and the paths
+	Items  interface{} `json:",omitempty"`
+	Output interface{} `json:",omitempty"`
+
+	Assign interface{} `json:",omitempty"`
+
+	// Iterator and Parameters are the legacy names for ItemProcessor and ItemSelector
+	Iterator   *StateMachine `json:",omitempty"`
+	Parameters interface{}   `json:",omitempty"`
 
 	MaxConcurrency *float64 `json:",omitempty"`
 
@@ -30,15 +43,235 @@ type MapState struct {
 	End  *bool   `json:",omitempty"`
 }
 
+// ItemProcessor is the State Machine executed for each item
+type ItemProcessor struct {
+	ProcessorConfig *ProcessorConfig `json:",omitempty"`
+
+	StateMachine
+}
+
+// UnmarshalJSON is required as the embedded StateMachine.UnmarshalJSON would skip ProcessorConfig
+func (ip *ItemProcessor) UnmarshalJSON(b []byte) error {
+	var config struct {
+		ProcessorConfig *ProcessorConfig
+	}
+
+	if err := json.Unmarshal(b, &config); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, &ip.StateMachine); err != nil {
+		return err
+	}
+
+	ip.ProcessorConfig = config.ProcessorConfig
+	return nil
+}
+
+type ProcessorConfig struct {
+	Mode          *string `json:",omitempty"` // INLINE or DISTRIBUTED
+	ExecutionType *string `json:",omitempty"`
+}
+
+// ItemsSource is the ItemsPath of a Map state,
+// it can be a path, an intrinsic function or an array literal
+type ItemsSource struct {
+	path      *jsonpath.Path
+	intrinsic *string
+	literal   []interface{}
+}
+
+// UnmarshalJSON parses a path string, intrinsic string or array
+func (items *ItemsSource) UnmarshalJSON(b []byte) error {
+	var literal []interface{}
+	if err := json.Unmarshal(b, &literal); err == nil {
+		items.literal = literal
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return fmt.Errorf("ItemsPath must be a path, intrinsic function or array")
+	}
+
+	if isIntrinsic(str) {
+		items.intrinsic = &str
+		return nil
+	}
+
+	path, err := jsonpath.NewPath(str)
+	if err != nil {
+		return err
+	}
+
+	items.path = path
+	return nil
+}
+
+// MarshalJSON converts ItemsSource back to its json value
+func (items *ItemsSource) MarshalJSON() ([]byte, error) {
+	if items.literal != nil {
+		return json.Marshal(items.literal)
+	}
+
+	if items.intrinsic != nil {
+		return json.Marshal(*items.intrinsic)
+	}
+
+	return items.path.MarshalJSON()
+}
+
+func (items *ItemsSource) String() string {
+	raw, _ := items.MarshalJSON()
+
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return string(raw)
+	}
+	return str
+}
+
+// GetSlice returns the items to iterate over from the input or workflow variables
+func (items *ItemsSource) GetSlice(input interface{}, vars map[string]interface{}) ([]interface{}, error) {
+	if items == nil {
+		return (*jsonpath.Path)(nil).GetSlice(input)
+	}
+
+	if items.literal != nil {
+		return items.literal, nil
+	}
+
+	if items.intrinsic != nil {
+		value, err := evalIntrinsic(*items.intrinsic, input, vars)
+		if err != nil {
+			return nil, err
+		}
+
+		output, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("GetSlice Error: must be an array")
+		}
+		return output, nil
+	}
+
+	source, err := pathSource(items.path, input, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return items.path.GetSlice(source)
+}
+
+// normalizeFields moves the legacy Iterator and Parameters into ItemProcessor and ItemSelector
+func (s *MapState) normalizeFields() {
+	if s.Iterator != nil && s.ItemProcessor == nil {
+		s.ItemProcessor = &ItemProcessor{StateMachine: *s.Iterator}
+		s.Iterator = nil
+	}
+
+	if s.Parameters != nil && s.ItemSelector == nil {
+		s.ItemSelector = s.Parameters
+		s.Parameters = nil
+	}
+}
+
+func (s *MapState) processor() *StateMachine {
+	if s.ItemProcessor != nil {
+		return &s.ItemProcessor.StateMachine
+	}
+	return s.Iterator
+}
+
+func (s *MapState) selector() interface{} {
+	if s.ItemSelector != nil {
+		return s.ItemSelector
+	}
+	return s.Parameters
+}
+
+// setDefaultQueryLanguage also sets the default of the ItemProcessor states
+func (s *MapState) setDefaultQueryLanguage(ql *string) {
+	s.stateStr.setDefaultQueryLanguage(ql)
+	if processor := s.processor(); processor != nil {
+		processor.setDefaultQueryLanguage(s.queryLanguage())
+	}
+}
+
+// mapContextObject adds the current item to the context object
+func mapContextObject(ctx context.Context, index int, value interface{}) map[string]interface{} {
+	contextObj := map[string]interface{}{}
+	for key, value := range contextObject(ctx) {
+		contextObj[key] = value
+	}
+
+	contextObj["Map"] = map[string]interface{}{
+		"Item": map[string]interface{}{
+			"Index": float64(index),
+			"Value": value,
+		},
+	}
+
+	return contextObj
+}
+
+func (s *MapState) items(ctx context.Context, input interface{}) ([]interface{}, error) {
+	if !s.isJSONata() {
+		return s.ItemsPath.GetSlice(input, variablesOf(ctx).all())
+	}
+
+	// Without Items the input must be the array
+	items := input
+	if s.Items != nil {
+		var err error
+		items, err = evalJSONata(s.Items, input, statesVars(ctx, map[string]interface{}{
+			"input": input,
+		}))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	output, ok := items.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Items must be an array")
+	}
+
+	return output, nil
+}
+
+func (s *MapState) selectItem(ctx context.Context, input interface{}, index int, item interface{}) (interface{}, error) {
+	selector := s.selector()
+	if selector == nil {
+		return item, nil
+	}
+
+	ctx = withContextObject(ctx, mapContextObject(ctx, index, item))
+
+	if s.isJSONata() {
+		return evalJSONata(selector, input, statesVars(ctx, map[string]interface{}{
+			"input": input,
+		}))
+	}
+
+	return replaceParamsJSONPath(selector, input, contextObject(ctx), variablesOf(ctx).all())
+}
+
 func (s *MapState) process(ctx context.Context, input interface{}) (interface{}, *string, error) {
-	output, err := s.ItemsPath.GetSlice(input)
+	output, err := s.items(ctx, input)
 	if err != nil {
 		return input, nextState(s.Next, s.End), err
 	}
 	var res []map[string]interface{}
 
-	for _, item := range output {
-		execution, err := s.Iterator.Execute(item)
+	for index, item := range output {
+		item, err = s.selectItem(ctx, input, index, item)
+		if err != nil {
+			return input, nextState(s.Next, s.End), err
+		}
+
+		// Each iteration has its own variables scope
+		execution, err := s.iterate(ctx, index, item)
 		if err != nil {
 			return input, nextState(s.Next, s.End), err
 		}
@@ -48,17 +281,42 @@ func (s *MapState) process(ctx context.Context, input interface{}) (interface{},
 	return res, nextState(s.Next, s.End), nil
 }
 
+// iterate executes the ItemProcessor for the item in a Map iteration span
+func (s *MapState) iterate(ctx context.Context, index int, item interface{}) (*Execution, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	processor := s.processor()
+	ctx, span := processor.tracer(ctx).Start(ctx, "Map Iteration", trace.WithAttributes(
+		stateNameKey.String(*s.Name()),
+		mapIndexKey.Int(index),
+	))
+
+	execution, err := processor.execute(ctx, item, variablesOf(ctx))
+	endSpan(span, err)
+
+	return execution, err
+}
+
 func (s *MapState) Execute(ctx context.Context, input interface{}) (output interface{}, next *string, err error) {
+	if s.isJSONata() {
+		return processError(s,
+			processCatcher(s.Catch, true,
+				processRetrier(s.Name(), s.Retry,
+					jsonataIO(nil, s.Output, s.Assign, s.process),
+				),
+			),
+		)(ctx, input)
+	}
+
 	return processError(s,
-		processCatcher(s.Catch,
+		processCatcher(s.Catch, false,
 			processRetrier(s.Name(), s.Retry,
 				inputOutput(
 					s.InputPath,
 					s.OutputPath,
-					withParams(
-						s.Parameters,
-						result(s.ResultPath, s.process),
-					),
+					result(s.ResultPath, withAssign(s.Assign, s.process)),
 				),
 			),
 		),
@@ -76,13 +334,58 @@ func (s *MapState) Validate() error {
 		return fmt.Errorf("%v %v", errorPrefix(s), err)
 	}
 
-	if s.Iterator == nil {
-		return fmt.Errorf("%v Requires Iterator", errorPrefix(s))
+	if s.Iterator != nil && s.ItemProcessor != nil {
+		return fmt.Errorf("%v Iterator and ItemProcessor both defined", errorPrefix(s))
+	}
+
+	if s.Parameters != nil && s.ItemSelector != nil {
+		return fmt.Errorf("%v Parameters and ItemSelector both defined", errorPrefix(s))
+	}
+
+	if s.processor() == nil {
+		return fmt.Errorf("%v Requires ItemProcessor (or Iterator)", errorPrefix(s))
+	}
+
+	if err := s.fieldsValid(map[string]bool{
+		"InputPath":        s.InputPath != nil,
+		"OutputPath":       s.OutputPath != nil,
+		"ResultPath":       s.ResultPath != nil,
+		"ItemsPath":        s.ItemsPath != nil,
+		"Catch.ResultPath": catchResultPathDefined(s.Catch),
+	}, map[string]bool{
+		"Items":        s.Items != nil,
+		"Output":       s.Output != nil,
+		"Catch.Output": catchOutputDefined(s.Catch),
+	}); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
+	if s.ItemProcessor != nil && s.ItemProcessor.ProcessorConfig != nil {
+		if mode := s.ItemProcessor.ProcessorConfig.Mode; mode != nil && *mode != "INLINE" && *mode != "DISTRIBUTED" {
+			return fmt.Errorf("%v Unknown ProcessorConfig Mode %q", errorPrefix(s), *mode)
+		}
+	}
+
+	if err := s.processor().Validate(); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
+	if err := assignValid(s.Assign); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
 	}
 
-	if err := s.Iterator.Validate(); err != nil {
+	if err := catchAssignValid(s.Catch); err != nil {
 		return fmt.Errorf("%v %v", errorPrefix(s), err)
 	}
+
+	if err := catchValid(s.Catch); err != nil {
+		return err
+	}
+
+	if err := retryValid(s.Retry); err != nil {
+		return err
+	}
+
 	return nil
 }
 
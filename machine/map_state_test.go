@@ -98,7 +98,7 @@ func Test_MapState_Catch(t *testing.T) {
 
 	// No Input path data. Should be caught
 	testState(state, stateTestData{
-		Input: map[string]interface{}{},
+		Input:  map[string]interface{}{},
 		Output: map[string]interface{}{"Error": "errorString", "Cause": "GetSlice Error \"Not Found\""},
 	}, t)
 
@@ -144,3 +144,86 @@ func Test_MapState_Integration(t *testing.T) {
 		Output: outputResults,
 	}, t)
 }
+
+func Test_MapState_ItemProcessor_ItemSelector(t *testing.T) {
+	state := parseMapState([]byte(`{
+      "Type": "Map",
+      "ItemsPath": "$.shipped",
+      "ItemSelector": {
+        "index.$": "$$.Map.Item.Index",
+        "value.$": "$$.Map.Item.Value",
+        "name.$": "$.name",
+        "static": "s"
+      },
+      "ResultPath": "$.output.data",
+      "OutputPath": "$.output",
+      "ItemProcessor": {
+        "ProcessorConfig": { "Mode": "INLINE" },
+        "StartAt": "Validate",
+        "States": {
+          "Validate": {
+            "Type": "Pass",
+            "End": true
+          }
+        }
+      },
+      "End": true
+    }`), t)
+
+	testState(state, stateTestData{
+		Input: map[string]interface{}{"shipped": []interface{}{"a", "b"}, "name": "n"},
+		Output: map[string]interface{}{"data": []map[string]interface{}{
+			{"index": float64(0), "value": "a", "name": "n", "static": "s"},
+			{"index": float64(1), "value": "b", "name": "n", "static": "s"},
+		}},
+	}, t)
+}
+
+func Test_MapState_ItemsPath_Literal_And_Intrinsic(t *testing.T) {
+	for _, itemsPath := range []string{`[1, 2]`, `"States.ArrayRange(1, 2, 1)"`} {
+		state := parseMapState([]byte(`{
+        "Type": "Map",
+        "ItemsPath": `+itemsPath+`,
+        "ItemSelector": { "value.$": "$$.Map.Item.Value" },
+        "ResultPath": "$.data",
+        "ItemProcessor": {
+          "StartAt": "Validate",
+          "States": { "Validate": { "Type": "Pass", "End": true } }
+        },
+        "End": true
+      }`), t)
+
+		assert.NoError(t, state.Validate())
+
+		output, _, err := state.Execute(nil, map[string]interface{}{})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"data": []map[string]interface{}{{"value": float64(1)}, {"value": float64(2)}},
+		}, output)
+	}
+}
+
+func Test_MapState_Validate_Legacy_And_Modern(t *testing.T) {
+	state := parseMapState([]byte(`{
+      "Next": "Pass",
+      "Iterator": { "StartAt": "A", "States": { "A": { "Type": "Succeed" } } },
+      "ItemProcessor": { "StartAt": "A", "States": { "A": { "Type": "Succeed" } } }
+    }`), t)
+
+	err := state.Validate()
+	assert.Error(t, err)
+	assert.Regexp(t, "Iterator and ItemProcessor both defined", err.Error())
+
+	state = parseMapState([]byte(`{
+      "Next": "Pass",
+      "ItemProcessor": {
+        "ProcessorConfig": { "Mode": "SIDEWAYS" },
+        "StartAt": "A",
+        "States": { "A": { "Type": "Succeed" } }
+      }
+    }`), t)
+
+	err = state.Validate()
+	assert.Error(t, err)
+	assert.Regexp(t, "Unknown ProcessorConfig Mode", err.Error())
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"reflect"
+	"strings"
 
+	"github.com/coinbase/step/jsonpath"
 	"github.com/coinbase/step/utils/to"
 )
 
@@ -98,13 +101,17 @@ func unmarshallState(name string, raw_json *json.RawMessage) ([]State, error) {
 	case "Map":
 		var s MapState
 		err = json.Unmarshal(*raw_json, &s)
+		// Accept the legacy Iterator and Parameters fields
+		s.normalizeFields()
 		newState = &s
 	case "TaskFn":
 		// This is a custom state that adds values to Task to be handled
 		var s TaskState
 		err = json.Unmarshal(*raw_json, &s)
 		// This will inject the Task name into the input
-		s.Parameters = map[string]interface{}{"Task": name, "Input.$": "$"}
+		s.taskFn = true
+		s.SetName(to.Strp(name))
+		s.setTaskFnInput()
 		s.Type = to.Strp("Task")
 		newState = &s
 	default:
@@ -116,9 +123,62 @@ func unmarshallState(name string, raw_json *json.RawMessage) ([]State, error) {
 		return nil, err
 	}
 
+	if err := setNullPaths(raw_json, newState); err != nil {
+		return nil, err
+	}
+
 	// Set Name and Defaults
 	newName := name
 	newState.SetName(&newName) // Require New Variable Pointer
 
 	return []State{newState}, nil
 }
+
+// nullPathFields are the paths where an explicit null is different to the field being absent
+var nullPathFields = []string{"InputPath", "OutputPath", "ResultPath"}
+
+// setNullPaths sets explicitly null paths of the state and its Catchers to jsonpath.NullPath(),
+// as json.Unmarshal leaves both absent and null pointer fields nil
+func setNullPaths(raw_json *json.RawMessage, state State) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(*raw_json, &fields); err != nil {
+		return err
+	}
+
+	pathType := reflect.TypeOf((*jsonpath.Path)(nil))
+	value := reflect.ValueOf(state).Elem()
+
+	for _, name := range nullPathFields {
+		field := value.FieldByName(name)
+		if isNullJSON(fields[name]) && field.IsValid() && field.Type() == pathType {
+			field.Set(reflect.ValueOf(jsonpath.NullPath()))
+		}
+	}
+
+	catchField := value.FieldByName("Catch")
+	if !catchField.IsValid() {
+		return nil
+	}
+
+	catch, ok := catchField.Interface().([]*Catcher)
+	if !ok || len(catch) == 0 {
+		return nil
+	}
+
+	var rawCatchers []map[string]json.RawMessage
+	if err := json.Unmarshal(fields["Catch"], &rawCatchers); err != nil {
+		return err
+	}
+
+	for i, rawCatcher := range rawCatchers {
+		if isNullJSON(rawCatcher["ResultPath"]) {
+			catch[i].ResultPath = jsonpath.NullPath()
+		}
+	}
+
+	return nil
+}
+
+func isNullJSON(raw json.RawMessage) bool {
+	return strings.TrimSpace(string(raw)) == "null"
+}
@@ -1,6 +1,7 @@
 package machine
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -115,10 +116,101 @@ func Test_Machine_Parser_Map(t *testing.T) {
 	mapState = sm.States["Start"].(*MapState)
 	assert.Equal(t, err, nil)
 	assert.NoError(t, sm.Validate())
-	assert.Equal(t, "$.detail", mapState.InputPath.String(), )
-	assert.Equal(t, "$.shipped", mapState.ItemsPath.String(), )
-	assert.Equal(t, "$.detail.shipped", mapState.ResultPath.String(), )
-	assert.Equal(t, 1, len(mapState.Iterator.States))
-	assert.Equal(t, "Task", *mapState.Iterator.States["Validate"].GetType(), )
+	assert.Equal(t, "$.detail", mapState.InputPath.String())
+	assert.Equal(t, "$.shipped", mapState.ItemsPath.String())
+	assert.Equal(t, "$.detail.shipped", mapState.ResultPath.String())
+	assert.Nil(t, mapState.Iterator)
+	assert.Equal(t, 1, len(mapState.ItemProcessor.States))
+	assert.Equal(t, "Task", *mapState.ItemProcessor.States["Validate"].GetType())
 
 }
+
+func Test_Machine_Parser_Map_Legacy_Fields_Marshal_Modern(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+    "StartAt": "Start",
+    "States": {
+      "Start": {
+        "Type": "Map",
+        "Parameters": { "value.$": "$$.Map.Item.Value" },
+        "Iterator": { "StartAt": "A", "States": { "A": { "Type": "Succeed" } } },
+        "End": true
+      }
+    }
+  }`))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Validate())
+
+	mapState := sm.States["Start"].(*MapState)
+	assert.Nil(t, mapState.Iterator)
+	assert.Nil(t, mapState.Parameters)
+	assert.NotNil(t, mapState.ItemProcessor)
+	assert.Equal(t, map[string]interface{}{"value.$": "$$.Map.Item.Value"}, mapState.ItemSelector)
+
+	raw, err := json.Marshal(sm)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+    "StartAt": "Start",
+    "States": {
+      "Start": {
+        "Type": "Map",
+        "ItemSelector": { "value.$": "$$.Map.Item.Value" },
+        "ItemProcessor": { "StartAt": "A", "States": { "A": { "Type": "Succeed" } } },
+        "End": true
+      }
+    }
+  }`, string(raw))
+}
+
+func Test_Parser_NullPaths(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"StartAt": "A",
+		"States": {
+			"A": {
+				"Type": "Task",
+				"Resource": "x",
+				"InputPath": null,
+				"ResultPath": "$.a",
+				"Catch": [{"ErrorEquals": ["States.ALL"], "ResultPath": null, "Next": "B"}],
+				"Next": "B"
+			},
+			"B": {"Type": "Succeed", "OutputPath": null}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	task := sm.States["A"].(*TaskState)
+	assert.True(t, task.InputPath.IsNull())
+	assert.False(t, task.ResultPath.IsNull())
+	assert.Nil(t, task.OutputPath)
+	assert.True(t, task.Catch[0].ResultPath.IsNull())
+	assert.True(t, sm.States["B"].(*SucceedState).OutputPath.IsNull())
+
+	// Explicit nulls survive marshalling
+	raw, err := json.Marshal(task)
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), `"InputPath":null`)
+	assert.Contains(t, string(raw), `"ResultPath":null`)
+	assert.NotContains(t, string(raw), `"OutputPath"`)
+}
+
+func Test_Parser_NullPaths_Catch_Execute(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"StartAt": "A",
+		"States": {
+			"A": {
+				"Type": "Task",
+				"Resource": "x",
+				"Catch": [{"ErrorEquals": ["States.ALL"], "ResultPath": null, "Next": "B"}],
+				"Next": "B"
+			},
+			"B": {"Type": "Succeed"}
+		}
+	}`))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.SetTaskHandler("A", ThrowTestErrorHandler))
+
+	// The error output is discarded
+	exec, err := sm.Execute(map[string]interface{}{"a": "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": "b"}, exec.Output)
+}
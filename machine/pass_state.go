@@ -17,25 +17,58 @@ type PassState struct {
 	InputPath  *jsonpath.Path `json:",omitempty"`
 	OutputPath *jsonpath.Path `json:",omitempty"`
 	ResultPath *jsonpath.Path `json:",omitempty"`
+	Parameters interface{}    `json:",omitempty"`
 
 	Result interface{} `json:",omitempty"`
 
+	Output interface{} `json:",omitempty"` // JSONata only
+
+	Assign interface{} `json:",omitempty"`
+
 	Next *string `json:",omitempty"`
 	End  *bool   `json:",omitempty"`
 }
 
 func (s *PassState) Execute(ctx context.Context, input interface{}) (output interface{}, next *string, err error) {
+	if s.isJSONata() {
+		return processError(s,
+			jsonataIO(nil, s.Output, s.Assign, s.processJSONata),
+		)(ctx, input)
+	}
+
 	return processError(s,
 		inputOutput(
 			s.InputPath,
 			s.OutputPath,
-			result(s.ResultPath, s.process),
+			result(s.ResultPath, withAssign(s.Assign, s.process)),
 		),
 	)(ctx, input)
 }
 
+// process returns the Result, otherwise the input with Parameters applied, which ResultPath sets on the input
 func (s *PassState) process(ctx context.Context, input interface{}) (output interface{}, next *string, err error) {
-	return s.Result, nextState(s.Next, s.End), nil
+	if s.Result != nil {
+		return s.Result, nextState(s.Next, s.End), nil
+	}
+
+	if s.Parameters != nil {
+		params, err := replaceParamsJSONPath(s.Parameters, input, contextObject(ctx), variablesOf(ctx).all())
+		return params, nextState(s.Next, s.End), err
+	}
+
+	if s.ResultPath.IsRoot() {
+		// The input passes through, whatever its type
+		return nil, nextState(s.Next, s.End), nil
+	}
+
+	// A copy, as ResultPath sets it inside the input
+	copied, err := to.FromJSON(input)
+	return copied, nextState(s.Next, s.End), err
+}
+
+// processJSONata passes the input through as $states.result
+func (s *PassState) processJSONata(ctx context.Context, input interface{}) (output interface{}, next *string, err error) {
+	return input, nextState(s.Next, s.End), nil
 }
 
 func (s *PassState) Validate() error {
@@ -50,6 +83,22 @@ func (s *PassState) Validate() error {
 		return fmt.Errorf("%v %v", errorPrefix(s), err)
 	}
 
+	if err := assignValid(s.Assign); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
+	if err := s.fieldsValid(map[string]bool{
+		"InputPath":  s.InputPath != nil,
+		"OutputPath": s.OutputPath != nil,
+		"ResultPath": s.ResultPath != nil,
+		"Parameters": s.Parameters != nil,
+		"Result":     s.Result != nil,
+	}, map[string]bool{
+		"Output": s.Output != nil,
+	}); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
 	return nil
 }
 
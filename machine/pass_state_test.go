@@ -1,6 +1,7 @@
 package machine
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/coinbase/step/utils/to"
@@ -94,3 +95,58 @@ func Test_PassState_BadOutputPath(t *testing.T) {
 		Error: to.Strp("Output Error"),
 	}, t)
 }
+
+func Test_PassState_Parameters(t *testing.T) {
+	state := parsePassState([]byte(`{
+		"Next": "Pass",
+		"InputPath": "$.a",
+		"Parameters": {"static": "x", "value.$": "$.b", "nested": {"value.$": "$.b"}}
+	}`), t)
+
+	testState(state, stateTestData{
+		Input:  map[string]interface{}{"a": map[string]interface{}{"b": "c"}},
+		Output: map[string]interface{}{"static": "x", "value": "c", "nested": map[string]interface{}{"value": "c"}},
+	}, t)
+}
+
+func Test_PassState_Parameters_ResultPath(t *testing.T) {
+	state := parsePassState([]byte(`{"Next": "Pass", "Parameters": {"b.$": "$.a"}, "ResultPath": "$.p"}`), t)
+	testState(state, stateTestData{
+		Input:  map[string]interface{}{"a": 1.0},
+		Output: map[string]interface{}{"a": 1.0, "p": map[string]interface{}{"b": 1.0}},
+	}, t)
+
+	// Without Parameters the input is the result
+	state = parsePassState([]byte(`{"Next": "Pass", "ResultPath": "$.p"}`), t)
+	testState(state, stateTestData{
+		Input:  map[string]interface{}{"a": 1.0},
+		Output: map[string]interface{}{"a": 1.0, "p": map[string]interface{}{"a": 1.0}},
+	}, t)
+}
+
+func Test_PassState_NullPaths(t *testing.T) {
+	input := map[string]interface{}{"a": "b"}
+
+	// null InputPath gives {} as the input
+	state := parseNullPathState(`{"Type": "Pass", "InputPath": null, "End": true}`, t)
+	testState(state, stateTestData{Input: input, Output: map[string]interface{}{}}, t)
+
+	// null ResultPath discards the result
+	state = parseNullPathState(`{"Type": "Pass", "Result": {"c": "d"}, "ResultPath": null, "End": true}`, t)
+	testState(state, stateTestData{Input: input, Output: input}, t)
+
+	// null OutputPath gives {} as the output
+	state = parseNullPathState(`{"Type": "Pass", "Result": {"c": "d"}, "OutputPath": null, "End": true}`, t)
+	testState(state, stateTestData{Input: input, Output: map[string]interface{}{}}, t)
+
+	// an absent path is $
+	state = parseNullPathState(`{"Type": "Pass", "Result": {"c": "d"}, "End": true}`, t)
+	testState(state, stateTestData{Input: input, Output: map[string]interface{}{"c": "d"}}, t)
+}
+
+func parseNullPathState(raw string, t *testing.T) State {
+	raw_json := json.RawMessage(raw)
+	states, err := unmarshallState("TestState", &raw_json)
+	assert.NoError(t, err)
+	return states[0]
+}
@@ -0,0 +1,262 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/coinbase/step/jsonata"
+	"github.com/coinbase/step/utils/to"
+)
+
+// A state machine (or a single state) can set QueryLanguage to "JSONPath" (default) or "JSONata".
+// JSONata states replace InputPath, Parameters, ResultSelector, ResultPath and OutputPath
+// with Arguments and Output, whose "{% expression %}" strings are evaluated with $states
+
+type queryLanguageState interface {
+	setDefaultQueryLanguage(*string)
+}
+
+func (s *stateStr) setDefaultQueryLanguage(ql *string) {
+	s.defaultQueryLanguage = ql
+}
+
+// queryLanguage returns the language the state is evaluated with
+func (s *stateStr) queryLanguage() *string {
+	if s.QueryLanguage != nil {
+		return s.QueryLanguage
+	}
+	return s.defaultQueryLanguage
+}
+
+func (s *stateStr) isJSONata() bool {
+	ql := s.queryLanguage()
+	return ql != nil && *ql == "JSONata"
+}
+
+// UnmarshalJSON parses the State Machine and sets the QueryLanguage default on its states
+func (sm *StateMachine) UnmarshalJSON(b []byte) error {
+	type xStateMachine StateMachine
+	var x xStateMachine
+	if err := json.Unmarshal(b, &x); err != nil {
+		return err
+	}
+
+	*sm = StateMachine(x)
+	sm.setDefaultQueryLanguage(nil)
+	return nil
+}
+
+// setDefaultQueryLanguage sets the language states use if they do not define their own,
+// parent is the language of the enclosing state for nested State Machines
+func (sm *StateMachine) setDefaultQueryLanguage(parent *string) {
+	ql := sm.QueryLanguage
+	if ql == nil {
+		ql = parent
+	}
+
+	for _, state := range sm.States {
+		if qls, ok := state.(queryLanguageState); ok {
+			qls.setDefaultQueryLanguage(ql)
+		}
+	}
+}
+
+//////
+// Validation
+//////
+
+func queryLanguageValid(ql *string) error {
+	if ql == nil {
+		return nil
+	}
+
+	switch *ql {
+	case "JSONPath", "JSONata":
+		return nil
+	}
+
+	return fmt.Errorf("Unknown QueryLanguage %q", *ql)
+}
+
+// fieldsValid errors if a field of the other query language is defined,
+// the maps are field name to whether it is defined
+func (s *stateStr) fieldsValid(jsonPathFields map[string]bool, jsonataFields map[string]bool) error {
+	if err := queryLanguageValid(s.QueryLanguage); err != nil {
+		return err
+	}
+
+	if s.isJSONata() {
+		return fieldsUndefined(jsonPathFields, "JSONata")
+	}
+
+	if ql := s.defaultQueryLanguage; ql != nil && *ql == "JSONata" {
+		return fmt.Errorf("QueryLanguage JSONPath not allowed in a JSONata State Machine")
+	}
+
+	return fieldsUndefined(jsonataFields, "JSONPath")
+}
+
+func fieldsUndefined(fields map[string]bool, ql string) error {
+	names := []string{}
+	for name, defined := range fields {
+		if defined {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	sort.Strings(names)
+	return fmt.Errorf("%v not allowed with QueryLanguage %v", strings.Join(names, ","), ql)
+}
+
+func catchResultPathDefined(catch []*Catcher) bool {
+	for _, c := range catch {
+		if c.ResultPath != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func catchOutputDefined(catch []*Catcher) bool {
+	for _, c := range catch {
+		if c.Output != nil {
+			return true
+		}
+	}
+	return false
+}
+
+//////
+// Evaluation
+//////
+
+// isJSONataExpression returns true for strings like "{% $states.input.name %}"
+func isJSONataExpression(str string) bool {
+	str = strings.TrimSpace(str)
+	return len(str) >= 4 && strings.HasPrefix(str, "{%") && strings.HasSuffix(str, "%}")
+}
+
+// statesVars returns the variables available to expressions, the workflow variables and $states
+func statesVars(ctx context.Context, states map[string]interface{}) map[string]interface{} {
+	vars := variablesOf(ctx).all()
+	states["context"] = contextObject(ctx)
+	vars["states"] = states
+	return vars
+}
+
+// evalJSONata replaces every JSONata expression string in template with its value,
+// object keys and array elements that evaluate to undefined are left out
+func evalJSONata(template interface{}, input interface{}, vars map[string]interface{}) (interface{}, error) {
+	value, err := evalJSONataTemplate(template, input, vars)
+	if err == jsonata.UNDEFINED_ERROR {
+		return nil, fmt.Errorf("JSONata Error: %v evaluated to undefined", template)
+	}
+	return value, err
+}
+
+func evalJSONataTemplate(template interface{}, input interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch t := template.(type) {
+	case string:
+		if !isJSONataExpression(t) {
+			return t, nil
+		}
+
+		expr := strings.TrimSpace(t)
+		value, err := jsonata.Evaluate(expr[2:len(expr)-2], input, vars)
+		if err != nil && err != jsonata.UNDEFINED_ERROR {
+			return nil, fmt.Errorf("JSONata Error: %v in %q", err, t)
+		}
+		return value, err
+	case map[string]interface{}:
+		output := map[string]interface{}{}
+		for key, value := range t {
+			newValue, err := evalJSONataTemplate(value, input, vars)
+			if err == jsonata.UNDEFINED_ERROR {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			output[key] = newValue
+		}
+		return output, nil
+	case []interface{}:
+		output := []interface{}{}
+		for _, value := range t {
+			newValue, err := evalJSONataTemplate(value, input, vars)
+			if err == jsonata.UNDEFINED_ERROR {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, newValue)
+		}
+		return output, nil
+	}
+
+	return template, nil
+}
+
+// jsonataIO is the JSONata replacement for inputOutput, withParams, result and withAssign:
+// Arguments is evaluated with $states.input to make the input to exec,
+// Output and Assign are evaluated with $states.input and $states.result
+func jsonataIO(arguments interface{}, output interface{}, assign interface{}, exec ExecutionFn) ExecutionFn {
+	return func(ctx context.Context, input interface{}) (interface{}, *string, error) {
+		args := input
+		if arguments != nil {
+			var err error
+			args, err = evalJSONata(arguments, input, statesVars(ctx, map[string]interface{}{
+				"input": input,
+			}))
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("Arguments Error: %v", err)
+			}
+		}
+
+		result, next, err := exec(ctx, args)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if output == nil && assign == nil {
+			return result, next, nil
+		}
+
+		result, err = to.FromJSON(result)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := assignJSONata(ctx, assign, input, map[string]interface{}{
+			"input":  input,
+			"result": result,
+		}); err != nil {
+			return nil, nil, err
+		}
+
+		if output == nil {
+			return result, next, nil
+		}
+
+		result, err = evalJSONata(output, input, statesVars(ctx, map[string]interface{}{
+			"input":  input,
+			"result": result,
+		}))
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Output Error: %v", err)
+		}
+
+		return result, next, nil
+	}
+}
@@ -0,0 +1,260 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/step/handler"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+type greetInput struct {
+	Name *string
+}
+
+func greetHandler(_ context.Context, input *greetInput) (map[string]interface{}, error) {
+	if *input.Name == "bad" {
+		return nil, fmt.Errorf("bad name")
+	}
+	return map[string]interface{}{"greeting": "hello " + *input.Name}, nil
+}
+
+func executeJSONata(sm_json string, input interface{}, t *testing.T) (*Execution, error) {
+	sm, err := FromJSON([]byte(sm_json))
+	assert.NoError(t, err)
+
+	err = sm.SetTaskFnHandlers(&handler.TaskHandlers{"Greet": greetHandler})
+	assert.NoError(t, err)
+
+	return sm.Execute(input)
+}
+
+var jsonataMachine = `{
+	"QueryLanguage": "JSONata",
+	"StartAt": "Check",
+	"States": {
+		"Check": {
+			"Type": "Choice",
+			"Choices": [{
+				"Condition": "{% $count($states.input.names) > 0 %}",
+				"Next": "Names"
+			}],
+			"Default": "Empty"
+		},
+		"Names": {
+			"Type": "Map",
+			"Items": "{% $states.input.names %}",
+			"ItemSelector": {
+				"Name": "{% $uppercase($states.context.Map.Item.Value) %}",
+				"Index": "{% $states.context.Map.Item.Index %}"
+			},
+			"ItemProcessor": {
+				"StartAt": "Format",
+				"States": {
+					"Format": {
+						"Type": "Pass",
+						"Output": {"name": "{% $states.input.Name & $string($states.input.Index) %}"},
+						"End": true
+					}
+				}
+			},
+			"Output": {
+				"Name": "{% $join($states.result.name, ',') %}",
+				"state": "{% $states.context.State.Name %}"
+			},
+			"Next": "Greet"
+		},
+		"Empty": {
+			"Type": "Pass",
+			"Output": {"Name": "nobody"},
+			"Next": "Greet"
+		},
+		"Greet": {
+			"Type": "TaskFn",
+			"Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+			"Output": "{% $merge([$states.input, $states.result]) %}",
+			"Next": "Done"
+		},
+		"Done": {
+			"Type": "Succeed",
+			"Output": "{% $merge([$states.input, {\"execution\": $states.context.Execution.Input}]) %}"
+		}
+	}
+}`
+
+func Test_QueryLanguage_JSONata_Execute(t *testing.T) {
+	input := map[string]interface{}{"names": []interface{}{"a", "b"}}
+	exec, err := executeJSONata(jsonataMachine, input, t)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"Name":      "A0,B1",
+		"state":     "Names",
+		"greeting":  "hello A0,B1",
+		"execution": input,
+	}, exec.Output)
+	assert.Equal(t, []string{"Check", "Names", "Greet", "Done"}, exec.Path())
+
+	exec, err = executeJSONata(jsonataMachine, map[string]interface{}{"names": []interface{}{}}, t)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Check", "Empty", "Greet", "Done"}, exec.Path())
+	assert.Equal(t, "hello nobody", exec.Output["greeting"])
+}
+
+func Test_QueryLanguage_JSONata_Parse(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"QueryLanguage": "JSONata",
+		"StartAt": "Each",
+		"States": {
+			"Each": {
+				"Type": "Map",
+				"ItemProcessor": {
+					"StartAt": "Hello",
+					"States": {"Hello": {"Type": "TaskFn", "Resource": "x", "End": true}}
+				},
+				"End": true
+			}
+		}
+	}`))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Validate())
+
+	// TaskFn uses Arguments in the JSONata ItemProcessor
+	mapState := sm.States["Each"].(*MapState)
+	task := mapState.ItemProcessor.States["Hello"].(*TaskState)
+	assert.True(t, task.isJSONata())
+	assert.Nil(t, task.Parameters)
+	assert.Equal(t, map[string]interface{}{"Task": "Hello", "Input": "{% $states.input %}"}, task.Arguments)
+
+	// JSONPath TaskFn keeps Parameters
+	sm, err = FromJSON([]byte(`{
+		"StartAt": "Hello",
+		"States": {"Hello": {"Type": "TaskFn", "Resource": "x", "End": true}}
+	}`))
+	assert.NoError(t, err)
+	task = sm.States["Hello"].(*TaskState)
+	assert.False(t, task.isJSONata())
+	assert.Nil(t, task.Arguments)
+	assert.Equal(t, map[string]interface{}{"Task": "Hello", "Input.$": "$"}, task.Parameters)
+}
+
+func Test_QueryLanguage_JSONata_Arguments_Catch(t *testing.T) {
+	sm_json := `{
+		"QueryLanguage": "JSONata",
+		"StartAt": "Greet",
+		"States": {
+			"Greet": {
+				"Type": "Task",
+				"Resource": "arn:aws:lambda:us-east-1:000000000000:function:greet",
+				"Arguments": {"Task": "Greet", "Input": {"Name": "{% $states.input.user %}"}},
+				"Output": {"message": "{% $states.result.greeting %}"},
+				"Catch": [{
+					"ErrorEquals": ["States.ALL"],
+					"Output": {"user": "{% $states.input.user %}", "error": "{% $states.errorOutput.Error %}"},
+					"Next": "Failed"
+				}],
+				"End": true
+			},
+			"Failed": {
+				"Type": "Fail",
+				"Error": "{% 'GreetFailed' %}",
+				"Cause": "{% 'could not greet ' & $states.input.user %}"
+			}
+		}
+	}`
+
+	exec, err := executeJSONata(sm_json, map[string]interface{}{"user": "step"}, t)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"message": "hello step"}, exec.Output)
+
+	exec, err = executeJSONata(sm_json, map[string]interface{}{"user": "bad"}, t)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"Greet", "Failed"}, exec.Path())
+	assert.Equal(t, map[string]interface{}{"user": "bad", "error": "errorString"}, exec.LastOutput)
+}
+
+func Test_QueryLanguage_JSONata_Fail(t *testing.T) {
+	state := &FailState{
+		Error: to.Strp("{% $states.input.error %}"),
+		Cause: to.Strp("static cause"),
+	}
+	state.SetName(to.Strp("TestState"))
+	state.QueryLanguage = to.Strp("JSONata")
+	assert.NoError(t, state.Validate())
+
+	output, _, err := state.Execute(nil, map[string]interface{}{"error": "MyError"})
+	assert.Error(t, err)
+	assert.Equal(t, map[string]interface{}{"Error": "MyError", "Cause": "static cause"}, output)
+
+	_, _, err = state.Execute(nil, map[string]interface{}{"error": 1})
+	assert.Error(t, err)
+	assert.Regexp(t, "must evaluate to a string", err.Error())
+}
+
+func Test_QueryLanguage_JSONata_Choice_NonBoolean(t *testing.T) {
+	state := parseChoiceState([]byte(`{
+		"QueryLanguage": "JSONata",
+		"Choices": [{"Condition": "{% $states.input.a %}", "Next": "A"}]
+	}`), t)
+	assert.NoError(t, state.Validate())
+
+	_, _, err := state.Execute(nil, map[string]interface{}{"a": "str"})
+	assert.Error(t, err)
+	assert.Regexp(t, "must evaluate to a boolean", err.Error())
+}
+
+func Test_QueryLanguage_JSONata_Undefined_Output(t *testing.T) {
+	state := parsePassState([]byte(`{
+		"QueryLanguage": "JSONata",
+		"Output": {"a": "{% $states.input.a %}", "b": "{% $states.input.missing %}"},
+		"End": true
+	}`), t)
+
+	testState(state, stateTestData{
+		Input:  map[string]interface{}{"a": "x"},
+		Output: map[string]interface{}{"a": "x"},
+	}, t)
+
+	state = parsePassState([]byte(`{
+		"QueryLanguage": "JSONata",
+		"Output": "{% $states.input.missing %}",
+		"End": true
+	}`), t)
+	assert.NoError(t, state.Validate())
+
+	_, _, err := state.Execute(nil, map[string]interface{}{})
+	assert.Error(t, err)
+	assert.Regexp(t, "evaluated to undefined", err.Error())
+}
+
+func Test_QueryLanguage_Validate(t *testing.T) {
+	invalid := map[string]string{
+		// JSONPath fields in JSONata states
+		`{"QueryLanguage": "JSONata", "StartAt": "A", "States": {"A": {"Type": "Pass", "ResultPath": "$.a", "End": true}}}`:                                                                           "ResultPath not allowed with QueryLanguage JSONata",
+		`{"QueryLanguage": "JSONata", "StartAt": "A", "States": {"A": {"Type": "Task", "Resource": "x", "InputPath": "$.a", "Parameters": {}, "End": true}}}`:                                         "InputPath,Parameters not allowed with QueryLanguage JSONata",
+		`{"QueryLanguage": "JSONata", "StartAt": "A", "States": {"A": {"Type": "Choice", "Choices": [{"Variable": "$.a", "BooleanEquals": true, "Next": "A"}]}}}`:                                     "Choice must have Condition",
+		`{"QueryLanguage": "JSONata", "StartAt": "A", "States": {"A": {"Type": "Wait", "SecondsPath": "$.a", "End": true}}}`:                                                                          "SecondsPath not allowed with QueryLanguage JSONata",
+		`{"QueryLanguage": "JSONata", "StartAt": "A", "States": {"A": {"Type": "Task", "Resource": "x", "Catch": [{"ErrorEquals": ["States.ALL"], "ResultPath": "$.e", "Next": "A"}], "End": true}}}`: "Catch.ResultPath not allowed with QueryLanguage JSONata",
+		// JSONata fields in JSONPath states
+		`{"StartAt": "A", "States": {"A": {"Type": "Task", "Resource": "x", "Arguments": {}, "End": true}}}`:                                                     "Arguments not allowed with QueryLanguage JSONPath",
+		`{"StartAt": "A", "States": {"A": {"Type": "Choice", "Choices": [{"Condition": "{% true %}", "Next": "A"}]}}}`:                                           "Condition not allowed with QueryLanguage JSONPath",
+		`{"StartAt": "A", "States": {"A": {"Type": "Map", "Items": [1], "ItemProcessor": {"StartAt": "B", "States": {"B": {"Type": "Succeed"}}}, "End": true}}}`: "Items not allowed with QueryLanguage JSONPath",
+		// Languages
+		`{"QueryLanguage": "JSONata", "StartAt": "A", "States": {"A": {"Type": "Succeed", "QueryLanguage": "JSONPath"}}}`: "not allowed in a JSONata State Machine",
+		`{"QueryLanguage": "XPath", "StartAt": "A", "States": {"A": {"Type": "Succeed"}}}`:                                "Unknown QueryLanguage",
+		`{"StartAt": "A", "States": {"A": {"Type": "Succeed", "QueryLanguage": "XPath"}}}`:                                "Unknown QueryLanguage",
+	}
+
+	for sm_json, message := range invalid {
+		err := Validate(&sm_json)
+		if assert.Error(t, err, sm_json) {
+			assert.Contains(t, err.Error(), message, sm_json)
+		}
+	}
+
+	// A single state can opt into JSONata
+	valid := `{"StartAt": "A", "States": {"A": {"Type": "Pass", "QueryLanguage": "JSONata", "Output": "{% $states.input %}", "End": true}}}`
+	assert.NoError(t, Validate(&valid))
+}
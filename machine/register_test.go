@@ -0,0 +1,30 @@
+package machine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/step/handler"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SetTaskFnHandlers_Register(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"StartAt": "Greet",
+		"States": {
+			"Greet": {"Type": "TaskFn", "Resource": "greet", "Next": "Farewell"},
+			"Farewell": {"Type": "TaskFn", "Resource": "greet", "End": true}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	tm := handler.TaskHandlers{"Greet": greetHandler}
+	handler.Register(&tm, "Farewell", func(_ context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"farewell": "bye, " + input["greeting"].(string)}, nil
+	})
+	assert.NoError(t, sm.SetTaskFnHandlers(&tm))
+
+	exec, err := sm.Execute(map[string]interface{}{"Name": "step"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"farewell": "bye, hello step"}, exec.Output)
+}
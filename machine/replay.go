@@ -0,0 +1,302 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Recording is a recorded execution built from its sfn history,
+// it is replayed with StateMachine.Replay
+type Recording struct {
+	Input *string // execution input
+
+	// Steps are the states entered by the execution in order, a retried Task is entered once per attempt.
+	// States inside Map iterations are not steps
+	Steps []*RecordedStep
+
+	mu      sync.Mutex
+	results map[string][]*RecordedResult // Task results by state name in call order
+	calls   map[string]int               // results returned by the stubs
+}
+
+// RecordedStep is a state entered by the recorded execution
+type RecordedStep struct {
+	Name  string
+	Input *string
+}
+
+// RecordedResult is the result of a Task attempt
+type RecordedResult struct {
+	Output *string
+	Error  *RecordedError // nil if the attempt succeeded
+}
+
+// RecordedError replays a recorded Task error, Retry and Catch match its Error name
+type RecordedError struct {
+	Name  string
+	Cause string
+}
+
+func (e *RecordedError) Error() string {
+	return e.Cause
+}
+
+func (e *RecordedError) ErrorName() string {
+	return e.Name
+}
+
+func (e *RecordedError) ErrorCause() string {
+	return e.Cause
+}
+
+// NoRecordingError is returned by a stub that has no more recorded results for its Task
+type NoRecordingError struct {
+	State string
+}
+
+func (e *NoRecordingError) Error() string {
+	return fmt.Sprintf("No recorded result for Task %q", e.State)
+}
+
+// Divergence is a step where the replay differs from the recording
+type Divergence struct {
+	Step     int    // index of the step
+	Expected string // recorded state name, empty if the replay entered more states
+	Actual   string // replayed state name, empty if the replay entered fewer states
+	Message  string
+}
+
+func (d *Divergence) String() string {
+	return fmt.Sprintf("step %v: %v (expected %q, got %q)", d.Step, d.Message, d.Expected, d.Actual)
+}
+
+// enteredTask is a TaskStateEntered event whose attempts are being recorded
+type enteredTask struct {
+	name     string
+	step     *RecordedStep // nil inside Map iterations
+	attempts int
+}
+
+// NewRecording reads the Task results and states of an execution history in ascending order,
+// e.g. from GetExecutionHistoryPages. Results are assigned to Tasks by PreviousEventId,
+// or to the last entered Task if the events have no ids
+func NewRecording(events []*sfn.HistoryEvent) (*Recording, error) {
+	rec := &Recording{
+		Steps:   []*RecordedStep{},
+		results: map[string][]*RecordedResult{},
+		calls:   map[string]int{},
+	}
+
+	owners := map[int64]*enteredTask{}
+	var last *enteredTask
+	depth := 0 // nesting of Map iterations
+
+	for _, he := range events {
+		if he == nil || he.Type == nil {
+			continue
+		}
+
+		var owner *enteredTask
+		if he.PreviousEventId != nil {
+			owner = owners[*he.PreviousEventId]
+		}
+		if owner == nil {
+			owner = last
+		}
+
+		switch *he.Type {
+		case "ExecutionStarted":
+			if he.ExecutionStartedEventDetails != nil {
+				rec.Input = he.ExecutionStartedEventDetails.Input
+			}
+		case "MapIterationStarted":
+			depth++
+		case "MapIterationSucceeded", "MapIterationFailed", "MapIterationAborted":
+			depth--
+		}
+
+		if he.StateEnteredEventDetails != nil {
+			if he.StateEnteredEventDetails.Name == nil {
+				return nil, fmt.Errorf("%v event without a state Name", *he.Type)
+			}
+
+			var step *RecordedStep
+			if depth == 0 {
+				step = &RecordedStep{Name: *he.StateEnteredEventDetails.Name, Input: he.StateEnteredEventDetails.Input}
+				rec.Steps = append(rec.Steps, step)
+			}
+
+			owner = nil
+			if *he.Type == "TaskStateEntered" {
+				owner = &enteredTask{name: *he.StateEnteredEventDetails.Name, step: step}
+				last = owner
+			}
+		}
+
+		if result := recordedResult(he); result != nil {
+			if owner == nil {
+				return nil, fmt.Errorf("%v event outside of a Task state", *he.Type)
+			}
+
+			// Retries enter the Task again
+			if owner.attempts > 0 && owner.step != nil {
+				rec.Steps = append(rec.Steps, &RecordedStep{Name: owner.name, Input: owner.step.Input})
+			}
+			owner.attempts++
+			rec.results[owner.name] = append(rec.results[owner.name], result)
+		}
+
+		if he.Id != nil && owner != nil {
+			owners[*he.Id] = owner
+		}
+	}
+
+	return rec, nil
+}
+
+// recordedResult returns the result of a Task attempt event, or nil
+func recordedResult(he *sfn.HistoryEvent) *RecordedResult {
+	failed := func(name *string, cause *string) *RecordedResult {
+		return &RecordedResult{Error: &RecordedError{Name: to.Strs(name), Cause: to.Strs(cause)}}
+	}
+
+	switch {
+	case he.LambdaFunctionSucceededEventDetails != nil:
+		return &RecordedResult{Output: he.LambdaFunctionSucceededEventDetails.Output}
+	case he.TaskSucceededEventDetails != nil:
+		return &RecordedResult{Output: he.TaskSucceededEventDetails.Output}
+	case he.ActivitySucceededEventDetails != nil:
+		return &RecordedResult{Output: he.ActivitySucceededEventDetails.Output}
+	case he.LambdaFunctionFailedEventDetails != nil:
+		return failed(he.LambdaFunctionFailedEventDetails.Error, he.LambdaFunctionFailedEventDetails.Cause)
+	case he.LambdaFunctionTimedOutEventDetails != nil:
+		return failed(he.LambdaFunctionTimedOutEventDetails.Error, he.LambdaFunctionTimedOutEventDetails.Cause)
+	case he.LambdaFunctionScheduleFailedEventDetails != nil:
+		return failed(he.LambdaFunctionScheduleFailedEventDetails.Error, he.LambdaFunctionScheduleFailedEventDetails.Cause)
+	case he.LambdaFunctionStartFailedEventDetails != nil:
+		return failed(he.LambdaFunctionStartFailedEventDetails.Error, he.LambdaFunctionStartFailedEventDetails.Cause)
+	case he.TaskFailedEventDetails != nil:
+		return failed(he.TaskFailedEventDetails.Error, he.TaskFailedEventDetails.Cause)
+	case he.TaskTimedOutEventDetails != nil:
+		return failed(he.TaskTimedOutEventDetails.Error, he.TaskTimedOutEventDetails.Cause)
+	case he.TaskStartFailedEventDetails != nil:
+		return failed(he.TaskStartFailedEventDetails.Error, he.TaskStartFailedEventDetails.Cause)
+	case he.TaskSubmitFailedEventDetails != nil:
+		return failed(he.TaskSubmitFailedEventDetails.Error, he.TaskSubmitFailedEventDetails.Cause)
+	case he.ActivityFailedEventDetails != nil:
+		return failed(he.ActivityFailedEventDetails.Error, he.ActivityFailedEventDetails.Cause)
+	case he.ActivityTimedOutEventDetails != nil:
+		return failed(he.ActivityTimedOutEventDetails.Error, he.ActivityTimedOutEventDetails.Cause)
+	case he.ActivityScheduleFailedEventDetails != nil:
+		return failed(he.ActivityScheduleFailedEventDetails.Error, he.ActivityScheduleFailedEventDetails.Cause)
+	}
+
+	return nil
+}
+
+// Stub returns a Task handler that returns the recorded results of the state in order
+func (rec *Recording) Stub(state string) func(context.Context, interface{}) (interface{}, error) {
+	return func(_ context.Context, _ interface{}) (interface{}, error) {
+		rec.mu.Lock()
+		results := rec.results[state]
+		call := rec.calls[state]
+		rec.calls[state]++
+		rec.mu.Unlock()
+
+		if call >= len(results) {
+			return nil, &NoRecordingError{state}
+		}
+
+		result := results[call]
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		var output interface{}
+		if result.Output != nil {
+			if err := json.Unmarshal([]byte(*result.Output), &output); err != nil {
+				return nil, err
+			}
+		}
+
+		return output, nil
+	}
+}
+
+// Reset rewinds the stubs to the first recorded results
+func (rec *Recording) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.calls = map[string]int{}
+}
+
+// Compare returns where the states entered by exec differ from the recording,
+// the inputs of the steps up to the first different state are compared as JSON
+func (rec *Recording) Compare(exec *Execution) []*Divergence {
+	replayed := []*RecordedStep{}
+	for _, he := range exec.ExecutionHistory {
+		if he.StateEnteredEventDetails != nil {
+			replayed = append(replayed, &RecordedStep{Name: *he.StateEnteredEventDetails.Name, Input: he.StateEnteredEventDetails.Input})
+		}
+	}
+
+	divergences := []*Divergence{}
+	for i := 0; i < len(rec.Steps) || i < len(replayed); i++ {
+		switch {
+		case i >= len(replayed):
+			return append(divergences, &Divergence{Step: i, Expected: rec.Steps[i].Name, Message: "replay stopped early"})
+		case i >= len(rec.Steps):
+			return append(divergences, &Divergence{Step: i, Actual: replayed[i].Name, Message: "replay entered more states"})
+		case rec.Steps[i].Name != replayed[i].Name:
+			return append(divergences, &Divergence{Step: i, Expected: rec.Steps[i].Name, Actual: replayed[i].Name, Message: "replay entered a different state"})
+		case !equalJSON(rec.Steps[i].Input, replayed[i].Input):
+			divergences = append(divergences, &Divergence{Step: i, Expected: rec.Steps[i].Name, Actual: replayed[i].Name, Message: "state input differs"})
+		}
+	}
+
+	return divergences
+}
+
+// equalJSON is true if both are missing or encode equal values
+func equalJSON(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	var av, bv interface{}
+	if err := json.Unmarshal([]byte(*a), &av); err != nil {
+		return *a == *b
+	}
+	if err := json.Unmarshal([]byte(*b), &bv); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(av, bv)
+}
+
+// Replay executes the recorded input with every Task handler replaced by the recorded results
+// and returns where the execution diverged from the recording
+func (sm *StateMachine) Replay(rec *Recording) (*Execution, []*Divergence, error) {
+	rec.Reset()
+	for _, task := range sm.TaskStates() {
+		task.SetTaskHandler(rec.Stub(*task.Name()))
+	}
+
+	input := rec.Input
+	if input == nil {
+		input = to.Strp("{}")
+	}
+
+	exec, err := sm.Execute(input)
+	if exec == nil {
+		return nil, nil, err
+	}
+
+	return exec, rec.Compare(exec), err
+}
@@ -0,0 +1,156 @@
+package machine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+var replayMachine = `{
+	"StartAt": "Prepare",
+	"States": {
+		"Prepare": {"Type": "Pass", "Result": true, "ResultPath": "$.prepared", "Next": "Deploy"},
+		"Deploy": {
+			"Type": "Task",
+			"Resource": "arn:aws:lambda:us-east-1:000000000000:function:deploy",
+			"ResultPath": "$.deploy",
+			"Retry": [{"ErrorEquals": ["Lambda.Unknown"], "MaxAttempts": 1}],
+			"Catch": [{"ErrorEquals": ["DeployError"], "Next": "Failed"}],
+			"Next": "Check"
+		},
+		"Check": {
+			"Type": "Choice",
+			"Choices": [{"Variable": "$.deploy.deployed", "BooleanEquals": true, "Next": "Done"}],
+			"Default": "Failed"
+		},
+		"Done": {"Type": "Succeed"},
+		"Failed": {"Type": "Fail", "Error": "Failed"}
+	}
+}`
+
+func historyEvent(id int64, event_type string) *sfn.HistoryEvent {
+	return &sfn.HistoryEvent{Id: to.Int64p(id), PreviousEventId: to.Int64p(id - 1), Type: to.Strp(event_type)}
+}
+
+func enteredEvent(id int64, event_type string, name string, input string) *sfn.HistoryEvent {
+	he := historyEvent(id, event_type)
+	he.StateEnteredEventDetails = &sfn.StateEnteredEventDetails{Name: to.Strp(name), Input: to.Strp(input)}
+	return he
+}
+
+// recordedHistory is a production history where Deploy failed once and was retried
+func recordedHistory() []*sfn.HistoryEvent {
+	started := historyEvent(1, "ExecutionStarted")
+	started.ExecutionStartedEventDetails = &sfn.ExecutionStartedEventDetails{Input: to.Strp(`{"name": "step"}`)}
+
+	failed := historyEvent(7, "LambdaFunctionFailed")
+	failed.LambdaFunctionFailedEventDetails = &sfn.LambdaFunctionFailedEventDetails{Error: to.Strp("Lambda.Unknown"), Cause: to.Strp("timed out")}
+
+	succeeded := historyEvent(10, "LambdaFunctionSucceeded")
+	succeeded.LambdaFunctionSucceededEventDetails = &sfn.LambdaFunctionSucceededEventDetails{Output: to.Strp(`{"deployed": true}`)}
+
+	deployed := `{"name": "step", "prepared": true, "deploy": {"deployed": true}}`
+	return []*sfn.HistoryEvent{
+		started,
+		enteredEvent(2, "PassStateEntered", "Prepare", `{"name": "step"}`),
+		historyEvent(3, "PassStateExited"),
+		enteredEvent(4, "TaskStateEntered", "Deploy", `{"name": "step", "prepared": true}`),
+		historyEvent(5, "LambdaFunctionScheduled"),
+		historyEvent(6, "LambdaFunctionStarted"),
+		failed,
+		historyEvent(8, "LambdaFunctionScheduled"),
+		historyEvent(9, "LambdaFunctionStarted"),
+		succeeded,
+		historyEvent(11, "TaskStateExited"),
+		enteredEvent(12, "ChoiceStateEntered", "Check", deployed),
+		historyEvent(13, "ChoiceStateExited"),
+		enteredEvent(14, "SucceedStateEntered", "Done", deployed),
+		historyEvent(15, "SucceedStateExited"),
+		historyEvent(16, "ExecutionSucceeded"),
+	}
+}
+
+func Test_Recording_Steps(t *testing.T) {
+	rec, err := NewRecording(recordedHistory())
+	assert.NoError(t, err)
+
+	names := []string{}
+	for _, step := range rec.Steps {
+		names = append(names, step.Name)
+	}
+	assert.Equal(t, []string{"Prepare", "Deploy", "Deploy", "Check", "Done"}, names)
+	assert.Equal(t, `{"name": "step"}`, *rec.Input)
+
+	stub := rec.Stub("Deploy")
+	_, err = stub(context.Background(), nil)
+	assert.Equal(t, &RecordedError{"Lambda.Unknown", "timed out"}, err)
+
+	output, err := stub(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"deployed": true}, output)
+
+	_, err = stub(context.Background(), nil)
+	assert.Equal(t, &NoRecordingError{"Deploy"}, err)
+}
+
+func Test_Recording_MapIterations(t *testing.T) {
+	succeeded := historyEvent(6, "LambdaFunctionSucceeded")
+	succeeded.LambdaFunctionSucceededEventDetails = &sfn.LambdaFunctionSucceededEventDetails{Output: to.Strp(`"done"`)}
+
+	rec, err := NewRecording([]*sfn.HistoryEvent{
+		historyEvent(1, "ExecutionStarted"),
+		enteredEvent(2, "MapStateEntered", "Items", `{}`),
+		historyEvent(3, "MapIterationStarted"),
+		enteredEvent(4, "TaskStateEntered", "Item", `1`),
+		historyEvent(5, "LambdaFunctionScheduled"),
+		succeeded,
+		historyEvent(7, "TaskStateExited"),
+		historyEvent(8, "MapIterationSucceeded"),
+		historyEvent(9, "MapStateExited"),
+	})
+	assert.NoError(t, err)
+
+	// States inside iterations are not steps but their results are recorded
+	assert.Equal(t, 1, len(rec.Steps))
+	output, err := rec.Stub("Item")(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "done", output)
+
+	// Results must belong to a Task
+	_, err = NewRecording([]*sfn.HistoryEvent{succeeded})
+	assert.Error(t, err)
+}
+
+func Test_Machine_Replay(t *testing.T) {
+	rec, err := NewRecording(recordedHistory())
+	assert.NoError(t, err)
+
+	sm, err := FromJSON([]byte(replayMachine))
+	assert.NoError(t, err)
+
+	exec, divergences, err := sm.Replay(rec)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Divergence{}, divergences)
+	assert.Equal(t, []string{"Prepare", "Deploy", "Deploy", "Check", "Done"}, exec.Path())
+
+	// A changed Choice takes a different path
+	sm, err = FromJSON([]byte(strings.Replace(replayMachine, "$.deploy.deployed", "$.deploy.ok", 1)))
+	assert.NoError(t, err)
+
+	_, divergences, err = sm.Replay(rec)
+	assert.Error(t, err)
+	assert.Equal(t, []*Divergence{{Step: 4, Expected: "Done", Actual: "Failed", Message: "replay entered a different state"}}, divergences)
+
+	// A changed Pass Result changes the input of the later states
+	sm, err = FromJSON([]byte(strings.Replace(replayMachine, `"Result": true`, `"Result": false`, 1)))
+	assert.NoError(t, err)
+
+	_, divergences, err = sm.Replay(rec)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(divergences))
+	assert.Equal(t, &Divergence{Step: 1, Expected: "Deploy", Actual: "Deploy", Message: "state input differs"}, divergences[0])
+}
@@ -0,0 +1,76 @@
+// sqlite is a machine.ExecutionStore that keeps Checkpoints in a SQLite file
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/coinbase/step/machine"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Store saves the latest Checkpoint of each execution in the checkpoints table
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore opens (or creates) the SQLite file at path
+func NewStore(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS checkpoints (
+		execution_id TEXT PRIMARY KEY,
+		status       TEXT NOT NULL,
+		checkpoint   TEXT NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Save(cp *machine.Checkpoint) error {
+	raw, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO checkpoints (execution_id, status, checkpoint) VALUES (?, ?, ?)`,
+		cp.ExecutionID, cp.Status, string(raw),
+	)
+
+	return err
+}
+
+func (s *Store) Load(execution_id string) (*machine.Checkpoint, error) {
+	var raw string
+	err := s.db.QueryRow(`SELECT checkpoint FROM checkpoints WHERE execution_id = ?`, execution_id).Scan(&raw)
+
+	if err == sql.ErrNoRows {
+		return nil, machine.NotFoundError{ExecutionID: execution_id}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var cp machine.Checkpoint
+	if err := json.Unmarshal([]byte(raw), &cp); err != nil {
+		return nil, err
+	}
+
+	return &cp, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coinbase/step/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+func returnInput(_ context.Context, input interface{}) (interface{}, error) {
+	return input, nil
+}
+
+func Test_Store_SaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-sqlite")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "executions.db")
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+
+	_, err = store.Load("missing")
+	assert.Equal(t, machine.NotFoundError{ExecutionID: "missing"}, err)
+
+	sm, err := machine.FromJSON([]byte(`{
+		"StartAt": "Start",
+		"States": {
+			"Start": {"Type": "Pass", "Assign": {"count": 1}, "Next": "Report"},
+			"Report": {"Type": "Task", "Resource": "report", "End": true}
+		}
+	}`))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.SetTaskHandler("Report", returnInput))
+
+	sm.SetStore(store)
+	_, err = sm.ExecuteWithID("exec-1", map[string]interface{}{"a": "b"})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	// Checkpoints are kept in the file
+	store, err = NewStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	cp, err := store.Load("exec-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "SUCCEEDED", cp.Status)
+	assert.Equal(t, map[string]interface{}{"count": float64(1)}, cp.Variables)
+	assert.Equal(t, map[string]interface{}{"a": "b"}, cp.Output)
+
+	// A RUNNING Checkpoint is resumed from its Next state
+	cp.Status = "RUNNING"
+	cp.Next = cp.ExecutionHistory[3].StateEnteredEventDetails.Name
+	cp.Input = map[string]interface{}{"a": "c"}
+	cp.ExecutionHistory = cp.ExecutionHistory[:3]
+	assert.NoError(t, store.Save(cp))
+
+	sm.SetStore(store)
+	exec, err := sm.Resume("exec-1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": "c"}, exec.Output)
+	assert.Equal(t, []string{"Start", "Report"}, exec.Path())
+	assert.Equal(t, float64(1), exec.Variables()["count"])
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/coinbase/step/errors"
 	"github.com/coinbase/step/jsonpath"
 	"github.com/coinbase/step/utils/is"
 	"github.com/coinbase/step/utils/to"
@@ -27,11 +28,16 @@ type State interface {
 
 type stateStr struct {
 	name *string `json:"-"`
+
+	QueryLanguage        *string `json:",omitempty"` // JSONPath (default) or JSONata
+	defaultQueryLanguage *string `json:"-"`          // QueryLanguage of the State Machine
 }
 
 type Catcher struct {
 	ErrorEquals []*string      `json:",omitempty"`
 	ResultPath  *jsonpath.Path `json:",omitempty"`
+	Output      interface{}    `json:",omitempty"` // JSONata only
+	Assign      interface{}    `json:",omitempty"`
 	Next        *string        `json:",omitempty"`
 }
 
@@ -40,11 +46,10 @@ type Retrier struct {
 	IntervalSeconds *int      `json:",omitempty"`
 	MaxAttempts     *int      `json:",omitempty"`
 	BackoffRate     *float64  `json:",omitempty"`
-	attempts        int       `json:"-"` // Used to remember attempts
 }
 
 func errorOutputFromError(err error) map[string]interface{} {
-	return errorOutput(to.Strp(to.ErrorType(err)), to.Strp(err.Error()))
+	return errorOutput(to.Strp(errors.Name(err)), to.Strp(errors.Cause(err)))
 }
 
 func errorOutput(err *string, cause *string) map[string]interface{} {
@@ -63,7 +68,7 @@ func errorOutput(err *string, cause *string) map[string]interface{} {
 }
 
 func errorIncluded(errorEquals []*string, err error) bool {
-	error_type := to.ErrorType(err)
+	error_type := errors.Name(err)
 
 	for _, et := range errorEquals {
 		if *et == "States.ALL" || *et == error_type {
@@ -106,6 +111,9 @@ func processRetrier(retryName *string, retriers []*Retrier, exec ExecutionFn) Ex
 			return output, next, err
 		}
 
+		// Attempts are kept per execution so a redrive retries again
+		attempts := retriesOf(ctx)
+
 		// Is Error in a Retrier
 		for _, retrier := range retriers {
 			// If the error type is defined in the retrier AND we have not attempted the retry yet
@@ -116,8 +124,9 @@ func processRetrier(retryName *string, retriers []*Retrier, exec ExecutionFn) Ex
 
 			// Match on first retrier
 			if errorIncluded(retrier.ErrorEquals, err) {
-				if retrier.attempts < *retrier.MaxAttempts {
-					retrier.attempts++
+				if attempts[retrier] < *retrier.MaxAttempts {
+					attempts[retrier]++
+					recordRetry(ctx, err)
 					// Returns the name of the state to the state-machine to re-execute
 					return input, retryName, nil
 				} else {
@@ -132,7 +141,9 @@ func processRetrier(retryName *string, retriers []*Retrier, exec ExecutionFn) Ex
 	}
 }
 
-func processCatcher(catchers []*Catcher, exec ExecutionFn) ExecutionFn {
+// processCatcher sends matching errors to the Catcher Next state,
+// jsonata selects how the Catcher Output and Assign are evaluated
+func processCatcher(catchers []*Catcher, jsonata bool, exec ExecutionFn) ExecutionFn {
 	return func(ctx context.Context, input interface{}) (interface{}, *string, error) {
 		output, next, err := exec(ctx, input)
 
@@ -144,6 +155,29 @@ func processCatcher(catchers []*Catcher, exec ExecutionFn) ExecutionFn {
 			if errorIncluded(catcher.ErrorEquals, err) {
 
 				eo := errorOutputFromError(err)
+
+				// Only the Assign of the Catcher is applied
+				variablesOf(ctx).discard()
+
+				if jsonata {
+					states := map[string]interface{}{"input": input, "errorOutput": eo}
+					if err := assignJSONata(ctx, catcher.Assign, input, states); err != nil {
+						return nil, nil, err
+					}
+
+					if catcher.Output == nil {
+						return eo, catcher.Next, nil
+					}
+
+					output, err := evalJSONata(catcher.Output, input, statesVars(ctx, states))
+					return output, catcher.Next, err
+				}
+
+				// JSONPath Assign reads from the error output
+				if err := assignJSONPath(ctx, catcher.Assign, eo); err != nil {
+					return nil, nil, err
+				}
+
 				output, err := catcher.ResultPath.Set(input, eo)
 
 				return output, catcher.Next, err
@@ -160,14 +194,42 @@ func processError(s State, exec ExecutionFn) ExecutionFn {
 		output, next, err := exec(ctx, input)
 
 		if err != nil {
-			return nil, nil, fmt.Errorf("%v %v", errorPrefix(s), err.Error())
+			return nil, nil, &stateError{prefix: errorPrefix(s), err: err}
 		}
 		return output, next, nil
 	}
 }
+
+// stateError prefixes the message of err with its state, keeping the name and cause of err
+// so the Retry and Catch of an enclosing Map or Parallel state match it
+type stateError struct {
+	prefix string
+	err    error
+}
+
+func (e *stateError) Error() string {
+	return fmt.Sprintf("%v %v", e.prefix, e.err.Error())
+}
+
+func (e *stateError) Unwrap() error {
+	return e.err
+}
+
+func (e *stateError) ErrorName() string {
+	return errors.Name(e.err)
+}
+
+func (e *stateError) ErrorCause() string {
+	return errors.Cause(e.err)
+}
 func inputOutput(inputPath *jsonpath.Path, outputPath *jsonpath.Path, exec ExecutionFn) ExecutionFn {
 	return func(ctx context.Context, input interface{}) (interface{}, *string, error) {
-		input, err := inputPath.Get(input)
+		vars := variablesOf(ctx).all()
+
+		input, err := pathSource(inputPath, input, vars)
+		if err == nil {
+			input, err = inputPath.Get(input)
+		}
 
 		if err != nil {
 			return nil, nil, fmt.Errorf("Input Error: %v", err)
@@ -179,7 +241,10 @@ func inputOutput(inputPath *jsonpath.Path, outputPath *jsonpath.Path, exec Execu
 			return nil, nil, err
 		}
 
-		output, err = outputPath.Get(output)
+		output, err = pathSource(outputPath, output, vars)
+		if err == nil {
+			output, err = outputPath.Get(output)
+		}
 
 		if err != nil {
 			return nil, nil, fmt.Errorf("Output Error: %v", err)
@@ -195,7 +260,7 @@ func withParams(params interface{}, exec ExecutionFn) ExecutionFn {
 			return exec(ctx, input)
 		}
 		// Loop through the input replace values with JSON paths
-		input, err := replaceParamsJSONPath(params, input)
+		input, err := replaceParamsJSONPath(params, input, contextObject(ctx), variablesOf(ctx).all())
 		if err != nil {
 			return nil, nil, err
 		}
@@ -204,7 +269,9 @@ func withParams(params interface{}, exec ExecutionFn) ExecutionFn {
 	}
 }
 
-func replaceParamsJSONPath(params interface{}, input interface{}) (interface{}, error) {
+// replaceParamsJSONPath replaces the values of keys ending in .$ with
+// the value of a path, $$ paths read from contextObj and $name paths from vars
+func replaceParamsJSONPath(params interface{}, input interface{}, contextObj interface{}, vars map[string]interface{}) (interface{}, error) {
 
 	switch params.(type) {
 	case map[string]interface{}:
@@ -221,17 +288,14 @@ func replaceParamsJSONPath(params interface{}, input interface{}) (interface{},
 					return nil, fmt.Errorf("value to key %q is not string", key)
 				}
 				valueStr := value.(string)
-				path, err := jsonpath.NewPath(valueStr)
-				if err != nil {
-					return nil, err
-				}
-				newValue, err := path.Get(input)
+
+				newValue, err := getPath(valueStr, input, contextObj, vars)
 				if err != nil {
 					return nil, err
 				}
 				newParams[key] = newValue
 			} else {
-				newValue, err := replaceParamsJSONPath(value, input)
+				newValue, err := replaceParamsJSONPath(value, input, contextObj, vars)
 				if err != nil {
 					return nil, err
 				}
@@ -243,6 +307,29 @@ func replaceParamsJSONPath(params interface{}, input interface{}) (interface{},
 	return params, nil
 }
 
+// getPath gets a value using a path, $$ paths are read from the context object
+// and $name paths from the workflow variables
+func getPath(path_string string, input interface{}, contextObj interface{}, vars map[string]interface{}) (interface{}, error) {
+	if strings.HasPrefix(path_string, "$$") {
+		path, err := jsonpath.NewPath(path_string[1:])
+		if err != nil {
+			return nil, err
+		}
+		return path.Get(contextObj)
+	}
+
+	path, err := jsonpath.NewPath(path_string)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := pathSource(path, input, vars)
+	if err != nil {
+		return nil, err
+	}
+	return path.Get(source)
+}
+
 func result(resultPath *jsonpath.Path, exec ExecutionFn) ExecutionFn {
 	return func(ctx context.Context, input interface{}) (interface{}, *string, error) {
 		result, next, err := exec(ctx, input)
@@ -1,6 +1,7 @@
 package machine
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -15,6 +16,9 @@ type stateTestData struct {
 	Next   *string
 }
 
+// testRetries keeps the Retrier attempts across testState calls like an execution does
+var testRetries = map[*Retrier]int{}
+
 func testState(state State, std stateTestData, t *testing.T) {
 	// Make sure the execution is on Valid State
 	err := state.Validate()
@@ -25,7 +29,7 @@ func testState(state State, std stateTestData, t *testing.T) {
 		std.Input = map[string]interface{}{}
 	}
 
-	output, next, err := state.Execute(nil, std.Input)
+	output, next, err := state.Execute(withRetries(context.Background(), testRetries), std.Input)
 
 	// expecting error?
 	if std.Error != nil {
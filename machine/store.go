@@ -0,0 +1,230 @@
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/coinbase/step/utils/to"
+)
+
+// An ExecutionStore saves a Checkpoint of an execution before each state is executed,
+// so a run that stopped part way through can be continued with StateMachine.Resume
+
+// Checkpoint is the saved progress of an execution
+type Checkpoint struct {
+	ExecutionID string
+	Status      string // RUNNING, SUCCEEDED or FAILED
+
+	// Next is the state to execute with Input, it is the failed state if Status is FAILED
+	Next  *string
+	Input interface{}
+
+	ExecutionInput   interface{}            // $$.Execution.Input
+	Variables        map[string]interface{} // workflow variables
+	ExecutionHistory []HistoryEvent
+
+	Output interface{} `json:",omitempty"`
+	Error  *string     `json:",omitempty"`
+}
+
+type ExecutionStore interface {
+	Save(*Checkpoint) error
+	Load(execution_id string) (*Checkpoint, error)
+}
+
+// NotFoundError is returned by Load if the store has no Checkpoint for the execution
+type NotFoundError struct {
+	ExecutionID string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("NotFoundError: execution %q not found", e.ExecutionID)
+}
+
+//////
+// MemoryStore
+//////
+
+// MemoryStore keeps Checkpoints in memory, e.g. for tests
+type MemoryStore struct {
+	mu          sync.Mutex
+	checkpoints map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{checkpoints: map[string][]byte{}}
+}
+
+func (m *MemoryStore) Save(cp *Checkpoint) error {
+	// Stored as JSON so later changes to the execution do not alter the Checkpoint
+	raw, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkpoints[cp.ExecutionID] = raw
+	return nil
+}
+
+func (m *MemoryStore) Load(execution_id string) (*Checkpoint, error) {
+	m.mu.Lock()
+	raw, ok := m.checkpoints[execution_id]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, NotFoundError{execution_id}
+	}
+
+	return unmarshalCheckpoint(raw)
+}
+
+//////
+// FileStore
+//////
+
+// FileStore writes each execution's Checkpoint to <Dir>/<execution_id>.json
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore creates dir if it does not exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+func (f *FileStore) path(execution_id string) (string, error) {
+	if execution_id == "" || strings.ContainsAny(execution_id, `/\`) || execution_id == "." || execution_id == ".." {
+		return "", fmt.Errorf("invalid execution id %q", execution_id)
+	}
+	return filepath.Join(f.Dir, execution_id+".json"), nil
+}
+
+// Save writes to a temporary file then renames it, so a crash never leaves a partial Checkpoint
+func (f *FileStore) Save(cp *Checkpoint) error {
+	path, err := f.path(cp.ExecutionID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(f.Dir, cp.ExecutionID+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *FileStore) Load(execution_id string) (*Checkpoint, error) {
+	path, err := f.path(execution_id)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, NotFoundError{execution_id}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalCheckpoint(raw)
+}
+
+func unmarshalCheckpoint(raw []byte) (*Checkpoint, error) {
+	var cp Checkpoint
+	if err := json.Unmarshal(raw, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+//////
+// Execution Checkpoints
+//////
+
+// checkpoint records the state about to run, and saves it if the execution has a store
+func (sm *Execution) checkpoint(next *string, input interface{}) error {
+	sm.next = next
+	sm.nextInput = input
+
+	if sm.store == nil {
+		return nil
+	}
+
+	return sm.store.Save(sm.newCheckpoint("RUNNING"))
+}
+
+// finish saves the final Checkpoint, a FAILED Checkpoint keeps the failed state and its input
+func (sm *Execution) finish(output interface{}, err error) error {
+	if sm.store == nil {
+		return nil
+	}
+
+	if err != nil {
+		cp := sm.newCheckpoint("FAILED")
+		cp.Error = to.Strp(err.Error())
+		return sm.store.Save(cp)
+	}
+
+	cp := sm.newCheckpoint("SUCCEEDED")
+	cp.Next = nil
+	cp.Input = nil
+	cp.Output = output
+	return sm.store.Save(cp)
+}
+
+func (sm *Execution) newCheckpoint(status string) *Checkpoint {
+	return &Checkpoint{
+		ExecutionID:      sm.ID,
+		Status:           status,
+		Next:             sm.next,
+		Input:            sm.nextInput,
+		ExecutionInput:   sm.input,
+		Variables:        sm.variables.all(),
+		ExecutionHistory: sm.ExecutionHistory,
+	}
+}
+
+// executionFromCheckpoint restores an execution with the same context object and variables
+func executionFromCheckpoint(cp *Checkpoint, store ExecutionStore) *Execution {
+	vars := newVariables(nil)
+	for name, value := range cp.Variables {
+		vars.values[name] = value
+	}
+
+	return &Execution{
+		ID:               cp.ExecutionID,
+		ExecutionHistory: cp.ExecutionHistory,
+		input:            cp.ExecutionInput,
+		variables:        vars,
+		store:            store,
+	}
+}
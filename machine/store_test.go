@@ -0,0 +1,126 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// crashStore stops saving once crashed, like the process being killed
+type crashStore struct {
+	ExecutionStore
+	crashed bool
+}
+
+func (c *crashStore) Save(cp *Checkpoint) error {
+	if c.crashed {
+		return fmt.Errorf("crashed")
+	}
+	return c.ExecutionStore.Save(cp)
+}
+
+var resumeMachine = `{
+	"StartAt": "Start",
+	"States": {
+		"Start": {"Type": "Pass", "Assign": {"count": 1}, "Result": "started", "ResultPath": "$.start", "Next": "Report"},
+		"Report": {
+			"Type": "Task",
+			"Resource": "report",
+			"Parameters": {"count.$": "$count", "name.$": "$$.Execution.Input.name", "start.$": "$.start"},
+			"End": true
+		}
+	}
+}`
+
+func testResume(t *testing.T, store ExecutionStore) {
+	sm, err := FromJSON([]byte(resumeMachine))
+	assert.NoError(t, err)
+
+	crash := &crashStore{ExecutionStore: store}
+	calls := 0
+	assert.NoError(t, sm.SetTaskHandler("Report", func(_ context.Context, input interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			crash.crashed = true
+			return nil, fmt.Errorf("killed")
+		}
+		return input, nil
+	}))
+
+	// The process is killed while Report runs
+	sm.SetStore(crash)
+	_, err = sm.ExecuteWithID("exec-1", map[string]interface{}{"name": "step"})
+	assert.Error(t, err)
+
+	cp, err := store.Load("exec-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "RUNNING", cp.Status)
+	assert.Equal(t, "Report", *cp.Next)
+
+	// Resume runs Report again with the same input, variables and context object
+	sm.SetStore(store)
+	exec, err := sm.Resume("exec-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "exec-1", exec.ID)
+	assert.Equal(t, map[string]interface{}{"count": float64(1), "name": "step", "start": "started"}, exec.Output)
+	assert.Equal(t, []string{"Start", "Report"}, exec.Path())
+
+	cp, err = store.Load("exec-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "SUCCEEDED", cp.Status)
+	assert.Nil(t, cp.Next)
+
+	// Finished executions cannot be resumed
+	_, err = sm.Resume("exec-1")
+	assert.Error(t, err)
+	assert.Regexp(t, "is SUCCEEDED", err.Error())
+
+	_, err = sm.Resume("unknown")
+	assert.Equal(t, NotFoundError{"unknown"}, err)
+}
+
+func Test_Store_Resume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-store")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file_store, err := NewFileStore(dir)
+	assert.NoError(t, err)
+
+	testResume(t, NewMemoryStore())
+	testResume(t, file_store)
+}
+
+func Test_Store_Failed(t *testing.T) {
+	sm, err := FromJSON([]byte(resumeMachine))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.SetTaskHandler("Report", func(_ context.Context, input interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("bad report")
+	}))
+
+	store := NewMemoryStore()
+	sm.SetStore(store)
+	_, err = sm.ExecuteWithID("exec-1", map[string]interface{}{"name": "step"})
+	assert.Error(t, err)
+
+	// A failed Checkpoint keeps the failed state and its input
+	cp, err := store.Load("exec-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "FAILED", cp.Status)
+	assert.Equal(t, "Report", *cp.Next)
+	assert.Equal(t, map[string]interface{}{"name": "step", "start": "started"}, cp.Input)
+	assert.Regexp(t, "bad report", *cp.Error)
+}
+
+func Test_FileStore_InvalidID(t *testing.T) {
+	store := &FileStore{Dir: os.TempDir()}
+	assert.Error(t, store.Save(&Checkpoint{ExecutionID: "../escape"}))
+
+	_, err := store.Load("")
+	assert.Error(t, err)
+}
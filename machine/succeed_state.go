@@ -16,6 +16,8 @@ type SucceedState struct {
 
 	InputPath  *jsonpath.Path `json:",omitempty"`
 	OutputPath *jsonpath.Path `json:",omitempty"`
+
+	Output interface{} `json:",omitempty"` // JSONata only
 }
 
 func (s *SucceedState) process(ctx context.Context, input interface{}) (interface{}, *string, error) {
@@ -23,6 +25,12 @@ func (s *SucceedState) process(ctx context.Context, input interface{}) (interfac
 }
 
 func (s *SucceedState) Execute(ctx context.Context, input interface{}) (output interface{}, next *string, err error) {
+	if s.isJSONata() {
+		return processError(s,
+			jsonataIO(nil, s.Output, nil, s.process),
+		)(ctx, input)
+	}
+
 	return processError(s,
 		inputOutput(
 			s.InputPath,
@@ -39,6 +47,15 @@ func (s *SucceedState) Validate() error {
 		return fmt.Errorf("%v %v", errorPrefix(s), err)
 	}
 
+	if err := s.fieldsValid(map[string]bool{
+		"InputPath":  s.InputPath != nil,
+		"OutputPath": s.OutputPath != nil,
+	}, map[string]bool{
+		"Output": s.Output != nil,
+	}); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
 	return nil
 }
 
@@ -3,6 +3,7 @@ package machine
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/coinbase/step/handler"
 	"github.com/coinbase/step/jsonpath"
@@ -20,6 +21,12 @@ type TaskState struct {
 	ResultPath *jsonpath.Path `json:",omitempty"`
 	Parameters interface{}    `json:",omitempty"`
 
+	// JSONata replacements for the paths and Parameters
+	Arguments interface{} `json:",omitempty"`
+	Output    interface{} `json:",omitempty"`
+
+	Assign interface{} `json:",omitempty"`
+
 	Resource *string `json:",omitempty"`
 
 	Catch []*Catcher `json:",omitempty"`
@@ -33,6 +40,32 @@ type TaskState struct {
 
 	TimeoutSeconds   int `json:",omitempty"`
 	HeartbeatSeconds int `json:",omitempty"`
+
+	taskFn bool // TaskFn states inject the Task name into the input
+}
+
+func (s *TaskState) setDefaultQueryLanguage(ql *string) {
+	s.stateStr.setDefaultQueryLanguage(ql)
+	if s.taskFn {
+		s.setTaskFnInput()
+	}
+}
+
+// setTaskFnInput injects the Task name into the input using the states query language
+func (s *TaskState) setTaskFnInput() {
+	if s.isJSONata() {
+		s.Parameters = nil
+		s.Arguments = map[string]interface{}{"Task": *s.Name(), "Input": "{% $states.input %}"}
+		return
+	}
+
+	s.Arguments = nil
+	s.Parameters = map[string]interface{}{"Task": *s.Name(), "Input.$": "$"}
+}
+
+// waitForTaskToken is true if the Task waits for SendTaskSuccess or SendTaskFailure with $$.Task.Token
+func (s *TaskState) waitForTaskToken() bool {
+	return s.Resource != nil && strings.HasSuffix(*s.Resource, ".waitForTaskToken")
 }
 
 func (s *TaskState) SetTaskHandler(resourcefn interface{}) {
@@ -57,15 +90,25 @@ func (s *TaskState) process(ctx context.Context, input interface{}) (interface{}
 
 // Input must include the Task name in $.Task
 func (s *TaskState) Execute(ctx context.Context, input interface{}) (output interface{}, next *string, err error) {
+	if s.isJSONata() {
+		return processError(s,
+			processCatcher(s.Catch, true,
+				processRetrier(s.Name(), s.Retry,
+					jsonataIO(s.Arguments, s.Output, s.Assign, s.process),
+				),
+			),
+		)(ctx, input)
+	}
+
 	return processError(s,
-		processCatcher(s.Catch,
+		processCatcher(s.Catch, false,
 			processRetrier(s.Name(), s.Retry,
 				inputOutput(
 					s.InputPath,
 					s.OutputPath,
 					withParams(
 						s.Parameters,
-						result(s.ResultPath, s.process),
+						result(s.ResultPath, withAssign(s.Assign, s.process)),
 					),
 				),
 			),
@@ -88,12 +131,34 @@ func (s *TaskState) Validate() error {
 		return fmt.Errorf("%v Requires Resource", errorPrefix(s))
 	}
 
+	if err := s.fieldsValid(map[string]bool{
+		"InputPath":        s.InputPath != nil,
+		"OutputPath":       s.OutputPath != nil,
+		"ResultPath":       s.ResultPath != nil,
+		"Parameters":       s.Parameters != nil,
+		"Catch.ResultPath": catchResultPathDefined(s.Catch),
+	}, map[string]bool{
+		"Arguments":    s.Arguments != nil,
+		"Output":       s.Output != nil,
+		"Catch.Output": catchOutputDefined(s.Catch),
+	}); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
 	if s.TaskHandler != nil {
 		if err := handler.ValidateHandler(s.TaskHandler); err != nil {
 			return err
 		}
 	}
 
+	if err := assignValid(s.Assign); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
+	if err := catchAssignValid(s.Catch); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
 	if err := catchValid(s.Catch); err != nil {
 		return err
 	}
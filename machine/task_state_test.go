@@ -2,8 +2,10 @@ package machine
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
+	"github.com/coinbase/step/errors"
 	"github.com/coinbase/step/utils/to"
 	"github.com/stretchr/testify/assert"
 )
@@ -126,6 +128,25 @@ func Test_TaskState_Catch_Doesnt_Catch(t *testing.T) {
 	}, t)
 }
 
+func Test_TaskState_Catch_NamedError(t *testing.T) {
+	throwNamed := func(_ context.Context, input interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("deploying: %w", &errors.ASLError{Name: "Deploy.Failed", Cause: "bad release"})
+	}
+
+	// Names match exactly, like Step Functions
+	state := parseValidTaskState([]byte(`{
+		"Next": "Pass",
+		"Resource": "test",
+		"Catch": [{ "ErrorEquals": ["Deploy"], "Next": "Partial" }, { "ErrorEquals": ["Deploy.Failed"], "Next": "Fail" }]
+	}`), throwNamed, t)
+
+	testState(state, stateTestData{
+		Input:  map[string]interface{}{"a": "c"},
+		Output: map[string]interface{}{"Error": "Deploy.Failed", "Cause": "bad release"},
+		Next:   to.Strp("Fail"),
+	}, t)
+}
+
 func Test_TaskState_Retry_Works(t *testing.T) {
 	th, calls := countCalls(ThrowTestErrorHandler)
 
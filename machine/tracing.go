@@ -0,0 +1,69 @@
+package machine
+
+import (
+	"context"
+
+	"github.com/coinbase/step/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/coinbase/step/machine"
+
+// Span attributes
+var (
+	executionIDKey = attribute.Key("step.execution.id")
+	stateNameKey   = attribute.Key("step.state.name")
+	stateTypeKey   = attribute.Key("step.state.type")
+	retryCountKey  = attribute.Key("step.state.retry_count")
+	mapIndexKey    = attribute.Key("step.map.index")
+	errorKey       = attribute.Key("step.error")
+)
+
+// SetTracerProvider sets the OpenTelemetry TracerProvider of the execution spans,
+// by default the global otel.GetTracerProvider() is used
+func (sm *StateMachine) SetTracerProvider(tp trace.TracerProvider) {
+	sm.tracerProvider = tp
+}
+
+// tracer returns the Tracer of the state machine, Map ItemProcessors use the Tracer of their Map state
+func (sm *StateMachine) tracer(ctx context.Context) trace.Tracer {
+	if sm.tracerProvider != nil {
+		return sm.tracerProvider.Tracer(tracerName)
+	}
+
+	if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
+		return span.TracerProvider().Tracer(tracerName)
+	}
+
+	return otel.Tracer(tracerName)
+}
+
+// startStateSpan starts the span of a state attempt, retries is the number of earlier attempts
+func (sm *StateMachine) startStateSpan(ctx context.Context, s State, retries int) (context.Context, trace.Span) {
+	return sm.tracer(ctx).Start(ctx, *s.Name(), trace.WithAttributes(
+		stateNameKey.String(*s.Name()),
+		stateTypeKey.String(*s.GetType()),
+		retryCountKey.Int(retries),
+	))
+}
+
+// recordRetry marks the state span as failed by err, the Retrier sends the state back to itself
+func recordRetry(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(errorKey.String(errors.Name(err)))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// endSpan ends span with the error of the execution or state
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.SetAttributes(errorKey.String(errors.Name(err)))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
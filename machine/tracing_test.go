@@ -0,0 +1,93 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func Test_Machine_Tracing(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"StartAt": "Flaky",
+		"States": {
+			"Flaky": {
+				"Type": "Task",
+				"Resource": "flaky",
+				"Retry": [{"ErrorEquals": ["States.ALL"], "MaxAttempts": 1}],
+				"Next": "Items"
+			},
+			"Items": {
+				"Type": "Map",
+				"ItemsPath": "$.items",
+				"Iterator": {"StartAt": "Item", "States": {"Item": {"Type": "Pass", "End": true}}},
+				"ResultPath": "$.items",
+				"End": true
+			}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	calls := 0
+	assert.NoError(t, sm.SetTaskHandler("Flaky", func(_ context.Context, input interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, fmt.Errorf("flaked")
+		}
+		return input, nil
+	}))
+
+	exporter := tracetest.NewInMemoryExporter()
+	sm.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	_, err = sm.ExecuteWithID("exec-1", map[string]interface{}{"items": []interface{}{1, 2}})
+	assert.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	names := []string{}
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	// Spans are exported when they end
+	assert.Equal(t, []string{"Flaky", "Flaky", "Item", "Map Iteration", "Item", "Map Iteration", "Items", "Execution"}, names)
+
+	execution := spans[7]
+	assert.Equal(t, "exec-1", spanAttribute(execution, executionIDKey).AsString())
+
+	// Each attempt of a retried state has its own span
+	first, retry := spans[0], spans[1]
+	assert.Equal(t, codes.Error, first.Status.Code)
+	assert.Equal(t, "errorString", spanAttribute(first, errorKey).AsString())
+	assert.Equal(t, int64(0), spanAttribute(first, retryCountKey).AsInt64())
+	assert.Equal(t, codes.Unset, retry.Status.Code)
+	assert.Equal(t, int64(1), spanAttribute(retry, retryCountKey).AsInt64())
+	assert.Equal(t, "Task", spanAttribute(retry, stateTypeKey).AsString())
+	assert.Equal(t, execution.SpanContext.SpanID(), retry.Parent.SpanID())
+
+	// Map iterations are children of the Map state, and their states children of the iteration
+	items, iteration, item := spans[6], spans[3], spans[2]
+	assert.Equal(t, execution.SpanContext.SpanID(), items.Parent.SpanID())
+	assert.Equal(t, items.SpanContext.SpanID(), iteration.Parent.SpanID())
+	assert.Equal(t, iteration.SpanContext.SpanID(), item.Parent.SpanID())
+	assert.Equal(t, int64(0), spanAttribute(iteration, mapIndexKey).AsInt64())
+	assert.Equal(t, "Pass", spanAttribute(item, stateTypeKey).AsString())
+
+	// All spans are in the execution trace
+	for _, span := range spans {
+		assert.Equal(t, execution.SpanContext.TraceID(), span.SpanContext.TraceID())
+	}
+}
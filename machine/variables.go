@@ -0,0 +1,192 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coinbase/step/jsonpath"
+)
+
+// Workflow variables are set by the Assign field once a state completes,
+// and read as $name in paths, Parameters, Choice rules and JSONata expressions.
+// Every Map iteration executes in its own scope that can read,
+// but not assign, the variables of the enclosing scope.
+
+type variables struct {
+	values map[string]interface{}
+	parent *variables
+
+	assigned map[string]interface{} // Assign of the running state
+}
+
+func newVariables(parent *variables) *variables {
+	return &variables{values: map[string]interface{}{}, parent: parent}
+}
+
+// all returns every variable visible in the scope
+func (v *variables) all() map[string]interface{} {
+	all := map[string]interface{}{}
+	if v.parent != nil {
+		for name, value := range v.parent.all() {
+			all[name] = value
+		}
+	}
+
+	for name, value := range v.values {
+		all[name] = value
+	}
+
+	return all
+}
+
+// assign records values to be set when the running state completes
+func (v *variables) assign(values map[string]interface{}) {
+	if v.assigned == nil {
+		v.assigned = map[string]interface{}{}
+	}
+
+	for name, value := range values {
+		v.assigned[name] = value
+	}
+}
+
+// discard drops the values assigned by the running state
+func (v *variables) discard() {
+	v.assigned = nil
+}
+
+// commit sets the values assigned by the running state and returns them
+func (v *variables) commit() (map[string]interface{}, error) {
+	assigned := v.assigned
+	v.assigned = nil
+
+	if v.parent != nil {
+		outer := v.parent.all()
+		for name := range assigned {
+			if _, ok := outer[name]; ok {
+				return nil, fmt.Errorf("Assign Error: cannot assign $%v of an outer scope", name)
+			}
+		}
+	}
+
+	for name, value := range assigned {
+		v.values[name] = value
+	}
+
+	return assigned, nil
+}
+
+// pathSource returns what path reads from, either the input or a workflow variable
+func pathSource(path *jsonpath.Path, input interface{}, vars map[string]interface{}) (interface{}, error) {
+	name := path.Variable()
+	if name == nil {
+		return input, nil
+	}
+
+	value, ok := vars[*name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown variable $%v", *name)
+	}
+
+	return value, nil
+}
+
+//////
+// Assign
+//////
+
+// withAssign evaluates the JSONPath Assign against the result of exec (or its input if there is no result)
+func withAssign(assign interface{}, exec ExecutionFn) ExecutionFn {
+	return func(ctx context.Context, input interface{}) (interface{}, *string, error) {
+		result, next, err := exec(ctx, input)
+
+		if err != nil || assign == nil {
+			return result, next, err
+		}
+
+		value := result
+		if value == nil {
+			value = input
+		}
+
+		if err := assignJSONPath(ctx, assign, value); err != nil {
+			return nil, nil, err
+		}
+
+		return result, next, nil
+	}
+}
+
+func assignJSONPath(ctx context.Context, assign interface{}, value interface{}) error {
+	if assign == nil {
+		return nil
+	}
+
+	vars := variablesOf(ctx)
+	values, err := replaceParamsJSONPath(assign, value, contextObject(ctx), vars.all())
+	if err != nil {
+		return fmt.Errorf("Assign Error: %v", err)
+	}
+
+	vars.assign(values.(map[string]interface{}))
+	return nil
+}
+
+func assignJSONata(ctx context.Context, assign interface{}, input interface{}, states map[string]interface{}) error {
+	if assign == nil {
+		return nil
+	}
+
+	values, err := evalJSONata(assign, input, statesVars(ctx, states))
+	if err != nil {
+		return fmt.Errorf("Assign Error: %v", err)
+	}
+
+	variablesOf(ctx).assign(values.(map[string]interface{}))
+	return nil
+}
+
+// assignValid checks Assign is an object of valid variable names
+func assignValid(assign interface{}) error {
+	if assign == nil {
+		return nil
+	}
+
+	values, ok := assign.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Assign must be an object")
+	}
+
+	for key := range values {
+		name := strings.TrimSuffix(key, ".$")
+		if name == "" || !isVariableName(name) {
+			return fmt.Errorf("Assign invalid variable name %q", name)
+		}
+
+		if name == "states" {
+			return fmt.Errorf("Assign variable name \"states\" is reserved")
+		}
+	}
+
+	return nil
+}
+
+func isVariableName(name string) bool {
+	for i, c := range name {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func catchAssignValid(catch []*Catcher) error {
+	for _, c := range catch {
+		if err := assignValid(c.Assign); err != nil {
+			return err
+		}
+	}
+	return nil
+}
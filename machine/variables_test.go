@@ -0,0 +1,193 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/coinbase/step/handler"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Variables_JSONPath(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"StartAt": "Start",
+		"States": {
+			"Start": {
+				"Type": "Pass",
+				"Assign": {"limit": 10, "user.$": "$.user", "old": "first"},
+				"Next": "Swap"
+			},
+			"Swap": {
+				"Type": "Pass",
+				"Assign": {"old": "second", "previous.$": "$old"},
+				"Next": "Check"
+			},
+			"Check": {
+				"Type": "Choice",
+				"Choices": [{
+					"Variable": "$limit",
+					"NumericGreaterThan": 5,
+					"Assign": {"big": true},
+					"Next": "Report"
+				}],
+				"Default": "Report"
+			},
+			"Report": {
+				"Type": "Task",
+				"Resource": "report",
+				"Parameters": {
+					"name.$": "$user.name",
+					"previous.$": "$previous",
+					"limit.$": "$limit"
+				},
+				"ResultPath": "$.report",
+				"End": true
+			}
+		}
+	}`))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.SetTaskHandler("Report", ReturnInputHandler))
+
+	exec, err := sm.Execute(map[string]interface{}{"user": map[string]interface{}{"name": "step"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"name":     "step",
+		"previous": "first", // Assign reads the values from before the state
+		"limit":    float64(10),
+	}, exec.Output["report"])
+
+	assert.Equal(t, map[string]interface{}{
+		"limit":    float64(10),
+		"user":     map[string]interface{}{"name": "step"},
+		"old":      "second",
+		"previous": "first",
+		"big":      true,
+	}, exec.Variables())
+
+	// Assigned variables are recorded on the exited events
+	assigned := []map[string]*string{}
+	for _, event := range exec.ExecutionHistory {
+		if event.AssignedVariables != nil {
+			assigned = append(assigned, event.AssignedVariables)
+		}
+	}
+
+	assert.Equal(t, 3, len(assigned))
+	assert.Equal(t, `{"name":"step"}`, *assigned[0]["user"])
+	assert.Equal(t, `"first"`, *assigned[1]["previous"])
+	assert.Equal(t, `true`, *assigned[2]["big"])
+}
+
+func Test_Variables_Map_Scope(t *testing.T) {
+	sm_json := func(inner_assign string) []byte {
+		return []byte(`{
+			"StartAt": "Start",
+			"States": {
+				"Start": {"Type": "Pass", "Assign": {"prefix": "item-"}, "Next": "Each"},
+				"Each": {
+					"Type": "Map",
+					"ItemsPath": "$.items",
+					"ItemSelector": {"prefix.$": "$prefix", "id.$": "$$.Map.Item.Value.id"},
+					"ItemProcessor": {
+						"StartAt": "Name",
+						"States": {
+							"Name": {
+								"Type": "Pass",
+								"Assign": ` + inner_assign + `,
+								"End": true
+							}
+						}
+					},
+					"ResultPath": "$.names",
+					"End": true
+				}
+			}
+		}`)
+	}
+
+	input := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"id": "a"},
+		map[string]interface{}{"id": "b"},
+	}}
+
+	// Inner scopes read outer variables, their own variables do not leak out
+	sm, err := FromJSON(sm_json(`{"inner": true}`))
+	assert.NoError(t, err)
+
+	exec, err := sm.Execute(input)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"prefix": "item-", "id": "a"},
+		{"prefix": "item-", "id": "b"},
+	}, exec.Output["names"])
+	assert.Equal(t, map[string]interface{}{"prefix": "item-"}, exec.Variables())
+
+	// Inner scopes cannot assign outer variables
+	sm, err = FromJSON(sm_json(`{"prefix": "x"}`))
+	assert.NoError(t, err)
+
+	_, err = sm.Execute(input)
+	assert.Error(t, err)
+	assert.Regexp(t, "cannot assign \\$prefix of an outer scope", err.Error())
+}
+
+func Test_Variables_JSONata(t *testing.T) {
+	sm, err := FromJSON([]byte(`{
+		"QueryLanguage": "JSONata",
+		"StartAt": "Greet",
+		"States": {
+			"Greet": {
+				"Type": "Task",
+				"Resource": "arn:aws:lambda:us-east-1:000000000000:function:greet",
+				"Arguments": {"Task": "Greet", "Input": {"Name": "{% $states.input.user %}"}},
+				"Assign": {"greeting": "{% $states.result.greeting %}", "attempts": 1},
+				"Catch": [{
+					"ErrorEquals": ["States.ALL"],
+					"Assign": {"failure": "{% $states.errorOutput.Cause %}"},
+					"Next": "Check"
+				}],
+				"Next": "Check"
+			},
+			"Check": {
+				"Type": "Choice",
+				"Choices": [{"Condition": "{% $exists($greeting) %}", "Next": "Done"}],
+				"Default": "Failed"
+			},
+			"Done": {"Type": "Succeed", "Output": {"message": "{% $greeting %}"}},
+			"Failed": {"Type": "Fail", "Error": "GreetFailed", "Cause": "{% $failure %}"}
+		}
+	}`))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.SetTaskFnHandlers(&handler.TaskHandlers{"Greet": greetHandler}))
+
+	exec, err := sm.Execute(map[string]interface{}{"user": "step"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"message": "hello step"}, exec.Output)
+	assert.Equal(t, float64(1), exec.Variables()["attempts"])
+
+	// The state Assign is not applied when the error is caught
+	exec, err = sm.Execute(map[string]interface{}{"user": "bad"})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"Greet", "Check", "Failed"}, exec.Path())
+	assert.Equal(t, map[string]interface{}{"failure": "bad name"}, exec.Variables())
+}
+
+func Test_Variables_Validate(t *testing.T) {
+	invalid := map[string]string{
+		`{"StartAt": "A", "States": {"A": {"Type": "Pass", "Assign": [], "End": true}}}`:                                                                          "Assign must be an object",
+		`{"StartAt": "A", "States": {"A": {"Type": "Pass", "Assign": {"1a": 1}, "End": true}}}`:                                                                   "Assign invalid variable name",
+		`{"StartAt": "A", "States": {"A": {"Type": "Pass", "Assign": {"a-b.$": "$"}, "End": true}}}`:                                                              "Assign invalid variable name",
+		`{"StartAt": "A", "States": {"A": {"Type": "Pass", "Assign": {"states": 1}, "End": true}}}`:                                                               "reserved",
+		`{"StartAt": "A", "States": {"A": {"Type": "Task", "Resource": "x", "End": true, "Catch": [{"ErrorEquals": ["States.ALL"], "Assign": 1, "Next": "A"}]}}}`: "Assign must be an object",
+	}
+
+	for sm_json, message := range invalid {
+		err := Validate(&sm_json)
+		if assert.Error(t, err, sm_json) {
+			assert.Contains(t, err.Error(), message, sm_json)
+		}
+	}
+
+	valid := `{"StartAt": "A", "States": {"A": {"Type": "Pass", "Assign": {"a_1": 1, "b.$": "$.b"}, "End": true}}}`
+	assert.NoError(t, Validate(&valid))
+}
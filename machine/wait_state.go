@@ -24,6 +24,10 @@ type WaitState struct {
 	Timestamp     *time.Time     `json:",omitempty"`
 	TimestampPath *jsonpath.Path `json:",omitempty"`
 
+	Output interface{} `json:",omitempty"` // JSONata only
+
+	Assign interface{} `json:",omitempty"`
+
 	Next *string `json:",omitempty"`
 	End  *bool   `json:",omitempty"`
 }
@@ -52,11 +56,17 @@ func (s *WaitState) process(ctx context.Context, input interface{}) (interface{}
 }
 
 func (s *WaitState) Execute(ctx context.Context, input interface{}) (output interface{}, next *string, err error) {
+	if s.isJSONata() {
+		return processError(s,
+			jsonataIO(nil, s.Output, s.Assign, s.process),
+		)(ctx, input)
+	}
+
 	return processError(s,
 		inputOutput(
 			s.InputPath,
 			s.OutputPath,
-			s.process,
+			withAssign(s.Assign, s.process),
 		),
 	)(ctx, input)
 }
@@ -91,6 +101,21 @@ func (s *WaitState) Validate() error {
 		return fmt.Errorf("%v Exactly One (Seconds,SecondsPath,TimeStamp,TimeStampPath)", errorPrefix(s))
 	}
 
+	if err := assignValid(s.Assign); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
+	if err := s.fieldsValid(map[string]bool{
+		"InputPath":     s.InputPath != nil,
+		"OutputPath":    s.OutputPath != nil,
+		"SecondsPath":   s.SecondsPath != nil,
+		"TimestampPath": s.TimestampPath != nil,
+	}, map[string]bool{
+		"Output": s.Output != nil,
+	}); err != nil {
+		return fmt.Errorf("%v %v", errorPrefix(s), err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/coinbase/step/utils/is"
+)
+
+// Config is the `step serve` configuration file,
+// Tasks maps a Task Resource to how it is handled ("" for any other Resource), e.g.
+//
+//	{"Tasks": {
+//	  "arn:aws:lambda:us-east-1:000000000000:function:deploy": {"URL": "http://localhost:9000/2015-03-31/functions/function/invocations"},
+//	  "arn:aws:lambda:us-east-1:000000000000:function:notify": {"Output": {"sent": true}},
+//	  "arn:aws:lambda:us-east-1:000000000000:function:broken": {"Error": "BrokenError", "Cause": "always fails"}
+//	}}
+type Config struct {
+	Tasks map[string]*TaskConfig
+}
+
+// TaskConfig either invokes a Lambda compatible URL with the Task input,
+// returns the static Output, or fails with Error and Cause
+type TaskConfig struct {
+	URL    *string
+	Output interface{}
+	Error  *string
+	Cause  *string
+}
+
+// LoadConfig reads and validates a Config file
+func LoadConfig(file string) (*Config, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func (c *Config) Validate() error {
+	for resource, task := range c.Tasks {
+		if task == nil {
+			return fmt.Errorf("Task %q must not be null", resource)
+		}
+
+		defined := 0
+		if task.URL != nil {
+			defined++
+		}
+		if task.Output != nil {
+			defined++
+		}
+		if task.Error != nil {
+			defined++
+		}
+
+		if defined != 1 {
+			return fmt.Errorf("Task %q must have exactly one of URL, Output or Error", resource)
+		}
+
+		if task.URL != nil && is.EmptyStr(task.URL) {
+			return fmt.Errorf("Task %q URL is empty", resource)
+		}
+	}
+
+	return nil
+}
+
+// Handlers returns the handler function of each Task Resource
+func (c *Config) Handlers() map[string]interface{} {
+	handlers := map[string]interface{}{}
+	for resource, task := range c.Tasks {
+		handlers[resource] = task.handler()
+	}
+	return handlers
+}
+
+func (t *TaskConfig) handler() interface{} {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		switch {
+		case t.URL != nil:
+			return invokeURL(ctx, *t.URL, input)
+		case t.Error != nil:
+			cause := ""
+			if t.Cause != nil {
+				cause = *t.Cause
+			}
+			return nil, &taskError{*t.Error, cause}
+		}
+		return t.Output, nil
+	}
+}
+
+// lambdaError is the body of a failed Lambda invocation
+type lambdaError struct {
+	ErrorType    *string `json:"errorType"`
+	ErrorMessage *string `json:"errorMessage"`
+}
+
+// invokeURL POSTs the input like a Lambda Invoke, e.g. to the Lambda Runtime Interface Emulator
+func invokeURL(ctx context.Context, url string, input interface{}) (interface{}, error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 || resp.Header.Get("X-Amz-Function-Error") != "" {
+		var le lambdaError
+		if err := json.Unmarshal(raw, &le); err != nil || le.ErrorType == nil {
+			return nil, &taskError{"States.TaskFailed", fmt.Sprintf("%v %v", resp.Status, string(raw))}
+		}
+
+		message := ""
+		if le.ErrorMessage != nil {
+			message = *le.ErrorMessage
+		}
+		return nil, &taskError{*le.ErrorType, message}
+	}
+
+	var output interface{}
+	if err := json.Unmarshal(raw, &output); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coinbase/step/handler"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Config_Handlers(t *testing.T) {
+	lambda := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) == `{"fail":true}` {
+			w.Header().Set("X-Amz-Function-Error", "Unhandled")
+			w.Write([]byte(`{"errorType": "BadInput", "errorMessage": "fail was true"}`))
+			return
+		}
+		w.Write(body)
+	}))
+	defer lambda.Close()
+
+	dir, err := ioutil.TempDir("", "step-serve")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`{"Tasks": {
+		"echo": {"URL": "`+lambda.URL+`"},
+		"static": {"Output": {"a": 1}},
+		"broken": {"Error": "Broken", "Cause": "always"}
+	}}`), 0644))
+
+	config, err := LoadConfig(file)
+	assert.NoError(t, err)
+	handlers := config.Handlers()
+
+	output, err := handler.CallHandlerFunction(handlers["echo"], context.Background(), map[string]interface{}{"b": 2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"b": float64(2)}, output)
+
+	_, err = handler.CallHandlerFunction(handlers["echo"], context.Background(), map[string]interface{}{"fail": true})
+	assert.Equal(t, &taskError{"BadInput", "fail was true"}, err)
+
+	output, err = handler.CallHandlerFunction(handlers["static"], context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, output)
+
+	_, err = handler.CallHandlerFunction(handlers["broken"], context.Background(), nil)
+	assert.Equal(t, &taskError{"Broken", "always"}, err)
+}
+
+func Test_Config_Validate(t *testing.T) {
+	config := &Config{Tasks: map[string]*TaskConfig{"x": {}}}
+	assert.Error(t, config.Validate())
+
+	config = &Config{Tasks: map[string]*TaskConfig{"x": {Output: 1, Error: new(string)}}}
+	assert.Error(t, config.Validate())
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/execution"
+)
+
+// Server serves the Step Functions JSON protocol (X-Amz-Target: AWSStepFunctions.<Operation>)
+// so sfn clients can use SFN by setting their Endpoint to the server address
+type Server struct {
+	SFN *SFN
+}
+
+// NewServer returns a Server with an empty SFN that uses handlers for Task Resources
+func NewServer(region *string, account_id *string, handlers map[string]interface{}) *Server {
+	s := NewSFN(region, account_id)
+	for resource, handler := range handlers {
+		s.Handlers[resource] = handler
+	}
+	return &Server{SFN: s}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "UnknownOperationException", "Only POST is supported")
+		return
+	}
+
+	target := r.Header.Get("X-Amz-Target")
+	if !strings.HasPrefix(target, "AWSStepFunctions.") {
+		writeError(w, http.StatusBadRequest, "UnknownOperationException", fmt.Sprintf("Unknown X-Amz-Target %q", target))
+		return
+	}
+
+	output, err := s.call(strings.TrimPrefix(target, "AWSStepFunctions."), r)
+
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			writeError(w, http.StatusBadRequest, aerr.Code(), aerr.Message())
+		} else {
+			writeError(w, http.StatusInternalServerError, "InternalFailure", err.Error())
+		}
+		return
+	}
+
+	body, err := jsonutil.BuildJSON(output)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalFailure", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Write(body)
+}
+
+// call decodes the input of the operation and calls it on SFN
+func (s *Server) call(operation string, r *http.Request) (interface{}, error) {
+	switch operation {
+	case "CreateStateMachine":
+		in := &sfn.CreateStateMachineInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.CreateStateMachine(in)
+	case "UpdateStateMachine":
+		in := &sfn.UpdateStateMachineInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.UpdateStateMachine(in)
+	case "DescribeStateMachine":
+		in := &sfn.DescribeStateMachineInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.DescribeStateMachine(in)
+	case "StartExecution":
+		in := &sfn.StartExecutionInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.StartExecution(in)
+	case "DescribeExecution":
+		in := &sfn.DescribeExecutionInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.DescribeExecution(in)
+	case "GetExecutionHistory":
+		in := &sfn.GetExecutionHistoryInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.GetExecutionHistory(in)
+	case "ListExecutions":
+		in := &sfn.ListExecutionsInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.ListExecutions(in)
+	case "StopExecution":
+		in := &sfn.StopExecutionInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.StopExecution(in)
+	case "RedriveExecution":
+		in := &execution.RedriveExecutionInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.RedriveExecution(in)
+	case "SendTaskSuccess":
+		in := &sfn.SendTaskSuccessInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.SendTaskSuccess(in)
+	case "SendTaskFailure":
+		in := &sfn.SendTaskFailureInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.SendTaskFailure(in)
+	case "SendTaskHeartbeat":
+		in := &sfn.SendTaskHeartbeatInput{}
+		if err := decode(r, in); err != nil {
+			return nil, err
+		}
+		return s.SFN.SendTaskHeartbeat(in)
+	}
+
+	return nil, awserr.New("UnknownOperationException", fmt.Sprintf("Unsupported Operation %q", operation), nil)
+}
+
+// decode reads the request body into an sfn input struct and validates it
+func decode(r *http.Request, in interface{ Validate() error }) error {
+	if err := jsonutil.UnmarshalJSON(in, r.Body); err != nil {
+		return awserr.New("SerializationException", err.Error(), nil)
+	}
+
+	if err := in.Validate(); err != nil {
+		return awserr.New("ValidationException", err.Error(), nil)
+	}
+
+	return nil
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	body, _ := json.Marshal(map[string]string{"__type": code, "message": message})
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(status)
+	w.Write(body)
+}
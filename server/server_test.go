@@ -0,0 +1,422 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/execution"
+	"github.com/coinbase/step/handler"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+var greetMachine = `{
+	"StartAt": "Greet",
+	"States": {
+		"Greet": {
+			"Type": "TaskFn",
+			"Resource": "arn:aws:lambda:us-east-1:000000000000:function:greeter",
+			"Catch": [{"ErrorEquals": ["States.ALL"], "Next": "Failed"}],
+			"End": true
+		},
+		"Failed": {"Type": "Fail", "Error": "GreetFailed", "Cause": "could not greet"}
+	}
+}`
+
+type greetInput struct {
+	Name *string
+}
+
+func greetHandler(_ context.Context, input *greetInput) (map[string]interface{}, error) {
+	if input.Name == nil {
+		return nil, &taskError{"NoName", "name missing"}
+	}
+	return map[string]interface{}{"Greeting": "hello " + *input.Name}, nil
+}
+
+func testServer(t *testing.T) (*sfn.SFN, *Server, func()) {
+	greeter, err := handler.CreateHandler(&handler.TaskHandlers{"Greet": greetHandler})
+	assert.NoError(t, err)
+
+	s := NewServer(to.Strp("us-east-1"), to.Strp("000000000000"), map[string]interface{}{
+		"arn:aws:lambda:us-east-1:000000000000:function:greeter": greeter,
+	})
+
+	ts := httptest.NewServer(s)
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(ts.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+
+	return sfn.New(sess), s, ts.Close
+}
+
+func createMachine(t *testing.T, sfnc *sfn.SFN, name string, definition string) *string {
+	out, err := sfnc.CreateStateMachine(&sfn.CreateStateMachineInput{
+		Name:       to.Strp(name),
+		Definition: to.Strp(definition),
+		RoleArn:    to.Strp("arn:aws:iam::000000000000:role/step"),
+	})
+	assert.NoError(t, err)
+	return out.StateMachineArn
+}
+
+func waitFor(t *testing.T, sfnc *sfn.SFN, arn *string) *execution.Execution {
+	exec := &execution.Execution{ExecutionArn: arn}
+	exec.WaitForExecution(sfnc, 0, func(_ *execution.Execution, _ *execution.StateDetails, err error) error {
+		return err
+	})
+	return exec
+}
+
+func Test_Server_StateMachine(t *testing.T) {
+	sfnc, _, close := testServer(t)
+	defer close()
+
+	arn := createMachine(t, sfnc, "greeter", greetMachine)
+	assert.Equal(t, "arn:aws:states:us-east-1:000000000000:stateMachine:greeter", *arn)
+
+	_, err := sfnc.UpdateStateMachine(&sfn.UpdateStateMachineInput{
+		StateMachineArn: arn,
+		Definition:      to.Strp(`{"StartAt": "A", "States": {"A": {"Type": "Succeed"}}}`),
+	})
+	assert.NoError(t, err)
+
+	out, err := sfnc.DescribeStateMachine(&sfn.DescribeStateMachineInput{StateMachineArn: arn})
+	assert.NoError(t, err)
+	assert.Equal(t, "greeter", *out.Name)
+	assert.Equal(t, "ACTIVE", *out.Status)
+	assert.Equal(t, `{"StartAt": "A", "States": {"A": {"Type": "Succeed"}}}`, *out.Definition)
+
+	// Errors are returned with their Step Functions codes
+	_, err = sfnc.UpdateStateMachine(&sfn.UpdateStateMachineInput{
+		StateMachineArn: arn,
+		Definition:      to.Strp(`{"StartAt": "A"}`),
+	})
+	assert.Equal(t, sfn.ErrCodeInvalidDefinition, err.(awserr.Error).Code())
+
+	_, err = sfnc.DescribeStateMachine(&sfn.DescribeStateMachineInput{StateMachineArn: to.Strp("arn:aws:states:us-east-1:000000000000:stateMachine:missing")})
+	assert.Equal(t, sfn.ErrCodeStateMachineDoesNotExist, err.(awserr.Error).Code())
+
+	_, err = sfnc.CreateStateMachine(&sfn.CreateStateMachineInput{
+		Name:       to.Strp("greeter"),
+		Definition: to.Strp(greetMachine),
+		RoleArn:    to.Strp("arn:aws:iam::000000000000:role/other"),
+	})
+	assert.Equal(t, sfn.ErrCodeStateMachineAlreadyExists, err.(awserr.Error).Code())
+}
+
+func Test_Server_Execution(t *testing.T) {
+	sfnc, _, close := testServer(t)
+	defer close()
+
+	arn := createMachine(t, sfnc, "greeter", greetMachine)
+
+	started, err := execution.StartExecution(sfnc, arn, to.Strp("succeeds"), map[string]interface{}{"Name": "step"})
+	assert.NoError(t, err)
+	exec := waitFor(t, sfnc, started.ExecutionArn)
+
+	assert.Equal(t, "SUCCEEDED", *exec.Status)
+	assert.JSONEq(t, `{"Greeting": "hello step"}`, *exec.Output)
+
+	history, err := sfnc.GetExecutionHistory(&sfn.GetExecutionHistoryInput{ExecutionArn: started.ExecutionArn})
+	assert.NoError(t, err)
+
+	types := []string{}
+	for _, he := range history.Events {
+		types = append(types, *he.Type)
+	}
+	assert.Equal(t, []string{"ExecutionStarted", "TaskStateEntered", "TaskStateExited", "ExecutionSucceeded"}, types)
+	assert.Equal(t, int64(4), *history.Events[3].Id)
+	assert.Equal(t, int64(3), *history.Events[3].PreviousEventId)
+
+	// execution.GetDetails reads the reversed history
+	_, sd, err := execution.GetDetails(sfnc, started.ExecutionArn)
+	assert.NoError(t, err)
+	assert.Equal(t, "Greet", *sd.LastTaskName)
+
+	// Failures record the Fail state Error and Cause
+	started, err = execution.StartExecution(sfnc, arn, to.Strp("fails"), map[string]interface{}{})
+	assert.NoError(t, err)
+	exec = waitFor(t, sfnc, started.ExecutionArn)
+	assert.Equal(t, "FAILED", *exec.Status)
+
+	history, err = sfnc.GetExecutionHistory(&sfn.GetExecutionHistoryInput{ExecutionArn: started.ExecutionArn, ReverseOrder: to.Boolp(true)})
+	assert.NoError(t, err)
+	assert.Equal(t, "GreetFailed", *history.Events[0].ExecutionFailedEventDetails.Error)
+	assert.Equal(t, "could not greet", *history.Events[0].ExecutionFailedEventDetails.Cause)
+
+	// Names are unique
+	_, err = execution.StartExecution(sfnc, arn, to.Strp("fails"), map[string]interface{}{})
+	assert.Equal(t, sfn.ErrCodeExecutionAlreadyExists, err.(awserr.Error).Code())
+
+	list, err := sfnc.ListExecutions(&sfn.ListExecutionsInput{StateMachineArn: arn})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list.Executions))
+	assert.Equal(t, "fails", *list.Executions[0].Name)
+
+	list, err = sfnc.ListExecutions(&sfn.ListExecutionsInput{StateMachineArn: arn, StatusFilter: to.Strp("SUCCEEDED")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(list.Executions))
+	assert.Equal(t, "succeeds", *list.Executions[0].Name)
+}
+
+func Test_Server_TaskToken(t *testing.T) {
+	sfnc, s, close := testServer(t)
+	defer close()
+
+	tokens := make(chan string, 1)
+	s.SFN.Handlers["arn:aws:states:::sqs:sendMessage.waitForTaskToken"] = func(_ context.Context, input map[string]string) (interface{}, error) {
+		tokens <- input["Token"]
+		return nil, nil
+	}
+
+	arn := createMachine(t, sfnc, "approval", `{
+		"StartAt": "Approve",
+		"States": {
+			"Approve": {
+				"Type": "Task",
+				"Resource": "arn:aws:states:::sqs:sendMessage.waitForTaskToken",
+				"Parameters": {"Token.$": "$$.Task.Token"},
+				"End": true
+			}
+		}
+	}`)
+
+	// SendTaskSuccess sends the output to the waiting Task
+	started, err := execution.StartExecution(sfnc, arn, to.Strp("approved"), map[string]interface{}{})
+	assert.NoError(t, err)
+
+	token := <-tokens
+
+	// The history of the running execution has the state it is waiting in
+	history, err := sfnc.GetExecutionHistory(&sfn.GetExecutionHistoryInput{ExecutionArn: started.ExecutionArn})
+	assert.NoError(t, err)
+
+	types := []string{}
+	for _, he := range history.Events {
+		types = append(types, *he.Type)
+	}
+	assert.Equal(t, []string{"ExecutionStarted", "TaskStateEntered"}, types)
+	assert.Equal(t, "Approve", *history.Events[1].StateEnteredEventDetails.Name)
+	assert.Equal(t, int64(2), *history.Events[1].Id)
+
+	_, err = sfnc.SendTaskHeartbeat(&sfn.SendTaskHeartbeatInput{TaskToken: &token})
+	assert.NoError(t, err)
+
+	_, err = sfnc.SendTaskSuccess(&sfn.SendTaskSuccessInput{TaskToken: &token, Output: to.Strp(`{"approved": true}`)})
+	assert.NoError(t, err)
+
+	exec := waitFor(t, sfnc, started.ExecutionArn)
+	assert.Equal(t, "SUCCEEDED", *exec.Status)
+	assert.JSONEq(t, `{"approved": true}`, *exec.Output)
+
+	// The machine execution ID is the name at the end of the execution ARN
+	s.SFN.mu.Lock()
+	assert.Equal(t, "approved", s.SFN.executions[*started.ExecutionArn].result.ID)
+	s.SFN.mu.Unlock()
+
+	_, err = sfnc.SendTaskSuccess(&sfn.SendTaskSuccessInput{TaskToken: &token, Output: to.Strp(`{}`)})
+	assert.Equal(t, sfn.ErrCodeTaskDoesNotExist, err.(awserr.Error).Code())
+
+	// SendTaskFailure fails the Task
+	started, err = execution.StartExecution(sfnc, arn, to.Strp("rejected"), map[string]interface{}{})
+	assert.NoError(t, err)
+
+	token = <-tokens
+	_, err = sfnc.SendTaskFailure(&sfn.SendTaskFailureInput{TaskToken: &token, Error: to.Strp("Rejected"), Cause: to.Strp("no")})
+	assert.NoError(t, err)
+
+	exec = waitFor(t, sfnc, started.ExecutionArn)
+	assert.Equal(t, "FAILED", *exec.Status)
+
+	// StopExecution aborts a waiting execution
+	started, err = execution.StartExecution(sfnc, arn, to.Strp("stopped"), map[string]interface{}{})
+	assert.NoError(t, err)
+	<-tokens
+
+	_, err = sfnc.StopExecution(&sfn.StopExecutionInput{ExecutionArn: started.ExecutionArn, Cause: to.Strp("cancelled")})
+	assert.NoError(t, err)
+
+	// The aborted status is kept after the Task returns
+	time.Sleep(10 * time.Millisecond)
+	exec = waitFor(t, sfnc, started.ExecutionArn)
+	assert.Equal(t, "ABORTED", *exec.Status)
+}
+
+var redriveMachine = `{
+	"StartAt": "Prepare",
+	"States": {
+		"Prepare": {"Type": "Pass", "Result": "prepared", "ResultPath": "$.prepare", "Next": "Greet"},
+		"Greet": {"Type": "Task", "Resource": "greeter", "End": true}
+	}
+}`
+
+func Test_Server_Redrive(t *testing.T) {
+	sfnc, s, close := testServer(t)
+	defer close()
+
+	s.SFN.Handlers["greeter"] = func(_ context.Context, input interface{}) (interface{}, error) {
+		return nil, &taskError{"Broken", "greeter is broken"}
+	}
+
+	arn := createMachine(t, sfnc, "redrive", redriveMachine)
+	started, err := execution.StartExecution(sfnc, arn, to.Strp("redrive"), map[string]interface{}{"Name": "step"})
+	assert.NoError(t, err)
+	exec := waitFor(t, sfnc, started.ExecutionArn)
+	assert.Equal(t, "FAILED", *exec.Status)
+
+	// Redrive runs Greet again with the fixed handler
+	s.SFN.mu.Lock()
+	s.SFN.Handlers["greeter"] = func(_ context.Context, input interface{}) (interface{}, error) {
+		return input, nil
+	}
+	s.SFN.mu.Unlock()
+
+	_, err = execution.Redrive(sfnc, started.ExecutionArn)
+	assert.NoError(t, err)
+	exec = waitFor(t, sfnc, started.ExecutionArn)
+	assert.Equal(t, "SUCCEEDED", *exec.Status)
+	assert.JSONEq(t, `{"Name": "step", "prepare": "prepared"}`, *exec.Output)
+
+	history, err := sfnc.GetExecutionHistory(&sfn.GetExecutionHistoryInput{ExecutionArn: started.ExecutionArn})
+	assert.NoError(t, err)
+
+	types := []string{}
+	for _, he := range history.Events {
+		types = append(types, *he.Type)
+	}
+	assert.Equal(t, []string{
+		"ExecutionStarted",
+		"PassStateEntered", "PassStateExited",
+		"TaskStateEntered", "TaskStateExited", "ExecutionFailed",
+		"ExecutionRedriven",
+		"TaskStateEntered", "TaskStateExited", "ExecutionSucceeded",
+	}, types)
+
+	// Only failed executions can be redriven
+	_, err = execution.Redrive(sfnc, started.ExecutionArn)
+	assert.Equal(t, "ExecutionNotRedrivable", err.(awserr.Error).Code())
+}
+
+func Test_Server_Pagination(t *testing.T) {
+	sfnc, _, close := testServer(t)
+	defer close()
+
+	arn := createMachine(t, sfnc, "empty", `{"StartAt": "A", "States": {"A": {"Type": "Succeed"}}}`)
+	for i := 0; i < 5; i++ {
+		_, err := sfnc.StartExecution(&sfn.StartExecutionInput{StateMachineArn: arn})
+		assert.NoError(t, err)
+	}
+
+	out, err := sfnc.ListExecutions(&sfn.ListExecutionsInput{StateMachineArn: arn, MaxResults: to.Int64p(2)})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(out.Executions))
+	assert.Equal(t, "2", *out.NextToken)
+
+	out, err = sfnc.ListExecutions(&sfn.ListExecutionsInput{StateMachineArn: arn, MaxResults: to.Int64p(2), NextToken: to.Strp("4")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(out.Executions))
+	assert.Nil(t, out.NextToken)
+
+	_, err = sfnc.ListExecutions(&sfn.ListExecutionsInput{StateMachineArn: arn, NextToken: to.Strp("bad")})
+	assert.Equal(t, sfn.ErrCodeInvalidToken, err.(awserr.Error).Code())
+}
+
+func Test_Server_UnknownOperation(t *testing.T) {
+	_, s, close := testServer(t)
+	defer close()
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("X-Amz-Target", "AWSStepFunctions.DeleteStateMachine")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"__type": "UnknownOperationException", "message": "Unsupported Operation \"DeleteStateMachine\""}`, w.Body.String())
+}
+
+func Test_Server_Execution_Failed_Details(t *testing.T) {
+	sfnc, s, close := testServer(t)
+	defer close()
+
+	s.SFN.Handlers["broken"] = func(_ context.Context, input interface{}) (interface{}, error) {
+		return nil, &taskError{"MyError", "boom"}
+	}
+
+	arn := createMachine(t, sfnc, "failing", `{
+		"StartAt": "T",
+		"States": {"T": {"Type": "Task", "Resource": "broken", "End": true}}
+	}`)
+
+	started, err := execution.StartExecution(sfnc, arn, to.Strp("failing"), map[string]interface{}{})
+	assert.NoError(t, err)
+	exec := waitFor(t, sfnc, started.ExecutionArn)
+	assert.Equal(t, "FAILED", *exec.Status)
+
+	history, err := sfnc.GetExecutionHistory(&sfn.GetExecutionHistoryInput{ExecutionArn: started.ExecutionArn})
+	assert.NoError(t, err)
+
+	failed := history.Events[len(history.Events)-1].ExecutionFailedEventDetails
+	assert.Equal(t, "MyError", *failed.Error)
+	assert.Equal(t, "boom", *failed.Cause)
+}
+
+func Test_Server_StopExecution_Stops_States(t *testing.T) {
+	sfnc, s, close := testServer(t)
+	defer close()
+
+	started := make(chan bool, 1)
+	stopped := make(chan bool, 1)
+	after := make(chan bool, 1)
+
+	s.SFN.Handlers["slow"] = func(ctx context.Context, input interface{}) (interface{}, error) {
+		started <- true
+		<-ctx.Done()
+		stopped <- true
+		return input, nil
+	}
+	s.SFN.Handlers["after"] = func(_ context.Context, input interface{}) (interface{}, error) {
+		after <- true
+		return input, nil
+	}
+
+	arn := createMachine(t, sfnc, "stoppable", `{
+		"StartAt": "Slow",
+		"States": {
+			"Slow": {"Type": "Task", "Resource": "slow", "Next": "After"},
+			"After": {"Type": "Task", "Resource": "after", "End": true}
+		}
+	}`)
+
+	out, err := execution.StartExecution(sfnc, arn, to.Strp("stoppable"), map[string]interface{}{})
+	assert.NoError(t, err)
+	<-started
+
+	_, err = sfnc.StopExecution(&sfn.StopExecutionInput{ExecutionArn: out.ExecutionArn})
+	assert.NoError(t, err)
+
+	// The execution context is cancelled so Slow returns, and After never runs
+	<-stopped
+	time.Sleep(10 * time.Millisecond)
+
+	select {
+	case <-after:
+		t.Fatal("After ran in a stopped execution")
+	default:
+	}
+
+	exec := waitFor(t, sfnc, out.ExecutionArn)
+	assert.Equal(t, "ABORTED", *exec.Status)
+}
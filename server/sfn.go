@@ -0,0 +1,688 @@
+// server is a local Step Functions service that executes state machines with the machine interpreter
+package server
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+	"github.com/coinbase/step/errors"
+	"github.com/coinbase/step/execution"
+	"github.com/coinbase/step/handler"
+	"github.com/coinbase/step/machine"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// SFN is an in-memory Step Functions service,
+// executions run in the background with the machine interpreter
+type SFN struct {
+	sfniface.SFNAPI // operations that are not implemented panic
+
+	Region    *string
+	AccountID *string
+
+	// Handlers maps a Task Resource to its handler function,
+	// the "" handler is used for Resources without their own handler
+	Handlers map[string]interface{}
+
+	mu            sync.Mutex
+	stateMachines map[string]*stateMachineRecord
+	executions    map[string]*executionRecord
+	tokens        map[string]*taskToken
+}
+
+type stateMachineRecord struct {
+	arn          *string
+	name         *string
+	definition   *string
+	roleArn      *string
+	smType       *string
+	creationDate time.Time
+	executions   []*executionRecord // in start order
+}
+
+type executionRecord struct {
+	arn             *string
+	name            *string
+	stateMachineArn *string
+	input           *string
+	output          *string
+	status          string
+	startDate       time.Time
+	stopDate        *time.Time
+	history         []*sfn.HistoryEvent
+	cancel          context.CancelFunc // called by StopExecution
+
+	stateMachine *machine.StateMachine // with the execution's Task handlers
+	result       *machine.Execution    // of the last run, to redrive from
+}
+
+// taskToken is a waitForTaskToken Task waiting for SendTaskSuccess or SendTaskFailure
+type taskToken struct {
+	exec   *executionRecord
+	result chan taskResult
+}
+
+type taskResult struct {
+	output interface{}
+	err    error
+}
+
+// taskError is the error a Task fails with, Name is the ASL error name
+type taskError struct {
+	Name  string
+	Cause string
+}
+
+func (e *taskError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Name, e.Cause)
+}
+
+func (e *taskError) ErrorName() string {
+	return e.Name
+}
+
+func (e *taskError) ErrorCause() string {
+	return e.Cause
+}
+
+// NewSFN returns an empty SFN for the region and account
+func NewSFN(region *string, account_id *string) *SFN {
+	return &SFN{
+		Region:        region,
+		AccountID:     account_id,
+		Handlers:      map[string]interface{}{},
+		stateMachines: map[string]*stateMachineRecord{},
+		executions:    map[string]*executionRecord{},
+		tokens:        map[string]*taskToken{},
+	}
+}
+
+//////
+// State Machines
+//////
+
+func (s *SFN) CreateStateMachine(in *sfn.CreateStateMachineInput) (*sfn.CreateStateMachineOutput, error) {
+	if is.EmptyStr(in.Name) || is.EmptyStr(in.RoleArn) {
+		return nil, awserr.New(sfn.ErrCodeInvalidName, "Name and RoleArn are required", nil)
+	}
+
+	if err := definitionValid(in.Definition); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	arn := to.Strp(fmt.Sprintf("arn:aws:states:%v:%v:stateMachine:%v", *s.Region, *s.AccountID, *in.Name))
+
+	if sm, ok := s.stateMachines[*arn]; ok {
+		// Creating an identical State Machine is idempotent
+		if *sm.definition != *in.Definition || *sm.roleArn != *in.RoleArn {
+			return nil, awserr.New(sfn.ErrCodeStateMachineAlreadyExists, fmt.Sprintf("State Machine Already Exists: '%v'", *arn), nil)
+		}
+		return &sfn.CreateStateMachineOutput{StateMachineArn: sm.arn, CreationDate: to.Timep(sm.creationDate)}, nil
+	}
+
+	smType := in.Type
+	if smType == nil {
+		smType = to.Strp(sfn.StateMachineTypeStandard)
+	}
+
+	sm := &stateMachineRecord{
+		arn:          arn,
+		name:         in.Name,
+		definition:   in.Definition,
+		roleArn:      in.RoleArn,
+		smType:       smType,
+		creationDate: time.Now(),
+	}
+	s.stateMachines[*arn] = sm
+
+	return &sfn.CreateStateMachineOutput{StateMachineArn: sm.arn, CreationDate: to.Timep(sm.creationDate)}, nil
+}
+
+func (s *SFN) UpdateStateMachine(in *sfn.UpdateStateMachineInput) (*sfn.UpdateStateMachineOutput, error) {
+	if in.Definition != nil {
+		if err := definitionValid(in.Definition); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sm, err := s.stateMachine(in.StateMachineArn)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Definition != nil {
+		sm.definition = in.Definition
+	}
+
+	if in.RoleArn != nil {
+		sm.roleArn = in.RoleArn
+	}
+
+	return &sfn.UpdateStateMachineOutput{UpdateDate: to.Timep(time.Now())}, nil
+}
+
+func (s *SFN) DescribeStateMachine(in *sfn.DescribeStateMachineInput) (*sfn.DescribeStateMachineOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sm, err := s.stateMachine(in.StateMachineArn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sfn.DescribeStateMachineOutput{
+		StateMachineArn: sm.arn,
+		Name:            sm.name,
+		Definition:      sm.definition,
+		RoleArn:         sm.roleArn,
+		Type:            sm.smType,
+		Status:          to.Strp(sfn.StateMachineStatusActive),
+		CreationDate:    to.Timep(sm.creationDate),
+	}, nil
+}
+
+func (s *SFN) stateMachine(arn *string) (*stateMachineRecord, error) {
+	if arn != nil {
+		if sm, ok := s.stateMachines[*arn]; ok {
+			return sm, nil
+		}
+	}
+	return nil, awserr.New(sfn.ErrCodeStateMachineDoesNotExist, fmt.Sprintf("State Machine Does Not Exist: '%v'", to.Strs(arn)), nil)
+}
+
+func definitionValid(definition *string) error {
+	if is.EmptyStr(definition) {
+		return awserr.New(sfn.ErrCodeInvalidDefinition, "Definition is required", nil)
+	}
+
+	if err := machine.Validate(definition); err != nil {
+		return awserr.New(sfn.ErrCodeInvalidDefinition, err.Error(), nil)
+	}
+
+	return nil
+}
+
+//////
+// Executions
+//////
+
+func (s *SFN) StartExecution(in *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error) {
+	input := in.Input
+	if is.EmptyStr(input) {
+		input = to.Strp("{}")
+	}
+
+	var input_value map[string]interface{}
+	if err := json.Unmarshal([]byte(*input), &input_value); err != nil {
+		return nil, awserr.New(sfn.ErrCodeInvalidExecutionInput, err.Error(), nil)
+	}
+
+	name := in.Name
+	if is.EmptyStr(name) {
+		name = to.TimeUUID("execution-")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sm, err := s.stateMachine(in.StateMachineArn)
+	if err != nil {
+		return nil, err
+	}
+
+	arn := to.Strp(fmt.Sprintf("arn:aws:states:%v:%v:execution:%v:%v", *s.Region, *s.AccountID, *sm.name, *name))
+	if _, ok := s.executions[*arn]; ok {
+		return nil, awserr.New(sfn.ErrCodeExecutionAlreadyExists, fmt.Sprintf("Execution Already Exists: '%v'", *arn), nil)
+	}
+
+	state_machine, err := machine.FromJSON([]byte(*sm.definition))
+	if err != nil {
+		return nil, awserr.New(sfn.ErrCodeInvalidDefinition, err.Error(), nil)
+	}
+
+	exec := &executionRecord{
+		arn:             arn,
+		name:            name,
+		stateMachineArn: sm.arn,
+		input:           input,
+		status:          sfn.ExecutionStatusRunning,
+		startDate:       time.Now(),
+	}
+
+	started := newEvent(sfn.HistoryEventTypeExecutionStarted, exec.startDate)
+	started.ExecutionStartedEventDetails = &sfn.ExecutionStartedEventDetails{
+		Input:   input,
+		RoleArn: sm.roleArn,
+	}
+	exec.history = numberEvents([]*sfn.HistoryEvent{started})
+
+	exec.stateMachine = state_machine
+	for _, task := range state_machine.TaskStates() {
+		task.SetTaskHandler(s.taskHandler(exec, task.Resource))
+	}
+
+	// The history of the running execution is recorded as its states run
+	state_machine.SetEventListener(func(he machine.HistoryEvent) {
+		s.addEvent(exec, he)
+	})
+
+	s.executions[*arn] = exec
+	sm.executions = append(sm.executions, exec)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exec.cancel = cancel
+
+	go s.run(exec, func() (*machine.Execution, error) {
+		return state_machine.ExecuteWithContext(ctx, *name, exec.input)
+	})
+
+	return &sfn.StartExecutionOutput{ExecutionArn: exec.arn, StartDate: to.Timep(exec.startDate)}, nil
+}
+
+// addEvent adds a State event of the machine execution to the history unless the execution was stopped
+func (s *SFN) addEvent(exec *executionRecord, he machine.HistoryEvent) {
+	switch *he.Type {
+	case "ExecutionStarted", "ExecutionSucceeded", "ExecutionFailed", "ExecutionRedriven":
+		return // replaced with events that have details
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if exec.status != sfn.ExecutionStatusRunning {
+		return
+	}
+
+	event := he.HistoryEvent
+	exec.history = numberEvents(append(exec.history, &event))
+}
+
+// run executes the State Machine and records the result unless the execution was stopped
+func (s *SFN) run(exec *executionRecord, execute func() (*machine.Execution, error)) {
+	result, err := execute()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec.cancel()
+
+	s.removeTokens(exec)
+
+	if exec.status != sfn.ExecutionStatusRunning {
+		return
+	}
+
+	now := time.Now()
+	exec.stopDate = &now
+
+	exec.result = result
+
+	var final *sfn.HistoryEvent
+	if err != nil {
+		exec.status = sfn.ExecutionStatusFailed
+		final = newEvent(sfn.HistoryEventTypeExecutionFailed, now)
+		final.ExecutionFailedEventDetails = failedDetails(result, err)
+	} else {
+		exec.status = sfn.ExecutionStatusSucceeded
+		exec.output = to.Strp(result.OutputJSON)
+		final = newEvent(sfn.HistoryEventTypeExecutionSucceeded, now)
+		final.ExecutionSucceededEventDetails = &sfn.ExecutionSucceededEventDetails{Output: exec.output}
+	}
+
+	exec.history = numberEvents(append(exec.history, final))
+}
+
+// RedriveExecution restarts a FAILED execution at the state that failed
+func (s *SFN) RedriveExecution(in *execution.RedriveExecutionInput) (*execution.RedriveExecutionOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, err := s.execution(in.ExecutionArn)
+	if err != nil {
+		return nil, err
+	}
+
+	if exec.status != sfn.ExecutionStatusFailed || exec.result == nil {
+		return nil, awserr.New("ExecutionNotRedrivable", fmt.Sprintf("Execution is %v, only FAILED executions can be redriven", exec.status), nil)
+	}
+
+	now := time.Now()
+	exec.status = sfn.ExecutionStatusRunning
+	exec.stopDate = nil
+	exec.history = numberEvents(append(exec.history, newEvent("ExecutionRedriven", now)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exec.cancel = cancel
+
+	failed := exec.result
+	go s.run(exec, func() (*machine.Execution, error) {
+		return exec.stateMachine.RedriveWithContext(ctx, failed)
+	})
+
+	return &execution.RedriveExecutionOutput{RedriveDate: &now}, nil
+}
+
+// failedDetails uses the Error and Cause of a Fail state, or of the State error that stopped the execution.
+// Other errors, like an unknown state, are States.Runtime
+func failedDetails(result *machine.Execution, err error) *sfn.ExecutionFailedEventDetails {
+	if result != nil {
+		errorName, eok := result.Output["Error"].(string)
+		cause, cok := result.Output["Cause"].(string)
+		if eok && cok {
+			return &sfn.ExecutionFailedEventDetails{Error: to.Strp(errorName), Cause: to.Strp(cause)}
+		}
+	}
+
+	var named errors.NamedError
+	if stderrors.As(err, &named) {
+		return &sfn.ExecutionFailedEventDetails{Error: to.Strp(errors.Name(err)), Cause: to.Strp(errors.Cause(err))}
+	}
+
+	return &sfn.ExecutionFailedEventDetails{Error: to.Strp("States.Runtime"), Cause: to.Strp(err.Error())}
+}
+
+func (s *SFN) DescribeExecution(in *sfn.DescribeExecutionInput) (*sfn.DescribeExecutionOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, err := s.execution(in.ExecutionArn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sfn.DescribeExecutionOutput{
+		ExecutionArn:    exec.arn,
+		StateMachineArn: exec.stateMachineArn,
+		Name:            exec.name,
+		Status:          to.Strp(exec.status),
+		Input:           exec.input,
+		Output:          exec.output,
+		StartDate:       to.Timep(exec.startDate),
+		StopDate:        exec.stopDate,
+	}, nil
+}
+
+func (s *SFN) GetExecutionHistory(in *sfn.GetExecutionHistoryInput) (*sfn.GetExecutionHistoryOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, err := s.execution(in.ExecutionArn)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*sfn.HistoryEvent{}
+	for _, he := range exec.history {
+		events = append(events, he)
+	}
+
+	if in.ReverseOrder != nil && *in.ReverseOrder {
+		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+			events[i], events[j] = events[j], events[i]
+		}
+	}
+
+	start, end, next, err := page(len(events), in.MaxResults, in.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sfn.GetExecutionHistoryOutput{Events: events[start:end], NextToken: next}, nil
+}
+
+// ListExecutions lists the executions of a State Machine, most recent first
+func (s *SFN) ListExecutions(in *sfn.ListExecutionsInput) (*sfn.ListExecutionsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sm, err := s.stateMachine(in.StateMachineArn)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*sfn.ExecutionListItem{}
+	for i := len(sm.executions) - 1; i >= 0; i-- {
+		exec := sm.executions[i]
+		if in.StatusFilter != nil && *in.StatusFilter != exec.status {
+			continue
+		}
+
+		items = append(items, &sfn.ExecutionListItem{
+			ExecutionArn:    exec.arn,
+			StateMachineArn: exec.stateMachineArn,
+			Name:            exec.name,
+			Status:          to.Strp(exec.status),
+			StartDate:       to.Timep(exec.startDate),
+			StopDate:        exec.stopDate,
+		})
+	}
+
+	start, end, next, err := page(len(items), in.MaxResults, in.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sfn.ListExecutionsOutput{Executions: items[start:end], NextToken: next}, nil
+}
+
+func (s *SFN) StopExecution(in *sfn.StopExecutionInput) (*sfn.StopExecutionOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, err := s.execution(in.ExecutionArn)
+	if err != nil {
+		return nil, err
+	}
+
+	if exec.status != sfn.ExecutionStatusRunning {
+		return &sfn.StopExecutionOutput{StopDate: exec.stopDate}, nil
+	}
+
+	now := time.Now()
+	exec.status = sfn.ExecutionStatusAborted
+	exec.stopDate = &now
+	exec.cancel()
+
+	aborted := newEvent(sfn.HistoryEventTypeExecutionAborted, now)
+	aborted.ExecutionAbortedEventDetails = &sfn.ExecutionAbortedEventDetails{
+		Error: in.Error,
+		Cause: in.Cause,
+	}
+	exec.history = numberEvents(append(exec.history, aborted))
+
+	return &sfn.StopExecutionOutput{StopDate: exec.stopDate}, nil
+}
+
+func (s *SFN) execution(arn *string) (*executionRecord, error) {
+	if arn != nil {
+		if exec, ok := s.executions[*arn]; ok {
+			return exec, nil
+		}
+	}
+	return nil, awserr.New(sfn.ErrCodeExecutionDoesNotExist, fmt.Sprintf("Execution Does Not Exist: '%v'", to.Strs(arn)), nil)
+}
+
+//////
+// Tasks
+//////
+
+// taskHandler returns the handler for a Task Resource, the handler is looked up when the Task runs
+// so a redriven execution uses the current Handlers.
+// waitForTaskToken Tasks call the Resource handler then wait for SendTaskSuccess or SendTaskFailure
+func (s *SFN) taskHandler(exec *executionRecord, resource *string) interface{} {
+	name := ""
+	if resource != nil {
+		name = *resource
+	}
+
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		s.mu.Lock()
+		resource_fn, ok := s.Handlers[name]
+		if !ok {
+			resource_fn, ok = s.Handlers[""]
+		}
+		s.mu.Unlock()
+
+		if !strings.HasSuffix(name, ".waitForTaskToken") {
+			if !ok {
+				return nil, &taskError{"States.Runtime", fmt.Sprintf("No handler for Resource %q", name)}
+			}
+			return handler.CallHandlerFunction(resource_fn, ctx, input)
+		}
+
+		token := machine.TaskToken(ctx)
+		if token == nil {
+			return nil, &taskError{"States.Runtime", "Task Token not found"}
+		}
+
+		result := s.addToken(exec, *token)
+
+		if ok {
+			if _, err := handler.CallHandlerFunction(resource_fn, ctx, input); err != nil {
+				s.mu.Lock()
+				delete(s.tokens, *token)
+				s.mu.Unlock()
+				return nil, err
+			}
+		}
+
+		select {
+		case r := <-result:
+			return r.output, r.err
+		case <-ctx.Done():
+			return nil, &taskError{"States.Runtime", "Execution Aborted"}
+		}
+	}
+}
+
+func (s *SFN) addToken(exec *executionRecord, token string) chan taskResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(chan taskResult, 1)
+	s.tokens[token] = &taskToken{exec: exec, result: result}
+	return result
+}
+
+func (s *SFN) removeTokens(exec *executionRecord) {
+	for token, tt := range s.tokens {
+		if tt.exec == exec {
+			delete(s.tokens, token)
+		}
+	}
+}
+
+// sendTaskResult removes the token and sends the result to the waiting Task
+func (s *SFN) sendTaskResult(token *string, result taskResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token == nil {
+		return awserr.New(sfn.ErrCodeInvalidToken, "Task Token is required", nil)
+	}
+
+	tt, ok := s.tokens[*token]
+	if !ok {
+		return awserr.New(sfn.ErrCodeTaskDoesNotExist, "Task Does Not Exist", nil)
+	}
+
+	delete(s.tokens, *token)
+	tt.result <- result
+	return nil
+}
+
+func (s *SFN) SendTaskSuccess(in *sfn.SendTaskSuccessInput) (*sfn.SendTaskSuccessOutput, error) {
+	var output interface{}
+	if in.Output == nil || json.Unmarshal([]byte(*in.Output), &output) != nil {
+		return nil, awserr.New(sfn.ErrCodeInvalidOutput, "Output must be JSON", nil)
+	}
+
+	if err := s.sendTaskResult(in.TaskToken, taskResult{output: output}); err != nil {
+		return nil, err
+	}
+
+	return &sfn.SendTaskSuccessOutput{}, nil
+}
+
+func (s *SFN) SendTaskFailure(in *sfn.SendTaskFailureInput) (*sfn.SendTaskFailureOutput, error) {
+	err := &taskError{to.Strs(in.Error), to.Strs(in.Cause)}
+
+	if err := s.sendTaskResult(in.TaskToken, taskResult{err: err}); err != nil {
+		return nil, err
+	}
+
+	return &sfn.SendTaskFailureOutput{}, nil
+}
+
+func (s *SFN) SendTaskHeartbeat(in *sfn.SendTaskHeartbeatInput) (*sfn.SendTaskHeartbeatOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if in.TaskToken == nil {
+		return nil, awserr.New(sfn.ErrCodeInvalidToken, "Task Token is required", nil)
+	}
+
+	if _, ok := s.tokens[*in.TaskToken]; !ok {
+		return nil, awserr.New(sfn.ErrCodeTaskDoesNotExist, "Task Does Not Exist", nil)
+	}
+
+	return &sfn.SendTaskHeartbeatOutput{}, nil
+}
+
+//////
+// Helpers
+//////
+
+func newEvent(event_type string, timestamp time.Time) *sfn.HistoryEvent {
+	return &sfn.HistoryEvent{Type: to.Strp(event_type), Timestamp: to.Timep(timestamp)}
+}
+
+// numberEvents sets the Id and PreviousEventId of events
+func numberEvents(events []*sfn.HistoryEvent) []*sfn.HistoryEvent {
+	for i, he := range events {
+		he.Id = to.Int64p(int64(i + 1))
+		he.PreviousEventId = to.Int64p(int64(i))
+	}
+	return events
+}
+
+// page returns the slice bounds of a page of results and the token for the next page,
+// tokens are the offset of the next result
+func page(length int, max_results *int64, next_token *string) (int, int, *string, error) {
+	start := 0
+	if !is.EmptyStr(next_token) {
+		offset, err := strconv.Atoi(*next_token)
+		if err != nil || offset < 0 || offset > length {
+			return 0, 0, nil, awserr.New(sfn.ErrCodeInvalidToken, "Invalid NextToken", nil)
+		}
+		start = offset
+	}
+
+	size := 100
+	if max_results != nil && *max_results > 0 {
+		size = int(*max_results)
+	}
+
+	end := start + size
+	if end >= length {
+		return start, length, nil, nil
+	}
+
+	return start, end, to.Strp(strconv.Itoa(end)), nil
+}
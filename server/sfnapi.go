@@ -0,0 +1,144 @@
+package server
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	stepaws "github.com/coinbase/step/aws"
+)
+
+// SFN satisfies aws.SFNAPI so it can replace an sfn client in tests
+var _ stepaws.SFNAPI = (*SFN)(nil)
+
+//////
+// Pagination
+//////
+
+func (s *SFN) GetExecutionHistoryPages(in *sfn.GetExecutionHistoryInput, fn func(*sfn.GetExecutionHistoryOutput, bool) bool) error {
+	return s.GetExecutionHistoryPagesWithContext(aws.BackgroundContext(), in, fn)
+}
+
+func (s *SFN) GetExecutionHistoryPagesWithContext(ctx aws.Context, in *sfn.GetExecutionHistoryInput, fn func(*sfn.GetExecutionHistoryOutput, bool) bool, _ ...request.Option) error {
+	page_in := *in
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		out, err := s.GetExecutionHistory(&page_in)
+		if err != nil {
+			return err
+		}
+
+		if !fn(out, out.NextToken == nil) || out.NextToken == nil {
+			return nil
+		}
+
+		page_in.NextToken = out.NextToken
+	}
+}
+
+func (s *SFN) ListExecutionsPages(in *sfn.ListExecutionsInput, fn func(*sfn.ListExecutionsOutput, bool) bool) error {
+	return s.ListExecutionsPagesWithContext(aws.BackgroundContext(), in, fn)
+}
+
+func (s *SFN) ListExecutionsPagesWithContext(ctx aws.Context, in *sfn.ListExecutionsInput, fn func(*sfn.ListExecutionsOutput, bool) bool, _ ...request.Option) error {
+	page_in := *in
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		out, err := s.ListExecutions(&page_in)
+		if err != nil {
+			return err
+		}
+
+		if !fn(out, out.NextToken == nil) || out.NextToken == nil {
+			return nil
+		}
+
+		page_in.NextToken = out.NextToken
+	}
+}
+
+//////
+// WithContext
+//////
+
+func (s *SFN) CreateStateMachineWithContext(ctx aws.Context, in *sfn.CreateStateMachineInput, _ ...request.Option) (*sfn.CreateStateMachineOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.CreateStateMachine(in)
+}
+
+func (s *SFN) UpdateStateMachineWithContext(ctx aws.Context, in *sfn.UpdateStateMachineInput, _ ...request.Option) (*sfn.UpdateStateMachineOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.UpdateStateMachine(in)
+}
+
+func (s *SFN) DescribeStateMachineWithContext(ctx aws.Context, in *sfn.DescribeStateMachineInput, _ ...request.Option) (*sfn.DescribeStateMachineOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.DescribeStateMachine(in)
+}
+
+func (s *SFN) StartExecutionWithContext(ctx aws.Context, in *sfn.StartExecutionInput, _ ...request.Option) (*sfn.StartExecutionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.StartExecution(in)
+}
+
+func (s *SFN) DescribeExecutionWithContext(ctx aws.Context, in *sfn.DescribeExecutionInput, _ ...request.Option) (*sfn.DescribeExecutionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.DescribeExecution(in)
+}
+
+func (s *SFN) GetExecutionHistoryWithContext(ctx aws.Context, in *sfn.GetExecutionHistoryInput, _ ...request.Option) (*sfn.GetExecutionHistoryOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.GetExecutionHistory(in)
+}
+
+func (s *SFN) ListExecutionsWithContext(ctx aws.Context, in *sfn.ListExecutionsInput, _ ...request.Option) (*sfn.ListExecutionsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.ListExecutions(in)
+}
+
+func (s *SFN) StopExecutionWithContext(ctx aws.Context, in *sfn.StopExecutionInput, _ ...request.Option) (*sfn.StopExecutionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.StopExecution(in)
+}
+
+func (s *SFN) SendTaskSuccessWithContext(ctx aws.Context, in *sfn.SendTaskSuccessInput, _ ...request.Option) (*sfn.SendTaskSuccessOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.SendTaskSuccess(in)
+}
+
+func (s *SFN) SendTaskFailureWithContext(ctx aws.Context, in *sfn.SendTaskFailureInput, _ ...request.Option) (*sfn.SendTaskFailureOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.SendTaskFailure(in)
+}
+
+func (s *SFN) SendTaskHeartbeatWithContext(ctx aws.Context, in *sfn.SendTaskHeartbeatInput, _ ...request.Option) (*sfn.SendTaskHeartbeatOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.SendTaskHeartbeat(in)
+}
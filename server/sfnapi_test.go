@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/execution"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SFN_Pages(t *testing.T) {
+	s := NewSFN(to.Strp("us-east-1"), to.Strp("000000000000"))
+
+	out, err := s.CreateStateMachine(&sfn.CreateStateMachineInput{
+		Name:       to.Strp("passes"),
+		Definition: to.Strp(`{"StartAt": "A", "States": {"A": {"Type": "Pass", "Next": "B"}, "B": {"Type": "Pass", "End": true}}}`),
+		RoleArn:    to.Strp("arn:aws:iam::000000000000:role/step"),
+	})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	var last *execution.Execution
+	for i := 0; i < 150; i++ {
+		last, err = execution.StartExecution(s, out.StateMachineArn, nil, map[string]interface{}{})
+		assert.NoError(t, err)
+	}
+
+	// ExecutionsAfter reads every page of ListExecutionsPages
+	executions, err := execution.ExecutionsAfter(s, out.StateMachineArn, nil, start)
+	assert.NoError(t, err)
+	assert.Equal(t, 150, len(executions))
+	assert.Equal(t, *last.ExecutionArn, *executions[0].ExecutionArn)
+
+	last.WaitForExecution(s, 0, func(_ *execution.Execution, _ *execution.StateDetails, err error) error {
+		return err
+	})
+	assert.Equal(t, "SUCCEEDED", *last.Status)
+
+	pages := 0
+	ids := []int64{}
+	err = s.GetExecutionHistoryPages(&sfn.GetExecutionHistoryInput{ExecutionArn: last.ExecutionArn, MaxResults: to.Int64p(4)}, func(page *sfn.GetExecutionHistoryOutput, lastPage bool) bool {
+		pages++
+		for _, he := range page.Events {
+			ids = append(ids, *he.Id)
+		}
+		assert.Equal(t, pages == 2, lastPage)
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, pages)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6}, ids)
+
+	// A cancelled context stops before the first call
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.DescribeExecutionWithContext(ctx, &sfn.DescribeExecutionInput{ExecutionArn: last.ExecutionArn})
+	assert.Error(t, err)
+}
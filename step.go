@@ -1,16 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/coinbase/step/machine"
 
+	"github.com/coinbase/step/aws"
 	"github.com/coinbase/step/bifrost"
 	"github.com/coinbase/step/client"
 	"github.com/coinbase/step/deployer"
+	"github.com/coinbase/step/execution"
+	"github.com/coinbase/step/lambdalocal"
+	"github.com/coinbase/step/server"
 	"github.com/coinbase/step/utils/run"
 	"github.com/coinbase/step/utils/to"
 )
@@ -32,6 +41,13 @@ func main() {
 	// Other Subcommands
 	bootstrapCommand := flag.NewFlagSet("bootstrap", flag.ExitOnError)
 	deployCommand := flag.NewFlagSet("deploy", flag.ExitOnError)
+	serveCommand := flag.NewFlagSet("serve", flag.ExitOnError)
+	redriveCommand := flag.NewFlagSet("redrive", flag.ExitOnError)
+	lambdaLocalCommand := flag.NewFlagSet("lambda-local", flag.ExitOnError)
+	rollbackCommand := flag.NewFlagSet("rollback", flag.ExitOnError)
+	releasesCommand := flag.NewFlagSet("releases", flag.ExitOnError)
+	haltCommand := flag.NewFlagSet("halt", flag.ExitOnError)
+	unhaltCommand := flag.NewFlagSet("unhalt", flag.ExitOnError)
 
 	// bootstrap args
 	bootstrapStates := bootstrapCommand.String("states", "{}", "State Machine JSON")
@@ -43,6 +59,8 @@ func main() {
 	bootstrapConfig := bootstrapCommand.String("config", "", "config name")
 	bootstrapRegion := bootstrapCommand.String("region", "", "AWS region")
 	bootstrapAccount := bootstrapCommand.String("account", "", "AWS account id")
+	bootstrapLambdas := bootstrapCommand.String("lambdas", "", "more lambdas as comma separated <name>=<zip file>")
+	bootstrapStateMachines := bootstrapCommand.String("state-machines", "", "more step functions as comma separated <name>=<json file>")
 
 	// deploy args
 	deployStates := deployCommand.String("states", "{}", "State Machine JSON")
@@ -55,11 +73,74 @@ func main() {
 	deployConfig := deployCommand.String("config", "", "config name")
 	deployRegion := deployCommand.String("region", "", "AWS region")
 	deployAccount := deployCommand.String("account", "", "AWS account id")
+	deployLambdas := deployCommand.String("lambdas", "", "more lambdas as comma separated <name>=<zip file>")
+	deployStateMachines := deployCommand.String("state-machines", "", "more step functions as comma separated <name>=<json file>")
+	deployAlias := deployCommand.String("alias", "", "lambda alias to shift traffic on, without it the code is updated in place")
+	deployShift := deployCommand.String("shift", "Canary", "traffic shifting type: Canary, Linear or AllAtOnce")
+	deployShiftPercent := deployCommand.Int("shift-percent", 0, "percent of traffic per step (default depends on -shift)")
+	deployShiftInterval := deployCommand.Int("shift-interval", -1, "seconds between steps (default depends on -shift)")
+	deployAlarms := deployCommand.String("alarms", "", "comma separated CloudWatch alarms that roll back the deploy")
+	deployDryRun := deployCommand.Bool("dry-run", false, "print what would change and validate the release, without deploying")
+	deployCreate := deployCommand.Bool("create", false, "create the step function and lambda if they do not exist")
+	deployStepRole := deployCommand.String("step-role", "", "with -create, arn of the step function role under /step/<project>/<config>/")
+	deployLambdaRole := deployCommand.String("lambda-role", "", "with -create, arn of the lambda role under /step/<project>/<config>/")
+	deployRuntime := deployCommand.String("runtime", "go1.x", "with -create, runtime of the lambda")
+	deployHandler := deployCommand.String("handler", "lambda", "with -create, handler of the lambda")
+	deployLambdaConfig := deployCommand.String("lambda-config", "", "json file of the lambda configuration, e.g. memory_size, timeout, environment")
+	deployLambdaConfigs := deployCommand.String("lambda-configs", "", "configuration of the -lambdas as comma separated <name>=<json file>")
+
+	// rollback args
+	rollbackRelease := rollbackCommand.String("release", "", "release id to roll back to (default last successful release before the deployed one)")
+	rollbackBucket := rollbackCommand.String("bucket", "", "s3 bucket of the releases")
+	rollbackDeployer := rollbackCommand.String("deployer", *def_step_arn, "step function deployer name or arn")
+	rollbackProject := rollbackCommand.String("project", "", "project name")
+	rollbackConfig := rollbackCommand.String("config", "", "config name")
+	rollbackRegion := rollbackCommand.String("region", "", "AWS region")
+	rollbackAccount := rollbackCommand.String("account", "", "AWS account id")
+
+	// releases args
+	releasesRelease := releasesCommand.String("release", "", "release id to show (default the latest release)")
+	releasesBucket := releasesCommand.String("bucket", "", "s3 bucket of the releases")
+	releasesDeployer := releasesCommand.String("deployer", *def_step_arn, "step function deployer name or arn")
+	releasesProject := releasesCommand.String("project", "", "project name")
+	releasesConfig := releasesCommand.String("config", "", "config name")
+	releasesRegion := releasesCommand.String("region", "", "AWS region")
+	releasesAccount := releasesCommand.String("account", "", "AWS account id")
+	releasesAction := ""
+
+	// halt args
+	haltMessage := haltCommand.String("message", "", "reason the deploy is halted")
+	haltBucket := haltCommand.String("bucket", "", "s3 bucket of the releases")
+	haltProject := haltCommand.String("project", "", "project name")
+	haltConfig := haltCommand.String("config", "", "config name")
+	haltRegion := haltCommand.String("region", "", "AWS region")
+	haltAccount := haltCommand.String("account", "", "AWS account id")
+
+	// unhalt args
+	unhaltBucket := unhaltCommand.String("bucket", "", "s3 bucket of the releases")
+	unhaltProject := unhaltCommand.String("project", "", "project name")
+	unhaltConfig := unhaltCommand.String("config", "", "config name")
+	unhaltRegion := unhaltCommand.String("region", "", "AWS region")
+	unhaltAccount := unhaltCommand.String("account", "", "AWS account id")
+
+	// serve args
+	serveAddr := serveCommand.String("addr", "localhost:8083", "address to listen on")
+	serveConfig := serveCommand.String("config", "", "JSON file of Task Resource handlers")
+	serveRegion := serveCommand.String("region", "us-east-1", "AWS region of the ARNs")
+	serveAccount := serveCommand.String("account", "000000000000", "AWS account id of the ARNs")
+
+	// redrive args
+	redriveArn := redriveCommand.String("arn", "", "arn of the failed execution")
+
+	// lambda-local args
+	lambdaLocalBinary := lambdaLocalCommand.String("binary", os.Args[0], "Lambda binary to run")
+	lambdaLocalAddr := lambdaLocalCommand.String("addr", "localhost:9001", "address of the Runtime and Invoke APIs")
+	lambdaLocalTimeout := lambdaLocalCommand.Duration("timeout", 15*time.Minute, "timeout of each invocation")
 
 	// By Default Run Lambda Function
 	if len(os.Args) == 1 {
 		fmt.Println("Starting Lambda")
-		run.LambdaTasks(deployer.TaskHandlers())
+		run.LambdaTasks(deployer.TaskHandlers(), run.DataDog)
 	}
 
 	switch os.Args[1] {
@@ -71,8 +152,29 @@ func main() {
 		bootstrapCommand.Parse(os.Args[2:])
 	case "deploy":
 		deployCommand.Parse(os.Args[2:])
+	case "serve":
+		serveCommand.Parse(os.Args[2:])
+	case "redrive":
+		redriveCommand.Parse(os.Args[2:])
+	case "lambda-local":
+		lambdaLocalCommand.Parse(os.Args[2:])
+	case "rollback":
+		rollbackCommand.Parse(os.Args[2:])
+	case "releases":
+		if len(os.Args) > 2 && (os.Args[2] == "list" || os.Args[2] == "show") {
+			releasesAction = os.Args[2]
+			releasesCommand.Parse(os.Args[3:])
+			break
+		}
+		fmt.Println("Usage of step releases: step releases <list|show> <args>")
+		releasesCommand.PrintDefaults()
+		os.Exit(1)
+	case "halt":
+		haltCommand.Parse(os.Args[2:])
+	case "unhalt":
+		unhaltCommand.Parse(os.Args[2:])
 	default:
-		fmt.Println("Usage of step: step <json|bootstrap|deploy|dot> <args> (No args starts Lambda)")
+		fmt.Println("Usage of step: step <json|bootstrap|deploy|rollback|releases|halt|unhalt|dot|serve|redrive|lambda-local> <args> (No args starts Lambda)")
 		fmt.Println("json")
 		jsonCommand.PrintDefaults()
 		fmt.Println("dot")
@@ -81,6 +183,20 @@ func main() {
 		bootstrapCommand.PrintDefaults()
 		fmt.Println("deploy")
 		deployCommand.PrintDefaults()
+		fmt.Println("rollback")
+		rollbackCommand.PrintDefaults()
+		fmt.Println("releases <list|show>")
+		releasesCommand.PrintDefaults()
+		fmt.Println("halt")
+		haltCommand.PrintDefaults()
+		fmt.Println("unhalt")
+		unhaltCommand.PrintDefaults()
+		fmt.Println("serve")
+		serveCommand.PrintDefaults()
+		fmt.Println("redrive")
+		redriveCommand.PrintDefaults()
+		fmt.Println("lambda-local")
+		lambdaLocalCommand.PrintDefaults()
 		os.Exit(1)
 	}
 
@@ -100,6 +216,7 @@ func main() {
 			bootstrapRegion,
 			bootstrapAccount,
 		)
+		addArtifacts(r, bootstrapLambdas, bootstrapStateMachines)
 		bootstrapRun(r, bootstrapZip)
 
 	} else if deployCommand.Parsed() {
@@ -114,8 +231,81 @@ func main() {
 			deployRegion,
 			deployAccount,
 		)
+		addArtifacts(r, deployLambdas, deployStateMachines)
+		addLambdaConfigs(r, deployLambdaConfig, deployLambdaConfigs)
+		r.TrafficShifting = newTrafficShifting(deployAlias, deployShift, deployShiftPercent, deployShiftInterval, deployAlarms)
+		if *deployCreate {
+			r.Create = &deployer.CreateConfig{
+				StepRoleArn:   deployStepRole,
+				LambdaRoleArn: deployLambdaRole,
+				Runtime:       deployRuntime,
+				Handler:       deployHandler,
+			}
+		}
+		if *deployDryRun {
+			planRun(r, deployZip)
+			return
+		}
 		arn := to.StepArn(region, account_id, deployDeployer)
 		deployRun(r, deployZip, arn)
+	} else if rollbackCommand.Parsed() {
+		region, account_id := to.RegionAccountOrExit()
+		r := newRelease(
+			rollbackProject,
+			rollbackConfig,
+			nil,
+			nil,
+			rollbackBucket,
+			nil,
+			rollbackRegion,
+			rollbackAccount,
+		)
+		arn := to.StepArn(region, account_id, rollbackDeployer)
+		rollbackRun(r, rollbackRelease, arn)
+	} else if releasesCommand.Parsed() {
+		region, account_id := to.RegionAccountOrExit()
+		r := newRelease(
+			releasesProject,
+			releasesConfig,
+			nil,
+			nil,
+			releasesBucket,
+			nil,
+			releasesRegion,
+			releasesAccount,
+		)
+		arn := to.StepArn(region, account_id, releasesDeployer)
+		releasesRun(releasesAction, r, releasesRelease, arn)
+	} else if haltCommand.Parsed() {
+		r := newRelease(
+			haltProject,
+			haltConfig,
+			nil,
+			nil,
+			haltBucket,
+			nil,
+			haltRegion,
+			haltAccount,
+		)
+		haltRun(r, haltMessage)
+	} else if unhaltCommand.Parsed() {
+		r := newRelease(
+			unhaltProject,
+			unhaltConfig,
+			nil,
+			nil,
+			unhaltBucket,
+			nil,
+			unhaltRegion,
+			unhaltAccount,
+		)
+		unhaltRun(r)
+	} else if serveCommand.Parsed() {
+		serveRun(serveAddr, serveConfig, serveRegion, serveAccount)
+	} else if redriveCommand.Parsed() {
+		redriveRun(redriveArn)
+	} else if lambdaLocalCommand.Parsed() {
+		lambdaLocalRun(lambdaLocalBinary, lambdaLocalAddr, lambdaLocalTimeout)
 	} else {
 		fmt.Println("ERROR: Command Line Not Parsed")
 		os.Exit(1)
@@ -140,6 +330,143 @@ func deployRun(release *deployer.Release, zip *string, deployer_arn *string) {
 	check(err)
 }
 
+func planRun(release *deployer.Release, zip *string) {
+	err := client.Plan(release, zip)
+	check(err)
+}
+
+func rollbackRun(release *deployer.Release, release_id *string, deployer_arn *string) {
+	err := client.Rollback(release, release_id, deployer_arn)
+	check(err)
+}
+
+func releasesRun(action string, release *deployer.Release, release_id *string, deployer_arn *string) {
+	if action == "show" {
+		check(client.ShowRelease(release, release_id, deployer_arn))
+		return
+	}
+	check(client.ListReleases(release, deployer_arn))
+}
+
+func haltRun(release *deployer.Release, message *string) {
+	check(client.Halt(release, message))
+}
+
+func unhaltRun(release *deployer.Release) {
+	check(client.Unhalt(release))
+}
+
+func serveRun(addr *string, config_file *string, region *string, account_id *string) {
+	handlers := map[string]interface{}{}
+	if *config_file != "" {
+		config, err := server.LoadConfig(*config_file)
+		check(err)
+		handlers = config.Handlers()
+	}
+
+	fmt.Printf("Serving Step Functions API on http://%v\n", *addr)
+	check(http.ListenAndServe(*addr, server.NewServer(region, account_id, handlers)))
+}
+
+func redriveRun(arn *string) {
+	exec, err := execution.Redrive((&aws.Clients{}).SFNClient(nil, nil, nil), arn)
+	check(err)
+	fmt.Printf("Redrove %v: %v\n", *exec.ExecutionArn, *exec.Status)
+}
+
+// newTrafficShifting returns nil without an alias
+func newTrafficShifting(alias *string, shift *string, percent *int, interval *int, alarms *string) *deployer.TrafficShifting {
+	if *alias == "" {
+		return nil
+	}
+
+	ts := &deployer.TrafficShifting{Alias: alias, Type: shift}
+	if *percent > 0 {
+		ts.Percent = percent
+	}
+	if *interval >= 0 {
+		ts.IntervalSeconds = interval
+	}
+	for _, alarm := range strings.Split(*alarms, ",") {
+		if alarm = strings.TrimSpace(alarm); alarm != "" {
+			ts.Alarms = append(ts.Alarms, to.Strp(alarm))
+		}
+	}
+
+	return ts
+}
+
+// addArtifacts adds the lambdas and state machines from comma separated <name>=<file> lists
+func addArtifacts(release *deployer.Release, lambdas *string, state_machines *string) {
+	for _, named := range namedFiles(lambdas) {
+		release.Lambdas = append(release.Lambdas, &deployer.LambdaArtifact{Name: to.Strp(named[0]), ZipFile: to.Strp(named[1])})
+	}
+
+	for _, named := range namedFiles(state_machines) {
+		states, err := ioutil.ReadFile(named[1])
+		check(err)
+		release.StateMachines = append(release.StateMachines, &deployer.StateMachineArtifact{Name: to.Strp(named[0]), StateMachineJSON: to.Strp(string(states))})
+	}
+}
+
+// addLambdaConfigs reads the configuration of the lambda and of the named lambdas
+func addLambdaConfigs(release *deployer.Release, lambda_config *string, lambda_configs *string) {
+	if *lambda_config != "" {
+		release.LambdaConfig = readLambdaConfig(*lambda_config)
+	}
+
+	for _, named := range namedFiles(lambda_configs) {
+		found := false
+		for _, l := range release.Lambdas {
+			if *l.Name == named[0] {
+				l.Config = readLambdaConfig(named[1])
+				found = true
+			}
+		}
+
+		if !found {
+			check(fmt.Errorf("lambda %q is not in -lambdas", named[0]))
+		}
+	}
+}
+
+func readLambdaConfig(file string) *deployer.LambdaConfig {
+	raw, err := ioutil.ReadFile(file)
+	check(err)
+
+	var config deployer.LambdaConfig
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	check(decoder.Decode(&config))
+	return &config
+}
+
+// namedFiles returns the [name, file] pairs in order
+func namedFiles(list *string) [][2]string {
+	files := [][2]string{}
+	for _, pair := range strings.Split(*list, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			check(fmt.Errorf("%q must be <name>=<file>", pair))
+		}
+		files = append(files, [2]string{parts[0], parts[1]})
+	}
+	return files
+}
+
+func lambdaLocalRun(binary *string, addr *string, timeout *time.Duration) {
+	fn := lambdalocal.NewFunction(*binary, *addr)
+	fn.Timeout = *timeout
+	defer fn.Close()
+
+	fmt.Printf("Serving Lambda Invoke API on http://%v/2015-03-31/functions/function/invocations\n", *addr)
+	check(http.ListenAndServe(*addr, fn))
+}
+
 func newRelease(project *string, config *string, lambda *string, step *string, bucket *string, states *string, region *string, account_id *string) *deployer.Release {
 	return &deployer.Release{
 		Release: bifrost.Release{
@@ -2,6 +2,8 @@
 package run
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -60,17 +62,44 @@ func JSON(state_machine *machine.StateMachine, err error) {
 	os.Exit(0)
 }
 
-// LambdaTasks takes task functions and and executes as a lambda
-func LambdaTasks(task_functions *handler.TaskHandlers) {
-	handler, err := handler.CreateHandler(task_functions)
+// LambdaTasks takes task functions and and executes as a lambda,
+// middleware e.g. DataDog or handler.Logging wraps every Task, without middleware DataDog is used.
+// Errors are returned with their errors.Name as the Lambda errorType
+func LambdaTasks(task_functions *handler.TaskHandlers, middleware ...handler.Middleware) {
+	if len(middleware) == 0 {
+		middleware = []handler.Middleware{DataDog}
+	}
+
+	task_handler, err := handler.CreateHandler(task_functions, middleware...)
 
 	if err != nil {
 		fmt.Println("ERROR", err)
 		os.Exit(1)
 	}
 
-	lambda.Start(ddlambda.WrapHandler(handler, nil))
+	lambda.Start(handler.LambdaErrors(task_handler))
 
 	fmt.Println("ERROR: lambda.Start returned, but should have blocked")
 	os.Exit(1)
 }
+
+// DataDog is handler Middleware that sends the Lambda metrics and traces to DataDog
+func DataDog(next handler.Handler) handler.Handler {
+	dd := ddlambda.WrapHandler(next, nil)
+
+	// Every call fails rather than silently skipping DataDog
+	wrapped, ok := dd.(func(context.Context, json.RawMessage) (interface{}, error))
+	if !ok {
+		return func(_ context.Context, _ *handler.RawMessage) (interface{}, error) {
+			return nil, fmt.Errorf("DataDog wrapped handler has unexpected type %T", dd)
+		}
+	}
+
+	return func(ctx context.Context, input *handler.RawMessage) (interface{}, error) {
+		raw, err := json.Marshal(input)
+		if err != nil {
+			return nil, err
+		}
+		return wrapped(ctx, raw)
+	}
+}